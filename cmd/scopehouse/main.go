@@ -1,138 +1,73 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"log/slog"
 	"os"
-	"time"
 
-	"github.com/spf13/viper"
-
-	"github.com/dlbarduzzi/scopehouse/internal/apis"
-	"github.com/dlbarduzzi/scopehouse/internal/core"
-	"github.com/dlbarduzzi/scopehouse/internal/tools/database"
-	"github.com/dlbarduzzi/scopehouse/internal/tools/logging"
+	"github.com/dlbarduzzi/scopehouse"
 )
 
 func main() {
-	if err := start(); err != nil {
+	if err := start(os.Args[1:]); err != nil {
 		fmt.Fprintf(os.Stderr, "[error] %s\n", err)
 		os.Exit(1)
 	}
 }
 
-func start() error {
-	config := getConfig()
-
-	logger := logging.NewLoggerWithConfig(config.logger)
-	logger = logger.With(slog.String("app", "scopehouse"))
+func start(args []string) error {
+	if len(args) > 0 && args[0] == "keys" {
+		return keys(args[1:])
+	}
 
-	db, err := database.New(config.db)
-	if err != nil {
-		return err
+	if len(args) > 0 && args[0] == "operators" {
+		return operators(args[1:])
 	}
 
-	defer func() {
-		if err := db.Close(); err != nil {
-			logger.Error("db close failed", slog.Any("error", err))
+	app, err := scopehouse.New()
+	if err != nil {
+		if errors.Is(err, scopehouse.ErrHelp) {
+			return nil
 		}
-	}()
-
-	app := core.NewBaseApp(db, logger)
-
-	if err := app.Bootstrap(); err != nil {
 		return err
 	}
 
-	return apis.Serve(app, config.server)
+	return app.Start()
 }
 
-type config struct {
-	db     database.Config
-	logger logging.Config
-	server apis.ServerConfig
-}
-
-var (
-	// default database configs
-	defaultDatabaseMaxIdleConns    = 10
-	defaultDatabaseMaxOpenConns    = 10
-	defaultDatabaseConnMaxIdleTime = time.Minute * 3
-
-	// default logger configs
-	defaultLoggerLevel  = "info"
-	defaultLoggerFormat = "json"
-
-	// default server configs
-	defaultServerPort         = 8090
-	defaultServerIdleTimeout  = time.Second * 10
-	defaultServerReadTimeout  = time.Second * 5
-	defaultServerWriteTimeout = time.Second * 5
-)
-
-func getConfig() config {
-	v := viper.New()
-	v.AutomaticEnv()
-
-	c := config{
-		db: database.Config{
-			Url:             v.GetString("SH_DATABASE_URL"),
-			MaxIdleConns:    v.GetInt("SH_DATABASE_MAX_IDLE_CONNS"),
-			MaxOpenConns:    v.GetInt("SH_DATABASE_MAX_OPEN_CONNS"),
-			ConnMaxIdleTime: v.GetDuration("SH_DATABASE_CONN_MAX_IDLE_TIME"),
-		},
-		logger: logging.Config{
-			Level:     v.GetString("SH_LOG_LEVEL"),
-			Format:    v.GetString("SH_LOG_FORMAT"),
-			UseNano:   v.GetBool("SH_LOG_USE_NANO"),
-			UseSource: v.GetBool("SH_LOG_USE_SOURCE"),
-		},
-		server: apis.ServerConfig{
-			Port:         v.GetInt("SH_SERVER_PORT"),
-			IdleTimeout:  v.GetDuration("SH_SERVER_IDLE_TIMEOUT"),
-			ReadTimeout:  v.GetDuration("SH_SERVER_READ_TIMEOUT"),
-			WriteTimeout: v.GetDuration("SH_SERVER_WRITE_TIMEOUT"),
-		},
-	}
-
-	// Database configs.
-	if c.db.MaxIdleConns < 1 {
-		c.db.MaxIdleConns = defaultDatabaseMaxIdleConns
+// keys runs the commands managing the master keys of the secrets, e.g.
+// `scopehouse keys rotate`.
+func keys(args []string) error {
+	if len(args) == 0 || args[0] != "rotate" {
+		return errors.New("usage: scopehouse keys rotate [flags]")
 	}
 
-	if c.db.MaxOpenConns < 1 {
-		c.db.MaxOpenConns = defaultDatabaseMaxOpenConns
-	}
-
-	if c.db.ConnMaxIdleTime < time.Minute {
-		c.db.ConnMaxIdleTime = defaultDatabaseConnMaxIdleTime
-	}
-
-	// Logger configs.
-	if c.logger.Level == "" {
-		c.logger.Level = defaultLoggerLevel
-	}
-
-	if c.logger.Format == "" {
-		c.logger.Format = defaultLoggerFormat
-	}
-
-	// Server configs.
-	if c.server.Port < 1 {
-		c.server.Port = defaultServerPort
+	settings, err := scopehouse.LoadSettings(args[1:])
+	if err != nil {
+		if errors.Is(err, scopehouse.ErrHelp) {
+			return nil
+		}
+		return err
 	}
 
-	if c.server.IdleTimeout < time.Second*10 {
-		c.server.IdleTimeout = defaultServerIdleTimeout
-	}
+	_, err = scopehouse.RotateKeys(settings)
+	return err
+}
 
-	if c.server.ReadTimeout < time.Second {
-		c.server.ReadTimeout = defaultServerReadTimeout
+// operators runs the commands managing the operators of the service, e.g.
+// `scopehouse operators grant admin@example.com`.
+func operators(args []string) error {
+	if len(args) < 2 || (args[0] != "grant" && args[0] != "revoke") {
+		return errors.New("usage: scopehouse operators grant|revoke <email> [flags]")
 	}
 
-	if c.server.WriteTimeout < time.Second {
-		c.server.WriteTimeout = defaultServerWriteTimeout
+	settings, err := scopehouse.LoadSettings(args[2:])
+	if err != nil {
+		if errors.Is(err, scopehouse.ErrHelp) {
+			return nil
+		}
+		return err
 	}
 
-	return c
+	return scopehouse.SetOperator(settings, args[1], args[0] == "grant")
 }
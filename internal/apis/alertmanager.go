@@ -0,0 +1,322 @@
+package apis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// alertmanagerSyncTimeout bounds the sync of a configuration, reload of the
+// Alertmanager included.
+const alertmanagerSyncTimeout = time.Second * 30
+
+// loadAlertmanagerConfig returns the Alertmanager configuration of the
+// cluster, and responds with a 404 error when it has none.
+func loadAlertmanagerConfig(e *core.EventRequest, clusterId string) (*data.AlertmanagerConfig, bool) {
+	config, err := e.Models().AlertmanagerConfigs.GetByClusterId(clusterId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Alertmanager configuration not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return nil, false
+	}
+
+	return config, true
+}
+
+// redactAlertmanagerConfig returns a copy of the configuration with the
+// secrets of its receivers redacted, so that they are never returned nor
+// audited. The configurations sent back redacted keep their secrets.
+func redactAlertmanagerConfig(config *data.AlertmanagerConfig) *data.AlertmanagerConfig {
+	if config == nil || config.Config == nil {
+		return config
+	}
+
+	redacted := *config
+	redacted.Config = config.Config.Redact()
+
+	return &redacted
+}
+
+// redactAlertmanagerConfigState redacts the state of an Alertmanager
+// configuration recorded by a change request, dropping it when it cannot
+// be decoded.
+func redactAlertmanagerConfigState(state json.RawMessage) json.RawMessage {
+	if len(state) == 0 {
+		return state
+	}
+
+	var config *data.AlertmanagerConfig
+	if err := json.Unmarshal(state, &config); err != nil {
+		return nil
+	}
+
+	b, err := json.Marshal(redactAlertmanagerConfig(config))
+	if err != nil {
+		return nil
+	}
+
+	return b
+}
+
+func getAlertmanagerConfig(e *core.EventRequest) {
+	config, ok := loadAlertmanagerConfig(e, e.Request.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	resp := struct {
+		AlertmanagerConfig *data.AlertmanagerConfig `json:"alertmanager_config"`
+	}{
+		AlertmanagerConfig: redactAlertmanagerConfig(config),
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// updateAlertmanagerConfig replaces the Alertmanager configuration of the
+// cluster. It is not synced to the Alertmanager until requested.
+func updateAlertmanagerConfig(e *core.EventRequest) {
+	cluster, ok := loadCluster(e)
+	if !ok {
+		return
+	}
+
+	var input alertmanager.Config
+
+	// Unknown fields are rejected rather than dropped: a route whose legacy
+	// match or match_re fields were dropped would match every alert.
+	opts := &event.UnmarshalOptions{DisallowUnknownFields: true}
+
+	if err := e.Unmarshal(&input, opts); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	before, err := e.Models().AlertmanagerConfigs.GetByClusterId(cluster.Id)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		internalServerError(e, err)
+		return
+	}
+
+	var prev *alertmanager.Config
+	if before != nil {
+		prev = before.Config
+	}
+
+	if err := input.Unredact(prev); err != nil {
+		badRequest(e, fmt.Sprintf("Invalid Alertmanager configuration: %v.", err))
+		return
+	}
+
+	if err := input.Validate(); err != nil {
+		badRequest(e, fmt.Sprintf("Invalid Alertmanager configuration: %v.", err))
+		return
+	}
+
+	config := &data.AlertmanagerConfig{
+		ClusterId: cluster.Id,
+		TenantId:  cluster.TenantId,
+		Config:    &input,
+	}
+
+	if cluster.IsProduction() {
+		requestChange(e, &data.ChangeRequest{
+			ClusterId:    cluster.Id,
+			ResourceType: "alertmanager_config",
+			ResourceId:   cluster.Id,
+			Action:       data.ChangeActionUpdate,
+		}, before, config)
+		return
+	}
+
+	if err := e.Models().AlertmanagerConfigs.Upsert(config); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Cluster not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelUpdate, "alertmanager_config", cluster.Id, redactAlertmanagerConfig(before), redactAlertmanagerConfig(config))
+
+	resp := struct {
+		AlertmanagerConfig *data.AlertmanagerConfig `json:"alertmanager_config"`
+	}{
+		AlertmanagerConfig: redactAlertmanagerConfig(config),
+	}
+
+	if err := e.Json(resp, http.StatusOK); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// renderAlertmanagerConfig responds with the configuration file synced to
+// the Alertmanager of the cluster, its secrets redacted.
+func renderAlertmanagerConfig(e *core.EventRequest) {
+	config, ok := loadAlertmanagerConfig(e, e.Request.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	b, err := config.Config.Redact().Render()
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	// The sync agents poll the rendered configurations, they only download
+	// them again once changed.
+	if e.NotModified(event.ETag(b), config.UpdatedAt) {
+		return
+	}
+
+	e.Response.Header().Set("Content-Type", "application/yaml")
+	e.Response.WriteHeader(http.StatusOK)
+
+	_, _ = e.Response.Write(b)
+}
+
+// syncAlertmanagerConfig pushes the configuration to the Alertmanager of the
+// cluster and reloads it. The outcome is recorded along with the
+// configuration.
+func syncAlertmanagerConfig(e *core.EventRequest) {
+	syncer := e.App.AlertmanagerSyncer()
+	if syncer == nil {
+		errorResponse(e, event.NewApiError(http.StatusNotImplemented, "Alertmanager sync is not configured."))
+		return
+	}
+
+	cluster, ok := loadCluster(e)
+	if !ok {
+		return
+	}
+
+	if cluster.AlertmanagerUrl == "" {
+		conflict(e, "Cluster has no Alertmanager url.")
+		return
+	}
+
+	config, ok := loadAlertmanagerConfig(e, cluster.Id)
+	if !ok {
+		return
+	}
+
+	e.Audit.SetChange("alertmanager_config", cluster.Id, nil, nil)
+
+	ctx, cancel := context.WithTimeout(e.Request.Context(), alertmanagerSyncTimeout)
+	defer cancel()
+
+	httpClient, err := core.ClusterHttpClient(e.App, e.Models(), cluster.Id)
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	target := alertmanager.Target{Id: cluster.Id, Url: cluster.AlertmanagerUrl, HttpClient: httpClient}
+
+	syncErr := syncer.Sync(ctx, target, config.Config)
+	e.App.Metrics().AlertmanagerSyncs.WithLabelValues(core.MetricResult(syncErr)).Inc()
+
+	if syncErr != nil {
+		config.SyncError = syncErr.Error()
+
+		e.App.Logger().WarnContext(e.Request.Context(), "alertmanager sync failed",
+			slog.String("cluster_id", cluster.Id),
+			slog.String("error", syncErr.Error()),
+		)
+	} else {
+		now := time.Now().UTC()
+		config.SyncedAt = &now
+		config.SyncError = ""
+	}
+
+	if err := e.Models().AlertmanagerConfigs.UpdateSync(config); err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	if syncErr != nil {
+		msg := fmt.Sprintf("Alertmanager sync failed: %v.", syncErr)
+		errorResponse(e, event.NewApiError(http.StatusBadGateway, msg))
+		return
+	}
+
+	resp := struct {
+		AlertmanagerConfig *data.AlertmanagerConfig `json:"alertmanager_config"`
+	}{
+		AlertmanagerConfig: redactAlertmanagerConfig(config),
+	}
+
+	if err := e.Json(resp, http.StatusOK); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// applyAlertmanagerConfigChange applies an approved change request of an
+// Alertmanager configuration, unless it changed since the change was
+// requested.
+func applyAlertmanagerConfigChange(e *core.EventRequest, cr *data.ChangeRequest) error {
+	if cr.Action != data.ChangeActionUpdate {
+		return fmt.Errorf("unsupported alertmanager config change action %q", cr.Action)
+	}
+
+	var requested *data.AlertmanagerConfig
+	if err := json.Unmarshal(cr.Before, &requested); err != nil {
+		return err
+	}
+
+	before, err := e.Models().AlertmanagerConfigs.GetByClusterId(cr.ResourceId)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		return err
+	}
+
+	switch {
+	case requested == nil && before != nil:
+		return errChangeConflict
+	case requested != nil && (before == nil || !before.UpdatedAt.Equal(requested.UpdatedAt)):
+		return errChangeConflict
+	}
+
+	var after data.AlertmanagerConfig
+	if err := json.Unmarshal(cr.After, &after); err != nil {
+		return err
+	}
+
+	config := &data.AlertmanagerConfig{
+		ClusterId: cr.ResourceId,
+		Config:    after.Config,
+	}
+
+	if err := e.Models().AlertmanagerConfigs.Upsert(config); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return errChangeConflict
+		default:
+			return err
+		}
+	}
+
+	e.SetChange(core.ModelUpdate, "alertmanager_config", cr.ResourceId, redactAlertmanagerConfig(before), redactAlertmanagerConfig(config))
+
+	return nil
+}
@@ -0,0 +1,269 @@
+package apis
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+)
+
+const testAlertmanagerConfig = `{
+	"route": {
+		"receiver": "default",
+		"routes": [{"receiver": "pager", "matchers": ["severity=\"critical\""]}]
+	},
+	"receivers": [
+		{"name": "default"},
+		{"name": "pager", "pagerduty_configs": [{"routing_key": "key"}]}
+	],
+	"templates": {"pager.tmpl": "{{ define \"pager.title\" }}{{ .Status }}{{ end }}"}
+}`
+
+func serveAlertmanagerRequest(t *testing.T, app *tests.TestApp, token, method, url, body string, status int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec := serveTestRequest(app, req)
+	if rec.Code != status {
+		t.Fatalf("expected status code to be %d, got %d (%s)", status, rec.Code, rec.Body.String())
+	}
+
+	return rec
+}
+
+func TestAlertmanagerConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	app, err := tests.NewTestAppWithConfig(tests.TestAppConfig{
+		AlertmanagerSyncer: alertmanager.NewFileSyncer(dir, nil),
+	})
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	cluster := app.NewCluster(team, "payments-staging", map[string]string{"env": "staging"})
+
+	am := tests.NewAlertmanager(filepath.Join(dir, cluster.Id, alertmanager.ConfigFile))
+	defer am.Close()
+
+	cluster.AlertmanagerUrl = am.Server.URL
+	_ = app.Clusters.Update(cluster)
+
+	editor := app.NewUser("editor@example.com")
+	app.Bind(editor, data.RoleEditor, data.ScopeTypeTeam, team.Id)
+	editorToken := app.NewToken(editor, data.ScopeRead, data.ScopeWrite)
+
+	viewer := app.NewUser("viewer@example.com")
+	app.Bind(viewer, data.RoleViewer, data.ScopeTypeTeam, team.Id)
+	viewerToken := app.NewToken(viewer, data.ScopeRead, data.ScopeWrite)
+
+	url := "/api/v1/clusters/" + cluster.Id + "/alertmanager"
+
+	serveAlertmanagerRequest(t, app, viewerToken, http.MethodGet, url, "", http.StatusNotFound)
+	serveAlertmanagerRequest(t, app, viewerToken, http.MethodPut, url, testAlertmanagerConfig, http.StatusForbidden)
+
+	invalid := strings.Replace(testAlertmanagerConfig, `{"name": "pager", `, `{"name": "oncall", `, 1)
+	rec := serveAlertmanagerRequest(t, app, editorToken, http.MethodPut, url, invalid, http.StatusBadRequest)
+	testBodyContent(t, rec, []string{`receiver \"pager\" is not defined`})
+
+	rec = serveAlertmanagerRequest(t, app, editorToken, http.MethodPut, url, `{"receivers": [{"name": 1}]}`, http.StatusBadRequest)
+	testBodyContent(t, rec, []string{`Invalid value type for field \"receivers.name\"`})
+
+	// A child route with the legacy matchers must not become a catch-all.
+	legacy := strings.Replace(testAlertmanagerConfig, `"matchers": ["severity=\"critical\""]`, `"match": {"severity": "critical"}`, 1)
+	rec = serveAlertmanagerRequest(t, app, editorToken, http.MethodPut, url, legacy, http.StatusBadRequest)
+	testBodyContent(t, rec, []string{`Unknown field '\"match\"' in request body.`})
+
+	serveAlertmanagerRequest(t, app, editorToken, http.MethodPut, url, testAlertmanagerConfig, http.StatusOK)
+
+	// The secrets of the receivers are redacted.
+	rec = serveAlertmanagerRequest(t, app, viewerToken, http.MethodGet, url, "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"receiver":"pager"`, `"pagerduty_configs":[{"routing_key":"\u003csecret\u003e"}]`})
+
+	var redacted struct {
+		AlertmanagerConfig data.AlertmanagerConfig `json:"alertmanager_config"`
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &redacted); err != nil {
+		t.Fatalf("failed to decode response body; %v", err)
+	}
+
+	rec = serveAlertmanagerRequest(t, app, viewerToken, http.MethodGet, url+"/render", "", http.StatusOK)
+	rendered := rec.Body.String()
+
+	if !strings.Contains(rendered, "routing_key: <secret>") || rec.Header().Get("Content-Type") != "application/yaml" {
+		t.Fatalf("expected rendered yaml config with redacted secrets, got %s", rendered)
+	}
+
+	// The sync agents do not download an unchanged config again.
+	for name, value := range map[string]string{
+		"If-None-Match":     rec.Header().Get("ETag"),
+		"If-Modified-Since": rec.Header().Get("Last-Modified"),
+	} {
+		req := httptest.NewRequest(http.MethodGet, url+"/render", nil)
+		req.Header.Set("Authorization", "Bearer "+viewerToken)
+		req.Header.Set(name, value)
+
+		if rec := serveTestRequest(app, req); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Fatalf("expected %s to get a 304 response, got %d (%s)", name, rec.Code, rec.Body.String())
+		}
+	}
+
+	// The redacted config sent back keeps its secrets.
+	b, _ := json.Marshal(redacted.AlertmanagerConfig.Config)
+	serveAlertmanagerRequest(t, app, editorToken, http.MethodPut, url, string(b), http.StatusOK)
+
+	for _, e := range app.Audit.Events() {
+		if strings.Contains(string(e.Before)+string(e.After), `"routing_key":"key"`) {
+			t.Fatalf("expected audited config secrets to be redacted, got %s", e.After)
+		}
+	}
+
+	serveAlertmanagerRequest(t, app, viewerToken, http.MethodPost, url+"/sync", "", http.StatusForbidden)
+
+	rec = serveAlertmanagerRequest(t, app, editorToken, http.MethodPost, url+"/sync", "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"synced_at":"`})
+
+	if strings.Replace(am.Config(), "routing_key: key", "routing_key: <secret>", 1) != rendered {
+		t.Fatalf("expected alertmanager to load the rendered config with its secrets, got\n%s", am.Config())
+	}
+
+	// Changing the config clears its sync state until it is synced again.
+	rec = serveAlertmanagerRequest(t, app, editorToken, http.MethodPut, url, testAlertmanagerConfig, http.StatusOK)
+
+	var resp struct {
+		AlertmanagerConfig data.AlertmanagerConfig `json:"alertmanager_config"`
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response body; %v", err)
+	}
+
+	if resp.AlertmanagerConfig.SyncedAt != nil {
+		t.Fatal("expected updated config not to be synced")
+	}
+
+	events := app.Audit.Events()
+	if last := events[len(events)-1]; last.ResourceType != "alertmanager_config" || last.Before == nil {
+		t.Fatalf("expected config change to be audited, got %+v", last)
+	}
+}
+
+func TestAlertmanagerConfigSyncErrors(t *testing.T) {
+	t.Parallel()
+
+	newApp := func(t *testing.T, syncer alertmanager.Syncer) (*tests.TestApp, *data.Cluster, string) {
+		app, err := tests.NewTestAppWithConfig(tests.TestAppConfig{AlertmanagerSyncer: syncer})
+		if err != nil {
+			t.Fatalf("failed to initialize test app instance; %v", err)
+		}
+
+		team := app.NewTeam("payments")
+		cluster := app.NewCluster(team, "payments-staging", nil)
+
+		editor := app.NewUser("editor@example.com")
+		app.Bind(editor, data.RoleEditor, data.ScopeTypeTeam, team.Id)
+		token := app.NewToken(editor, data.ScopeRead, data.ScopeWrite)
+
+		url := "/api/v1/clusters/" + cluster.Id + "/alertmanager"
+		serveAlertmanagerRequest(t, app, token, http.MethodPut, url, testAlertmanagerConfig, http.StatusOK)
+
+		return app, cluster, token
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		app, cluster, token := newApp(t, nil)
+
+		url := "/api/v1/clusters/" + cluster.Id + "/alertmanager/sync"
+		serveAlertmanagerRequest(t, app, token, http.MethodPost, url, "", http.StatusNotImplemented)
+	})
+
+	t.Run("no alertmanager url", func(t *testing.T) {
+		app, cluster, token := newApp(t, alertmanager.NewFileSyncer(t.TempDir(), nil))
+
+		url := "/api/v1/clusters/" + cluster.Id + "/alertmanager/sync"
+		serveAlertmanagerRequest(t, app, token, http.MethodPost, url, "", http.StatusConflict)
+	})
+
+	t.Run("reload failure", func(t *testing.T) {
+		app, cluster, token := newApp(t, alertmanager.NewFileSyncer(t.TempDir(), nil))
+
+		// The Alertmanager does not read the synced directory.
+		am := tests.NewAlertmanager(filepath.Join(t.TempDir(), alertmanager.ConfigFile))
+		defer am.Close()
+
+		cluster.AlertmanagerUrl = am.Server.URL
+		_ = app.Clusters.Update(cluster)
+
+		url := "/api/v1/clusters/" + cluster.Id + "/alertmanager/sync"
+		rec := serveAlertmanagerRequest(t, app, token, http.MethodPost, url, "", http.StatusBadGateway)
+		testBodyContent(t, rec, []string{"Alertmanager sync failed", "failed to reload config"})
+
+		config, _ := app.AlertmanagerConfigs.GetByClusterId(cluster.Id)
+		if config.SyncedAt != nil || !strings.Contains(config.SyncError, "failed to reload config") {
+			t.Fatalf("expected sync error to be recorded, got %+v", config)
+		}
+
+		if !app.Logs.Contains("alertmanager sync failed") {
+			t.Fatal("expected sync failure to be logged")
+		}
+	})
+}
+
+func TestAlertmanagerConfigProduction(t *testing.T) {
+	t.Parallel()
+
+	f := newChangeRequestFixture(t)
+	url := "/api/v1/clusters/" + f.cluster.Id + "/alertmanager"
+
+	request := func() string {
+		rec := f.serve(t, f.adminToken, http.MethodPut, url, testAlertmanagerConfig, http.StatusAccepted)
+
+		var resp struct {
+			ChangeRequest data.ChangeRequest `json:"change_request"`
+		}
+
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response body; %v", err)
+		}
+
+		if resp.ChangeRequest.ResourceType != "alertmanager_config" {
+			t.Fatalf("expected alertmanager config change request, got %q", resp.ChangeRequest.ResourceType)
+		}
+
+		return "/api/v1/change-requests/" + resp.ChangeRequest.Id
+	}
+
+	first, second := request(), request()
+
+	rec := f.serve(t, f.viewerToken, http.MethodGet, first, "", http.StatusOK)
+	if strings.Contains(rec.Body.String(), `"routing_key":"key"`) {
+		t.Fatalf("expected change request secrets to be redacted, got %s", rec.Body.String())
+	}
+
+	f.serve(t, f.viewerToken, http.MethodGet, url, "", http.StatusNotFound)
+
+	f.serve(t, f.approverToken, http.MethodPost, first+"/approve", "", http.StatusOK)
+
+	rec = f.serve(t, f.viewerToken, http.MethodGet, url, "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"receiver":"pager"`})
+
+	config, _ := f.app.AlertmanagerConfigs.GetByClusterId(f.cluster.Id)
+	if key := config.Config.Receiver("pager").Integrations["pagerduty_configs"][0]["routing_key"]; key != "key" {
+		t.Fatalf("expected approved config to keep its secrets, got %v", key)
+	}
+
+	// The second change was requested before the config was set.
+	f.serve(t, f.approverToken, http.MethodPost, second+"/approve", "", http.StatusConflict)
+}
@@ -0,0 +1,113 @@
+package apis
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+// listAlerts returns the cached alerts of the clusters of the tenant the
+// caller can view, the most recent first. The `cluster`, `team`, `severity` and `state` query
+// parameters filter them, and may be repeated to match any of their values.
+// Clusters are matched by id or name, and teams by id.
+func listAlerts(e *core.EventRequest) {
+	query := e.Request.URL.Query()
+
+	for _, state := range query["state"] {
+		if state != core.AlertPending && state != core.AlertFiring && state != core.AlertSuppressed {
+			badRequest(e, fmt.Sprintf("Invalid state %q, must be one of %q, %q or %q.",
+				state, core.AlertPending, core.AlertFiring, core.AlertSuppressed))
+			return
+		}
+	}
+
+	permissions, ok := loadPermissions(e)
+	if !ok {
+		return
+	}
+
+	clusters, err := core.SelectClusters(e.Models(), nil)
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	alerts := []*core.Alert{}
+	polls := []*core.ClusterAlerts{}
+
+	for _, cluster := range clusters {
+		if !permissions.Allows(data.RoleViewer, cluster.Resource()) {
+			continue
+		}
+
+		if !matchesQuery(query["cluster"], cluster.Id, cluster.Name) || !matchesQuery(query["team"], cluster.TeamId) {
+			continue
+		}
+
+		polled, ok := e.App.AlertPoller().ClusterAlerts(cluster.Id)
+		if !ok {
+			continue
+		}
+
+		polls = append(polls, polled)
+
+		for _, alert := range polled.Alerts {
+			if !matchesQuery(query["severity"], alert.Severity) || !matchesQuery(query["state"], alert.State) {
+				continue
+			}
+
+			alerts = append(alerts, clusterAlert(alert, cluster))
+		}
+	}
+
+	slices.SortStableFunc(alerts, func(a, b *core.Alert) int {
+		return b.StartsAt.Compare(a.StartsAt)
+	})
+
+	slices.SortFunc(polls, func(a, b *core.ClusterAlerts) int {
+		return cmp.Compare(a.ClusterId, b.ClusterId)
+	})
+
+	resp := struct {
+		Alerts   []*core.Alert         `json:"alerts"`
+		Clusters []*core.ClusterAlerts `json:"clusters"`
+	}{
+		Alerts:   alerts,
+		Clusters: polls,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// matchesQuery reports whether one of the values is one of the query
+// values, or whether there is no query value.
+func matchesQuery(query []string, values ...string) bool {
+	if len(query) == 0 {
+		return true
+	}
+
+	for _, value := range values {
+		if slices.Contains(query, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clusterAlert returns a copy of the alert with the current name and team of
+// its cluster, which may have changed since it was polled.
+func clusterAlert(alert *core.Alert, cluster *data.Cluster) *core.Alert {
+	a := *alert
+	a.ClusterName = cluster.Name
+	a.TeamId = cluster.TeamId
+	return &a
+}
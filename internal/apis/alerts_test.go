@@ -0,0 +1,208 @@
+package apis
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/prometheus"
+)
+
+func TestAlerts(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	payments := app.NewTeam("payments")
+	search := app.NewTeam("search")
+
+	paymentsProd := app.NewCluster(payments, "payments-prod", map[string]string{"env": "production"})
+	searchProd := app.NewCluster(search, "search-prod", map[string]string{"env": "production"})
+
+	// The cluster of another tenant is polled, but not listed.
+	tenant := app.NewTenant("other-bu")
+	otherCluster := app.NewCluster(app.NewTenantTeam(tenant.Id, "other"), "other-prod", nil)
+
+	am := tests.NewAlertmanager("")
+	defer am.Close()
+
+	prom := tests.NewPrometheus()
+	defer prom.Close()
+
+	otherAm := tests.NewAlertmanager("")
+	defer otherAm.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	am.SetAlerts(
+		&alertmanager.Alert{
+			Fingerprint: "a1",
+			Labels:      map[string]string{"alertname": "HighLatency", "severity": "critical", "cluster": "payments-prod"},
+			StartsAt:    now.Add(-time.Hour),
+			Status:      alertmanager.AlertStatus{State: "active"},
+		},
+		&alertmanager.Alert{
+			Fingerprint: "a2",
+			Labels:      map[string]string{"alertname": "DiskFull", "severity": "warning", "cluster": "payments-prod"},
+			StartsAt:    now.Add(-2 * time.Hour),
+			Status:      alertmanager.AlertStatus{State: "suppressed", SilencedBy: []string{"s1"}},
+		},
+	)
+
+	prom.SetAlerts(
+		// Also in Alertmanager, with the external labels of Prometheus.
+		&prometheus.Alert{
+			Labels:   map[string]string{"alertname": "HighLatency", "severity": "critical"},
+			State:    "firing",
+			ActiveAt: now.Add(-time.Hour),
+		},
+		&prometheus.Alert{
+			Labels:   map[string]string{"alertname": "HighErrorRate", "severity": "critical"},
+			State:    "pending",
+			ActiveAt: now.Add(-time.Minute),
+		},
+	)
+
+	otherAm.SetAlerts(&alertmanager.Alert{
+		Fingerprint: "o1",
+		Labels:      map[string]string{"alertname": "OtherTenant", "severity": "critical"},
+		Status:      alertmanager.AlertStatus{State: "active"},
+	})
+
+	// The Alertmanager of the search cluster is down.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	paymentsProd.AlertmanagerUrl = am.Server.URL
+	paymentsProd.PrometheusUrl = prom.Server.URL
+	searchProd.AlertmanagerUrl = down.URL
+	otherCluster.AlertmanagerUrl = otherAm.Server.URL
+
+	for _, cluster := range []*data.Cluster{paymentsProd, searchProd, otherCluster} {
+		_ = app.Clusters.Update(cluster)
+	}
+
+	if err := app.AlertPoller().Poll(context.Background()); err != nil {
+		t.Fatalf("failed to poll alerts; %v", err)
+	}
+
+	viewer := app.NewUser("viewer@example.com")
+	app.Bind(viewer, data.RoleViewer, data.ScopeTypeGlobal, "")
+	token := app.NewToken(viewer, data.ScopeRead)
+
+	rec := serveAlertmanagerRequest(t, app, token, http.MethodGet, "/api/v1/alerts", "", http.StatusOK)
+
+	var resp struct {
+		Alerts   []*core.Alert         `json:"alerts"`
+		Clusters []*core.ClusterAlerts `json:"clusters"`
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response body; %v", err)
+	}
+
+	names := []string{}
+	for _, alert := range resp.Alerts {
+		names = append(names, alert.Name+"/"+alert.State)
+	}
+
+	expected := "HighErrorRate/pending,HighLatency/firing,DiskFull/suppressed"
+	if got := strings.Join(names, ","); got != expected {
+		t.Fatalf("expected alerts %s, got %s", expected, got)
+	}
+
+	if alert := resp.Alerts[0]; alert.Source != core.AlertSourcePrometheus || alert.ClusterName != "payments-prod" || alert.TeamId != payments.Id {
+		t.Fatalf("unexpected pending alert %+v", alert)
+	}
+
+	if len(resp.Clusters) != 2 {
+		t.Fatalf("expected the polls of the 2 clusters of the tenant, got %d", len(resp.Clusters))
+	}
+
+	for _, polled := range resp.Clusters {
+		failed := polled.Errors[core.AlertSourceAlertmanager] != ""
+		if failed != (polled.ClusterId == searchProd.Id) {
+			t.Fatalf("expected only the search cluster poll to fail, got %+v", polled)
+		}
+	}
+
+	if !app.Logs.Contains("alert poll of cluster failed") {
+		t.Fatal("expected poll failure to be logged")
+	}
+
+	testCases := []struct {
+		name   string
+		query  string
+		alerts []string
+	}{
+		{"by severity", "?severity=warning", []string{"DiskFull"}},
+		{"by state", "?state=pending&state=suppressed", []string{"HighErrorRate", "DiskFull"}},
+		{"by cluster name", "?cluster=payments-prod&severity=critical", []string{"HighErrorRate", "HighLatency"}},
+		{"by cluster id", "?cluster=" + searchProd.Id, []string{}},
+		{"by team", "?team=" + search.Id, []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveAlertmanagerRequest(t, app, token, http.MethodGet, "/api/v1/alerts"+tc.query, "", http.StatusOK)
+
+			var resp struct {
+				Alerts []*core.Alert `json:"alerts"`
+			}
+
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response body; %v", err)
+			}
+
+			names := []string{}
+			for _, alert := range resp.Alerts {
+				names = append(names, alert.Name)
+			}
+
+			if strings.Join(names, ",") != strings.Join(tc.alerts, ",") {
+				t.Fatalf("expected alerts %v, got %v", tc.alerts, names)
+			}
+		})
+	}
+
+	// A viewer of a team only lists the alerts of its clusters.
+	searchViewer := app.NewUser("search@example.com")
+	app.Bind(searchViewer, data.RoleViewer, data.ScopeTypeTeam, search.Id)
+	searchToken := app.NewToken(searchViewer, data.ScopeRead)
+
+	rec = serveAlertmanagerRequest(t, app, searchToken, http.MethodGet, "/api/v1/alerts", "", http.StatusOK)
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response body; %v", err)
+	}
+
+	if len(resp.Alerts) != 0 || len(resp.Clusters) != 1 || resp.Clusters[0].ClusterId != searchProd.Id {
+		t.Fatalf("expected only the poll of the search cluster, got %+v", resp)
+	}
+
+	rec = serveAlertmanagerRequest(t, app, token, http.MethodGet, "/api/v1/alerts?state=resolved", "", http.StatusBadRequest)
+	testBodyContent(t, rec, []string{"Invalid state"})
+
+	// The alerts of the previous poll are kept while Prometheus is down.
+	prom.Close()
+
+	if err := app.AlertPoller().Poll(context.Background()); err != nil {
+		t.Fatalf("failed to poll alerts; %v", err)
+	}
+
+	polled, _ := app.AlertPoller().ClusterAlerts(paymentsProd.Id)
+	if len(polled.Alerts) != 3 || polled.Errors[core.AlertSourcePrometheus] == "" {
+		t.Fatalf("expected stale alerts and the prometheus error, got %+v", polled)
+	}
+}
@@ -0,0 +1,208 @@
+package apis
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// audited records every mutating request to the audit log once its handler
+// is done, including the requests denied by the access policy of the route,
+// along with the requests of the routes whose policy is audited.
+// The event is recorded once the handler applied the change and responded,
+// so a failed insert keeps the real status of the response, and is logged
+// and counted by the audit failures metric to be alerted on.
+func audited(policy access, next func(*core.EventRequest)) func(*core.EventRequest) {
+	return func(e *core.EventRequest) {
+		if isSafeMethod(e.Request.Method) && !policy.audit {
+			next(e)
+			return
+		}
+
+		_, route, _ := strings.Cut(e.Request.Pattern, " ")
+
+		remoteAddr, _, err := net.SplitHostPort(e.Request.RemoteAddr)
+		if err != nil {
+			remoteAddr = e.Request.RemoteAddr
+		}
+
+		e.Audit = &data.AuditEvent{
+			OccurredAt: time.Now(),
+			Method:     e.Request.Method,
+			Route:      route,
+			Path:       e.Request.URL.Path,
+			RemoteAddr: remoteAddr,
+			UserAgent:  e.Request.UserAgent(),
+		}
+
+		sw := &statusResponseWriter{ResponseWriter: e.Response, status: http.StatusOK}
+		e.Response = sw
+
+		next(e)
+
+		e.Audit.Status = sw.status
+
+		if e.Auth != nil {
+			e.Audit.TenantId = e.Auth.TenantId
+			e.Audit.ActorUserId = e.Auth.Id
+			e.Audit.ActorEmail = e.Auth.Email
+		}
+
+		if e.Token != nil {
+			e.Audit.ActorTokenId = e.Token.Id
+		}
+
+		if err := e.Models().Audit.Insert(e.Audit); err != nil {
+			e.App.Logger().ErrorContext(e.Request.Context(), "audit event insert failed",
+				slog.String("error", err.Error()),
+				slog.String("method", e.Audit.Method),
+				slog.String("request", e.Request.RequestURI),
+				slog.String("actor_user_id", e.Audit.ActorUserId),
+				slog.Int("status", e.Audit.Status),
+			)
+
+			e.App.Metrics().AuditFailures.Inc()
+		}
+	}
+}
+
+// statusResponseWriter records the status code of the response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func listAuditEvents(e *core.EventRequest) {
+	q, apiErr := e.ListQuery()
+	if apiErr != nil {
+		errorResponse(e, apiErr)
+		return
+	}
+
+	events, next, err := e.Models().Audit.List(q)
+	if err != nil {
+		var lqe *data.ListQueryError
+		switch {
+		case errors.As(err, &lqe):
+			badRequest(e, lqe.Message)
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	resp := struct {
+		Events     []*data.AuditEvent `json:"events"`
+		NextCursor string             `json:"next_cursor"`
+	}{
+		Events:     events,
+		NextCursor: next,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// exportAuditEvents streams all the audit events matching the list query
+// filters as JSON lines, following the pages until the last one.
+func exportAuditEvents(e *core.EventRequest) {
+	q, apiErr := e.ListQuery()
+	if apiErr != nil {
+		errorResponse(e, apiErr)
+		return
+	}
+
+	q.Limit = event.MaxListLimit
+
+	// Fetch the first page before writing anything, so that an invalid
+	// query still gets a proper error response.
+	events, next, err := e.Models().Audit.List(q)
+	if err != nil {
+		var lqe *data.ListQueryError
+		switch {
+		case errors.As(err, &lqe):
+			badRequest(e, lqe.Message)
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.Response.Header().Set("Content-Type", "application/x-ndjson")
+	e.Response.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	e.Response.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(e.Response)
+	rc := http.NewResponseController(e.Response)
+
+	for {
+		for _, ev := range events {
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+		}
+
+		_ = rc.Flush()
+
+		if next == "" {
+			return
+		}
+
+		q.Cursor = next
+
+		events, next, err = e.Models().Audit.List(q)
+		if err != nil {
+			// The response has already started, it can only be cut short.
+			e.App.Logger().ErrorContext(e.Request.Context(), "audit export failed", slog.String("error", err.Error()))
+			return
+		}
+	}
+}
+
+func verifyAuditLog(e *core.EventRequest) {
+	result, err := e.Models().Audit.Verify()
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	if !result.Valid {
+		e.App.Logger().ErrorContext(e.Request.Context(), "audit log chain is broken",
+			slog.Int64("broken_at", result.BrokenAt),
+			slog.String("reason", result.Reason),
+		)
+	}
+
+	if err := e.Json(result, http.StatusOK); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
@@ -0,0 +1,286 @@
+package apis
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+func TestAuditMutations(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	cluster := app.NewCluster(team, "payments-prod", map[string]string{"env": "staging"})
+
+	user := app.NewUser("test@example.com")
+	app.Bind(user, data.RoleAdmin, data.ScopeTypeTeam, team.Id)
+
+	token := app.NewToken(user, data.ScopeRead, data.ScopeWrite)
+
+	// Safe requests are not recorded.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/clusters/"+cluster.Id, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	serveTestRequest(app, req)
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/clusters/"+cluster.Id, strings.NewReader(`{"labels":{"env":"qa"}}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", "audit-test")
+
+	if rec := serveTestRequest(app, req); rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	// Denied requests are recorded as well.
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/teams", strings.NewReader(`{"name":"search"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	if rec := serveTestRequest(app, req); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	events := app.Audit.Events()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 audit events, got %d", len(events))
+	}
+
+	update := events[0]
+
+	if update.ActorUserId != user.Id || update.ActorEmail != user.Email || update.ActorTokenId == "" {
+		t.Fatalf("expected event actor to be the token of %q, got %+v", user.Email, update)
+	}
+
+	if update.Route != "/api/v1/clusters/{id}" || update.Path != "/api/v1/clusters/"+cluster.Id {
+		t.Fatalf("unexpected event route %q and path %q", update.Route, update.Path)
+	}
+
+	if update.Status != http.StatusOK || update.UserAgent != "audit-test" {
+		t.Fatalf("unexpected event status %d and user agent %q", update.Status, update.UserAgent)
+	}
+
+	if update.ResourceType != "cluster" || update.ResourceId != cluster.Id {
+		t.Fatalf("unexpected event resource %q %q", update.ResourceType, update.ResourceId)
+	}
+
+	if !strings.Contains(string(update.Before), `"env":"staging"`) ||
+		!strings.Contains(string(update.After), `"env":"qa"`) {
+		t.Fatalf("unexpected event states %s and %s", update.Before, update.After)
+	}
+
+	denied := events[1]
+
+	if denied.Status != http.StatusForbidden || denied.Route != "/api/v1/teams" || denied.ResourceType != "" {
+		t.Fatalf("unexpected denied event %+v", denied)
+	}
+}
+
+func TestAuditFailure(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	admin := app.NewUser("admin@example.com")
+	admin.Roles = []string{data.RoleAdmin}
+	token := app.NewToken(admin, data.ScopeRead, data.ScopeWrite)
+
+	app.Audit.SetError(errors.New("database is down"))
+
+	// A request that cannot be audited keeps its response, the change was
+	// applied, and the failure is logged and counted.
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/teams", strings.NewReader(`{"name":"billing"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec := serveTestRequest(app, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	testBodyContent(t, rec, []string{`"name":"billing"`})
+
+	if !app.Logs.Contains("audit event insert failed", "database is down") {
+		t.Fatal("expected audit failure to be logged")
+	}
+
+	rec = serveTestRequest(app, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	testBodyContent(t, rec, []string{`scopehouse_audit_failures_total 1`})
+
+	app.Audit.SetError(nil)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/teams", strings.NewReader(`{"name":"search"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec = serveTestRequest(app, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	testBodyContent(t, rec, []string{`"name":"search"`})
+}
+
+func TestAuditSecrets(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	user := app.NewUser("test@example.com")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tokens", strings.NewReader(`{"name":"ci","scopes":["read"]}`))
+	req.Header.Set("Authorization", "Bearer "+app.NewToken(user, data.ScopeRead, data.ScopeWrite))
+
+	rec := serveTestRequest(app, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	var resp struct {
+		Token struct {
+			Plaintext string `json:"token"`
+		} `json:"token"`
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response body; %v", err)
+	}
+
+	events := app.Audit.Events()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 audit event, got %d", len(events))
+	}
+
+	if events[0].ResourceType != "token" || len(events[0].After) == 0 {
+		t.Fatalf("expected token creation to be recorded, got %+v", events[0])
+	}
+
+	if resp.Token.Plaintext == "" || strings.Contains(string(events[0].After), resp.Token.Plaintext) {
+		t.Fatal("expected token plaintext not to be recorded")
+	}
+}
+
+func TestAuditEndpoints(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	admin := app.NewUser("admin@example.com")
+	admin.Roles = []string{data.RoleAdmin}
+	token := app.NewToken(admin, data.ScopeRead, data.ScopeWrite)
+
+	for _, name := range []string{"payments", "search", "billing"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/teams", strings.NewReader(`{"name":"`+name+`"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		if rec := serveTestRequest(app, req); rec.Code != http.StatusCreated {
+			t.Fatalf("expected status code to be %d, got %d", http.StatusCreated, rec.Code)
+		}
+	}
+
+	viewer := app.NewUser("viewer@example.com")
+	viewer.Roles = []string{data.RoleViewer}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/audit", nil)
+	req.Header.Set("Authorization", "Bearer "+app.NewToken(viewer, data.ScopeRead))
+
+	if rec := serveTestRequest(app, req); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/audit", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec := serveTestRequest(app, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	testBodyContent(t, rec, []string{`"events":[{"seq":3,`, `"resource_type":"team"`, `"next_cursor":""`})
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/audit/export", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec = serveTestRequest(app, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("expected content type to be application/x-ndjson, got %q", ct)
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var e data.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("expected export line to be an audit event; %v", err)
+		}
+		lines++
+	}
+
+	if lines != 3 {
+		t.Fatalf("expected 3 exported events, got %d", lines)
+	}
+
+	verify := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/audit/verify", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return serveTestRequest(app, req)
+	}
+
+	rec = verify()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	testBodyContent(t, rec, []string{`"valid":true`, `"events":3`})
+
+	// Deleting an event breaks the chain.
+	app.Audit.Delete(1)
+
+	rec = verify()
+	testBodyContent(t, rec, []string{`"valid":false`, `"broken_at":3`})
+
+	if !app.Logs.Contains("audit log chain is broken") {
+		t.Fatal("expected broken chain to be logged")
+	}
+
+	// The chains are per tenant, the other tenants only verify their own.
+	tenant := app.NewTenant("search-bu")
+	other := app.NewUser("admin@search.example.com")
+	if err := app.Tenants.AddUser(tenant.Id, other.Id, data.RoleAdmin); err != nil {
+		t.Fatalf("failed to move user to tenant; %v", err)
+	}
+	otherToken := app.NewToken(other, data.ScopeRead, data.ScopeWrite)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/teams", strings.NewReader(`{"name":"search"}`))
+	req.Header.Set("Authorization", "Bearer "+otherToken)
+
+	if rec := serveTestRequest(app, req); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/audit/verify", nil)
+	req.Header.Set("Authorization", "Bearer "+otherToken)
+
+	rec = serveTestRequest(app, req)
+	testBodyContent(t, rec, []string{`"valid":true`, `"events":1`})
+}
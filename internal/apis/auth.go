@@ -0,0 +1,191 @@
+package apis
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+const (
+	sessionCookieName = "sh_session"
+	csrfHeaderName    = "X-CSRF-Token"
+)
+
+// loadAuth resolves the `Authorization: Bearer` token, or else the session
+// cookie, of the request to its user. Requests without credentials, or with
+// an expired session cookie, continue anonymously, while requests with an
+// invalid or expired token are rejected.
+func loadAuth(e *core.EventRequest, next http.Handler) {
+	var auth core.AuthState
+
+	if header := e.Request.Header.Get("Authorization"); header != "" {
+		token, ok := loadToken(e, header)
+		if !ok {
+			return
+		}
+		auth.Token = token
+	} else if cookie, err := e.Request.Cookie(sessionCookieName); err == nil {
+		session, ok := loadSession(e, cookie)
+		if !ok {
+			return
+		}
+		auth.Session = session
+	}
+
+	if auth.Token == nil && auth.Session == nil {
+		next.ServeHTTP(e.Response, e.Request)
+		return
+	}
+
+	userId := ""
+	if auth.Token != nil {
+		userId = auth.Token.UserId
+	} else {
+		userId = auth.Session.UserId
+	}
+
+	user, err := e.App.Models().Users.GetById(userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			unauthorized(e, "Invalid or expired credentials.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	if !user.IsActivated {
+		unauthorized(e, "User account must be activated.")
+		return
+	}
+
+	auth.User = user
+
+	ctx := core.ContextWithAuth(e.Request.Context(), auth)
+	next.ServeHTTP(e.Response, e.Request.WithContext(ctx))
+}
+
+func loadToken(e *core.EventRequest, header string) (*data.Token, bool) {
+	scheme, plaintext, _ := strings.Cut(header, " ")
+	plaintext = strings.TrimSpace(plaintext)
+
+	if !strings.EqualFold(scheme, "Bearer") || plaintext == "" {
+		unauthorized(e, "Invalid authorization header, expected a bearer token.")
+		return nil, false
+	}
+
+	token, err := e.App.Models().Tokens.GetForToken(plaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			unauthorized(e, "Invalid or expired authentication token.")
+		default:
+			internalServerError(e, err)
+		}
+		return nil, false
+	}
+
+	if err := e.App.Models().Tokens.Touch(token.Id); err != nil {
+		e.App.Logger().WarnContext(e.Request.Context(), "token usage update failed",
+			slog.String("token_id", token.Id),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	return token, true
+}
+
+func loadSession(e *core.EventRequest, cookie *http.Cookie) (*data.Session, bool) {
+	session, err := e.App.Models().Sessions.GetForToken(cookie.Value)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			// Drop the stale cookie so that the browser stops sending it,
+			// and carry on anonymously so that the user can sign in again.
+			clearSessionCookie(e)
+			return nil, true
+		default:
+			internalServerError(e, err)
+		}
+		return nil, false
+	}
+
+	return session, true
+}
+
+// requireAuth only lets authenticated requests through to the handler.
+// Token authenticated requests must also hold the `read` scope for safe
+// methods and the `write` scope for everything else, while cookie
+// authenticated mutations must carry the session CSRF token.
+func requireAuth(next func(*core.EventRequest)) func(*core.EventRequest) {
+	return func(e *core.EventRequest) {
+		if e.Auth == nil {
+			unauthorized(e, "You must be authenticated to access this resource.")
+			return
+		}
+
+		safe := isSafeMethod(e.Request.Method)
+
+		if e.Token != nil {
+			scope := data.ScopeWrite
+			if safe {
+				scope = data.ScopeRead
+			}
+
+			if !e.Token.HasScope(scope) {
+				forbidden(e, fmt.Sprintf("Token is missing the %q scope.", scope))
+				return
+			}
+		}
+
+		if e.Session != nil && !safe {
+			csrfToken := e.Request.Header.Get(csrfHeaderName)
+			if subtle.ConstantTimeCompare([]byte(csrfToken), []byte(e.Session.CsrfToken)) != 1 {
+				forbidden(e, "Invalid or missing CSRF token.")
+				return
+			}
+		}
+
+		next(e)
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+func setSessionCookie(e *core.EventRequest, session *data.Session) {
+	http.SetCookie(e.Response, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    session.Plaintext,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   e.App.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearSessionCookie(e *core.EventRequest) {
+	http.SetCookie(e.Response, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   e.App.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,129 @@
+package apis
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+func TestAuth(t *testing.T) {
+	t.Parallel()
+
+	whoami := &apiRoute{
+		pattern: "/whoami",
+		access:  authAccess,
+		handler: func(e *core.EventRequest) {
+			_ = e.Json(map[string]string{"email": e.Auth.Email}, http.StatusOK)
+		},
+	}
+
+	scenarios := []apiTestScenario{
+		{
+			name:     "anonymous",
+			method:   http.MethodGet,
+			url:      "/whoami",
+			apiRoute: whoami,
+			status:   http.StatusUnauthorized,
+			content: []string{
+				`"status":401`,
+				`"message":"You must be authenticated to access this resource."`,
+			},
+		},
+		{
+			name:     "invalid scheme",
+			method:   http.MethodGet,
+			url:      "/whoami",
+			headers:  map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+			apiRoute: whoami,
+			status:   http.StatusUnauthorized,
+			content: []string{
+				`"message":"Invalid authorization header, expected a bearer token."`,
+			},
+		},
+		{
+			name:     "unknown token",
+			method:   http.MethodGet,
+			url:      "/whoami",
+			headers:  map[string]string{"Authorization": "Bearer sh_unknown"},
+			apiRoute: whoami,
+			status:   http.StatusUnauthorized,
+			content: []string{
+				`"message":"Invalid or expired authentication token."`,
+			},
+		},
+		{
+			name:           "health check stays public",
+			method:         http.MethodGet,
+			url:            "/api/v1/health",
+			status:         http.StatusOK,
+			beforeTestFunc: startWorkers,
+			content:        []string{`"status":200`},
+		},
+	}
+
+	for _, s := range scenarios {
+		s.test(t)
+	}
+
+	headers := map[string]string{}
+
+	s := apiTestScenario{
+		name:     "valid token",
+		method:   http.MethodGet,
+		url:      "/whoami",
+		headers:  headers,
+		apiRoute: whoami,
+		status:   http.StatusOK,
+		content:  []string{`"email":"test@example.com"`},
+		beforeTestFunc: func(_ testing.TB, app *tests.TestApp) {
+			user := app.NewUser("test@example.com")
+			headers["Authorization"] = "Bearer " + app.NewToken(user, data.ScopeRead)
+		},
+	}
+
+	s.test(t)
+}
+
+func TestAuthInactiveUser(t *testing.T) {
+	t.Parallel()
+
+	headers := map[string]string{}
+
+	s := apiTestScenario{
+		method:  http.MethodGet,
+		url:     "/api/v1/tokens",
+		headers: headers,
+		status:  http.StatusUnauthorized,
+		content: []string{`"message":"User account must be activated."`},
+		beforeTestFunc: func(_ testing.TB, app *tests.TestApp) {
+			user := app.NewUser("test@example.com")
+			user.IsActivated = false
+			headers["Authorization"] = "Bearer " + app.NewToken(user, data.ScopeRead)
+		},
+	}
+
+	s.test(t)
+}
+
+func TestAuthMissingScope(t *testing.T) {
+	t.Parallel()
+
+	headers := map[string]string{}
+
+	s := apiTestScenario{
+		method:  http.MethodGet,
+		url:     "/api/v1/tokens",
+		headers: headers,
+		status:  http.StatusForbidden,
+		content: []string{`"message":"Token is missing the \"read\" scope."`},
+		beforeTestFunc: func(_ testing.TB, app *tests.TestApp) {
+			user := app.NewUser("test@example.com")
+			headers["Authorization"] = "Bearer " + app.NewToken(user, data.ScopeWrite)
+		},
+	}
+
+	s.test(t)
+}
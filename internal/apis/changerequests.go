@@ -0,0 +1,388 @@
+package apis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+const (
+	changeRequestTTL = time.Hour * 72
+	maxCommentLen    = 10000
+)
+
+// errChangeConflict is returned by change appliers when the resource changed
+// since the change was requested, so the approved change cannot be applied.
+var errChangeConflict = errors.New("resource changed since the change was requested")
+
+// changeAppliers apply the approved change requests, by resource type.
+var changeAppliers = map[string]func(e *core.EventRequest, cr *data.ChangeRequest) error{
+	"cluster":             applyClusterChange,
+	"alertmanager_config": applyAlertmanagerConfigChange,
+}
+
+// changeRedactors redact the secrets of the states recorded by the change
+// requests, by resource type, so that they are never returned nor audited.
+var changeRedactors = map[string]func(state json.RawMessage) json.RawMessage{
+	"alertmanager_config": redactAlertmanagerConfigState,
+}
+
+// redactChangeRequest returns a copy of the change request with the secrets
+// of its states redacted.
+func redactChangeRequest(cr *data.ChangeRequest) *data.ChangeRequest {
+	redact, ok := changeRedactors[cr.ResourceType]
+	if !ok {
+		return cr
+	}
+
+	redacted := *cr
+	redacted.Before = redact(cr.Before)
+	redacted.After = redact(cr.After)
+
+	return &redacted
+}
+
+// requestChange records the change as a pending change request instead of
+// applying it, and responds with the request.
+func requestChange(e *core.EventRequest, cr *data.ChangeRequest, before any, after any) {
+	var err error
+
+	if cr.Before, err = json.Marshal(before); err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	if after != nil {
+		if cr.After, err = json.Marshal(after); err != nil {
+			internalServerError(e, err)
+			return
+		}
+	}
+
+	cr.RequestedBy = e.Auth.Id
+	cr.ExpiresAt = time.Now().Add(changeRequestTTL)
+
+	if err := e.Models().ChangeRequests.Insert(cr); err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	e.SetChange(core.ModelCreate, "change_request", cr.Id, nil, redactChangeRequest(cr))
+
+	resp := struct {
+		ChangeRequest *data.ChangeRequest `json:"change_request"`
+	}{
+		ChangeRequest: redactChangeRequest(cr),
+	}
+
+	if err := e.Json(resp, http.StatusAccepted); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func listChangeRequests(e *core.EventRequest) {
+	q, apiErr := e.ListQuery()
+	if apiErr != nil {
+		errorResponse(e, apiErr)
+		return
+	}
+
+	crs, next, err := e.Models().ChangeRequests.List(q)
+	if err != nil {
+		var lqe *data.ListQueryError
+		switch {
+		case errors.As(err, &lqe):
+			badRequest(e, lqe.Message)
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	redacted := make([]*data.ChangeRequest, 0, len(crs))
+	for _, cr := range crs {
+		redacted = append(redacted, redactChangeRequest(cr))
+	}
+
+	resp := struct {
+		ChangeRequests []*data.ChangeRequest `json:"change_requests"`
+		NextCursor     string                `json:"next_cursor"`
+	}{
+		ChangeRequests: redacted,
+		NextCursor:     next,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// loadChangeRequest returns the change request of the request path, and
+// responds with a 404 error when it does not exist.
+func loadChangeRequest(e *core.EventRequest) (*data.ChangeRequest, bool) {
+	cr, err := e.Models().ChangeRequests.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Change request not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return nil, false
+	}
+
+	return cr, true
+}
+
+func getChangeRequest(e *core.EventRequest) {
+	cr, ok := loadChangeRequest(e)
+	if !ok {
+		return
+	}
+
+	comments, err := e.Models().ChangeRequests.ListComments(cr.Id)
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	resp := struct {
+		ChangeRequest *data.ChangeRequest          `json:"change_request"`
+		Comments      []*data.ChangeRequestComment `json:"comments"`
+	}{
+		ChangeRequest: redactChangeRequest(cr),
+		Comments:      comments,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func commentChangeRequest(e *core.EventRequest) {
+	var input struct {
+		Body string `json:"body"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	cr, ok := loadChangeRequest(e)
+	if !ok {
+		return
+	}
+
+	comment := &data.ChangeRequestComment{
+		ChangeRequestId: cr.Id,
+		UserId:          e.Auth.Id,
+		Body:            strings.TrimSpace(input.Body),
+	}
+
+	if msg := validateComment(comment.Body); msg != "" {
+		badRequest(e, msg)
+		return
+	}
+
+	if err := e.Models().ChangeRequests.InsertComment(comment); err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	e.SetChange(core.ModelCreate, "change_request_comment", comment.Id, nil, comment)
+
+	resp := struct {
+		Comment *data.ChangeRequestComment `json:"comment"`
+	}{
+		Comment: comment,
+	}
+
+	if err := e.Json(resp, http.StatusCreated); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// approveChangeRequest applies a pending change request. Requests must be
+// approved by another user than the one who requested the change.
+func approveChangeRequest(e *core.EventRequest) {
+	cr, ok := loadChangeRequest(e)
+	if !ok {
+		return
+	}
+
+	if cr.RequestedBy == e.Auth.Id {
+		forbidden(e, "Change requests must be approved by another user.")
+		return
+	}
+
+	if cr.Status != data.ChangeRequestPending {
+		conflict(e, fmt.Sprintf("Change request is %s.", cr.Status))
+		return
+	}
+
+	apply, ok := changeAppliers[cr.ResourceType]
+	if !ok {
+		internalServerError(e, fmt.Errorf("no change applier for resource type %q", cr.ResourceType))
+		return
+	}
+
+	// Approve the request first, so that it is only ever applied once.
+	before := *cr
+
+	cr.Status = data.ChangeRequestApproved
+	cr.ReviewedBy = e.Auth.Id
+
+	if err := e.Models().ChangeRequests.UpdateStatus(cr, data.ChangeRequestPending); err != nil {
+		switch {
+		case errors.Is(err, data.ErrChangeRequestClosed):
+			conflict(e, "Change request is no longer pending.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	// The audit event records the change applied in place of the approval,
+	// or the reopening of the request when it cannot be applied.
+	e.SetChange(core.ModelUpdate, "change_request", cr.Id, redactChangeRequest(&before), redactChangeRequest(cr))
+
+	if err := apply(e, cr); err != nil {
+		reopenChangeRequest(e, cr)
+
+		switch {
+		case errors.Is(err, errChangeConflict):
+			conflict(e, "The resource changed since the change was requested.")
+		case errors.Is(err, data.ErrDuplicateClusterName):
+			conflict(e, "A cluster with this name already exists.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	resp := struct {
+		ChangeRequest *data.ChangeRequest `json:"change_request"`
+	}{
+		ChangeRequest: redactChangeRequest(cr),
+	}
+
+	if err := e.Json(resp, http.StatusOK); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// reopenChangeRequest moves back an approved change request that could not
+// be applied to pending, so that it can be rejected or approved again.
+func reopenChangeRequest(e *core.EventRequest, cr *data.ChangeRequest) {
+	before := *cr
+
+	cr.Status = data.ChangeRequestPending
+	cr.ReviewedBy = ""
+
+	if err := e.Models().ChangeRequests.UpdateStatus(cr, data.ChangeRequestApproved); err != nil {
+		e.App.Logger().ErrorContext(e.Request.Context(), "change request reopen failed",
+			slog.String("change_request_id", cr.Id),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	e.SetChange(core.ModelUpdate, "change_request", cr.Id, redactChangeRequest(&before), redactChangeRequest(cr))
+}
+
+func rejectChangeRequest(e *core.EventRequest) {
+	var input struct {
+		Comment string `json:"comment"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	input.Comment = strings.TrimSpace(input.Comment)
+
+	if input.Comment != "" {
+		if msg := validateComment(input.Comment); msg != "" {
+			badRequest(e, msg)
+			return
+		}
+	}
+
+	cr, ok := loadChangeRequest(e)
+	if !ok {
+		return
+	}
+
+	if cr.Status != data.ChangeRequestPending {
+		conflict(e, fmt.Sprintf("Change request is %s.", cr.Status))
+		return
+	}
+
+	before := *cr
+
+	cr.Status = data.ChangeRequestRejected
+	cr.ReviewedBy = e.Auth.Id
+
+	if err := e.Models().ChangeRequests.UpdateStatus(cr, data.ChangeRequestPending); err != nil {
+		switch {
+		case errors.Is(err, data.ErrChangeRequestClosed):
+			conflict(e, "Change request is no longer pending.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelUpdate, "change_request", cr.Id, redactChangeRequest(&before), redactChangeRequest(cr))
+
+	if input.Comment != "" {
+		comment := &data.ChangeRequestComment{
+			ChangeRequestId: cr.Id,
+			UserId:          e.Auth.Id,
+			Body:            input.Comment,
+		}
+
+		if err := e.Models().ChangeRequests.InsertComment(comment); err != nil {
+			internalServerError(e, err)
+			return
+		}
+	}
+
+	resp := struct {
+		ChangeRequest *data.ChangeRequest `json:"change_request"`
+	}{
+		ChangeRequest: redactChangeRequest(cr),
+	}
+
+	if err := e.Json(resp, http.StatusOK); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// validateComment returns the validation error message of a comment body,
+// or an empty string when it is valid.
+func validateComment(body string) string {
+	switch {
+	case body == "":
+		return "Comment must not be empty."
+	case len(body) > maxCommentLen:
+		return fmt.Sprintf("Comment must not be longer than %d characters.", maxCommentLen)
+	default:
+		return ""
+	}
+}
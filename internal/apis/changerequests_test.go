@@ -0,0 +1,248 @@
+package apis
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+// changeRequestFixture is a production cluster of the team "payments", a
+// team admin requesting changes and a team approver reviewing them.
+type changeRequestFixture struct {
+	app           *tests.TestApp
+	cluster       *data.Cluster
+	adminToken    string
+	approverToken string
+	viewerToken   string
+}
+
+func newChangeRequestFixture(t *testing.T) *changeRequestFixture {
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+
+	admin := app.NewUser("admin@example.com")
+	app.Bind(admin, data.RoleAdmin, data.ScopeTypeTeam, team.Id)
+
+	approver := app.NewUser("approver@example.com")
+	app.Bind(approver, data.RoleApprover, data.ScopeTypeTeam, team.Id)
+
+	viewer := app.NewUser("viewer@example.com")
+	app.Bind(viewer, data.RoleViewer, data.ScopeTypeTeam, team.Id)
+
+	return &changeRequestFixture{
+		app:           app,
+		cluster:       app.NewCluster(team, "payments-prod", map[string]string{"env": "production"}),
+		adminToken:    app.NewToken(admin, data.ScopeRead, data.ScopeWrite),
+		approverToken: app.NewToken(approver, data.ScopeRead, data.ScopeWrite),
+		viewerToken:   app.NewToken(viewer, data.ScopeRead, data.ScopeWrite),
+	}
+}
+
+func (f *changeRequestFixture) serve(t *testing.T, token, method, url, body string, status int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec := serveTestRequest(f.app, req)
+	if rec.Code != status {
+		t.Fatalf("expected status code to be %d, got %d (%s)", status, rec.Code, rec.Body.String())
+	}
+
+	return rec
+}
+
+// request asks for a change of the cluster, and returns the id of the
+// change request.
+func (f *changeRequestFixture) request(t *testing.T, method, body string) string {
+	t.Helper()
+
+	rec := f.serve(t, f.adminToken, method, "/api/v1/clusters/"+f.cluster.Id, body, http.StatusAccepted)
+
+	var resp struct {
+		ChangeRequest data.ChangeRequest `json:"change_request"`
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response body; %v", err)
+	}
+
+	if resp.ChangeRequest.Status != data.ChangeRequestPending {
+		t.Fatalf("expected change request to be pending, got %q", resp.ChangeRequest.Status)
+	}
+
+	return resp.ChangeRequest.Id
+}
+
+func TestChangeRequestApprove(t *testing.T) {
+	t.Parallel()
+
+	f := newChangeRequestFixture(t)
+	id := f.request(t, http.MethodPut, `{"labels":{"env":"production","tier":"1"}}`)
+
+	cluster, _ := f.app.Clusters.GetById(f.cluster.Id)
+	if cluster.Labels["tier"] != "" {
+		t.Fatal("expected change not to be applied before approval")
+	}
+
+	var changes []*core.ModelEvent
+
+	f.app.OnModelChange().Bind(func(e *core.ModelEvent) error {
+		changes = append(changes, e)
+		return nil
+	})
+
+	url := "/api/v1/change-requests/" + id
+
+	// Requesters cannot approve their own changes, and viewers cannot
+	// approve changes at all.
+	f.serve(t, f.adminToken, http.MethodPost, url+"/approve", "", http.StatusForbidden)
+	f.serve(t, f.viewerToken, http.MethodPost, url+"/approve", "", http.StatusForbidden)
+
+	f.serve(t, f.viewerToken, http.MethodPost, url+"/comments", `{"body":"Why tier 1?"}`, http.StatusCreated)
+	f.serve(t, f.viewerToken, http.MethodPost, url+"/comments", `{"body":"  "}`, http.StatusBadRequest)
+
+	rec := f.serve(t, f.approverToken, http.MethodPost, url+"/approve", "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"status":"approved"`, `"reviewed_by":"`})
+
+	cluster, _ = f.app.Clusters.GetById(f.cluster.Id)
+	if cluster.Labels["tier"] != "1" {
+		t.Fatalf("expected change to be applied once approved, got %v", cluster.Labels)
+	}
+
+	f.serve(t, f.approverToken, http.MethodPost, url+"/approve", "", http.StatusConflict)
+
+	rec = f.serve(t, f.viewerToken, http.MethodGet, url, "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"status":"approved"`, `"body":"Why tier 1?"`})
+
+	// The approval records the change it applied, the second approval is
+	// recorded after it.
+	events := f.app.Audit.Events()
+	approval := events[len(events)-2]
+
+	if approval.ResourceType != "cluster" || !strings.Contains(string(approval.After), `"tier":"1"`) {
+		t.Fatalf("expected applied change to be audited, got %+v", approval)
+	}
+
+	// The approval of the request is recorded before the change it applied.
+	if len(changes) != 3 || changes[1].Type != "change_request" || changes[2].Type != "cluster" {
+		t.Fatalf("expected approval and applied change, got %+v", changes)
+	}
+
+	if cr := changes[1].Before.(*data.ChangeRequest); cr.Status != data.ChangeRequestPending {
+		t.Fatalf("expected change request to be pending before approval, got %q", cr.Status)
+	}
+
+	if cr := changes[1].After.(*data.ChangeRequest); cr.Status != data.ChangeRequestApproved || cr.ReviewedBy == "" {
+		t.Fatalf("expected change request to be approved, got %+v", cr)
+	}
+}
+
+func TestChangeRequestDelete(t *testing.T) {
+	t.Parallel()
+
+	f := newChangeRequestFixture(t)
+	id := f.request(t, http.MethodDelete, "")
+
+	if _, err := f.app.Clusters.GetById(f.cluster.Id); err != nil {
+		t.Fatal("expected cluster not to be deleted before approval")
+	}
+
+	f.serve(t, f.approverToken, http.MethodPost, "/api/v1/change-requests/"+id+"/approve", "", http.StatusOK)
+
+	if _, err := f.app.Clusters.GetById(f.cluster.Id); !errors.Is(err, data.ErrRecordNotFound) {
+		t.Fatal("expected cluster to be deleted once approved")
+	}
+}
+
+func TestChangeRequestReject(t *testing.T) {
+	t.Parallel()
+
+	f := newChangeRequestFixture(t)
+	id := f.request(t, http.MethodPut, `{"name":"payments-production"}`)
+	url := "/api/v1/change-requests/" + id
+
+	rec := f.serve(t, f.approverToken, http.MethodPost, url+"/reject", `{"comment":"Not during the freeze."}`, http.StatusOK)
+	testBodyContent(t, rec, []string{`"status":"rejected"`})
+
+	f.serve(t, f.approverToken, http.MethodPost, url+"/approve", "", http.StatusConflict)
+
+	rec = f.serve(t, f.viewerToken, http.MethodGet, url, "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"body":"Not during the freeze."`})
+
+	cluster, _ := f.app.Clusters.GetById(f.cluster.Id)
+	if cluster.Name != "payments-prod" {
+		t.Fatalf("expected rejected change not to be applied, got %q", cluster.Name)
+	}
+}
+
+func TestChangeRequestExpiry(t *testing.T) {
+	t.Parallel()
+
+	f := newChangeRequestFixture(t)
+	id := f.request(t, http.MethodPut, `{"labels":{"env":"staging"}}`)
+	url := "/api/v1/change-requests/" + id
+
+	f.app.ChangeRequests.Expire(id)
+
+	rec := f.serve(t, f.viewerToken, http.MethodGet, "/api/v1/change-requests", "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"status":"expired"`})
+
+	f.serve(t, f.approverToken, http.MethodPost, url+"/approve", "", http.StatusConflict)
+	f.serve(t, f.approverToken, http.MethodPost, url+"/reject", "{}", http.StatusConflict)
+}
+
+func TestChangeRequestConflict(t *testing.T) {
+	t.Parallel()
+
+	f := newChangeRequestFixture(t)
+	id := f.request(t, http.MethodPut, `{"labels":{"env":"production","tier":"1"}}`)
+
+	// The cluster changes after the change was requested.
+	cluster, _ := f.app.Clusters.GetById(f.cluster.Id)
+	cluster.Name = "payments-main"
+	_ = f.app.Clusters.Update(cluster)
+
+	url := "/api/v1/change-requests/" + id
+
+	f.serve(t, f.approverToken, http.MethodPost, url+"/approve", "", http.StatusConflict)
+
+	rec := f.serve(t, f.viewerToken, http.MethodGet, url, "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"status":"pending"`})
+
+	// The failed approval records the reopening of the request.
+	events := f.app.Audit.Events()
+	reopen := events[len(events)-1]
+
+	if reopen.ResourceType != "change_request" || reopen.ResourceId != id ||
+		!strings.Contains(string(reopen.Before), `"status":"approved"`) ||
+		!strings.Contains(string(reopen.After), `"status":"pending"`) {
+		t.Fatalf("expected reopening to be audited, got %+v", reopen)
+	}
+}
+
+func TestChangeRequestNonProduction(t *testing.T) {
+	t.Parallel()
+
+	f := newChangeRequestFixture(t)
+
+	team, _ := f.app.Teams.GetById(f.cluster.TeamId)
+	staging := f.app.NewCluster(team, "payments-staging", map[string]string{"env": "staging"})
+	url := "/api/v1/clusters/" + staging.Id
+
+	f.serve(t, f.adminToken, http.MethodPut, url, `{"labels":{"env":"qa"}}`, http.StatusOK)
+
+	// Labeling a cluster as a production one requires an approval too.
+	f.serve(t, f.adminToken, http.MethodPut, url, `{"labels":{"env":"production"}}`, http.StatusAccepted)
+}
@@ -0,0 +1,389 @@
+package apis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+// labelNameRegex matches valid Prometheus label names.
+var labelNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func listClusters(e *core.EventRequest) {
+	q, apiErr := e.ListQuery()
+	if apiErr != nil {
+		errorResponse(e, apiErr)
+		return
+	}
+
+	clusters, next, err := e.Models().Clusters.List(q)
+	if err != nil {
+		var lqe *data.ListQueryError
+		switch {
+		case errors.As(err, &lqe):
+			badRequest(e, lqe.Message)
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	permissions, ok := loadPermissions(e)
+	if !ok {
+		return
+	}
+
+	// Only the clusters the caller can view are listed, pages may then be
+	// shorter than the limit.
+	visible := make([]*data.Cluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		if permissions.Allows(data.RoleViewer, cluster.Resource()) {
+			visible = append(visible, cluster)
+		}
+	}
+
+	resp := struct {
+		Clusters   []*data.Cluster `json:"clusters"`
+		NextCursor string          `json:"next_cursor"`
+	}{
+		Clusters:   visible,
+		NextCursor: next,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func createCluster(e *core.EventRequest) {
+	var input struct {
+		Name            string            `json:"name"`
+		TeamId          string            `json:"team_id"`
+		Labels          map[string]string `json:"labels"`
+		AlertmanagerUrl string            `json:"alertmanager_url"`
+		PrometheusUrl   string            `json:"prometheus_url"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	cluster := &data.Cluster{
+		Name:            strings.TrimSpace(input.Name),
+		TeamId:          input.TeamId,
+		Labels:          input.Labels,
+		AlertmanagerUrl: strings.TrimSpace(input.AlertmanagerUrl),
+		PrometheusUrl:   strings.TrimSpace(input.PrometheusUrl),
+	}
+
+	if msg := validateCluster(cluster); msg != "" {
+		badRequest(e, msg)
+		return
+	}
+
+	resource, ok := scopeResource(e, data.ScopeTypeTeam, cluster.TeamId)
+	if !ok {
+		return
+	}
+
+	// Only the admins of the team can add clusters to it.
+	if !allow(e, data.RoleAdmin, resource) {
+		return
+	}
+
+	if err := e.Models().Clusters.Insert(cluster); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateClusterName):
+			conflict(e, "A cluster with this name already exists.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelCreate, "cluster", cluster.Id, nil, cluster)
+
+	resp := struct {
+		Cluster *data.Cluster `json:"cluster"`
+	}{
+		Cluster: cluster,
+	}
+
+	if err := e.Json(resp, http.StatusCreated); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// loadCluster returns the cluster of the request path, and responds with a
+// 404 error when it does not exist.
+func loadCluster(e *core.EventRequest) (*data.Cluster, bool) {
+	cluster, err := e.Models().Clusters.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Cluster not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return nil, false
+	}
+
+	return cluster, true
+}
+
+func getCluster(e *core.EventRequest) {
+	cluster, err := e.Models().Clusters.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Cluster not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	resp := struct {
+		Cluster *data.Cluster `json:"cluster"`
+	}{
+		Cluster: cluster,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, cluster.UpdatedAt); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func updateCluster(e *core.EventRequest) {
+	cluster, err := e.Models().Clusters.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Cluster not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	before := *cluster
+
+	var input struct {
+		Name            *string           `json:"name"`
+		Labels          map[string]string `json:"labels"`
+		AlertmanagerUrl *string           `json:"alertmanager_url"`
+		PrometheusUrl   *string           `json:"prometheus_url"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	if input.Name != nil {
+		cluster.Name = strings.TrimSpace(*input.Name)
+	}
+
+	if input.Labels != nil {
+		cluster.Labels = input.Labels
+	}
+
+	if input.AlertmanagerUrl != nil {
+		cluster.AlertmanagerUrl = strings.TrimSpace(*input.AlertmanagerUrl)
+	}
+
+	if input.PrometheusUrl != nil {
+		cluster.PrometheusUrl = strings.TrimSpace(*input.PrometheusUrl)
+	}
+
+	if msg := validateCluster(cluster); msg != "" {
+		badRequest(e, msg)
+		return
+	}
+
+	// Changes to production clusters, including labeling a cluster as a
+	// production one, are only applied once approved.
+	if before.IsProduction() || cluster.IsProduction() {
+		requestChange(e, &data.ChangeRequest{
+			ClusterId:    cluster.Id,
+			ResourceType: "cluster",
+			ResourceId:   cluster.Id,
+			Action:       data.ChangeActionUpdate,
+		}, &before, cluster)
+		return
+	}
+
+	if err := e.Models().Clusters.Update(cluster); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Cluster not found.")
+		case errors.Is(err, data.ErrDuplicateClusterName):
+			conflict(e, "A cluster with this name already exists.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelUpdate, "cluster", cluster.Id, &before, cluster)
+
+	resp := struct {
+		Cluster *data.Cluster `json:"cluster"`
+	}{
+		Cluster: cluster,
+	}
+
+	if err := e.Json(resp, http.StatusOK); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func deleteCluster(e *core.EventRequest) {
+	cluster, err := e.Models().Clusters.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Cluster not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	if cluster.IsProduction() {
+		requestChange(e, &data.ChangeRequest{
+			ClusterId:    cluster.Id,
+			ResourceType: "cluster",
+			ResourceId:   cluster.Id,
+			Action:       data.ChangeActionDelete,
+		}, cluster, nil)
+		return
+	}
+
+	err = e.Models().Clusters.Delete(cluster.Id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Cluster not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelDelete, "cluster", cluster.Id, cluster, nil)
+
+	e.NoContent()
+}
+
+// applyClusterChange applies an approved change request of a cluster, unless
+// the cluster changed since the change was requested.
+func applyClusterChange(e *core.EventRequest, cr *data.ChangeRequest) error {
+	cluster, err := e.Models().Clusters.GetById(cr.ResourceId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return errChangeConflict
+		default:
+			return err
+		}
+	}
+
+	var requested data.Cluster
+	if err := json.Unmarshal(cr.Before, &requested); err != nil {
+		return err
+	}
+
+	if !cluster.UpdatedAt.Equal(requested.UpdatedAt) {
+		return errChangeConflict
+	}
+
+	switch cr.Action {
+	case data.ChangeActionDelete:
+		err := e.Models().Clusters.Delete(cluster.Id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				return errChangeConflict
+			default:
+				return err
+			}
+		}
+
+		e.SetChange(core.ModelDelete, "cluster", cluster.Id, cluster, nil)
+	case data.ChangeActionUpdate:
+		var after data.Cluster
+		if err := json.Unmarshal(cr.After, &after); err != nil {
+			return err
+		}
+
+		before := *cluster
+		cluster.Name = after.Name
+		cluster.Labels = after.Labels
+		cluster.AlertmanagerUrl = after.AlertmanagerUrl
+		cluster.PrometheusUrl = after.PrometheusUrl
+
+		err := e.Models().Clusters.Update(cluster)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				return errChangeConflict
+			default:
+				return err
+			}
+		}
+
+		e.SetChange(core.ModelUpdate, "cluster", cluster.Id, &before, cluster)
+	default:
+		return fmt.Errorf("unsupported cluster change action %q", cr.Action)
+	}
+
+	return nil
+}
+
+// validateCluster returns the validation error message of the cluster, or
+// an empty string when it is valid.
+func validateCluster(cluster *data.Cluster) string {
+	if msg := validateName("Cluster", cluster.Name); msg != "" {
+		return msg
+	}
+
+	for name := range cluster.Labels {
+		if !labelNameRegex.MatchString(name) {
+			return fmt.Sprintf("Invalid cluster label name %q.", name)
+		}
+	}
+
+	if cluster.AlertmanagerUrl != "" && !isHttpUrl(cluster.AlertmanagerUrl) {
+		return "Alertmanager url must be an absolute http or https url."
+	}
+
+	if cluster.PrometheusUrl != "" && !isHttpUrl(cluster.PrometheusUrl) {
+		return "Prometheus url must be an absolute http or https url."
+	}
+
+	return ""
+}
+
+// isHttpUrl reports whether the value is an absolute http or https url.
+func isHttpUrl(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,202 @@
+package apis
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+)
+
+const (
+	encodingGzip string = "gzip"
+	encodingZstd string = "zstd"
+)
+
+// supportedEncodings lists the response encodings in order of preference,
+// used to break ties between encodings accepted with the same quality.
+var supportedEncodings = []string{encodingZstd, encodingGzip}
+
+type encoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+var encoderPools = map[string]*sync.Pool{
+	encodingGzip: {
+		New: func() any {
+			return gzip.NewWriter(io.Discard)
+		},
+	},
+	encodingZstd: {
+		New: func() any {
+			// Errors are only returned for invalid options.
+			enc, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+			return enc
+		},
+	},
+}
+
+// compress encodes response bodies with the best encoding accepted by the
+// client, as advertised by the request `Accept-Encoding` header.
+func compress(e *core.EventRequest, next http.Handler) {
+	e.Response.Header().Add("Vary", "Accept-Encoding")
+
+	encoding := negotiateEncoding(e.Request.Header.Get("Accept-Encoding"))
+	if encoding == "" || e.Request.Method == http.MethodHead {
+		next.ServeHTTP(e.Response, e.Request)
+		return
+	}
+
+	cw := &compressResponseWriter{
+		ResponseWriter: e.Response,
+		encoding:       encoding,
+	}
+
+	defer func() {
+		if err := cw.Close(); err != nil {
+			e.App.Logger().ErrorContext(e.Request.Context(), "response compression failed",
+				slog.String("encoding", encoding),
+				slog.String("error", err.Error()),
+			)
+		}
+	}()
+
+	next.ServeHTTP(cw, e.Request)
+}
+
+// negotiateEncoding returns the supported encoding with the highest quality
+// value in the given `Accept-Encoding` header, or an empty string when none
+// of the supported encodings is acceptable.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	qualities := map[string]float64{}
+
+	for part := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		q := 1.0
+
+		params = strings.TrimSpace(params)
+		if value, ok := strings.CutPrefix(params, "q="); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = v
+		}
+
+		qualities[name] = q
+	}
+
+	best, bestQ := "", 0.0
+
+	for _, enc := range supportedEncodings {
+		q, ok := qualities[enc]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+
+	return best
+}
+
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	encoder     encoder
+	wroteHeader bool
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+
+	h := w.Header()
+
+	if canCompress(status, h) {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+
+		// The encoded representation is no longer byte-for-byte identical
+		// to the one the strong validator was computed from.
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+
+		w.encoder = encoderPools[w.encoding].Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.encoder == nil {
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.encoder.Write(b)
+}
+
+func (w *compressResponseWriter) Flush() {
+	if w.encoder != nil {
+		_ = w.encoder.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying response writer so that it can be used
+// with an http.ResponseController.
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close flushes any pending compressed data and returns the encoder to its pool.
+func (w *compressResponseWriter) Close() error {
+	if w.encoder == nil {
+		return nil
+	}
+
+	err := w.encoder.Close()
+
+	w.encoder.Reset(io.Discard)
+	encoderPools[w.encoding].Put(w.encoder)
+	w.encoder = nil
+
+	return err
+}
+
+func canCompress(status int, h http.Header) bool {
+	if status < http.StatusOK ||
+		status == http.StatusNoContent ||
+		status == http.StatusNotModified {
+		return false
+	}
+
+	return h.Get("Content-Encoding") == ""
+}
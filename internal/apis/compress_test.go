@@ -0,0 +1,172 @@
+package apis
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		header   string
+		expected string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"br", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"gzip;q=1.0, zstd;q=0.5", "gzip"},
+		{"zstd;q=0, gzip", "gzip"},
+		{"gzip;q=0", ""},
+		{"*", "zstd"},
+		{"*;q=0.5, gzip", "gzip"},
+		{"GZIP", "gzip"},
+		{"gzip;q=invalid", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.header, func(t *testing.T) {
+			encoding := negotiateEncoding(tc.header)
+			if encoding != tc.expected {
+				t.Fatalf("expected encoding to be %q, got %q", tc.expected, encoding)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat(`{"foo":"bar"}`, 100)
+
+	testCases := []struct {
+		name           string
+		acceptEncoding string
+		status         int
+		etag           string
+		encoding       string
+		expectedETag   string
+		decode         func(io.Reader) (io.Reader, error)
+	}{
+		{
+			name:           "no accept encoding",
+			acceptEncoding: "",
+			status:         http.StatusOK,
+			etag:           `"abc"`,
+			encoding:       "",
+			expectedETag:   `"abc"`,
+		},
+		{
+			name:           "gzip",
+			acceptEncoding: "gzip",
+			status:         http.StatusOK,
+			etag:           `"abc"`,
+			encoding:       "gzip",
+			expectedETag:   `W/"abc"`,
+			decode: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		{
+			name:           "zstd",
+			acceptEncoding: "gzip, zstd",
+			status:         http.StatusOK,
+			etag:           `W/"abc"`,
+			encoding:       "zstd",
+			expectedETag:   `W/"abc"`,
+			decode: func(r io.Reader) (io.Reader, error) {
+				return zstd.NewReader(r)
+			},
+		},
+		{
+			name:           "not modified",
+			acceptEncoding: "gzip",
+			status:         http.StatusNotModified,
+			etag:           `"abc"`,
+			encoding:       "",
+			expectedETag:   `"abc"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, err := tests.NewTestApp()
+			if err != nil {
+				t.Fatalf("failed to initialize test app instance; %v", err)
+			}
+
+			router := newRouter(app)
+			router.get("/test", publicAccess, func(e *core.EventRequest) {
+				e.Response.Header().Set("ETag", tc.etag)
+				e.Response.WriteHeader(tc.status)
+				if tc.status != http.StatusNotModified {
+					_, _ = e.Response.Write([]byte(body))
+				}
+			})
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+
+			router.handler().ServeHTTP(rec, req)
+
+			res := rec.Result()
+
+			if res.StatusCode != tc.status {
+				t.Fatalf("expected status code to be %d, got %d", tc.status, res.StatusCode)
+			}
+
+			if value := res.Header.Get("Content-Encoding"); value != tc.encoding {
+				t.Fatalf("expected content-encoding header to be %q, got %q", tc.encoding, value)
+			}
+
+			if value := res.Header.Get("Vary"); value != "Accept-Encoding" {
+				t.Fatalf("expected vary header to be %q, got %q", "Accept-Encoding", value)
+			}
+
+			if value := res.Header.Get("ETag"); value != tc.expectedETag {
+				t.Fatalf("expected etag header to be %q, got %q", tc.expectedETag, value)
+			}
+
+			if tc.status == http.StatusNotModified {
+				if rec.Body.Len() != 0 {
+					t.Fatalf("expected empty content, got \n%v", rec.Body.String())
+				}
+				return
+			}
+
+			var r io.Reader = res.Body
+
+			if tc.decode != nil {
+				if rec.Body.Len() >= len(body) {
+					t.Fatalf("expected compressed body to be smaller than %d bytes", len(body))
+				}
+
+				r, err = tc.decode(res.Body)
+				if err != nil {
+					t.Fatalf("failed to create decoder; %v", err)
+				}
+			}
+
+			decoded, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("failed to read response body; %v", err)
+			}
+
+			if string(decoded) != body {
+				t.Fatalf("expected decoded body to be \n%v \ngot \n%v", body, string(decoded))
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package apis
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// The types of the credentials of the clusters.
+const (
+	credentialsBearerToken = "bearer_token"
+	credentialsBasicAuth   = "basic_auth"
+)
+
+// clusterCredentials describes the credentials of a cluster, without their
+// secrets, which are never returned. KeyId is the id of the master key
+// wrapping their data key, to follow its rotation.
+type clusterCredentials struct {
+	ClusterId         string             `json:"cluster_id"`
+	Type              string             `json:"type,omitempty"`
+	Username          string             `json:"username,omitempty"`
+	ClientCertificate *clientCertificate `json:"client_certificate,omitempty"`
+	KeyId             string             `json:"key_id"`
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
+}
+
+// clientCertificate describes the client certificate of mutual TLS.
+type clientCertificate struct {
+	Subject  string    `json:"subject"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+func newClusterCredentials(credential *data.ClusterCredential, credentials *core.ClusterCredentials) *clusterCredentials {
+	c := &clusterCredentials{
+		ClusterId: credential.ClusterId,
+		KeyId:     credential.KeyId,
+		CreatedAt: credential.CreatedAt,
+		UpdatedAt: credential.UpdatedAt,
+	}
+
+	switch {
+	case credentials.BearerToken != "":
+		c.Type = credentialsBearerToken
+	case credentials.BasicAuth != nil:
+		c.Type = credentialsBasicAuth
+		c.Username = credentials.BasicAuth.Username
+	}
+
+	if credentials.TLS != nil {
+		if certificate, err := parseCertificate(credentials.TLS.Certificate); err == nil {
+			c.ClientCertificate = &clientCertificate{
+				Subject:  certificate.Subject.String(),
+				NotAfter: certificate.NotAfter,
+			}
+		}
+	}
+
+	return c
+}
+
+func getClusterCredentials(e *core.EventRequest) {
+	cluster, ok := loadCluster(e)
+	if !ok {
+		return
+	}
+
+	credential, err := e.Models().ClusterCredentials.GetByClusterId(cluster.Id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Cluster has no credentials.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	credentials, ok := loadClusterCredentials(e, cluster.Id)
+	if !ok {
+		return
+	}
+
+	resp := struct {
+		Credentials *clusterCredentials `json:"credentials"`
+	}{
+		Credentials: newClusterCredentials(credential, credentials),
+	}
+
+	if err := e.Json(resp, http.StatusOK); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// updateClusterCredentials sets the credentials the services of the cluster
+// are called with, replacing the current ones.
+func updateClusterCredentials(e *core.EventRequest) {
+	if e.App.Secrets() == nil {
+		secretsNotConfigured(e)
+		return
+	}
+
+	var input core.ClusterCredentials
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	input.BearerToken = strings.TrimSpace(input.BearerToken)
+
+	if msg := validateClusterCredentials(&input); msg != "" {
+		badRequest(e, msg)
+		return
+	}
+
+	cluster, ok := loadCluster(e)
+	if !ok {
+		return
+	}
+
+	var before *clusterCredentials
+
+	if current, err := e.Models().ClusterCredentials.GetByClusterId(cluster.Id); err == nil {
+		if credentials, ok := loadClusterCredentials(e, cluster.Id); ok {
+			before = newClusterCredentials(current, credentials)
+		} else {
+			return
+		}
+	} else if !errors.Is(err, data.ErrRecordNotFound) {
+		internalServerError(e, err)
+		return
+	}
+
+	credential, err := core.SaveClusterCredentials(e.App, e.Models(), cluster.Id, &input)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Cluster not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	after := newClusterCredentials(credential, &input)
+
+	if before == nil {
+		e.SetChange(core.ModelCreate, "cluster_credentials", cluster.Id, nil, after)
+	} else {
+		e.SetChange(core.ModelUpdate, "cluster_credentials", cluster.Id, before, after)
+	}
+
+	resp := struct {
+		Credentials *clusterCredentials `json:"credentials"`
+	}{
+		Credentials: after,
+	}
+
+	if err := e.Json(resp, http.StatusOK); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func deleteClusterCredentials(e *core.EventRequest) {
+	cluster, ok := loadCluster(e)
+	if !ok {
+		return
+	}
+
+	if err := e.Models().ClusterCredentials.Delete(cluster.Id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Cluster has no credentials.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelDelete, "cluster_credentials", cluster.Id, nil, nil)
+
+	e.NoContent()
+}
+
+// loadClusterCredentials decrypts the credentials of the cluster. It
+// responds with an error and returns false when they cannot be.
+func loadClusterCredentials(e *core.EventRequest, clusterId string) (*core.ClusterCredentials, bool) {
+	credentials, err := core.LoadClusterCredentials(e.App, e.Models(), clusterId)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrSecretsNotConfigured):
+			secretsNotConfigured(e)
+		default:
+			internalServerError(e, err)
+		}
+		return nil, false
+	}
+
+	if credentials == nil {
+		notFound(e, "Cluster has no credentials.")
+		return nil, false
+	}
+
+	return credentials, true
+}
+
+func secretsNotConfigured(e *core.EventRequest) {
+	errorResponse(e, event.NewApiError(http.StatusNotImplemented, "Secret store is not configured."))
+}
+
+// validateClusterCredentials returns the message of the first invalid
+// field, or an empty string when the credentials are valid.
+func validateClusterCredentials(credentials *core.ClusterCredentials) string {
+	switch {
+	case credentials.BearerToken != "" && credentials.BasicAuth != nil:
+		return "Only one of bearer_token and basic_auth can be set."
+	case credentials.BearerToken == "" && credentials.BasicAuth == nil && credentials.TLS == nil:
+		return "One of bearer_token, basic_auth and tls is required."
+	case credentials.BasicAuth != nil && strings.TrimSpace(credentials.BasicAuth.Username) == "":
+		return "Basic auth username is required."
+	case credentials.BasicAuth != nil && credentials.BasicAuth.Password == "":
+		return "Basic auth password is required."
+	case credentials.TLS != nil && (credentials.TLS.Certificate == "" || credentials.TLS.Key == ""):
+		return "TLS certificate and key are required."
+	}
+
+	if credentials.TLS != nil {
+		if _, err := credentials.TLS.Config(); err != nil {
+			return "TLS certificate, key or CA is invalid."
+		}
+	}
+
+	return ""
+}
+
+// parseCertificate parses the first certificate of the PEM encoded chain.
+func parseCertificate(chain string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(chain))
+	if block == nil {
+		return nil, errors.New("certificate not PEM encoded")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
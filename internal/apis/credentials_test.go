@@ -0,0 +1,330 @@
+package apis
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/secrets"
+)
+
+func TestClusterCredentials(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	cluster := app.NewCluster(team, "payments-staging", map[string]string{"env": "staging"})
+
+	am := tests.NewAlertmanager("")
+	defer am.Close()
+
+	cluster.AlertmanagerUrl = am.Server.URL
+	_ = app.Clusters.Update(cluster)
+
+	admin := app.NewUser("admin@example.com")
+	app.Bind(admin, data.RoleAdmin, data.ScopeTypeTeam, team.Id)
+	adminToken := app.NewToken(admin, data.ScopeRead, data.ScopeWrite)
+
+	editor := app.NewUser("editor@example.com")
+	app.Bind(editor, data.RoleEditor, data.ScopeTypeTeam, team.Id)
+	editorToken := app.NewToken(editor, data.ScopeRead, data.ScopeWrite)
+
+	url := "/api/v1/clusters/" + cluster.Id + "/credentials"
+
+	serveAlertmanagerRequest(t, app, adminToken, http.MethodGet, url, "", http.StatusNotFound)
+	serveAlertmanagerRequest(t, app, editorToken, http.MethodPut, url, `{"bearer_token":"s3cr3t-token"}`, http.StatusForbidden)
+
+	rec := serveAlertmanagerRequest(t, app, adminToken, http.MethodPut, url, `{"bearer_token":"s3cr3t-token"}`, http.StatusOK)
+	testBodyContent(t, rec, []string{`"cluster_id":"` + cluster.Id + `"`, `"type":"bearer_token"`})
+
+	rec = serveAlertmanagerRequest(t, app, adminToken, http.MethodGet, url, "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"type":"bearer_token"`})
+
+	if strings.Contains(rec.Body.String(), "s3cr3t-token") {
+		t.Fatalf("expected token not to be returned, got %s", rec.Body.String())
+	}
+
+	credential, err := app.ClusterCredentials.GetByClusterId(cluster.Id)
+	if err != nil {
+		t.Fatalf("expected credentials to be stored; %v", err)
+	}
+
+	if bytes.Contains(credential.Ciphertext, []byte("s3cr3t-token")) {
+		t.Fatal("expected credentials to be stored encrypted")
+	}
+
+	// The silences are created with the credentials of the cluster.
+	body := `{
+		"matchers": ["alertname=\"HighLatency\""],
+		"cluster_selector": {"env": "staging"},
+		"duration": "1h",
+		"comment": "Maintenance"
+	}`
+
+	serveAlertmanagerRequest(t, app, adminToken, http.MethodPost, "/api/v1/silences", body, http.StatusCreated)
+
+	if auth := am.Authorizations(); len(auth) != 1 || auth[0] != "Bearer s3cr3t-token" {
+		t.Fatalf("expected silence to be created with the bearer token, got %v", auth)
+	}
+
+	rec = serveAlertmanagerRequest(t, app, adminToken, http.MethodPut, url, `{"basic_auth":{"username":"scopehouse","password":"pa55word"}}`, http.StatusOK)
+	testBodyContent(t, rec, []string{`"type":"basic_auth"`, `"username":"scopehouse"`})
+
+	serveAlertmanagerRequest(t, app, adminToken, http.MethodPost, "/api/v1/silences", body, http.StatusCreated)
+
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("scopehouse:pa55word"))
+	if auth := am.Authorizations(); len(auth) != 2 || auth[1] != basic {
+		t.Fatalf("expected silence to be created with basic auth, got %v", auth)
+	}
+
+	for _, event := range app.Audit.Events() {
+		if event.ResourceType != "cluster_credentials" {
+			continue
+		}
+		if strings.Contains(string(event.Before)+string(event.After), "pa55word") ||
+			strings.Contains(string(event.Before)+string(event.After), "s3cr3t-token") {
+			t.Fatalf("expected audit event without secrets, got %+v", event)
+		}
+	}
+
+	serveAlertmanagerRequest(t, app, editorToken, http.MethodDelete, url, "", http.StatusForbidden)
+	serveAlertmanagerRequest(t, app, adminToken, http.MethodDelete, url, "", http.StatusNoContent)
+	serveAlertmanagerRequest(t, app, adminToken, http.MethodDelete, url, "", http.StatusNotFound)
+	serveAlertmanagerRequest(t, app, adminToken, http.MethodGet, url, "", http.StatusNotFound)
+}
+
+func TestClusterCredentialsValidation(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	cluster := app.NewCluster(team, "payments-staging", nil)
+
+	admin := app.NewUser("admin@example.com")
+	app.Bind(admin, data.RoleAdmin, data.ScopeTypeGlobal, "")
+	token := app.NewToken(admin, data.ScopeRead, data.ScopeWrite)
+
+	url := "/api/v1/clusters/" + cluster.Id + "/credentials"
+
+	scenarios := []struct {
+		body    string
+		message string
+	}{
+		{`{}`, "One of bearer_token, basic_auth and tls is required."},
+		{`{"bearer_token":" "}`, "One of bearer_token, basic_auth and tls is required."},
+		{`{"bearer_token":"token","basic_auth":{"username":"u","password":"p"}}`, "Only one of bearer_token and basic_auth can be set."},
+		{`{"basic_auth":{"password":"p"}}`, "Basic auth username is required."},
+		{`{"basic_auth":{"username":"u"}}`, "Basic auth password is required."},
+		{`{"tls":{"certificate":"cert"}}`, "TLS certificate and key are required."},
+		{`{"tls":{"certificate":"cert","key":"key"}}`, "TLS certificate, key or CA is invalid."},
+	}
+
+	for _, s := range scenarios {
+		rec := serveAlertmanagerRequest(t, app, token, http.MethodPut, url, s.body, http.StatusBadRequest)
+		testBodyContent(t, rec, []string{s.message})
+	}
+
+	serveAlertmanagerRequest(t, app, token, http.MethodPut, "/api/v1/clusters/7f0c2d4e-0b1a-4c3d-9e8f-1a2b3c4d5e6f/credentials", `{"bearer_token":"token"}`, http.StatusNotFound)
+}
+
+func TestClusterCredentialsMutualTLS(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	cluster := app.NewCluster(team, "payments-prod", nil)
+
+	ca, caKey, _, _ := newTestCertificate(t, "scopehouse-ca", nil, nil)
+	_, _, certificate, key := newTestCertificate(t, "scopehouse", ca, caKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	// The Alertmanager only accepts the clients with a certificate of the
+	// CA.
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	server.StartTLS()
+	defer server.Close()
+
+	serverCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	admin := app.NewUser("admin@example.com")
+	app.Bind(admin, data.RoleAdmin, data.ScopeTypeGlobal, "")
+	token := app.NewToken(admin, data.ScopeRead, data.ScopeWrite)
+
+	body, _ := json.Marshal(map[string]any{
+		"bearer_token": "s3cr3t-token",
+		"tls": map[string]string{
+			"certificate": certificate,
+			"key":         key,
+			"ca":          serverCA,
+		},
+	})
+
+	url := "/api/v1/clusters/" + cluster.Id + "/credentials"
+
+	rec := serveAlertmanagerRequest(t, app, token, http.MethodPut, url, string(body), http.StatusOK)
+	testBodyContent(t, rec, []string{`"type":"bearer_token"`, `"subject":"CN=scopehouse"`, `"key_id":"` + app.Secrets().KeyId() + `"`})
+
+	if strings.Contains(rec.Body.String(), "PRIVATE KEY") {
+		t.Fatalf("expected client key not to be returned, got %s", rec.Body.String())
+	}
+
+	credential, _ := app.ClusterCredentials.GetByClusterId(cluster.Id)
+	if bytes.Contains(credential.Ciphertext, []byte("PRIVATE KEY")) || len(credential.WrappedKey) == 0 {
+		t.Fatalf("expected credentials encrypted with a wrapped data key, got %+v", credential)
+	}
+
+	client, err := core.ClusterHttpClient(app, app.Models(), cluster.Id)
+	if err != nil {
+		t.Fatalf("failed to create cluster client; %v", err)
+	}
+
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected request with the client certificate to succeed; %v", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if b, _ := io.ReadAll(res.Body); string(b) != "scopehouse" {
+		t.Fatalf("expected client certificate to be presented, got %q", b)
+	}
+}
+
+func TestRotateClusterCredentials(t *testing.T) {
+	t.Parallel()
+
+	oldKey := bytes.Repeat([]byte{1}, secrets.KeySize)
+	newKey := bytes.Repeat([]byte{2}, secrets.KeySize)
+
+	keyring, _ := secrets.NewKeyring(oldKey)
+
+	app, err := tests.NewTestAppWithConfig(tests.TestAppConfig{Secrets: keyring})
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	staging := app.NewCluster(team, "payments-staging", nil)
+	prod := app.NewCluster(team, "payments-prod", nil)
+
+	for _, cluster := range []*data.Cluster{staging, prod} {
+		if _, err := core.SaveClusterCredentials(app, app.Models(), cluster.Id, &core.ClusterCredentials{BearerToken: "token-" + cluster.Name}); err != nil {
+			t.Fatalf("failed to save credentials; %v", err)
+		}
+	}
+
+	before, _ := app.ClusterCredentials.GetByClusterId(prod.Id)
+
+	rotated, _ := secrets.NewKeyring(newKey, oldKey)
+
+	n, err := core.RotateClusterCredentials(rotated, app.Models())
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 credentials rotated, got %d (%v)", n, err)
+	}
+
+	after, _ := app.ClusterCredentials.GetByClusterId(prod.Id)
+
+	if after.KeyId != secrets.KeyId(newKey) || !bytes.Equal(after.Ciphertext, before.Ciphertext) || !after.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Fatalf("expected data key wrapped with the new key only, got %+v", after)
+	}
+
+	// Once rotated, the credentials are decrypted without the old key.
+	current, _ := secrets.NewKeyring(newKey)
+
+	plaintext, err := current.Open(&secrets.Envelope{KeyId: after.KeyId, WrappedKey: after.WrappedKey, Ciphertext: after.Ciphertext}, []byte(prod.Id))
+	if err != nil || !strings.Contains(string(plaintext), "token-payments-prod") {
+		t.Fatalf("expected credentials of the cluster, got %q (%v)", plaintext, err)
+	}
+
+	if n, err := core.RotateClusterCredentials(rotated, app.Models()); err != nil || n != 0 {
+		t.Fatalf("expected no credentials left to rotate, got %d (%v)", n, err)
+	}
+
+	// The credentials of an unknown master key are reported.
+	other, _ := secrets.NewKeyring(bytes.Repeat([]byte{3}, secrets.KeySize))
+
+	if _, err := core.RotateClusterCredentials(other, app.Models()); !errors.Is(err, secrets.ErrUnknownKey) {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+}
+
+// newTestCertificate creates a certificate and its key signed by the parent,
+// or a self-signed CA when parent is nil, along with their PEM encoding.
+func newTestCertificate(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key; %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate; %v", err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key; %v", err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate; %v", err)
+	}
+
+	certificatePem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	return certificate, key, string(certificatePem), string(keyPem)
+}
@@ -6,10 +6,11 @@ import (
 	"net/http"
 
 	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
 )
 
 func internalServerError(e *core.EventRequest, err error) {
-	e.App.Logger().Error("internal server error",
+	e.App.Logger().ErrorContext(e.Request.Context(), "internal server error",
 		slog.String("code", "INTERNAL_SERVER_ERROR"),
 		slog.String("error", fmt.Sprintf("%v", err)),
 		slog.String("method", e.Request.Method),
@@ -23,3 +24,42 @@ func internalServerError(e *core.EventRequest, err error) {
 		return
 	}
 }
+
+func errorResponse(e *core.EventRequest, apiErr *event.ApiError) {
+	if err := e.Json(apiErr, apiErr.Status); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func badRequest(e *core.EventRequest, message string) {
+	errorResponse(e, event.NewApiError(http.StatusBadRequest, message))
+}
+
+func notFound(e *core.EventRequest, message string) {
+	errorResponse(e, event.NewApiError(http.StatusNotFound, message))
+}
+
+func conflict(e *core.EventRequest, message string) {
+	errorResponse(e, event.NewApiError(http.StatusConflict, message))
+}
+
+func unauthorized(e *core.EventRequest, message string) {
+	e.Response.Header().Set("WWW-Authenticate", `Bearer realm="scopehouse"`)
+	errorResponse(e, event.NewApiError(http.StatusUnauthorized, message))
+}
+
+func forbidden(e *core.EventRequest, message string) {
+	errorResponse(e, event.NewApiError(http.StatusForbidden, message))
+}
+
+// unmarshalError responds to a request body decoding failure, hiding the
+// details of errors that are not caused by the client.
+func unmarshalError(e *core.EventRequest, err *event.UnmarshalError) {
+	if !err.IsClientError {
+		internalServerError(e, err.Err)
+		return
+	}
+
+	badRequest(e, err.Message)
+}
@@ -6,6 +6,9 @@ import (
 	"github.com/dlbarduzzi/scopehouse/internal/core"
 )
 
+// healthCheck runs the readiness checks of the app, keeping the response of
+// the endpoint predating them. It is deprecated in favor of the readiness
+// endpoint, which reports the checks that failed.
 func healthCheck(e *core.EventRequest) {
 	resp := struct {
 		Status  int    `json:"status"`
@@ -15,8 +18,39 @@ func healthCheck(e *core.EventRequest) {
 		Message: "API is healthy.",
 	}
 
+	if report := e.App.Health().Ready(e.Request.Context()); report.Status != core.HealthStatusOk {
+		resp.Status = http.StatusServiceUnavailable
+		resp.Message = "API is unhealthy."
+	}
+
 	if err := e.Json(resp, resp.Status); err != nil {
 		internalServerError(e, err)
 		return
 	}
 }
+
+// liveHealthCheck runs the liveness checks of the app, e.g. for the
+// liveness probe of Kubernetes. It responds with a 503 error when the app
+// must be restarted.
+func liveHealthCheck(e *core.EventRequest) {
+	healthReport(e, e.App.Health().Live(e.Request.Context()))
+}
+
+// readyHealthCheck runs all the health checks of the app, e.g. for the
+// readiness probe of Kubernetes. It responds with a 503 error when the app
+// cannot serve requests, e.g. when the database is down.
+func readyHealthCheck(e *core.EventRequest) {
+	healthReport(e, e.App.Health().Ready(e.Request.Context()))
+}
+
+func healthReport(e *core.EventRequest, report *core.HealthReport) {
+	status := http.StatusOK
+	if report.Status != core.HealthStatusOk {
+		status = http.StatusServiceUnavailable
+	}
+
+	if err := e.Json(report, status); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
@@ -1,23 +1,193 @@
 package apis
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"testing"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+	"github.com/dlbarduzzi/scopehouse/migrations"
 )
 
+// TestHealthCheck covers the deprecated health endpoint, which fails along
+// with the readiness checks.
 func TestHealthCheck(t *testing.T) {
 	t.Parallel()
 
-	s := apiTestScenario{
-		name:   "health check",
-		method: http.MethodGet,
-		url:    "/api/v1/health",
-		status: http.StatusOK,
-		content: []string{
-			`"status":200`,
-			`"message":"API is healthy."`,
+	scenarios := []apiTestScenario{
+		{
+			name:           "health check",
+			method:         http.MethodGet,
+			url:            "/api/v1/health",
+			status:         http.StatusOK,
+			beforeTestFunc: startWorkers,
+			content: []string{
+				`"status":200`,
+				`"message":"API is healthy."`,
+			},
+		},
+		{
+			name:   "health check with database down",
+			method: http.MethodGet,
+			url:    "/api/v1/health",
+			status: http.StatusServiceUnavailable,
+			beforeTestFunc: func(t testing.TB, app *tests.TestApp) {
+				startWorkers(t, app)
+				app.Schema.SetError(errors.New("connection refused"))
+			},
+			content: []string{
+				`"status":503`,
+				`"message":"API is unhealthy."`,
+			},
 		},
 	}
 
-	s.test(t)
+	for _, s := range scenarios {
+		s.test(t)
+	}
+}
+
+// startWorkers runs the background workers of the app until the test ends.
+func startWorkers(t testing.TB, app *tests.TestApp) {
+	app.Scheduler().Start()
+	app.AlertPoller().Start()
+
+	t.Cleanup(func() {
+		app.Scheduler().Stop()
+		app.AlertPoller().Stop()
+	})
+}
+
+func TestHealthChecks(t *testing.T) {
+	t.Parallel()
+
+	latest, err := migrations.Latest()
+	if err != nil {
+		t.Fatalf("failed to read the latest migration; %v", err)
+	}
+
+	scenarios := []apiTestScenario{
+		{
+			name:           "live",
+			method:         http.MethodGet,
+			url:            "/api/v1/health/live",
+			status:         http.StatusOK,
+			beforeTestFunc: startWorkers,
+			content: []string{
+				`"status":"ok"`,
+				`{"name":"scheduler","status":"ok","latency_seconds":`,
+				`{"name":"alert_poller","status":"ok","latency_seconds":`,
+			},
+		},
+		{
+			name:   "live with stopped workers",
+			method: http.MethodGet,
+			url:    "/api/v1/health/live",
+			status: http.StatusServiceUnavailable,
+			content: []string{
+				`"status":"failed"`,
+				`"name":"scheduler","status":"failed"`,
+				`"error":"not running"`,
+			},
+		},
+		{
+			name:           "ready",
+			method:         http.MethodGet,
+			url:            "/api/v1/health/ready",
+			status:         http.StatusOK,
+			beforeTestFunc: startWorkers,
+			content: []string{
+				`"status":"ok"`,
+				`{"name":"database","status":"ok"`,
+				`{"name":"migrations","status":"ok"`,
+				`{"name":"scheduler","status":"ok"`,
+			},
+		},
+		{
+			name:   "ready with database down",
+			method: http.MethodGet,
+			url:    "/api/v1/health/ready",
+			status: http.StatusServiceUnavailable,
+			beforeTestFunc: func(t testing.TB, app *tests.TestApp) {
+				startWorkers(t, app)
+				app.Schema.SetError(errors.New("connection refused"))
+			},
+			content: []string{
+				`"status":"failed"`,
+				`"name":"database","status":"failed"`,
+				`"error":"connection refused"`,
+			},
+		},
+		{
+			name:   "ready with schema behind",
+			method: http.MethodGet,
+			url:    "/api/v1/health/ready",
+			status: http.StatusServiceUnavailable,
+			beforeTestFunc: func(t testing.TB, app *tests.TestApp) {
+				startWorkers(t, app)
+				app.Schema.SetVersion(latest-1, false)
+			},
+			content: []string{
+				`"name":"database","status":"ok"`,
+				`"name":"migrations","status":"failed"`,
+				`is behind version`,
+			},
+		},
+		{
+			name:   "ready with schema ahead",
+			method: http.MethodGet,
+			url:    "/api/v1/health/ready",
+			status: http.StatusOK,
+			beforeTestFunc: func(t testing.TB, app *tests.TestApp) {
+				startWorkers(t, app)
+				app.Schema.SetVersion(latest+1, false)
+			},
+			content: []string{
+				`"name":"migrations","status":"ok"`,
+			},
+		},
+		{
+			name:   "ready with dirty schema",
+			method: http.MethodGet,
+			url:    "/api/v1/health/ready",
+			status: http.StatusServiceUnavailable,
+			beforeTestFunc: func(t testing.TB, app *tests.TestApp) {
+				startWorkers(t, app)
+				app.Schema.SetVersion(latest, true)
+			},
+			content: []string{
+				`"name":"migrations","status":"failed"`,
+				`failed and must be fixed`,
+			},
+		},
+		{
+			name:   "ready with check timing out",
+			method: http.MethodGet,
+			url:    "/api/v1/health/ready",
+			status: http.StatusServiceUnavailable,
+			beforeTestFunc: func(t testing.TB, app *tests.TestApp) {
+				startWorkers(t, app)
+				app.Health().Register(&core.HealthCheck{
+					Name:    "cache",
+					Timeout: time.Millisecond * 10,
+					Check: func(context.Context) error {
+						time.Sleep(time.Second)
+						return nil
+					},
+				})
+			},
+			content: []string{
+				`"name":"database","status":"ok"`,
+				`"name":"cache","status":"failed"`,
+				`"error":"timed out after 10ms"`,
+			},
+		},
+	}
+
+	for _, s := range scenarios {
+		s.test(t)
+	}
 }
@@ -0,0 +1,229 @@
+package apis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+func TestOnServe(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	app.OnServe().Bind(func(e *core.ServeEvent) error {
+		e.Router.PublicRoute("GET /api/v1/plugins/ping", func(e *core.EventRequest) {
+			_ = e.Text(http.StatusOK, "pong")
+		})
+
+		e.Router.Route("GET /api/v1/plugins/whoami", data.RoleAdmin, func(e *core.EventRequest) {
+			_ = e.Text(http.StatusOK, e.Auth.Email)
+		})
+
+		e.Router.Use(func(e *core.EventRequest, next http.Handler) {
+			e.Response.Header().Set("X-Plugin", "enabled")
+			next.ServeHTTP(e.Response, e.Request)
+		})
+
+		return nil
+	})
+
+	handler, err := serveHandler(app, &http.Server{})
+	if err != nil {
+		t.Fatalf("failed to create handler; %v", err)
+	}
+
+	serve := func(url string, token string, status int) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != status {
+			t.Fatalf("expected status code to be %d, got %d (%s)", status, rec.Code, rec.Body.String())
+		}
+
+		if rec.Header().Get("X-Plugin") != "enabled" {
+			t.Fatalf("expected middleware of the hook to run")
+		}
+
+		return rec
+	}
+
+	testBodyContent(t, serve("/api/v1/plugins/ping", "", http.StatusOK), []string{"pong"})
+
+	// The routes of the hooks are authorized as the ones of the API.
+	serve("/api/v1/plugins/whoami", "", http.StatusUnauthorized)
+
+	viewer := app.NewUser("viewer@example.com")
+	app.Bind(viewer, data.RoleViewer, data.ScopeTypeGlobal, "")
+	serve("/api/v1/plugins/whoami", app.NewToken(viewer, data.ScopeRead), http.StatusForbidden)
+
+	admin := app.NewUser("admin@example.com")
+	app.Bind(admin, data.RoleAdmin, data.ScopeTypeGlobal, "")
+	rec := serve("/api/v1/plugins/whoami", app.NewToken(admin, data.ScopeRead), http.StatusOK)
+	testBodyContent(t, rec, []string{"admin@example.com"})
+
+	// The routes of the API are still served.
+	startWorkers(t, app)
+	serve("/api/v1/health", "", http.StatusOK)
+
+	app.OnServe().Bind(func(e *core.ServeEvent) error {
+		return errors.New("plugin misconfigured")
+	})
+
+	if _, err := serveHandler(app, &http.Server{}); err == nil || err.Error() != "plugin misconfigured" {
+		t.Fatalf("expected error of the hook, got %v", err)
+	}
+}
+
+func TestOnModelChange(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	var events []*core.ModelEvent
+
+	app.OnModelChange().Bind(func(e *core.ModelEvent) error {
+		events = append(events, e)
+		return nil
+	})
+
+	admin := app.NewUser("admin@example.com")
+	app.Bind(admin, data.RoleAdmin, data.ScopeTypeGlobal, "")
+	token := app.NewToken(admin, data.ScopeRead, data.ScopeWrite)
+
+	serveAlertmanagerRequest(t, app, token, http.MethodPost, "/api/v1/teams", `{"name":"search"}`, http.StatusCreated)
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 model event, got %d", len(events))
+	}
+
+	created := events[0]
+
+	team, ok := created.After.(*data.Team)
+	if !ok || created.Action != core.ModelCreate || created.Type != "team" || created.Before != nil {
+		t.Fatalf("expected team to be created, got %+v", created)
+	}
+
+	if team.Name != "search" || created.Id != team.Id || created.Request.Auth.Id != admin.Id {
+		t.Fatalf("unexpected event %+v of team %+v", created, team)
+	}
+
+	cluster := app.NewCluster(team, "search-prod", nil)
+
+	serveAlertmanagerRequest(t, app, token, http.MethodPut, "/api/v1/clusters/"+cluster.Id, `{"labels":{"env":"qa"}}`, http.StatusOK)
+	serveAlertmanagerRequest(t, app, token, http.MethodDelete, "/api/v1/clusters/"+cluster.Id, "", http.StatusNoContent)
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 model events, got %d", len(events))
+	}
+
+	if e := events[1]; e.Action != core.ModelUpdate || e.Type != "cluster" || e.Id != cluster.Id || e.Before == nil || e.After == nil {
+		t.Fatalf("expected cluster to be updated, got %+v", e)
+	}
+
+	if e := events[2]; e.Action != core.ModelDelete || e.Type != "cluster" || e.Id != cluster.Id || e.Before == nil || e.After != nil {
+		t.Fatalf("expected cluster to be deleted, got %+v", e)
+	}
+
+	// The change is saved already, so the errors of the hooks are logged
+	// only.
+	app.OnModelChange().Bind(func(e *core.ModelEvent) error {
+		return errors.New("webhook unreachable")
+	})
+
+	serveAlertmanagerRequest(t, app, token, http.MethodPost, "/api/v1/teams", `{"name":"checkout"}`, http.StatusCreated)
+
+	if !app.Logs.Contains("model change hook failed", "webhook unreachable") {
+		t.Fatalf("expected error of the hook to be logged")
+	}
+}
+
+func TestOnBootstrap(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	var after bool
+
+	app.OnAfterBootstrap().Bind(func(e *core.BootstrapEvent) error {
+		after = true
+		return nil
+	})
+
+	if err := app.Bootstrap(); err != nil || !after {
+		t.Fatalf("expected after bootstrap hook to run, got %v", err)
+	}
+
+	after = false
+
+	app.OnBeforeBootstrap().Bind(func(e *core.BootstrapEvent) error {
+		return errors.New("license expired")
+	})
+
+	if err := app.Bootstrap(); err == nil || err.Error() != "license expired" || after {
+		t.Fatalf("expected before bootstrap hook to abort bootstrap, got %v", err)
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	var shutdown bool
+
+	app.ShutdownHooks().Register(&core.ShutdownHook{
+		Name: "plugin",
+		Run: func(context.Context) error {
+			shutdown = true
+			return nil
+		},
+	})
+
+	// The port is already in use.
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to listen; %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	if err := Serve(app, ServerConfig{Port: port}); err == nil {
+		t.Fatal("expected error of the listener")
+	}
+
+	if !shutdown {
+		t.Fatal("expected shutdown hooks to run")
+	}
+
+	if err := app.Scheduler().Alive(); err == nil {
+		t.Fatal("expected scheduler not to be started")
+	}
+}
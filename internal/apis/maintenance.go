@@ -0,0 +1,387 @@
+package apis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/schedule"
+)
+
+func listMaintenanceWindows(e *core.EventRequest) {
+	q, apiErr := e.ListQuery()
+	if apiErr != nil {
+		errorResponse(e, apiErr)
+		return
+	}
+
+	windows, next, err := e.Models().MaintenanceWindows.List(q)
+	if err != nil {
+		var lqe *data.ListQueryError
+		switch {
+		case errors.As(err, &lqe):
+			badRequest(e, lqe.Message)
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	resp := struct {
+		MaintenanceWindows []*data.MaintenanceWindow `json:"maintenance_windows"`
+		NextCursor         string                    `json:"next_cursor"`
+	}{
+		MaintenanceWindows: windows,
+		NextCursor:         next,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func loadMaintenanceWindow(e *core.EventRequest) (*data.MaintenanceWindow, bool) {
+	window, err := e.Models().MaintenanceWindows.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Maintenance window not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return nil, false
+	}
+
+	return window, true
+}
+
+func getMaintenanceWindow(e *core.EventRequest) {
+	window, ok := loadMaintenanceWindow(e)
+	if !ok {
+		return
+	}
+
+	maintenanceWindowResponse(e, window, http.StatusOK)
+}
+
+type maintenanceWindowInput struct {
+	Name            *string           `json:"name"`
+	ScheduleKind    *string           `json:"schedule_kind"`
+	Schedule        *string           `json:"schedule"`
+	Timezone        *string           `json:"timezone"`
+	Duration        *string           `json:"duration"`
+	Matchers        []string          `json:"matchers"`
+	ClusterSelector map[string]string `json:"cluster_selector"`
+	Comment         *string           `json:"comment"`
+	Enabled         *bool             `json:"enabled"`
+}
+
+// apply sets the given fields of the input on the window.
+func (input *maintenanceWindowInput) apply(window *data.MaintenanceWindow) {
+	if input.Name != nil {
+		window.Name = strings.TrimSpace(*input.Name)
+	}
+
+	if input.ScheduleKind != nil {
+		window.ScheduleKind = *input.ScheduleKind
+	}
+
+	if input.Schedule != nil {
+		window.Schedule = strings.TrimSpace(*input.Schedule)
+	}
+
+	if input.Timezone != nil {
+		window.Timezone = strings.TrimSpace(*input.Timezone)
+	}
+
+	if input.Duration != nil {
+		window.Duration = strings.TrimSpace(*input.Duration)
+	}
+
+	if input.Matchers != nil {
+		window.Matchers = input.Matchers
+	}
+
+	if input.ClusterSelector != nil {
+		window.ClusterSelector = input.ClusterSelector
+	}
+
+	if input.Comment != nil {
+		window.Comment = strings.TrimSpace(*input.Comment)
+	}
+
+	if input.Enabled != nil {
+		window.Enabled = *input.Enabled
+	}
+}
+
+// createMaintenanceWindow creates a window silencing the clusters matching
+// its selector during each occurrence of its schedule. The caller must be an
+// editor of all the clusters the selector matches.
+func createMaintenanceWindow(e *core.EventRequest) {
+	var input maintenanceWindowInput
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	window := &data.MaintenanceWindow{
+		ScheduleKind: schedule.KindCron,
+		Timezone:     "UTC",
+		Enabled:      true,
+		CreatedBy:    e.Auth.Id,
+	}
+
+	input.apply(window)
+
+	if !prepareMaintenanceWindow(e, window) {
+		return
+	}
+
+	if err := e.Models().MaintenanceWindows.Insert(window); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateMaintenanceWindowName):
+			conflict(e, "A maintenance window with this name already exists.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelCreate, "maintenance_window", window.Id, nil, window)
+
+	maintenanceWindowResponse(e, window, http.StatusCreated)
+}
+
+// updateMaintenanceWindow updates the window, its next occurrence is
+// computed again from its new schedule. Disabling the window expires the
+// silences of its current and upcoming occurrences.
+func updateMaintenanceWindow(e *core.EventRequest) {
+	window, ok := loadMaintenanceWindow(e)
+	if !ok {
+		return
+	}
+
+	if !allowMaintenanceWindow(e, window) {
+		return
+	}
+
+	before := *window
+
+	var input maintenanceWindowInput
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	input.apply(window)
+
+	if !prepareMaintenanceWindow(e, window) {
+		return
+	}
+
+	if err := e.Models().MaintenanceWindows.Update(window); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Maintenance window not found.")
+		case errors.Is(err, data.ErrDuplicateMaintenanceWindowName):
+			conflict(e, "A maintenance window with this name already exists.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	if before.Enabled && !window.Enabled && !expireMaintenanceSilences(e, window) {
+		return
+	}
+
+	e.SetChange(core.ModelUpdate, "maintenance_window", window.Id, &before, window)
+
+	maintenanceWindowResponse(e, window, http.StatusOK)
+}
+
+// deleteMaintenanceWindow deletes the window, once the silences of its
+// current and upcoming occurrences are expired.
+func deleteMaintenanceWindow(e *core.EventRequest) {
+	window, ok := loadMaintenanceWindow(e)
+	if !ok {
+		return
+	}
+
+	if !allowMaintenanceWindow(e, window) {
+		return
+	}
+
+	if !expireMaintenanceSilences(e, window) {
+		return
+	}
+
+	if err := e.Models().MaintenanceWindows.Delete(window.Id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Maintenance window not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelDelete, "maintenance_window", window.Id, window, nil)
+
+	e.NoContent()
+}
+
+// prepareMaintenanceWindow validates the window, checks that the caller is
+// an editor of the clusters it matches, and computes its next occurrence. It
+// responds with an error and returns false when one of them fails.
+func prepareMaintenanceWindow(e *core.EventRequest, window *data.MaintenanceWindow) bool {
+	if msg := validateMaintenanceWindow(window); msg != "" {
+		badRequest(e, msg)
+		return false
+	}
+
+	clusters, err := core.SelectClusters(e.Models(), window.ClusterSelector)
+	if err != nil {
+		internalServerError(e, err)
+		return false
+	}
+
+	if len(clusters) == 0 {
+		badRequest(e, "No cluster matches the cluster selector.")
+		return false
+	}
+
+	for _, cluster := range clusters {
+		if !allow(e, data.RoleEditor, cluster.Resource()) {
+			return false
+		}
+	}
+
+	window.NextRunAt = nil
+
+	if window.Enabled {
+		next, err := window.NextRun(time.Now())
+		if err != nil {
+			internalServerError(e, err)
+			return false
+		}
+
+		if next == nil {
+			badRequest(e, "Schedule has no upcoming occurrence.")
+			return false
+		}
+
+		window.NextRunAt = next
+	}
+
+	return true
+}
+
+// allowMaintenanceWindow reports whether the caller is an editor of all the
+// clusters the window matches, and responds with a 403 error when it is
+// not.
+func allowMaintenanceWindow(e *core.EventRequest, window *data.MaintenanceWindow) bool {
+	clusters, err := core.SelectClusters(e.Models(), window.ClusterSelector)
+	if err != nil {
+		internalServerError(e, err)
+		return false
+	}
+
+	for _, cluster := range clusters {
+		if !allow(e, data.RoleEditor, cluster.Resource()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func expireMaintenanceSilences(e *core.EventRequest, window *data.MaintenanceWindow) bool {
+	ctx, cancel := context.WithTimeout(e.Request.Context(), silenceFanOutTimeout)
+	defer cancel()
+
+	if err := core.ExpireMaintenanceSilences(ctx, e.App, e.Models(), window); err != nil {
+		internalServerError(e, err)
+		return false
+	}
+
+	return true
+}
+
+// validateMaintenanceWindow returns the validation error message of the
+// window, or an empty string when it is valid.
+func validateMaintenanceWindow(window *data.MaintenanceWindow) string {
+	if msg := validateName("Maintenance window", window.Name); msg != "" {
+		return msg
+	}
+
+	if window.ScheduleKind != schedule.KindCron && window.ScheduleKind != schedule.KindRRule {
+		return fmt.Sprintf("Schedule kind must be one of %q or %q.", schedule.KindCron, schedule.KindRRule)
+	}
+
+	if _, err := schedule.LoadLocation(window.Timezone); err != nil {
+		return fmt.Sprintf("Invalid timezone %q.", window.Timezone)
+	}
+
+	if _, err := window.ParseSchedule(); err != nil {
+		return fmt.Sprintf("Invalid schedule: %v.", err)
+	}
+
+	if d, err := window.ParseDuration(); err != nil || d <= 0 {
+		return fmt.Sprintf("Invalid duration %q, e.g. 2h or 1d12h.", window.Duration)
+	}
+
+	if len(window.Matchers) == 0 {
+		return "At least one matcher must be given."
+	}
+
+	matchers, err := alertmanager.ParseMatchers(window.Matchers)
+	if err != nil {
+		return fmt.Sprintf("Invalid matcher: %v.", err)
+	}
+
+	if alertmanager.MatchesAll(matchers, map[string]string{}) {
+		return "At least one matcher must not match the empty string."
+	}
+
+	for name := range window.ClusterSelector {
+		if !labelNameRegex.MatchString(name) {
+			return fmt.Sprintf("Invalid cluster selector label name %q.", name)
+		}
+	}
+
+	if window.Comment == "" {
+		return "Comment must be provided."
+	}
+
+	if len(window.Comment) > maxCommentLen {
+		return fmt.Sprintf("Comment must not be more than %d bytes long.", maxCommentLen)
+	}
+
+	return ""
+}
+
+// maintenanceWindowResponse responds with the maintenance window, or with a
+// 304 response to the conditional requests of clients already holding it.
+func maintenanceWindowResponse(e *core.EventRequest, window *data.MaintenanceWindow, status int) {
+	resp := struct {
+		MaintenanceWindow *data.MaintenanceWindow `json:"maintenance_window"`
+	}{
+		MaintenanceWindow: window,
+	}
+
+	if err := e.JsonConditional(resp, status, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
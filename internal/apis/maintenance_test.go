@@ -0,0 +1,347 @@
+package apis
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+func TestMaintenanceWindows(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+
+	staging := app.NewCluster(team, "payments-staging", map[string]string{"env": "staging"})
+	prod := app.NewCluster(team, "payments-prod", map[string]string{"env": "production"})
+
+	amStaging := tests.NewAlertmanager("")
+	defer amStaging.Close()
+
+	amProd := tests.NewAlertmanager("")
+	defer amProd.Close()
+
+	staging.AlertmanagerUrl = amStaging.Server.URL
+	prod.AlertmanagerUrl = amProd.Server.URL
+
+	for _, cluster := range []*data.Cluster{staging, prod} {
+		_ = app.Clusters.Update(cluster)
+	}
+
+	editor := app.NewUser("editor@example.com")
+	app.Bind(editor, data.RoleEditor, data.ScopeTypeTeam, team.Id)
+	editorToken := app.NewToken(editor, data.ScopeRead, data.ScopeWrite)
+
+	viewer := app.NewUser("viewer@example.com")
+	app.Bind(viewer, data.RoleViewer, data.ScopeTypeTeam, team.Id)
+	viewerToken := app.NewToken(viewer, data.ScopeRead, data.ScopeWrite)
+
+	body := `{
+		"name": "Weekly upgrade",
+		"schedule": "0 2 * * SUN",
+		"timezone": "Europe/Berlin",
+		"duration": "2h",
+		"matchers": ["severity=~\"warning|critical\""],
+		"cluster_selector": {"env": "staging"},
+		"comment": "Node upgrades"
+	}`
+
+	serveAlertmanagerRequest(t, app, viewerToken, http.MethodPost, "/api/v1/maintenance-windows", body, http.StatusForbidden)
+
+	rec := serveAlertmanagerRequest(t, app, editorToken, http.MethodPost, "/api/v1/maintenance-windows", body, http.StatusCreated)
+
+	var resp struct {
+		MaintenanceWindow data.MaintenanceWindow `json:"maintenance_window"`
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response body; %v", err)
+	}
+
+	window := resp.MaintenanceWindow
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	if next := window.NextRunAt; next == nil ||
+		next.In(berlin).Weekday() != time.Sunday || next.In(berlin).Hour() != 2 || !next.After(time.Now()) {
+		t.Fatalf("expected next run on Sunday 2 AM in Berlin, got %v", next)
+	}
+
+	serveAlertmanagerRequest(t, app, editorToken, http.MethodPost, "/api/v1/maintenance-windows", body, http.StatusConflict)
+
+	// Nothing is due yet.
+	if err := app.Scheduler().Run(time.Now()); err != nil {
+		t.Fatalf("failed to run scheduler; %v", err)
+	}
+
+	if len(amStaging.Silences()) != 0 {
+		t.Fatalf("expected no silence before the window, got %+v", amStaging.Silences())
+	}
+
+	// The next occurrence starts in a minute.
+	now := time.Now().UTC()
+	start := now.Add(time.Minute).Truncate(time.Second)
+	window.NextRunAt = &start
+
+	stored, _ := app.MaintenanceWindows.GetById(window.Id)
+	stored.NextRunAt = &start
+	_ = app.MaintenanceWindows.Update(stored)
+
+	if err := app.Scheduler().Run(now); err != nil {
+		t.Fatalf("failed to run scheduler; %v", err)
+	}
+
+	created := amStaging.Silences()
+	if len(created) != 1 || !created[0].StartsAt.Equal(start) || created[0].EndsAt.Sub(created[0].StartsAt) != 2*time.Hour {
+		t.Fatalf("expected a 2h silence starting with the window, got %+v", created)
+	}
+
+	if len(amProd.Silences()) != 0 {
+		t.Fatal("expected no silence on the production alertmanager")
+	}
+
+	if !app.Logs.Contains("maintenance window fired") {
+		t.Fatal("expected maintenance window to be logged")
+	}
+
+	stored, _ = app.MaintenanceWindows.GetById(window.Id)
+	if stored.NextRunAt == nil || !stored.NextRunAt.After(start) {
+		t.Fatalf("expected window to advance past %v, got %v", start, stored.NextRunAt)
+	}
+
+	// Another replica does not run while the lease is held, and once it
+	// expires it does not silence the same occurrence again.
+	replica := core.NewScheduler(app, 0)
+
+	stored.NextRunAt = &start
+	_ = app.MaintenanceWindows.Update(stored)
+
+	if err := replica.Run(now); err != nil {
+		t.Fatalf("failed to run replica scheduler; %v", err)
+	}
+
+	if stored, _ := app.MaintenanceWindows.GetById(window.Id); !stored.NextRunAt.Equal(start) {
+		t.Fatal("expected replica not to run while the lease is held")
+	}
+
+	app.Leases.Expire("scheduler")
+
+	if err := replica.Run(now); err != nil {
+		t.Fatalf("failed to run replica scheduler; %v", err)
+	}
+
+	if len(amStaging.Silences()) != 1 {
+		t.Fatalf("expected occurrence to be silenced once, got %d silences", len(amStaging.Silences()))
+	}
+
+	if stored, _ := app.MaintenanceWindows.GetById(window.Id); !stored.NextRunAt.After(start) {
+		t.Fatal("expected replica to advance the window")
+	}
+
+	url := "/api/v1/maintenance-windows/" + window.Id
+
+	rec = serveAlertmanagerRequest(t, app, viewerToken, http.MethodGet, url, "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"name":"Weekly upgrade"`, `"timezone":"Europe/Berlin"`})
+
+	rec = serveAlertmanagerRequest(t, app, viewerToken, http.MethodGet, "/api/v1/maintenance-windows", "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"id":"` + window.Id + `"`})
+
+	// Disabling the window expires the silence of its upcoming occurrence.
+	serveAlertmanagerRequest(t, app, viewerToken, http.MethodPut, url, `{"enabled": false}`, http.StatusForbidden)
+
+	rec = serveAlertmanagerRequest(t, app, editorToken, http.MethodPut, url, `{"enabled": false}`, http.StatusOK)
+	testBodyContent(t, rec, []string{`"enabled":false`, `"next_run_at":null`})
+
+	if silences := amStaging.Silences(); !silences[0].Expired {
+		t.Fatalf("expected silence to be expired, got %+v", silences[0])
+	}
+
+	events := app.Audit.Events()
+	if last := events[len(events)-1]; last.ResourceType != "maintenance_window" || last.Before == nil {
+		t.Fatalf("expected maintenance window update to be audited, got %+v", last)
+	}
+
+	rec = serveAlertmanagerRequest(t, app, editorToken, http.MethodPut, url, `{"enabled": true}`, http.StatusOK)
+	testBodyContent(t, rec, []string{`"enabled":true`, `"next_run_at":"`})
+
+	serveAlertmanagerRequest(t, app, editorToken, http.MethodDelete, url, "", http.StatusNoContent)
+	serveAlertmanagerRequest(t, app, viewerToken, http.MethodGet, url, "", http.StatusNotFound)
+}
+
+func TestMaintenanceWindowsMissed(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	cluster := app.NewCluster(team, "payments-staging", map[string]string{"env": "staging"})
+
+	am := tests.NewAlertmanager("")
+	defer am.Close()
+
+	cluster.AlertmanagerUrl = am.Server.URL
+	_ = app.Clusters.Update(cluster)
+
+	// The window last occurred while no replica was running.
+	start := time.Now().UTC().Add(-3 * time.Hour).Truncate(time.Second)
+
+	window := &data.MaintenanceWindow{
+		TenantId:        team.TenantId,
+		Name:            "Nightly backup",
+		ScheduleKind:    "rrule",
+		Schedule:        "FREQ=DAILY;BYHOUR=1;BYMINUTE=0;BYSECOND=0",
+		Timezone:        "UTC",
+		Duration:        "1h",
+		Matchers:        []string{`alertname="BackupRunning"`},
+		ClusterSelector: map[string]string{"env": "staging"},
+		Comment:         "Backups",
+		Enabled:         true,
+		NextRunAt:       &start,
+	}
+
+	if err := app.MaintenanceWindows.Insert(window); err != nil {
+		t.Fatalf("failed to insert maintenance window; %v", err)
+	}
+
+	if err := app.Scheduler().Run(time.Now()); err != nil {
+		t.Fatalf("failed to run scheduler; %v", err)
+	}
+
+	if len(am.Silences()) != 0 {
+		t.Fatalf("expected missed occurrence not to be silenced, got %+v", am.Silences())
+	}
+
+	if !app.Logs.Contains("maintenance window occurrence missed") {
+		t.Fatal("expected missed occurrence to be logged")
+	}
+
+	stored, _ := app.MaintenanceWindows.GetById(window.Id)
+	if stored.NextRunAt == nil || !stored.NextRunAt.After(time.Now()) || stored.NextRunAt.Hour() != 1 {
+		t.Fatalf("expected window to advance to the next 1 AM, got %v", stored.NextRunAt)
+	}
+}
+
+func TestMaintenanceWindowsSlowAlertmanager(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	cluster := app.NewCluster(team, "payments-staging", map[string]string{"env": "staging"})
+
+	am := tests.NewAlertmanager("")
+	defer am.Close()
+
+	am.SetDelay(time.Minute)
+
+	cluster.AlertmanagerUrl = am.Server.URL
+	_ = app.Clusters.Update(cluster)
+
+	now := time.Now().UTC()
+	start := now.Add(time.Minute).Truncate(time.Second)
+
+	for _, name := range []string{"Node upgrades", "Backups", "Certificates"} {
+		window := &data.MaintenanceWindow{
+			TenantId:        team.TenantId,
+			Name:            name,
+			ScheduleKind:    "cron",
+			Schedule:        "0 2 * * SUN",
+			Timezone:        "UTC",
+			Duration:        "1h",
+			Matchers:        []string{`severity="warning"`},
+			ClusterSelector: map[string]string{"env": "staging"},
+			Comment:         name,
+			Enabled:         true,
+			NextRunAt:       &start,
+		}
+
+		if err := app.MaintenanceWindows.Insert(window); err != nil {
+			t.Fatalf("failed to insert maintenance window; %v", err)
+		}
+	}
+
+	// The whole run is bounded by the interval, however many silences wait
+	// on the Alertmanager, so that the heartbeat of the scheduler is not.
+	interval := 200 * time.Millisecond
+	scheduler := core.NewScheduler(app, interval)
+
+	began := time.Now()
+
+	if err := scheduler.Run(now); err != nil {
+		t.Fatalf("failed to run scheduler; %v", err)
+	}
+
+	if elapsed := time.Since(began); elapsed > 2*interval {
+		t.Fatalf("expected run to be bounded by %s, took %s", interval, elapsed)
+	}
+
+	if len(am.Silences()) != 0 {
+		t.Fatalf("expected no silence on the slow alertmanager, got %+v", am.Silences())
+	}
+
+	// The silences are retried once the Alertmanager answers again.
+	am.SetDelay(0)
+
+	if err := scheduler.Run(now); err != nil {
+		t.Fatalf("failed to run scheduler; %v", err)
+	}
+
+	if len(am.Silences()) != 3 {
+		t.Fatalf("expected the silences to be retried, got %d silences", len(am.Silences()))
+	}
+}
+
+func TestMaintenanceWindowsValidation(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	app.NewCluster(team, "payments-staging", map[string]string{"env": "staging"})
+
+	editor := app.NewUser("editor@example.com")
+	app.Bind(editor, data.RoleEditor, data.ScopeTypeTeam, team.Id)
+	token := app.NewToken(editor, data.ScopeRead, data.ScopeWrite)
+
+	testCases := []struct {
+		name string
+		body string
+		msg  string
+	}{
+		{"no name", `{"schedule": "@daily", "duration": "1h", "matchers": ["a=\"b\""], "comment": "c"}`, "name must not be empty"},
+		{"unknown kind", `{"name": "w", "schedule_kind": "at", "schedule": "@daily", "duration": "1h", "matchers": ["a=\"b\""], "comment": "c"}`, "Schedule kind must be one of"},
+		{"invalid cron", `{"name": "w", "schedule": "0 2 * *", "duration": "1h", "matchers": ["a=\"b\""], "comment": "c"}`, "Invalid schedule"},
+		{"invalid rrule", `{"name": "w", "schedule_kind": "rrule", "schedule": "FREQ=SOMETIMES", "duration": "1h", "matchers": ["a=\"b\""], "comment": "c"}`, "Invalid schedule"},
+		{"invalid timezone", `{"name": "w", "schedule": "@daily", "timezone": "Mars/Olympus", "duration": "1h", "matchers": ["a=\"b\""], "comment": "c"}`, "Invalid timezone"},
+		{"invalid duration", `{"name": "w", "schedule": "@daily", "duration": "0h", "matchers": ["a=\"b\""], "comment": "c"}`, "Invalid duration"},
+		{"no matchers", `{"name": "w", "schedule": "@daily", "duration": "1h", "comment": "c"}`, "At least one matcher must be given"},
+		{"no comment", `{"name": "w", "schedule": "@daily", "duration": "1h", "matchers": ["a=\"b\""]}`, "Comment must be provided"},
+		{"no cluster", `{"name": "w", "schedule": "@daily", "duration": "1h", "matchers": ["a=\"b\""], "cluster_selector": {"env": "production"}, "comment": "c"}`, "No cluster matches the cluster selector"},
+		{"no occurrence", `{"name": "w", "schedule_kind": "rrule", "schedule": "DTSTART:20200101T000000Z\nFREQ=DAILY;COUNT=1", "duration": "1h", "matchers": ["a=\"b\""], "comment": "c"}`, "no upcoming occurrence"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveAlertmanagerRequest(t, app, token, http.MethodPost, "/api/v1/maintenance-windows", tc.body, http.StatusBadRequest)
+			testBodyContent(t, rec, []string{tc.msg})
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package apis
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+)
+
+// metrics serves the metrics of the app in the Prometheus exposition
+// format.
+func metrics(e *core.EventRequest) {
+	e.App.Metrics().Handler().ServeHTTP(e.Response, e.Request)
+}
+
+// instrumented counts the requests and observes their duration, labelled by
+// the pattern of the route rather than the path, so that the number of
+// series stays bounded.
+func instrumented(next func(*core.EventRequest)) func(*core.EventRequest) {
+	return func(e *core.EventRequest) {
+		m := e.App.Metrics()
+		method, route, _ := strings.Cut(e.Request.Pattern, " ")
+
+		m.HttpRequestsInFlight.Inc()
+		defer m.HttpRequestsInFlight.Dec()
+
+		sw := &statusResponseWriter{ResponseWriter: e.Response, status: http.StatusOK}
+		e.Response = sw
+
+		start := time.Now()
+
+		next(e)
+
+		m.HttpRequests.WithLabelValues(method, route, strconv.Itoa(sw.status)).Inc()
+		m.HttpRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,44 @@
+package apis
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	for _, url := range []string{"/api/v1/health", "/api/v1/health", "/api/v1/clusters/unknown"} {
+		serveTestRequest(app, httptest.NewRequest(http.MethodGet, url, nil))
+	}
+
+	if err := app.AlertPoller().Poll(context.Background()); err != nil {
+		t.Fatalf("failed to poll alerts; %v", err)
+	}
+
+	rec := serveTestRequest(app, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	testBodyContent(t, rec, []string{
+		// The requests are labelled by the pattern of their route. The
+		// workers are not running, so the health checks fail.
+		`scopehouse_http_requests_total{method="GET",route="/api/v1/health",status="503"} 2`,
+		`scopehouse_http_requests_total{method="GET",route="/api/v1/clusters/{id}",status="401"} 1`,
+		`scopehouse_http_request_duration_seconds_count{method="GET",route="/api/v1/health"} 2`,
+		`scopehouse_http_requests_in_flight 1`,
+		`scopehouse_alert_poll_duration_seconds_count 1`,
+		`go_sql_max_open_connections{db_name="scopehouse"}`,
+		`go_goroutines`,
+	})
+}
@@ -0,0 +1,246 @@
+package apis
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/oidc"
+)
+
+const (
+	oidcCookieName = "sh_oidc"
+	oidcCookiePath = "/api/v1/auth/oidc"
+	oidcCookieTTL  = time.Minute * 10
+
+	// oidcLoginRedirect is where users land once signed in.
+	oidcLoginRedirect = "/"
+)
+
+// oidcLogin starts the authorization code flow. The state, nonce and PKCE
+// verifier are kept in a short-lived cookie until the provider redirects the
+// user back to the callback.
+func oidcLogin(e *core.EventRequest) {
+	provider := e.App.OIDC()
+	if provider == nil {
+		notFound(e, "Single sign-on is not configured.")
+		return
+	}
+
+	state, err := randomString()
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	verifier := oauth2.GenerateVerifier()
+
+	http.SetCookie(e.Response, &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    strings.Join([]string{state, nonce, verifier}, "."),
+		Path:     oidcCookiePath,
+		MaxAge:   int(oidcCookieTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   e.App.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(e.Response, e.Request, provider.AuthCodeUrl(state, nonce, verifier), http.StatusFound)
+}
+
+// oidcCallback completes the authorization code flow, signing in the user
+// with the email of the verified id token. Unknown users are provisioned,
+// and the roles of every user are synced from their groups on each sign-in.
+func oidcCallback(e *core.EventRequest) {
+	provider := e.App.OIDC()
+	if provider == nil {
+		notFound(e, "Single sign-on is not configured.")
+		return
+	}
+
+	cookie, err := e.Request.Cookie(oidcCookieName)
+	if err != nil {
+		badRequest(e, "Single sign-on session not found, please sign in again.")
+		return
+	}
+
+	clearOIDCCookie(e)
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 {
+		badRequest(e, "Single sign-on session not found, please sign in again.")
+		return
+	}
+
+	state, nonce, verifier := parts[0], parts[1], parts[2]
+
+	query := e.Request.URL.Query()
+
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		badRequest(e, "Invalid single sign-on state.")
+		return
+	}
+
+	if errCode := query.Get("error"); errCode != "" {
+		e.App.Logger().WarnContext(e.Request.Context(), "oidc sign-in rejected by provider",
+			slog.String("error", errCode),
+			slog.String("description", query.Get("error_description")),
+		)
+		unauthorized(e, "Single sign-on failed.")
+		return
+	}
+
+	identity, err := provider.Exchange(e.Request.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrEmailNotVerified):
+			forbidden(e, "Single sign-on email address must be verified.")
+		default:
+			e.App.Logger().WarnContext(e.Request.Context(), "oidc sign-in failed", slog.String("error", err.Error()))
+			unauthorized(e, "Single sign-on failed.")
+		}
+		return
+	}
+
+	user, err := oidcUser(e, identity, provider.Roles(identity))
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	session, err := data.NewSession(user.Id, sessionTTL)
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	if err := e.Models().Sessions.Insert(session); err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	setSessionCookie(e, session)
+
+	http.Redirect(e.Response, e.Request, oidcLoginRedirect, http.StatusFound)
+}
+
+// oidcUser returns the user with the identity email, provisioning it when
+// it does not exist yet. Since the provider verified the email, the account
+// is activated. The password of an account that was not activated yet is
+// removed: nobody proved owning its email, so it may have been registered
+// by someone else to take over the account once linked.
+//
+// The roles synced from the groups are the global roles of the user, which
+// cover every team and cluster of its own tenant only. Provisioned users
+// join the default tenant. The changes are recorded as model changes of
+// the user.
+func oidcUser(e *core.EventRequest, identity *oidc.Identity, roles []string) (*data.User, error) {
+	users := e.Models().Users
+
+	user, err := users.GetByEmail(identity.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			user = &data.User{
+				Email:       identity.Email,
+				IsActivated: true,
+				Roles:       roles,
+			}
+
+			if err := users.Insert(user); err != nil {
+				return nil, err
+			}
+
+			e.App.Logger().InfoContext(e.Request.Context(), "oidc user provisioned",
+				slog.String("user_id", user.Id),
+				slog.String("subject", identity.Subject),
+			)
+
+			e.SetChange(core.ModelCreate, "user", user.Id, nil, auditUser(user))
+
+			return user, nil
+		default:
+			return nil, err
+		}
+	}
+
+	if user.IsActivated && slices.Equal(user.Roles, roles) {
+		return user, nil
+	}
+
+	before := *user
+
+	if !user.IsActivated && user.PasswordHash != "" {
+		if err := users.SetPassword(user.Id, ""); err != nil {
+			return nil, err
+		}
+
+		user.PasswordHash = ""
+
+		e.App.Logger().InfoContext(e.Request.Context(), "oidc user password removed",
+			slog.String("user_id", user.Id),
+			slog.String("subject", identity.Subject),
+		)
+	}
+
+	user.IsActivated = true
+	user.Roles = roles
+
+	if err := users.Update(user); err != nil {
+		return nil, err
+	}
+
+	e.SetChange(core.ModelUpdate, "user", user.Id, auditUser(&before), auditUser(user))
+
+	return user, nil
+}
+
+// auditUser returns the user state recorded in the audit log, which tells
+// whether the user has a password, but leaves out its hash.
+func auditUser(user *data.User) any {
+	return struct {
+		*data.User
+		HasPassword bool `json:"has_password"`
+	}{
+		User:        user,
+		HasPassword: user.PasswordHash != "",
+	}
+}
+
+func clearOIDCCookie(e *core.EventRequest) {
+	http.SetCookie(e.Response, &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    "",
+		Path:     oidcCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   e.App.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// randomString returns 32 random bytes encoded as unpadded base64url.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,321 @@
+package apis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+// oidcSignIn runs the sign-in flow against the issuer, and returns the
+// response of the callback request.
+func oidcSignIn(t *testing.T, app *tests.TestApp, tamperState bool) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/login", nil)
+	rec := serveTestRequest(app, req)
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusFound, rec.Code)
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oidcCookieName {
+		t.Fatalf("expected oidc cookie, got %v", cookies)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to request issuer authorization; %v", err)
+	}
+	_ = res.Body.Close()
+
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to parse issuer redirect; %v", err)
+	}
+
+	if tamperState {
+		query := callback.Query()
+		query.Set("state", "tampered")
+		callback.RawQuery = query.Encode()
+	}
+
+	req = httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	req.AddCookie(cookies[0])
+
+	return serveTestRequest(app, req)
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	t.Parallel()
+
+	issuer := tests.NewOIDCIssuer()
+	defer issuer.Close()
+
+	provider := issuer.Provider(map[string]string{
+		"sre":  "admin",
+		"devs": "editor",
+	})
+
+	testCases := []struct {
+		name        string
+		identity    tests.OIDCIdentity
+		existing    bool
+		activated   bool
+		tamperState bool
+		status      int
+		content     []string
+		roles       []string
+	}{
+		{
+			name: "new user",
+			identity: tests.OIDCIdentity{
+				Subject:       "1",
+				Email:         "Test@Example.com",
+				EmailVerified: true,
+				Groups:        []string{"devs", "sre", "other"},
+			},
+			status: http.StatusFound,
+			roles:  []string{"admin", "editor"},
+		},
+		{
+			name: "existing user",
+			identity: tests.OIDCIdentity{
+				Subject:       "1",
+				Email:         "test@example.com",
+				EmailVerified: true,
+				Groups:        []string{"devs"},
+			},
+			existing:  true,
+			activated: true,
+			status:    http.StatusFound,
+			roles:     []string{"editor"},
+		},
+		{
+			name: "existing user not activated",
+			identity: tests.OIDCIdentity{
+				Subject:       "1",
+				Email:         "test@example.com",
+				EmailVerified: "true",
+				Groups:        []string{"devs"},
+			},
+			existing: true,
+			status:   http.StatusFound,
+			roles:    []string{"editor"},
+		},
+		{
+			name: "unverified email",
+			identity: tests.OIDCIdentity{
+				Subject:       "1",
+				Email:         "test@example.com",
+				EmailVerified: false,
+			},
+			status:  http.StatusForbidden,
+			content: []string{`"message":"Single sign-on email address must be verified."`},
+		},
+		{
+			name: "missing email verification",
+			identity: tests.OIDCIdentity{
+				Subject: "1",
+				Email:   "test@example.com",
+			},
+			status:  http.StatusForbidden,
+			content: []string{`"message":"Single sign-on email address must be verified."`},
+		},
+		{
+			name: "state mismatch",
+			identity: tests.OIDCIdentity{
+				Subject:       "1",
+				Email:         "test@example.com",
+				EmailVerified: true,
+			},
+			tamperState: true,
+			status:      http.StatusBadRequest,
+			content:     []string{`"message":"Invalid single sign-on state."`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, err := tests.NewTestAppWithOIDC(provider)
+			if err != nil {
+				t.Fatalf("failed to initialize test app instance; %v", err)
+			}
+
+			if tc.existing {
+				user := app.NewUserWithPassword("test@example.com", "secret-password")
+				user.IsActivated = tc.activated
+				user.Roles = []string{"admin"}
+			}
+
+			// The issuer is shared, so the identity is set right before
+			// the flow starts, within the same sequential subtest.
+			issuer.SetIdentity(tc.identity)
+
+			rec := oidcSignIn(t, app, tc.tamperState)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status code to be %d, got %d", tc.status, rec.Code)
+			}
+
+			if tc.status != http.StatusFound {
+				testBodyContent(t, rec, tc.content)
+
+				if _, err := app.Users.GetByEmail("test@example.com"); !tc.existing && err == nil {
+					t.Fatal("expected user not to be provisioned")
+				}
+				return
+			}
+
+			var session *http.Cookie
+			for _, cookie := range rec.Result().Cookies() {
+				if cookie.Name == sessionCookieName {
+					session = cookie
+				}
+			}
+
+			if session == nil {
+				t.Fatal("expected session cookie to be set")
+			}
+
+			user, err := app.Users.GetByEmail("test@example.com")
+			if err != nil {
+				t.Fatalf("expected user to exist, got %v", err)
+			}
+
+			if !user.IsActivated {
+				t.Fatal("expected user to be activated")
+			}
+
+			if !slices.Equal(user.Roles, tc.roles) {
+				t.Fatalf("expected roles %v, got %v", tc.roles, user.Roles)
+			}
+
+			// The password of activated users is kept, the one of users who
+			// never proved owning the email is removed.
+			if tc.existing && tc.activated != (user.PasswordHash != "") {
+				t.Fatalf("expected password to be kept to be %v, got %v", tc.activated, !tc.activated)
+			}
+
+			// The provisioning, the roles synced and the password removed
+			// are recorded in the audit log.
+			events := app.Audit.Events()
+			change := events[len(events)-1]
+
+			if change.Route != "/api/v1/auth/oidc/callback" || change.ResourceType != "user" || change.ResourceId != user.Id {
+				t.Fatalf("expected user change to be audited, got %+v", change)
+			}
+
+			if tc.existing != (change.Before != nil) || strings.Contains(string(change.After), "password_hash") {
+				t.Fatalf("unexpected audited user change %s -> %s", change.Before, change.After)
+			}
+
+			if tc.existing && !tc.activated &&
+				(!strings.Contains(string(change.Before), `"has_password":true`) ||
+					!strings.Contains(string(change.After), `"has_password":false`)) {
+				t.Fatalf("expected password removal to be audited, got %s -> %s", change.Before, change.After)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/session", nil)
+			req.AddCookie(session)
+
+			if rec := serveTestRequest(app, req); rec.Code != http.StatusOK {
+				t.Fatalf("expected session to be valid, got status %d", rec.Code)
+			}
+		})
+	}
+}
+
+// TestOIDCGroupRoles covers the scope of the roles synced from the groups,
+// which are global to the tenant of the user only.
+func TestOIDCGroupRoles(t *testing.T) {
+	t.Parallel()
+
+	issuer := tests.NewOIDCIssuer()
+	defer issuer.Close()
+
+	app, err := tests.NewTestAppWithOIDC(issuer.Provider(map[string]string{"sre": "admin"}))
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	own := app.NewCluster(app.NewTeam("payments"), "payments-prod", nil)
+
+	tenant := app.NewTenant("search-bu")
+	other := app.NewCluster(app.NewTenantTeam(tenant.Id, "search"), "search-prod", nil)
+
+	issuer.SetIdentity(tests.OIDCIdentity{
+		Subject:       "1",
+		Email:         "sre@example.com",
+		EmailVerified: true,
+		Groups:        []string{"sre"},
+	})
+
+	rec := oidcSignIn(t, app, false)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusFound, rec.Code)
+	}
+
+	var session *http.Cookie
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == sessionCookieName {
+			session = cookie
+		}
+	}
+
+	if session == nil {
+		t.Fatal("expected session cookie to be set")
+	}
+
+	testCases := []struct {
+		url    string
+		status int
+	}{
+		{url: "/api/v1/clusters/" + own.Id, status: http.StatusOK},
+		{url: "/api/v1/clusters/" + other.Id, status: http.StatusNotFound},
+		{url: "/api/v1/tenants", status: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+		req.AddCookie(session)
+
+		if rec := serveTestRequest(app, req); rec.Code != tc.status {
+			t.Fatalf("expected status code of %s to be %d, got %d", tc.url, tc.status, rec.Code)
+		}
+	}
+}
+
+func TestOIDCNotConfigured(t *testing.T) {
+	t.Parallel()
+
+	scenarios := []apiTestScenario{
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/auth/oidc/login",
+			status:  http.StatusNotFound,
+			content: []string{`"message":"Single sign-on is not configured."`},
+		},
+		{
+			method:  http.MethodGet,
+			url:     "/api/v1/auth/oidc/callback?code=code&state=state",
+			status:  http.StatusNotFound,
+			content: []string{`"message":"Single sign-on is not configured."`},
+		},
+	}
+
+	for _, s := range scenarios {
+		s.test(t)
+	}
+}
@@ -0,0 +1,328 @@
+package apis
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+// access is the access policy of a route.
+type access struct {
+	// public routes can be requested anonymously.
+	public bool
+
+	// role is the minimum role the caller must be granted, any authenticated
+	// caller is allowed when empty.
+	role string
+
+	// resource resolves what the request acts on, the role must be granted
+	// on it. When nil, the role must be granted on any resource, and the
+	// handler is expected to check the role on the resource it acts on.
+	resource func(e *core.EventRequest) (data.Resource, bool)
+
+	// global routes act across the tenants, e.g. the ones managing them, so
+	// their models are not scoped to the tenant of the caller.
+	global bool
+
+	// audit routes are recorded to the audit log whatever their method,
+	// since their handlers change state on safe methods, e.g. the single
+	// sign-on callback provisioning users.
+	audit bool
+}
+
+var (
+	// publicAccess allows anonymous requests.
+	publicAccess = access{public: true}
+
+	// publicAuditedAccess allows anonymous requests, and records them to
+	// the audit log.
+	publicAuditedAccess = access{public: true, audit: true}
+
+	// authAccess allows any authenticated request.
+	authAccess = access{}
+)
+
+// roleAccess requires the caller to be granted the role on the resource.
+func roleAccess(role string, resource func(e *core.EventRequest) (data.Resource, bool)) access {
+	return access{role: role, resource: resource}
+}
+
+// globalAccess requires the caller to be granted the role on the resource,
+// and leaves the models of the route unscoped.
+func globalAccess(role string, resource func(e *core.EventRequest) (data.Resource, bool)) access {
+	return access{role: role, resource: resource, global: true}
+}
+
+// authorize wraps the route handler with the checks of its access policy.
+// Authenticated requests are scoped to the tenant of their user before the
+// checks run, so that they cannot see the data of other tenants, unless the
+// route is global.
+func authorize(policy access, next func(*core.EventRequest)) func(*core.EventRequest) {
+	if policy.public {
+		return next
+	}
+
+	check := func(e *core.EventRequest) {
+		if policy.role == "" {
+			next(e)
+			return
+		}
+
+		if policy.resource == nil {
+			if !allowAnywhere(e, policy.role) {
+				return
+			}
+			next(e)
+			return
+		}
+
+		resource, ok := policy.resource(e)
+		if !ok {
+			return
+		}
+
+		if !allow(e, policy.role, resource) {
+			return
+		}
+
+		next(e)
+	}
+
+	if policy.global {
+		return requireAuth(check)
+	}
+
+	return requireAuth(scopeTenant(check))
+}
+
+// allow reports whether the caller is granted the role on the resource, and
+// responds with a 403 error when it is not.
+func allow(e *core.EventRequest, role string, resource data.Resource) bool {
+	permissions, ok := loadPermissions(e)
+	if !ok {
+		return false
+	}
+
+	if !permissions.Allows(role, resource) {
+		accessDenied(e, role, resource)
+		return false
+	}
+
+	return true
+}
+
+// allowAnywhere reports whether the caller is granted the role on any
+// resource, and responds with a 403 error when it is not.
+func allowAnywhere(e *core.EventRequest, role string) bool {
+	permissions, ok := loadPermissions(e)
+	if !ok {
+		return false
+	}
+
+	if !permissions.AllowsAnywhere(role) {
+		accessDenied(e, role, data.Resource{})
+		return false
+	}
+
+	return true
+}
+
+// allowedClusters returns whether the caller is granted the role on each
+// cluster of the tenant, by cluster id, so that the resources spanning
+// clusters can be filtered. Deleted clusters are missing from it.
+func allowedClusters(e *core.EventRequest, role string) (map[string]bool, bool) {
+	permissions, ok := loadPermissions(e)
+	if !ok {
+		return nil, false
+	}
+
+	clusters, err := core.SelectClusters(e.Models(), nil)
+	if err != nil {
+		internalServerError(e, err)
+		return nil, false
+	}
+
+	allowed := make(map[string]bool, len(clusters))
+	for _, cluster := range clusters {
+		allowed[cluster.Id] = permissions.Allows(role, cluster.Resource())
+	}
+
+	return allowed, true
+}
+
+func loadPermissions(e *core.EventRequest) (*data.Permissions, bool) {
+	if e.Permissions != nil {
+		return e.Permissions, true
+	}
+
+	bindings, err := e.Models().RoleBindings.ListForUser(e.Auth.Id)
+	if err != nil {
+		internalServerError(e, err)
+		return nil, false
+	}
+
+	e.Permissions = data.NewPermissions(e.Auth, e.Token, bindings)
+
+	return e.Permissions, true
+}
+
+func accessDenied(e *core.EventRequest, role string, resource data.Resource) {
+	attrs := []any{
+		slog.String("user_id", e.Auth.Id),
+		slog.String("role", role),
+		slog.String("method", e.Request.Method),
+		slog.String("request", e.Request.RequestURI),
+	}
+
+	if e.Token != nil {
+		attrs = append(attrs, slog.String("token_id", e.Token.Id))
+	}
+
+	if resource.TeamId != "" {
+		attrs = append(attrs, slog.String("team_id", resource.TeamId))
+	}
+
+	if resource.ClusterId != "" {
+		attrs = append(attrs, slog.String("cluster_id", resource.ClusterId))
+	}
+
+	e.App.Logger().WarnContext(e.Request.Context(), "access denied", attrs...)
+
+	forbidden(e, fmt.Sprintf("You must have the %q role to access this resource.", role))
+}
+
+// globalResource is the resource of routes acting on the whole service.
+func globalResource(*core.EventRequest) (data.Resource, bool) {
+	return data.Resource{}, true
+}
+
+// teamResource resolves the team of the request path.
+func teamResource(e *core.EventRequest) (data.Resource, bool) {
+	team, err := e.Models().Teams.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Team not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return data.Resource{}, false
+	}
+
+	return data.Resource{TeamId: team.Id}, true
+}
+
+// clusterResource resolves the cluster of the request path, along with the
+// team owning it.
+func clusterResource(e *core.EventRequest) (data.Resource, bool) {
+	cluster, ok := loadCluster(e)
+	if !ok {
+		return data.Resource{}, false
+	}
+
+	return cluster.Resource(), true
+}
+
+// roleBindingResource resolves the scope of the role binding of the request
+// path, so that bindings can be managed by the admins of their scope.
+func roleBindingResource(e *core.EventRequest) (data.Resource, bool) {
+	binding, err := e.Models().RoleBindings.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Role binding not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return data.Resource{}, false
+	}
+
+	resource := binding.Resource()
+
+	// Include the owning team, unless the cluster was deleted meanwhile.
+	if resource.ClusterId != "" {
+		cluster, err := e.Models().Clusters.GetById(resource.ClusterId)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			internalServerError(e, err)
+			return data.Resource{}, false
+		}
+		if cluster != nil {
+			resource = cluster.Resource()
+		}
+	}
+
+	return resource, true
+}
+
+// changeRequestResource resolves the cluster of the change request of the
+// request path. Requests of deleted clusters require global roles.
+func changeRequestResource(e *core.EventRequest) (data.Resource, bool) {
+	cr, ok := loadChangeRequest(e)
+	if !ok {
+		return data.Resource{}, false
+	}
+
+	if cr.ClusterId == "" {
+		return data.Resource{}, true
+	}
+
+	cluster, err := e.Models().Clusters.GetById(cr.ClusterId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return data.Resource{}, true
+		default:
+			internalServerError(e, err)
+		}
+		return data.Resource{}, false
+	}
+
+	return cluster.Resource(), true
+}
+
+// scopeResource validates a role binding scope, and resolves it to the
+// resource it is bound to.
+func scopeResource(e *core.EventRequest, scopeType string, scopeId string) (data.Resource, bool) {
+	switch scopeType {
+	case data.ScopeTypeGlobal:
+		if scopeId != "" {
+			badRequest(e, "Global role bindings must not have a scope id.")
+			return data.Resource{}, false
+		}
+		return data.Resource{}, true
+	case data.ScopeTypeTeam:
+		team, err := e.Models().Teams.GetById(scopeId)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				badRequest(e, fmt.Sprintf("Team %q not found.", scopeId))
+			default:
+				internalServerError(e, err)
+			}
+			return data.Resource{}, false
+		}
+		return data.Resource{TeamId: team.Id}, true
+	case data.ScopeTypeCluster:
+		cluster, err := e.Models().Clusters.GetById(scopeId)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				badRequest(e, fmt.Sprintf("Cluster %q not found.", scopeId))
+			default:
+				internalServerError(e, err)
+			}
+			return data.Resource{}, false
+		}
+		return cluster.Resource(), true
+	default:
+		badRequest(e, fmt.Sprintf(
+			"Invalid scope type %q, must be one of %s.",
+			scopeType, strings.Join(data.ScopeTypes, ", "),
+		))
+		return data.Resource{}, false
+	}
+}
@@ -0,0 +1,492 @@
+package apis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+// rbacFixture is a team "payments" owning a production cluster, and a team
+// "search" owning a staging cluster.
+type rbacFixture struct {
+	payments        *data.Team
+	search          *data.Team
+	paymentsCluster *data.Cluster
+	searchCluster   *data.Cluster
+}
+
+func newRbacFixture(app *tests.TestApp) rbacFixture {
+	f := rbacFixture{
+		payments: app.NewTeam("payments"),
+		search:   app.NewTeam("search"),
+	}
+
+	f.paymentsCluster = app.NewCluster(f.payments, "payments-prod", map[string]string{"env": "production"})
+	f.searchCluster = app.NewCluster(f.search, "search-staging", map[string]string{"env": "staging"})
+
+	return f
+}
+
+func TestRbac(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		bind    func(app *tests.TestApp, user *data.User, f rbacFixture)
+		method  string
+		url     func(f rbacFixture) string
+		body    func(f rbacFixture) string
+		status  int
+		content []string
+	}{
+		{
+			name:   "no role",
+			method: http.MethodGet,
+			url:    func(f rbacFixture) string { return "/api/v1/clusters" },
+			status: http.StatusForbidden,
+			content: []string{
+				`"message":"You must have the \"viewer\" role to access this resource."`,
+			},
+		},
+		{
+			name: "team viewer reads own cluster",
+			bind: func(app *tests.TestApp, user *data.User, f rbacFixture) {
+				app.Bind(user, data.RoleViewer, data.ScopeTypeTeam, f.payments.Id)
+			},
+			method:  http.MethodGet,
+			url:     func(f rbacFixture) string { return "/api/v1/clusters/" + f.paymentsCluster.Id },
+			status:  http.StatusOK,
+			content: []string{`"name":"payments-prod"`},
+		},
+		{
+			name: "team viewer cannot read other team cluster",
+			bind: func(app *tests.TestApp, user *data.User, f rbacFixture) {
+				app.Bind(user, data.RoleViewer, data.ScopeTypeTeam, f.payments.Id)
+			},
+			method: http.MethodGet,
+			url:    func(f rbacFixture) string { return "/api/v1/clusters/" + f.searchCluster.Id },
+			status: http.StatusForbidden,
+		},
+		{
+			name: "team editor cannot modify own cluster",
+			bind: func(app *tests.TestApp, user *data.User, f rbacFixture) {
+				app.Bind(user, data.RoleEditor, data.ScopeTypeTeam, f.search.Id)
+			},
+			method: http.MethodPut,
+			url:    func(f rbacFixture) string { return "/api/v1/clusters/" + f.searchCluster.Id },
+			body:   func(rbacFixture) string { return `{"labels":{"env":"production"}}` },
+			status: http.StatusForbidden,
+			content: []string{
+				`"message":"You must have the \"admin\" role to access this resource."`,
+			},
+		},
+		{
+			name: "team admin modifies own cluster",
+			bind: func(app *tests.TestApp, user *data.User, f rbacFixture) {
+				app.Bind(user, data.RoleAdmin, data.ScopeTypeTeam, f.search.Id)
+			},
+			method:  http.MethodPut,
+			url:     func(f rbacFixture) string { return "/api/v1/clusters/" + f.searchCluster.Id },
+			body:    func(rbacFixture) string { return `{"labels":{"env":"qa"}}` },
+			status:  http.StatusOK,
+			content: []string{`"labels":{"env":"qa"}`},
+		},
+		{
+			name: "team admin cannot modify other team production cluster",
+			bind: func(app *tests.TestApp, user *data.User, f rbacFixture) {
+				app.Bind(user, data.RoleAdmin, data.ScopeTypeTeam, f.search.Id)
+			},
+			method: http.MethodDelete,
+			url:    func(f rbacFixture) string { return "/api/v1/clusters/" + f.paymentsCluster.Id },
+			status: http.StatusForbidden,
+		},
+		{
+			name: "cluster admin cannot add clusters to the team",
+			bind: func(app *tests.TestApp, user *data.User, f rbacFixture) {
+				app.Bind(user, data.RoleAdmin, data.ScopeTypeCluster, f.searchCluster.Id)
+			},
+			method: http.MethodPost,
+			url:    func(rbacFixture) string { return "/api/v1/clusters" },
+			body: func(f rbacFixture) string {
+				return `{"name":"search-prod","team_id":"` + f.search.Id + `"}`
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name: "team admin adds clusters to own team",
+			bind: func(app *tests.TestApp, user *data.User, f rbacFixture) {
+				app.Bind(user, data.RoleAdmin, data.ScopeTypeTeam, f.search.Id)
+			},
+			method: http.MethodPost,
+			url:    func(rbacFixture) string { return "/api/v1/clusters" },
+			body: func(f rbacFixture) string {
+				return `{"name":"search-prod","team_id":"` + f.search.Id + `"}`
+			},
+			status:  http.StatusCreated,
+			content: []string{`"name":"search-prod"`},
+		},
+		{
+			name: "team admin cannot create teams",
+			bind: func(app *tests.TestApp, user *data.User, f rbacFixture) {
+				app.Bind(user, data.RoleAdmin, data.ScopeTypeTeam, f.search.Id)
+			},
+			method: http.MethodPost,
+			url:    func(rbacFixture) string { return "/api/v1/teams" },
+			body:   func(rbacFixture) string { return `{"name":"billing"}` },
+			status: http.StatusForbidden,
+		},
+		{
+			name: "global role from single sign-on",
+			bind: func(_ *tests.TestApp, user *data.User, _ rbacFixture) {
+				user.Roles = []string{data.RoleAdmin}
+			},
+			method:  http.MethodPost,
+			url:     func(rbacFixture) string { return "/api/v1/teams" },
+			body:    func(rbacFixture) string { return `{"name":"billing"}` },
+			status:  http.StatusCreated,
+			content: []string{`"name":"billing"`},
+		},
+		{
+			name:   "unknown cluster",
+			bind:   func(_ *tests.TestApp, user *data.User, _ rbacFixture) { user.Roles = []string{data.RoleAdmin} },
+			method: http.MethodGet,
+			url:    func(rbacFixture) string { return "/api/v1/clusters/unknown" },
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, err := tests.NewTestApp()
+			if err != nil {
+				t.Fatalf("failed to initialize test app instance; %v", err)
+			}
+
+			f := newRbacFixture(app)
+			user := app.NewUser("test@example.com")
+
+			if tc.bind != nil {
+				tc.bind(app, user, f)
+			}
+
+			body := ""
+			if tc.body != nil {
+				body = tc.body(f)
+			}
+
+			req := httptest.NewRequest(tc.method, tc.url(f), strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+app.NewToken(user, data.ScopeRead, data.ScopeWrite))
+
+			rec := serveTestRequest(app, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status code to be %d, got %d", tc.status, rec.Code)
+			}
+
+			if len(tc.content) > 0 {
+				testBodyContent(t, rec, tc.content)
+			}
+
+			if tc.status == http.StatusForbidden && !app.Logs.Contains("access denied", "user_id="+user.Id) {
+				t.Fatal("expected access denial to be logged")
+			}
+		})
+	}
+}
+
+func TestRbacLists(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	f := newRbacFixture(app)
+
+	viewer := app.NewUser("viewer@example.com")
+	app.Bind(viewer, data.RoleViewer, data.ScopeTypeTeam, f.payments.Id)
+	token := app.NewToken(viewer, data.ScopeRead)
+
+	// A viewer of a team only lists the team and its clusters.
+	testCases := []struct {
+		url      string
+		listed   string
+		unlisted string
+	}{
+		{"/api/v1/teams", f.payments.Id, f.search.Id},
+		{"/api/v1/clusters", f.paymentsCluster.Id, f.searchCluster.Id},
+	}
+
+	for _, tc := range testCases {
+		rec := serveAlertmanagerRequest(t, app, token, http.MethodGet, tc.url, "", http.StatusOK)
+		testBodyContent(t, rec, []string{tc.listed})
+
+		if strings.Contains(rec.Body.String(), tc.unlisted) {
+			t.Fatalf("expected %s not to list %s, got %s", tc.url, tc.unlisted, rec.Body.String())
+		}
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	f := newRbacFixture(app)
+
+	admin := app.NewUser("admin@example.com")
+	admin.Roles = []string{data.RoleAdmin}
+	token := app.NewToken(admin, data.ScopeRead, data.ScopeWrite)
+
+	get := func(url string, header string, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		return serveTestRequest(app, req)
+	}
+
+	url := "/api/v1/clusters/" + f.searchCluster.Id
+
+	rec := get(url, "", "")
+	etag, lastModified := rec.Header().Get("ETag"), rec.Header().Get("Last-Modified")
+
+	if etag == "" || lastModified == "" {
+		t.Fatalf("expected validators, got %v", rec.Header())
+	}
+
+	if rec := get(url, "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusNotModified, rec.Code)
+	}
+
+	// Lists only have an entity tag.
+	rec = get("/api/v1/teams", "", "")
+	if rec.Header().Get("Last-Modified") != "" {
+		t.Fatalf("expected no Last-Modified header, got %q", rec.Header().Get("Last-Modified"))
+	}
+
+	if rec := get("/api/v1/teams", "If-None-Match", rec.Header().Get("ETag")); rec.Code != http.StatusNotModified {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusNotModified, rec.Code)
+	}
+
+	// A changed cluster is sent again.
+	serveAlertmanagerRequest(t, app, token, http.MethodPut, url, `{"labels":{"env":"qa"}}`, http.StatusOK)
+
+	if rec := get(url, "If-None-Match", etag); rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestRbacTokenRoles(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	f := newRbacFixture(app)
+
+	user := app.NewUser("test@example.com")
+	app.Bind(user, data.RoleAdmin, data.ScopeTypeTeam, f.payments.Id)
+
+	token := app.NewToken(user, data.ScopeRead, data.ScopeWrite)
+
+	// Tokens cannot be granted roles their owner does not hold.
+	body := `{"name":"ci","scopes":["read"],"roles":[{"role":"viewer","scope_type":"team","scope_id":"` + f.search.Id + `"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tokens", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	if rec := serveTestRequest(app, req); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	body = `{"name":"ci","scopes":["read","write"],"roles":[{"role":"viewer","scope_type":"cluster","scope_id":"` + f.paymentsCluster.Id + `"}]}`
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/tokens", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec := serveTestRequest(app, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	testBodyContent(t, rec, []string{`"roles":[{`, `"role":"viewer"`})
+
+	tokens, _, _ := app.Tokens.ListForUser(user.Id, nil)
+	restricted := tokens[len(tokens)-1].Plaintext
+
+	// The restricted token can read the cluster, but not modify it even
+	// though its owner is a team admin.
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/clusters/"+f.paymentsCluster.Id, nil)
+	req.Header.Set("Authorization", "Bearer "+restricted)
+
+	if rec := serveTestRequest(app, req); rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/clusters/"+f.paymentsCluster.Id, nil)
+	req.Header.Set("Authorization", "Bearer "+restricted)
+
+	if rec := serveTestRequest(app, req); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestRbacTokenRolesMinted(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	f := newRbacFixture(app)
+
+	user := app.NewUser("test@example.com")
+	app.Bind(user, data.RoleAdmin, data.ScopeTypeTeam, f.payments.Id)
+	app.Bind(user, data.RoleViewer, data.ScopeTypeTeam, f.search.Id)
+
+	token := app.NewToken(user, data.ScopeRead, data.ScopeWrite)
+
+	mint := func(token string, body string, status int) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tokens", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rec := serveTestRequest(app, req)
+		if rec.Code != status {
+			t.Fatalf("expected status code to be %d, got %d", status, rec.Code)
+		}
+
+		return rec
+	}
+
+	mint(token, `{"name":"ci","scopes":["read","write"],"roles":[{"role":"viewer","scope_type":"cluster","scope_id":"`+f.paymentsCluster.Id+`"}]}`, http.StatusCreated)
+
+	tokens, _, _ := app.Tokens.ListForUser(user.Id, nil)
+	restricted := tokens[len(tokens)-1].Plaintext
+
+	// A restricted token cannot mint a token with roles beyond its own,
+	// even ones its owner holds.
+	mint(restricted, `{"name":"ci","scopes":["read"],"roles":[{"role":"viewer","scope_type":"team","scope_id":"`+f.search.Id+`"}]}`, http.StatusForbidden)
+
+	// Nor an unrestricted one, a token minted without roles inherits the
+	// ones of the caller token.
+	rec := mint(restricted, `{"name":"ci","scopes":["read"],"roles":[]}`, http.StatusCreated)
+	testBodyContent(t, rec, []string{`"scope_id":"` + f.paymentsCluster.Id + `"`})
+
+	tokens, _, _ = app.Tokens.ListForUser(user.Id, nil)
+	minted := tokens[len(tokens)-1].Plaintext
+
+	for url, status := range map[string]int{
+		"/api/v1/clusters/" + f.paymentsCluster.Id: http.StatusOK,
+		"/api/v1/clusters/" + f.searchCluster.Id:   http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "Bearer "+minted)
+
+		if rec := serveTestRequest(app, req); rec.Code != status {
+			t.Fatalf("expected status code of %s to be %d, got %d", url, status, rec.Code)
+		}
+	}
+}
+
+func TestRoleBindings(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	f := newRbacFixture(app)
+
+	admin := app.NewUser("admin@example.com")
+	app.Bind(admin, data.RoleAdmin, data.ScopeTypeTeam, f.search.Id)
+	token := app.NewToken(admin, data.ScopeRead, data.ScopeWrite)
+
+	member := app.NewUser("member@example.com")
+	other := app.Bind(member, data.RoleViewer, data.ScopeTypeTeam, f.payments.Id)
+
+	testCases := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		status int
+	}{
+		{
+			name:   "bind on own team",
+			method: http.MethodPost,
+			url:    "/api/v1/role-bindings",
+			body:   `{"user_id":"` + member.Id + `","role":"editor","scope_type":"team","scope_id":"` + f.search.Id + `"}`,
+			status: http.StatusCreated,
+		},
+		{
+			name:   "bind on own team cluster",
+			method: http.MethodPost,
+			url:    "/api/v1/role-bindings",
+			body:   `{"user_id":"` + member.Id + `","role":"editor","scope_type":"cluster","scope_id":"` + f.searchCluster.Id + `"}`,
+			status: http.StatusCreated,
+		},
+		{
+			name:   "duplicate binding",
+			method: http.MethodPost,
+			url:    "/api/v1/role-bindings",
+			body:   `{"user_id":"` + member.Id + `","role":"editor","scope_type":"team","scope_id":"` + f.search.Id + `"}`,
+			status: http.StatusConflict,
+		},
+		{
+			name:   "bind on other team",
+			method: http.MethodPost,
+			url:    "/api/v1/role-bindings",
+			body:   `{"user_id":"` + member.Id + `","role":"editor","scope_type":"team","scope_id":"` + f.payments.Id + `"}`,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "bind globally",
+			method: http.MethodPost,
+			url:    "/api/v1/role-bindings",
+			body:   `{"user_id":"` + member.Id + `","role":"admin","scope_type":"global"}`,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "invalid role",
+			method: http.MethodPost,
+			url:    "/api/v1/role-bindings",
+			body:   `{"user_id":"` + member.Id + `","role":"owner","scope_type":"team","scope_id":"` + f.search.Id + `"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "list requires global admin",
+			method: http.MethodGet,
+			url:    "/api/v1/role-bindings",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "unbind on other team",
+			method: http.MethodDelete,
+			url:    "/api/v1/role-bindings/" + other.Id,
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		if rec := serveTestRequest(app, req); rec.Code != tc.status {
+			t.Fatalf("%s: expected status code to be %d, got %d", tc.name, tc.status, rec.Code)
+		}
+	}
+}
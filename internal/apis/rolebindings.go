@@ -0,0 +1,156 @@
+package apis
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+type roleBindingInput struct {
+	Role      string `json:"role"`
+	ScopeType string `json:"scope_type"`
+	ScopeId   string `json:"scope_id"`
+}
+
+// bindingResource validates the role binding input, and resolves the
+// resource its scope is bound to.
+func bindingResource(e *core.EventRequest, input roleBindingInput) (data.Resource, bool) {
+	if !data.IsValidRole(input.Role) {
+		badRequest(e, fmt.Sprintf(
+			"Invalid role %q, must be one of %s.",
+			input.Role, strings.Join(data.Roles, ", "),
+		))
+		return data.Resource{}, false
+	}
+
+	return scopeResource(e, input.ScopeType, input.ScopeId)
+}
+
+func listRoleBindings(e *core.EventRequest) {
+	q, apiErr := e.ListQuery()
+	if apiErr != nil {
+		errorResponse(e, apiErr)
+		return
+	}
+
+	bindings, next, err := e.Models().RoleBindings.List(q)
+	if err != nil {
+		var lqe *data.ListQueryError
+		switch {
+		case errors.As(err, &lqe):
+			badRequest(e, lqe.Message)
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	resp := struct {
+		RoleBindings []*data.RoleBinding `json:"role_bindings"`
+		NextCursor   string              `json:"next_cursor"`
+	}{
+		RoleBindings: bindings,
+		NextCursor:   next,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func createRoleBinding(e *core.EventRequest) {
+	var input struct {
+		UserId string `json:"user_id"`
+		roleBindingInput
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	resource, ok := bindingResource(e, input.roleBindingInput)
+	if !ok {
+		return
+	}
+
+	// Only the admins of a scope can bind roles on it.
+	if !allow(e, data.RoleAdmin, resource) {
+		return
+	}
+
+	user, err := e.Models().Users.GetById(input.UserId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			badRequest(e, fmt.Sprintf("User %q not found.", input.UserId))
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	binding := &data.RoleBinding{
+		UserId:    user.Id,
+		Role:      input.Role,
+		ScopeType: input.ScopeType,
+		ScopeId:   input.ScopeId,
+	}
+
+	if err := e.Models().RoleBindings.Insert(binding); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRoleBinding):
+			conflict(e, "Role binding already exists.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelCreate, "role_binding", binding.Id, nil, binding)
+
+	resp := struct {
+		RoleBinding *data.RoleBinding `json:"role_binding"`
+	}{
+		RoleBinding: binding,
+	}
+
+	if err := e.Json(resp, http.StatusCreated); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func deleteRoleBinding(e *core.EventRequest) {
+	binding, err := e.Models().RoleBindings.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Role binding not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	err = e.Models().RoleBindings.Delete(binding.Id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Role binding not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelDelete, "role_binding", binding.Id, binding, nil)
+
+	e.NoContent()
+}
@@ -5,11 +5,13 @@ import (
 	"net/http"
 
 	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
 	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
 )
 
 type apiRoute struct {
 	pattern string
+	access  access
 	handler func(*core.EventRequest)
 }
 
@@ -33,18 +35,65 @@ func newRouter(app core.App) *router {
 	// routes wires all service API endpoints to their handlers.
 	r.routes()
 
+	// useMiddlewares wraps all service API endpoints with the global middlewares.
+	r.useMiddlewares()
+
 	return r
 }
 
-func (r *router) add(pattern string, handler func(*core.EventRequest)) {
+// add registers a route. Every route declares its access policy, which is
+// enforced before the handler runs.
+func (r *router) add(pattern string, policy access, handler func(*core.EventRequest)) {
 	r.apiRoutes = append(r.apiRoutes, apiRoute{
 		pattern: pattern,
+		access:  policy,
 		handler: handler,
 	})
 }
 
-func (r *router) get(pattern string, handler func(*core.EventRequest)) {
-	r.add(fmt.Sprintf("%s %s", http.MethodGet, pattern), handler)
+// use registers a global middleware. Middlewares wrap the handler in the
+// order they are registered, so the last registered one runs first.
+func (r *router) use(fn func(*core.EventRequest, http.Handler)) {
+	r.middlewares = append(r.middlewares, middleware{
+		fn: fn,
+	})
+}
+
+// Route registers the route of a hook, requested by authenticated callers
+// granted role on any resource, or by any of them when role is empty.
+func (r *router) Route(pattern string, role string, handler func(*core.EventRequest)) {
+	policy := authAccess
+	if role != "" {
+		policy = roleAccess(role, nil)
+	}
+	r.add(pattern, policy, handler)
+}
+
+// PublicRoute registers the route of a hook, requested anonymously.
+func (r *router) PublicRoute(pattern string, handler func(*core.EventRequest)) {
+	r.add(pattern, publicAccess, handler)
+}
+
+// Use registers the global middleware of a hook. Since the middlewares of
+// the API are registered already, it runs before them.
+func (r *router) Use(fn func(*core.EventRequest, http.Handler)) {
+	r.use(fn)
+}
+
+func (r *router) get(pattern string, policy access, handler func(*core.EventRequest)) {
+	r.add(fmt.Sprintf("%s %s", http.MethodGet, pattern), policy, handler)
+}
+
+func (r *router) post(pattern string, policy access, handler func(*core.EventRequest)) {
+	r.add(fmt.Sprintf("%s %s", http.MethodPost, pattern), policy, handler)
+}
+
+func (r *router) put(pattern string, policy access, handler func(*core.EventRequest)) {
+	r.add(fmt.Sprintf("%s %s", http.MethodPut, pattern), policy, handler)
+}
+
+func (r *router) delete(pattern string, policy access, handler func(*core.EventRequest)) {
+	r.add(fmt.Sprintf("%s %s", http.MethodDelete, pattern), policy, handler)
 }
 
 func (r *router) handler() http.Handler {
@@ -52,15 +101,9 @@ func (r *router) handler() http.Handler {
 
 	// register API routes
 	for _, route := range r.apiRoutes {
+		handler := routed(instrumented(audited(route.access, authorize(route.access, route.handler))))
 		mux.HandleFunc(route.pattern, func(res http.ResponseWriter, req *http.Request) {
-			e := &core.EventRequest{
-				App: r.app,
-				Event: event.Event{
-					Request:  req,
-					Response: res,
-				},
-			}
-			route.handler(e)
+			handler(r.newEvent(res, req))
 		})
 	}
 
@@ -72,14 +115,7 @@ func (r *router) handler() http.Handler {
 	for _, m := range r.middlewares {
 		mf := func(next http.Handler) http.Handler {
 			return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-				e := &core.EventRequest{
-					App: r.app,
-					Event: event.Event{
-						Request:  req,
-						Response: res,
-					},
-				}
-				m.fn(e, next)
+				m.fn(r.newEvent(res, req), next)
 			})
 		}
 		// wrap handler with middleware
@@ -89,6 +125,118 @@ func (r *router) handler() http.Handler {
 	return handler
 }
 
+// newEvent creates the event for a request, restoring the authentication
+// state that previous middlewares stored in the request context.
+func (r *router) newEvent(res http.ResponseWriter, req *http.Request) *core.EventRequest {
+	auth := core.AuthFromContext(req.Context())
+
+	return &core.EventRequest{
+		App:     r.app,
+		Auth:    auth.User,
+		Token:   auth.Token,
+		Session: auth.Session,
+		Event: event.Event{
+			Request:  req,
+			Response: res,
+		},
+	}
+}
+
 func (r *router) routes() {
-	r.get("/api/v1/health", healthCheck)
+	r.get("/api/v1/health", publicAccess, healthCheck)
+	r.get("/api/v1/health/live", publicAccess, liveHealthCheck)
+	r.get("/api/v1/health/ready", publicAccess, readyHealthCheck)
+	r.get("/metrics", publicAccess, metrics)
+
+	r.get("/api/v1/users", roleAccess(data.RoleAdmin, globalResource), listUsers)
+	r.post("/api/v1/users", publicAccess, registerUser)
+	r.put("/api/v1/users/activate", publicAccess, activateUser)
+	r.post("/api/v1/users/activation", publicAccess, resendActivationToken)
+
+	r.post("/api/v1/auth/login", publicAccess, login)
+	r.post("/api/v1/auth/logout", authAccess, logout)
+	r.get("/api/v1/auth/session", authAccess, currentSession)
+	r.get("/api/v1/auth/oidc/login", publicAccess, oidcLogin)
+	r.get("/api/v1/auth/oidc/callback", publicAuditedAccess, oidcCallback)
+
+	r.get("/api/v1/tokens", authAccess, listTokens)
+	r.post("/api/v1/tokens", authAccess, createToken)
+	r.delete("/api/v1/tokens/{id}", authAccess, revokeToken)
+
+	r.get("/api/v1/teams", roleAccess(data.RoleViewer, nil), listTeams)
+	r.post("/api/v1/teams", roleAccess(data.RoleAdmin, globalResource), createTeam)
+	r.get("/api/v1/teams/{id}", roleAccess(data.RoleViewer, teamResource), getTeam)
+	r.delete("/api/v1/teams/{id}", roleAccess(data.RoleAdmin, globalResource), deleteTeam)
+
+	// The team of a new cluster is only known once the body is read, so the
+	// handler checks the role on it.
+	r.get("/api/v1/clusters", roleAccess(data.RoleViewer, nil), listClusters)
+	r.post("/api/v1/clusters", roleAccess(data.RoleAdmin, nil), createCluster)
+	r.get("/api/v1/clusters/{id}", roleAccess(data.RoleViewer, clusterResource), getCluster)
+	r.put("/api/v1/clusters/{id}", roleAccess(data.RoleAdmin, clusterResource), updateCluster)
+	r.delete("/api/v1/clusters/{id}", roleAccess(data.RoleAdmin, clusterResource), deleteCluster)
+
+	// The credentials of the clusters are write-only, their secrets are
+	// never returned.
+	r.get("/api/v1/clusters/{id}/credentials", roleAccess(data.RoleAdmin, clusterResource), getClusterCredentials)
+	r.put("/api/v1/clusters/{id}/credentials", roleAccess(data.RoleAdmin, clusterResource), updateClusterCredentials)
+	r.delete("/api/v1/clusters/{id}/credentials", roleAccess(data.RoleAdmin, clusterResource), deleteClusterCredentials)
+
+	r.get("/api/v1/clusters/{id}/alertmanager", roleAccess(data.RoleViewer, clusterResource), getAlertmanagerConfig)
+	r.put("/api/v1/clusters/{id}/alertmanager", roleAccess(data.RoleEditor, clusterResource), updateAlertmanagerConfig)
+	r.get("/api/v1/clusters/{id}/alertmanager/render", roleAccess(data.RoleViewer, clusterResource), renderAlertmanagerConfig)
+	r.post("/api/v1/clusters/{id}/alertmanager/sync", roleAccess(data.RoleEditor, clusterResource), syncAlertmanagerConfig)
+
+	// Silences span the clusters matching their selector, the handlers
+	// check the role on each of them.
+	r.get("/api/v1/silences", roleAccess(data.RoleViewer, nil), listSilences)
+	r.post("/api/v1/silences", roleAccess(data.RoleEditor, nil), createSilence)
+	r.get("/api/v1/silences/{id}", roleAccess(data.RoleViewer, nil), getSilence)
+	r.post("/api/v1/silences/{id}/retry", roleAccess(data.RoleEditor, nil), retrySilence)
+	r.post("/api/v1/silences/{id}/expire", roleAccess(data.RoleEditor, nil), expireSilence)
+
+	// The alerts are polled from the clusters in the background, and
+	// served from the cache.
+	r.get("/api/v1/alerts", roleAccess(data.RoleViewer, nil), listAlerts)
+
+	// Rules are the alerts sharing a name, their statistics are computed
+	// from the recorded transitions of the alerts, and their routing from
+	// the Alertmanager configurations of the clusters.
+	r.get("/api/v1/rules/noisiest", roleAccess(data.RoleViewer, nil), listNoisiestRules)
+	r.get("/api/v1/rules/{id}/stats", roleAccess(data.RoleViewer, nil), getRuleStats)
+	r.get("/api/v1/rules/{id}/routing", roleAccess(data.RoleViewer, nil), routeRule)
+
+	// Maintenance windows silence the clusters matching their selector on
+	// a schedule, the handlers check the role on each of them.
+	r.get("/api/v1/maintenance-windows", roleAccess(data.RoleViewer, nil), listMaintenanceWindows)
+	r.post("/api/v1/maintenance-windows", roleAccess(data.RoleEditor, nil), createMaintenanceWindow)
+	r.get("/api/v1/maintenance-windows/{id}", roleAccess(data.RoleViewer, nil), getMaintenanceWindow)
+	r.put("/api/v1/maintenance-windows/{id}", roleAccess(data.RoleEditor, nil), updateMaintenanceWindow)
+	r.delete("/api/v1/maintenance-windows/{id}", roleAccess(data.RoleEditor, nil), deleteMaintenanceWindow)
+
+	// Changes to production clusters are requested, and applied once
+	// approved by another user.
+	r.get("/api/v1/change-requests", roleAccess(data.RoleViewer, nil), listChangeRequests)
+	r.get("/api/v1/change-requests/{id}", roleAccess(data.RoleViewer, changeRequestResource), getChangeRequest)
+	r.post("/api/v1/change-requests/{id}/comments", roleAccess(data.RoleViewer, changeRequestResource), commentChangeRequest)
+	r.post("/api/v1/change-requests/{id}/approve", roleAccess(data.RoleApprover, changeRequestResource), approveChangeRequest)
+	r.post("/api/v1/change-requests/{id}/reject", roleAccess(data.RoleApprover, changeRequestResource), rejectChangeRequest)
+
+	r.get("/api/v1/role-bindings", roleAccess(data.RoleAdmin, globalResource), listRoleBindings)
+	r.post("/api/v1/role-bindings", roleAccess(data.RoleAdmin, nil), createRoleBinding)
+	r.delete("/api/v1/role-bindings/{id}", roleAccess(data.RoleAdmin, roleBindingResource), deleteRoleBinding)
+
+	r.get("/api/v1/tenants", globalAccess(data.RoleAdmin, operatorResource), listTenants)
+	r.post("/api/v1/tenants", globalAccess(data.RoleAdmin, operatorResource), createTenant)
+	r.put("/api/v1/tenants/{id}/users/{user_id}", globalAccess(data.RoleAdmin, operatorResource), addTenantUser)
+
+	r.get("/api/v1/audit", roleAccess(data.RoleAdmin, globalResource), listAuditEvents)
+	r.get("/api/v1/audit/export", roleAccess(data.RoleAdmin, globalResource), exportAuditEvents)
+	r.get("/api/v1/audit/verify", roleAccess(data.RoleAdmin, globalResource), verifyAuditLog)
+}
+
+func (r *router) useMiddlewares() {
+	r.use(loadAuth)
+	r.use(compress)
+	r.use(traceRequest)
 }
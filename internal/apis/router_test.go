@@ -20,15 +20,15 @@ func TestNewRouter(t *testing.T) {
 	// The calls to be made by each registered endpoint.
 	calls := ""
 
-	router.get("/a", func(*core.EventRequest) {
+	router.get("/a", publicAccess, func(*core.EventRequest) {
 		calls += "a"
 	})
 
-	router.get("/b", func(*core.EventRequest) {
+	router.get("/b", publicAccess, func(*core.EventRequest) {
 		calls += "b"
 	})
 
-	router.get("/a/b", func(*core.EventRequest) {
+	router.get("/a/b", publicAccess, func(*core.EventRequest) {
 		calls += "a_b"
 	})
 
@@ -0,0 +1,315 @@
+package apis
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+)
+
+const (
+	// defaultStatsPeriod is the period of the alert statistics when no
+	// period is given.
+	defaultStatsPeriod = time.Hour * 24 * 30
+
+	// statsFlapInterval is how soon alerts fire again after they resolved
+	// to count as flapping.
+	statsFlapInterval = time.Hour
+
+	defaultNoisiestLimit = 10
+	maxNoisiestLimit     = 100
+)
+
+// getRuleStats returns the statistics of the alerts of a rule, identified
+// by their name, e.g. `/api/v1/rules/HighLatency/stats`.
+func getRuleStats(e *core.EventRequest) {
+	q, ok := statsQuery(e)
+	if !ok {
+		return
+	}
+
+	stats, err := e.Models().AlertHistory.RuleStats(e.Request.PathValue("id"), q)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Rule not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	resp := struct {
+		Stats *data.RuleStats `json:"stats"`
+	}{
+		Stats: stats,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// listNoisiestRules returns the rules whose alerts fired the most during
+// the period, the noisiest first.
+func listNoisiestRules(e *core.EventRequest) {
+	q, ok := statsQuery(e)
+	if !ok {
+		return
+	}
+
+	limit := defaultNoisiestLimit
+
+	if value := e.Request.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxNoisiestLimit {
+			badRequest(e, fmt.Sprintf("Limit must be a number between 1 and %d.", maxNoisiestLimit))
+			return
+		}
+		limit = n
+	}
+
+	rules, err := e.Models().AlertHistory.Noisiest(q, limit)
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	resp := struct {
+		Rules []*data.RuleStats `json:"rules"`
+	}{
+		Rules: rules,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// ruleRouting is where the alerts of a rule are routed on a cluster.
+type ruleRouting struct {
+	ClusterId    string                     `json:"cluster_id"`
+	ClusterName  string                     `json:"cluster_name"`
+	Labels       map[string]string          `json:"labels"`
+	Routes       []*alertmanager.RouteMatch `json:"routes"`
+	FallsThrough bool                       `json:"falls_through"`
+}
+
+// routeRule evaluates the routing trees of the Alertmanagers of the clusters
+// for the alerts of a rule, identified by their name, with the static labels
+// of the query, e.g. `/api/v1/rules/HighLatency/routing?label=severity=critical`.
+// The labels of each cluster are added to the alerts the way Prometheus adds
+// its external labels, without overriding the labels of the rule. The
+// `cluster` query parameter restricts it to a cluster, it covers all the
+// clusters with a configuration the caller can view otherwise.
+func routeRule(e *core.EventRequest) {
+	rule := e.Request.PathValue("id")
+	query := e.Request.URL.Query()
+
+	labels, msg := parseQueryLabels(query["label"])
+	if msg != "" {
+		badRequest(e, msg)
+		return
+	}
+
+	if _, ok := labels["alertname"]; ok {
+		badRequest(e, `Label "alertname" is the name of the rule, it cannot be given.`)
+		return
+	}
+
+	labels["alertname"] = rule
+
+	clusters, ok := routingClusters(e, query.Get("cluster"))
+	if !ok {
+		return
+	}
+
+	routings := []*ruleRouting{}
+	fallsThrough := false
+
+	for _, cluster := range clusters {
+		config, err := e.Models().AlertmanagerConfigs.GetByClusterId(cluster.Id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				continue
+			default:
+				internalServerError(e, err)
+			}
+			return
+		}
+
+		alertLabels := maps.Clone(labels)
+		for name, value := range cluster.Labels {
+			if _, ok := alertLabels[name]; !ok {
+				alertLabels[name] = value
+			}
+		}
+
+		routes := config.Config.Match(alertLabels)
+
+		routing := &ruleRouting{
+			ClusterId:    cluster.Id,
+			ClusterName:  cluster.Name,
+			Labels:       alertLabels,
+			Routes:       routes,
+			FallsThrough: alertmanager.FallsThrough(routes),
+		}
+
+		routings = append(routings, routing)
+		fallsThrough = fallsThrough || routing.FallsThrough
+	}
+
+	resp := struct {
+		Rule         string         `json:"rule"`
+		Clusters     []*ruleRouting `json:"clusters"`
+		FallsThrough bool           `json:"falls_through"`
+	}{
+		Rule:         rule,
+		Clusters:     routings,
+		FallsThrough: fallsThrough,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// routingClusters returns the cluster of the id, when not empty, or all the
+// clusters the caller can view. It responds with an error and returns false
+// when the cluster does not exist or cannot be viewed.
+func routingClusters(e *core.EventRequest, clusterId string) ([]*data.Cluster, bool) {
+	if clusterId != "" {
+		cluster, err := e.Models().Clusters.GetById(clusterId)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				badRequest(e, "Cluster not found.")
+			default:
+				internalServerError(e, err)
+			}
+			return nil, false
+		}
+
+		if !allow(e, data.RoleViewer, cluster.Resource()) {
+			return nil, false
+		}
+
+		return []*data.Cluster{cluster}, true
+	}
+
+	permissions, ok := loadPermissions(e)
+	if !ok {
+		return nil, false
+	}
+
+	clusters, err := core.SelectClusters(e.Models(), nil)
+	if err != nil {
+		internalServerError(e, err)
+		return nil, false
+	}
+
+	return slices.DeleteFunc(clusters, func(cluster *data.Cluster) bool {
+		return !permissions.Allows(data.RoleViewer, cluster.Resource())
+	}), true
+}
+
+// parseQueryLabels parses the `name=value` labels of a query, and returns
+// the validation error message when one is invalid.
+func parseQueryLabels(values []string) (map[string]string, string) {
+	labels := map[string]string{}
+
+	for _, value := range values {
+		name, v, ok := strings.Cut(value, "=")
+		if !ok || !labelNameRegex.MatchString(name) {
+			return nil, fmt.Sprintf("Invalid label %q, labels must be given as name=value.", value)
+		}
+
+		if _, ok := labels[name]; ok {
+			return nil, fmt.Sprintf("Label %q is given more than once.", name)
+		}
+
+		labels[name] = v
+	}
+
+	return labels, ""
+}
+
+// statsQuery reads the `period`, e.g. `7d`, and `cluster` query parameters
+// of the statistics, restricted to the clusters the caller can view. It
+// responds with a 400 error and returns false when they are invalid.
+func statsQuery(e *core.EventRequest) (*data.StatsQuery, bool) {
+	query := e.Request.URL.Query()
+
+	period := defaultStatsPeriod
+
+	if value := query.Get("period"); value != "" {
+		d, err := alertmanager.ParseDuration(value)
+		if err != nil || d <= 0 {
+			badRequest(e, fmt.Sprintf("Invalid period %q, e.g. 7d or 12h.", value))
+			return nil, false
+		}
+		period = d
+	}
+
+	q := &data.StatsQuery{
+		Since:        time.Now().UTC().Add(-period),
+		FlapInterval: statsFlapInterval,
+	}
+
+	if clusterId := query.Get("cluster"); clusterId != "" {
+		cluster, err := e.Models().Clusters.GetById(clusterId)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				badRequest(e, "Cluster not found.")
+			default:
+				internalServerError(e, err)
+			}
+			return nil, false
+		}
+
+		if !allow(e, data.RoleViewer, cluster.Resource()) {
+			return nil, false
+		}
+
+		q.ClusterIds = []string{cluster.Id}
+		return q, true
+	}
+
+	permissions, ok := loadPermissions(e)
+	if !ok {
+		return nil, false
+	}
+
+	// Global viewers see the statistics of all the clusters, deleted ones
+	// included.
+	if permissions.Allows(data.RoleViewer, data.Resource{}) {
+		return q, true
+	}
+
+	allowed, ok := allowedClusters(e, data.RoleViewer)
+	if !ok {
+		return nil, false
+	}
+
+	q.ClusterIds = []string{}
+	for clusterId, ok := range allowed {
+		if ok {
+			q.ClusterIds = append(q.ClusterIds, clusterId)
+		}
+	}
+
+	return q, true
+}
@@ -0,0 +1,294 @@
+package apis
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/prometheus"
+)
+
+func TestAlertHistory(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	cluster := app.NewCluster(app.NewTeam("payments"), "payments-prod", nil)
+
+	am := tests.NewAlertmanager("")
+	defer am.Close()
+
+	prom := tests.NewPrometheus()
+	defer prom.Close()
+
+	cluster.AlertmanagerUrl = am.Server.URL
+	cluster.PrometheusUrl = prom.Server.URL
+	_ = app.Clusters.Update(cluster)
+
+	labels := map[string]string{"alertname": "HighErrorRate", "severity": "critical"}
+
+	poll := func(p *core.AlertPoller) {
+		if err := p.Poll(context.Background()); err != nil {
+			t.Fatalf("failed to poll alerts; %v", err)
+		}
+	}
+
+	prom.SetAlerts(&prometheus.Alert{Labels: labels, State: "pending"})
+	poll(app.AlertPoller())
+
+	// Once firing, the alert is also in Alertmanager, with the external
+	// labels of Prometheus.
+	prom.SetAlerts(&prometheus.Alert{Labels: labels, State: "firing"})
+	am.SetAlerts(&alertmanager.Alert{
+		Fingerprint: "am-fingerprint",
+		Labels:      map[string]string{"alertname": "HighErrorRate", "severity": "critical", "cluster": "payments-prod"},
+		Status:      alertmanager.AlertStatus{State: "suppressed"},
+	})
+	poll(app.AlertPoller())
+	poll(app.AlertPoller())
+
+	// Another replica does not record the transitions while the lease is
+	// held.
+	prom.SetAlerts()
+	am.SetAlerts()
+	poll(core.NewAlertPoller(app, 0))
+
+	transitions := app.AlertHistory.Transitions()
+	if len(transitions) != 2 {
+		t.Fatalf("expected 2 transitions, got %+v", transitions)
+	}
+
+	// The alerts of Alertmanager are not resolved while it is down.
+	am.Close()
+	poll(app.AlertPoller())
+
+	if len(app.AlertHistory.Transitions()) != 2 {
+		t.Fatalf("expected no transition while alertmanager is down, got %+v", app.AlertHistory.Transitions())
+	}
+
+	am = tests.NewAlertmanager("")
+	defer am.Close()
+
+	cluster.AlertmanagerUrl = am.Server.URL
+	_ = app.Clusters.Update(cluster)
+
+	poll(app.AlertPoller())
+
+	transitions = app.AlertHistory.Transitions()
+
+	expected := []struct{ state, previous string }{
+		{data.AlertStatePending, ""},
+		{data.AlertStateFiring, data.AlertStatePending},
+		{data.AlertStateResolved, data.AlertStateFiring},
+	}
+
+	if len(transitions) != len(expected) {
+		t.Fatalf("expected %d transitions, got %+v", len(expected), transitions)
+	}
+
+	for i, tr := range transitions {
+		if tr.State != expected[i].state || tr.PreviousState != expected[i].previous {
+			t.Fatalf("expected transition %d from %q to %q, got %+v", i, expected[i].previous, expected[i].state, tr)
+		}
+
+		if tr.Fingerprint != transitions[0].Fingerprint || tr.Rule != "HighErrorRate" || tr.TenantId != cluster.TenantId {
+			t.Fatalf("expected transitions of the same alert, got %+v", tr)
+		}
+	}
+
+	if states, _ := app.AlertHistory.States(cluster.Id); len(states) != 0 {
+		t.Fatalf("expected no state once resolved, got %+v", states)
+	}
+}
+
+func TestRuleStats(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	cluster := app.NewCluster(team, "payments-prod", nil)
+	other := app.NewCluster(team, "payments-staging", nil)
+
+	now := time.Now().UTC()
+
+	transition := func(rule string, fingerprint string, state string, ago time.Duration) *data.AlertTransition {
+		return &data.AlertTransition{
+			ClusterId:   cluster.Id,
+			Fingerprint: fingerprint,
+			Rule:        rule,
+			State:       state,
+			ObservedAt:  now.Add(-ago),
+		}
+	}
+
+	err = app.AlertHistory.Record([]*data.AlertTransition{
+		// Fired before the period.
+		transition("HighLatency", "a", data.AlertStateFiring, 40*24*time.Hour),
+		transition("HighLatency", "a", data.AlertStateResolved, 40*24*time.Hour-time.Minute),
+		// Fired for an hour, then again half an hour after it resolved.
+		transition("HighLatency", "a", data.AlertStatePending, 4*time.Hour),
+		transition("HighLatency", "a", data.AlertStateFiring, 3*time.Hour),
+		transition("HighLatency", "a", data.AlertStateResolved, 2*time.Hour),
+		transition("HighLatency", "a", data.AlertStateFiring, 90*time.Minute),
+		transition("HighLatency", "a", data.AlertStateResolved, 60*time.Minute),
+		// Still firing.
+		transition("DiskFull", "b", data.AlertStateFiring, 10*time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("failed to record transitions; %v", err)
+	}
+
+	viewer := app.NewUser("viewer@example.com")
+	app.Bind(viewer, data.RoleViewer, data.ScopeTypeGlobal, "")
+	token := app.NewToken(viewer, data.ScopeRead)
+
+	rec := serveAlertmanagerRequest(t, app, token, http.MethodGet, "/api/v1/rules/HighLatency/stats", "", http.StatusOK)
+
+	var resp struct {
+		Stats data.RuleStats `json:"stats"`
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response body; %v", err)
+	}
+
+	stats := resp.Stats
+
+	if stats.FireCount != 2 || stats.FlapCount != 1 || stats.Flappiness != 0.5 || stats.Clusters != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	if stats.MeanTimeFiring != (45 * time.Minute).Seconds() {
+		t.Fatalf("expected alerts to fire for 45m on average, got %vs", stats.MeanTimeFiring)
+	}
+
+	if stats.LastFiredAt == nil || !stats.LastFiredAt.Equal(now.Add(-90*time.Minute)) {
+		t.Fatalf("expected last fired 90m ago, got %v", stats.LastFiredAt)
+	}
+
+	// The rule fired before the period only.
+	rec = serveAlertmanagerRequest(t, app, token, http.MethodGet, "/api/v1/rules/HighLatency/stats?period=1h", "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"fire_count":0`, `"last_fired_at":"`})
+
+	serveAlertmanagerRequest(t, app, token, http.MethodGet, "/api/v1/rules/Unknown/stats", "", http.StatusNotFound)
+	serveAlertmanagerRequest(t, app, token, http.MethodGet, "/api/v1/rules/HighLatency/stats?cluster="+other.Id, "", http.StatusNotFound)
+
+	rec = serveAlertmanagerRequest(t, app, token, http.MethodGet, "/api/v1/rules/HighLatency/stats?period=soon", "", http.StatusBadRequest)
+	testBodyContent(t, rec, []string{"Invalid period"})
+
+	rec = serveAlertmanagerRequest(t, app, token, http.MethodGet, "/api/v1/rules/noisiest", "", http.StatusOK)
+
+	var noisiest struct {
+		Rules []*data.RuleStats `json:"rules"`
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &noisiest); err != nil {
+		t.Fatalf("failed to decode response body; %v", err)
+	}
+
+	if len(noisiest.Rules) != 2 || noisiest.Rules[0].Rule != "HighLatency" || noisiest.Rules[1].Rule != "DiskFull" {
+		t.Fatalf("expected HighLatency then DiskFull, got %+v", noisiest.Rules)
+	}
+
+	rec = serveAlertmanagerRequest(t, app, token, http.MethodGet, "/api/v1/rules/noisiest?period=1h&limit=1", "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"rule":"DiskFull"`})
+
+	serveAlertmanagerRequest(t, app, token, http.MethodGet, "/api/v1/rules/noisiest?limit=0", "", http.StatusBadRequest)
+
+	// A viewer of another team does not see the statistics of the clusters
+	// of the team.
+	searchViewer := app.NewUser("search@example.com")
+	app.Bind(searchViewer, data.RoleViewer, data.ScopeTypeTeam, app.NewTeam("search").Id)
+	searchToken := app.NewToken(searchViewer, data.ScopeRead)
+
+	serveAlertmanagerRequest(t, app, searchToken, http.MethodGet, "/api/v1/rules/HighLatency/stats", "", http.StatusNotFound)
+	serveAlertmanagerRequest(t, app, searchToken, http.MethodGet, "/api/v1/rules/HighLatency/stats?cluster="+cluster.Id, "", http.StatusForbidden)
+
+	rec = serveAlertmanagerRequest(t, app, searchToken, http.MethodGet, "/api/v1/rules/noisiest", "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"rules":[]`})
+}
+
+func TestRuleRouting(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	cluster := app.NewCluster(team, "payments-staging", map[string]string{"env": "staging"})
+	other := app.NewCluster(app.NewTeam("search"), "search-staging", map[string]string{"env": "staging"})
+
+	viewer := app.NewUser("viewer@example.com")
+	app.Bind(viewer, data.RoleViewer, data.ScopeTypeTeam, team.Id)
+	token := app.NewToken(viewer, data.ScopeRead)
+
+	url := "/api/v1/rules/HighLatency/routing"
+
+	// The clusters without a configuration are left out.
+	rec := serveAlertmanagerRequest(t, app, token, http.MethodGet, url+"?label=severity=critical", "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"rule":"HighLatency","clusters":[],"falls_through":false`})
+
+	var config alertmanager.Config
+	_ = json.Unmarshal([]byte(`{
+		"route": {
+			"receiver": "default",
+			"routes": [
+				{"receiver": "pager", "matchers": ["severity=\"critical\"", "env=\"production\""]},
+				{"receiver": "blackhole", "matchers": ["env=\"staging\""]}
+			]
+		},
+		"receivers": [
+			{"name": "default", "slack_configs": [{"channel": "#alerts"}]},
+			{"name": "pager", "pagerduty_configs": [{"routing_key": "key"}]},
+			{"name": "blackhole"}
+		]
+	}`), &config)
+
+	for _, c := range []*data.Cluster{cluster, other} {
+		_ = app.AlertmanagerConfigs.Upsert(&data.AlertmanagerConfig{ClusterId: c.Id, Config: &config})
+	}
+
+	// The cluster labels route critical alerts of the staging cluster to the
+	// null receiver. The cluster of another team is left out.
+	rec = serveAlertmanagerRequest(t, app, token, http.MethodGet, url+"?label=severity=critical", "", http.StatusOK)
+	testBodyContent(t, rec, []string{
+		`"cluster_id":"` + cluster.Id + `"`,
+		`"labels":{"alertname":"HighLatency","env":"staging","severity":"critical"}`,
+		`"path":"route.routes[1]","receiver":"blackhole"`,
+		`"null_receiver":true`,
+		`"falls_through":true`,
+	})
+
+	if strings.Contains(rec.Body.String(), other.Id) {
+		t.Fatalf("expected cluster of another team to be left out, got %s", rec.Body.String())
+	}
+
+	// Rule labels take precedence over the cluster labels.
+	rec = serveAlertmanagerRequest(t, app, token, http.MethodGet, url+"?label=severity=critical&label=env=production&cluster="+cluster.Id, "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"receiver":"pager"`, `"falls_through":false`})
+
+	serveAlertmanagerRequest(t, app, token, http.MethodGet, url+"?cluster="+other.Id, "", http.StatusForbidden)
+	serveAlertmanagerRequest(t, app, token, http.MethodGet, url+"?cluster=unknown", "", http.StatusBadRequest)
+
+	rec = serveAlertmanagerRequest(t, app, token, http.MethodGet, url+"?label=severity", "", http.StatusBadRequest)
+	testBodyContent(t, rec, []string{"labels must be given as name=value"})
+
+	serveAlertmanagerRequest(t, app, token, http.MethodGet, url+"?label=a=1&label=a=2", "", http.StatusBadRequest)
+	serveAlertmanagerRequest(t, app, token, http.MethodGet, url+"?label=alertname=Other", "", http.StatusBadRequest)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,13 +15,8 @@ import (
 	"github.com/dlbarduzzi/scopehouse/internal/core"
 )
 
-const (
-	DefaultPort         = 8090
-	DefaultIdleTimeout  = time.Second * 30
-	DefaultReadTimeout  = time.Second * 5
-	DefaultWriteTimeout = time.Second * 5
-)
-
+// ServerConfig configures the API server. Its values are validated by the
+// settings, and are used as is.
 type ServerConfig struct {
 	Port         int
 	IdleTimeout  time.Duration
@@ -28,31 +24,33 @@ type ServerConfig struct {
 	WriteTimeout time.Duration
 }
 
-func Serve(app core.App, config ServerConfig) error {
-	if config.Port < 1 {
-		config.Port = DefaultPort
-	}
+// shutdownTimeout bounds the shutdown of the server, including the shutdown
+// hooks of the app.
+const shutdownTimeout = time.Second * 30
 
-	if config.IdleTimeout < time.Second*10 {
-		config.IdleTimeout = DefaultIdleTimeout
-	}
-
-	if config.ReadTimeout < time.Second*1 {
-		config.ReadTimeout = DefaultReadTimeout
+// Serve serves the API until the process receives a shutdown signal, along
+// with the background workers of the app. The shutdown hooks of the app run
+// before it returns, whether the server stopped or failed.
+func Serve(app core.App, config ServerConfig) error {
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%d", config.Port),
+		IdleTimeout:  config.IdleTimeout,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
 	}
 
-	if config.WriteTimeout < time.Second*1 {
-		config.WriteTimeout = DefaultWriteTimeout
+	handler, err := serveHandler(app, server)
+	if err != nil {
+		return shutdown(app, err)
 	}
 
-	router := newRouter(app)
+	server.Handler = handler
 
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", config.Port),
-		Handler:      router.handler(),
-		IdleTimeout:  time.Second * config.IdleTimeout,
-		ReadTimeout:  time.Second * config.ReadTimeout,
-		WriteTimeout: time.Second * config.WriteTimeout,
+	// Listen before starting the background workers, so that a port that
+	// cannot be bound fails right away.
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return shutdown(app, err)
 	}
 
 	shutdownErr := make(chan error)
@@ -66,25 +64,25 @@ func Serve(app core.App, config ServerConfig) error {
 			slog.String("signal", inSignal.String()),
 		)
 
-		// Finish running jobs before the server shuts down.
-		app.OnShutdown()
-
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
 
+		// Finish serving the requests in flight, then run the shutdown hooks
+		// of the app, e.g. stopping the background workers, within the same
+		// deadline.
 		err := server.Shutdown(ctx)
-		if err != nil {
-			shutdownErr <- err
-		}
 
-		shutdownErr <- nil
+		shutdownErr <- errors.Join(err, app.OnShutdown(ctx))
 	}()
 
+	app.Scheduler().Start()
+	app.AlertPoller().Start()
+
 	app.Logger().Info("server starting", slog.Int("port", config.Port))
 
-	err := server.ListenAndServe()
+	err = server.Serve(listener)
 	if !errors.Is(err, http.ErrServerClosed) {
-		return err
+		return shutdown(app, err)
 	}
 
 	err = <-shutdownErr
@@ -96,3 +94,29 @@ func Serve(app core.App, config ServerConfig) error {
 
 	return nil
 }
+
+// shutdown runs the shutdown hooks of the app once the server failed, and
+// returns their errors along with the one of the server.
+func shutdown(app core.App, err error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return errors.Join(err, app.OnShutdown(ctx))
+}
+
+// serveHandler creates the handler of the API served by server, once the
+// serve hooks of the app added their routes and middlewares.
+func serveHandler(app core.App, server *http.Server) (http.Handler, error) {
+	router := newRouter(app)
+
+	err := app.OnServe().Trigger(&core.ServeEvent{
+		App:    app,
+		Router: router,
+		Server: server,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return router.handler(), nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -58,6 +59,12 @@ func (s *apiTestScenario) _test(t *testing.T) {
 		t.Fatalf("failed to initialize test app instance; %v", err)
 	}
 
+	// Run before building the request so that the function can still
+	// change the scenario headers (e.g. set a token created for the test).
+	if s.beforeTestFunc != nil {
+		s.beforeTestFunc(t, app)
+	}
+
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(s.method, s.url, s.body)
 
@@ -68,14 +75,10 @@ func (s *apiTestScenario) _test(t *testing.T) {
 		req.Header.Set(k, v)
 	}
 
-	if s.beforeTestFunc != nil {
-		s.beforeTestFunc(t, app)
-	}
-
 	router := newRouter(app)
 
 	if (s.apiRoute) != nil {
-		router.get(s.apiRoute.pattern, s.apiRoute.handler)
+		router.get(s.apiRoute.pattern, s.apiRoute.access, s.apiRoute.handler)
 	}
 
 	handler := router.handler()
@@ -93,6 +96,13 @@ func (s *apiTestScenario) _test(t *testing.T) {
 	testBodyContent(t, rec, s.content)
 }
 
+// serveTestRequest runs the request through a router of the given app.
+func serveTestRequest(app *tests.TestApp, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	newRouter(app).handler().ServeHTTP(rec, req)
+	return rec
+}
+
 func (s *apiTestScenario) normalizeName() string {
 	name := strings.TrimSpace(s.name)
 
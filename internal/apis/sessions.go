@@ -0,0 +1,138 @@
+package apis
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/security"
+)
+
+const sessionTTL = time.Hour * 24 * 7
+
+// dummyPasswordHash is compared against when the user does not exist, so
+// that the response time does not reveal which emails are registered.
+var dummyPasswordHash, _ = security.HashPassword("dummy-password")
+
+type sessionResponse struct {
+	User      *data.User `json:"user"`
+	CsrfToken string     `json:"csrf_token"`
+}
+
+func login(e *core.EventRequest) {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(input.Email))
+
+	user, err := e.Models().Users.GetByEmail(email)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		internalServerError(e, err)
+		return
+	}
+
+	hash := dummyPasswordHash
+	if user != nil && user.PasswordHash != "" {
+		hash = user.PasswordHash
+	}
+
+	match, err := security.ComparePassword(input.Password, hash)
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	if user == nil || user.PasswordHash == "" || !match {
+		unauthorized(e, "Invalid email or password.")
+		return
+	}
+
+	if !user.IsActivated {
+		forbidden(e, "User account must be activated.")
+		return
+	}
+
+	session, err := data.NewSession(user.Id, sessionTTL)
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	if err := e.Models().Sessions.Insert(session); err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	setSessionCookie(e, session)
+
+	e.SetChange(core.ModelCreate, "session", session.Id, nil, auditSession(session))
+
+	resp := sessionResponse{
+		User:      user,
+		CsrfToken: session.CsrfToken,
+	}
+
+	if err := e.Json(resp, http.StatusOK); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func logout(e *core.EventRequest) {
+	if e.Session == nil {
+		badRequest(e, "Request is not authenticated with a session.")
+		return
+	}
+
+	if err := e.Models().Sessions.Delete(e.Session.Id); err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	clearSessionCookie(e)
+
+	e.SetChange(core.ModelDelete, "session", e.Session.Id, auditSession(e.Session), nil)
+
+	e.NoContent()
+}
+
+// currentSession returns the signed in user along with the CSRF token, so
+// that browser clients can recover it after a page reload.
+func currentSession(e *core.EventRequest) {
+	if e.Session == nil {
+		badRequest(e, "Request is not authenticated with a session.")
+		return
+	}
+
+	resp := sessionResponse{
+		User:      e.Auth,
+		CsrfToken: e.Session.CsrfToken,
+	}
+
+	if err := e.Json(resp, http.StatusOK); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// auditSession returns the session state recorded in the audit log, which
+// leaves out its secrets.
+func auditSession(session *data.Session) any {
+	return struct {
+		UserId    string    `json:"user_id"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{
+		UserId:    session.UserId,
+		ExpiresAt: session.ExpiresAt,
+	}
+}
@@ -0,0 +1,213 @@
+package apis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+func TestLogin(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		body      string
+		activated bool
+		status    int
+		content   []string
+	}{
+		{
+			name:      "success",
+			body:      `{"email":"Test@Example.com","password":"secret-password"}`,
+			activated: true,
+			status:    http.StatusOK,
+			content:   []string{`"email":"test@example.com"`, `"csrf_token":"`},
+		},
+		{
+			name:      "wrong password",
+			body:      `{"email":"test@example.com","password":"wrong-password"}`,
+			activated: true,
+			status:    http.StatusUnauthorized,
+			content:   []string{`"message":"Invalid email or password."`},
+		},
+		{
+			name:      "unknown email",
+			body:      `{"email":"unknown@example.com","password":"secret-password"}`,
+			activated: true,
+			status:    http.StatusUnauthorized,
+			content:   []string{`"message":"Invalid email or password."`},
+		},
+		{
+			name:      "not activated",
+			body:      `{"email":"test@example.com","password":"secret-password"}`,
+			activated: false,
+			status:    http.StatusForbidden,
+			content:   []string{`"message":"User account must be activated."`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, err := tests.NewTestApp()
+			if err != nil {
+				t.Fatalf("failed to initialize test app instance; %v", err)
+			}
+
+			user := app.NewUserWithPassword("test@example.com", "secret-password")
+			user.IsActivated = tc.activated
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(tc.body))
+			rec := serveTestRequest(app, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status code to be %d, got %d", tc.status, rec.Code)
+			}
+
+			testBodyContent(t, rec, tc.content)
+
+			cookies := rec.Result().Cookies()
+
+			if tc.status != http.StatusOK {
+				if len(cookies) != 0 {
+					t.Fatalf("expected no cookies, got %v", cookies)
+				}
+				return
+			}
+
+			if len(cookies) != 1 || cookies[0].Name != sessionCookieName {
+				t.Fatalf("expected session cookie, got %v", cookies)
+			}
+
+			cookie := cookies[0]
+
+			if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+				t.Fatalf("expected session cookie to be http-only, secure and same-site lax, got %v", cookie)
+			}
+
+			if _, err := app.Sessions.GetForToken(cookie.Value); err != nil {
+				t.Fatalf("expected session to be stored, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoginInsecureCookies(t *testing.T) {
+	t.Parallel()
+
+	// Development setups sign in over plain http.
+	app, err := tests.NewTestAppWithConfig(tests.TestAppConfig{InsecureCookies: true})
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	app.NewUserWithPassword("test@example.com", "secret-password")
+
+	body := `{"email":"test@example.com","password":"secret-password"}`
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(body))
+	rec := serveTestRequest(app, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Secure || !cookies[0].HttpOnly {
+		t.Fatalf("expected http-only session cookie not to be secure, got %v", cookies)
+	}
+}
+
+func TestSessionAuth(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		method  string
+		url     string
+		csrf    func(csrfToken string) string
+		status  int
+		content []string
+	}{
+		{
+			name:    "current session",
+			method:  http.MethodGet,
+			url:     "/api/v1/auth/session",
+			status:  http.StatusOK,
+			content: []string{`"email":"test@example.com"`, `"csrf_token":"`},
+		},
+		{
+			name:    "mutation without csrf token",
+			method:  http.MethodPost,
+			url:     "/api/v1/auth/logout",
+			status:  http.StatusForbidden,
+			content: []string{`"message":"Invalid or missing CSRF token."`},
+		},
+		{
+			name:    "mutation with wrong csrf token",
+			method:  http.MethodPost,
+			url:     "/api/v1/auth/logout",
+			csrf:    func(string) string { return "wrong" },
+			status:  http.StatusForbidden,
+			content: []string{`"message":"Invalid or missing CSRF token."`},
+		},
+		{
+			name:   "logout",
+			method: http.MethodPost,
+			url:    "/api/v1/auth/logout",
+			csrf:   func(csrfToken string) string { return csrfToken },
+			status: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, err := tests.NewTestApp()
+			if err != nil {
+				t.Fatalf("failed to initialize test app instance; %v", err)
+			}
+
+			user := app.NewUserWithPassword("test@example.com", "secret-password")
+			session := app.NewSession(user)
+
+			req := httptest.NewRequest(tc.method, tc.url, nil)
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session.Plaintext})
+
+			if tc.csrf != nil {
+				req.Header.Set(csrfHeaderName, tc.csrf(session.CsrfToken))
+			}
+
+			rec := serveTestRequest(app, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status code to be %d, got %d", tc.status, rec.Code)
+			}
+
+			testBodyContent(t, rec, tc.content)
+		})
+	}
+}
+
+func TestSessionExpiredCookie(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	app.NewUserWithPassword("test@example.com", "secret-password")
+
+	// A stale cookie must not prevent the user from signing in again.
+	body := strings.NewReader(`{"email":"test@example.com","password":"secret-password"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", body)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "expired"})
+
+	rec := serveTestRequest(app, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+}
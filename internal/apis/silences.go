@@ -0,0 +1,352 @@
+package apis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+)
+
+// silenceFanOutTimeout bounds the calls to the Alertmanagers of all the
+// clusters a silence targets.
+const silenceFanOutTimeout = time.Second * 30
+
+func listSilences(e *core.EventRequest) {
+	q, apiErr := e.ListQuery()
+	if apiErr != nil {
+		errorResponse(e, apiErr)
+		return
+	}
+
+	silences, next, err := e.Models().Silences.List(q)
+	if err != nil {
+		var lqe *data.ListQueryError
+		switch {
+		case errors.As(err, &lqe):
+			badRequest(e, lqe.Message)
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	allowed, ok := allowedClusters(e, data.RoleViewer)
+	if !ok {
+		return
+	}
+
+	// Only the silences of clusters the caller can view are listed, pages
+	// may then be shorter than the limit.
+	visible := make([]*data.Silence, 0, len(silences))
+	for _, silence := range silences {
+		if allowsSilenceTargets(allowed, silence) {
+			visible = append(visible, silence)
+		}
+	}
+
+	resp := struct {
+		Silences   []*data.Silence `json:"silences"`
+		NextCursor string          `json:"next_cursor"`
+	}{
+		Silences:   visible,
+		NextCursor: next,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func loadSilence(e *core.EventRequest) (*data.Silence, bool) {
+	silence, err := e.Models().Silences.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Silence not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return nil, false
+	}
+
+	return silence, true
+}
+
+func getSilence(e *core.EventRequest) {
+	silence, ok := loadSilence(e)
+	if !ok {
+		return
+	}
+
+	if !allowSilenceTargets(e, data.RoleViewer, silence) {
+		return
+	}
+
+	silenceResponse(e, silence, http.StatusOK)
+}
+
+// createSilence creates a silence on the Alertmanager of every cluster
+// matching the selector, e.g. all the clusters labeled `env=staging`. The
+// caller must be an editor of all of them. The silence is created even when
+// it fails on some clusters, the outcome is reported for each of them.
+func createSilence(e *core.EventRequest) {
+	var input struct {
+		Matchers        []string          `json:"matchers"`
+		ClusterSelector map[string]string `json:"cluster_selector"`
+		StartsAt        *time.Time        `json:"starts_at"`
+		EndsAt          *time.Time        `json:"ends_at"`
+		Duration        string            `json:"duration"`
+		Comment         string            `json:"comment"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	silence := &data.Silence{
+		Matchers:        input.Matchers,
+		ClusterSelector: input.ClusterSelector,
+		Comment:         strings.TrimSpace(input.Comment),
+		CreatedBy:       e.Auth.Id,
+	}
+
+	now := time.Now().UTC()
+
+	silence.StartsAt = now
+	if input.StartsAt != nil {
+		silence.StartsAt = input.StartsAt.UTC()
+	}
+
+	switch {
+	case input.EndsAt != nil && input.Duration != "":
+		badRequest(e, "Only one of ends_at and duration must be given.")
+		return
+	case input.EndsAt != nil:
+		silence.EndsAt = input.EndsAt.UTC()
+	case input.Duration != "":
+		d, err := alertmanager.ParseDuration(input.Duration)
+		if err != nil || d <= 0 {
+			badRequest(e, fmt.Sprintf("Invalid duration %q, e.g. 2h or 1d12h.", input.Duration))
+			return
+		}
+		silence.EndsAt = silence.StartsAt.Add(d)
+	default:
+		badRequest(e, "Either ends_at or duration must be given.")
+		return
+	}
+
+	if msg := validateSilence(silence, now); msg != "" {
+		badRequest(e, msg)
+		return
+	}
+
+	clusters, err := core.SelectClusters(e.Models(), silence.ClusterSelector)
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	if len(clusters) == 0 {
+		badRequest(e, "No cluster matches the cluster selector.")
+		return
+	}
+
+	for _, cluster := range clusters {
+		if !allow(e, data.RoleEditor, cluster.Resource()) {
+			return
+		}
+
+		silence.Targets = append(silence.Targets, &data.SilenceTarget{ClusterId: cluster.Id})
+	}
+
+	if err := e.Models().Silences.Insert(silence); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			conflict(e, "A selected cluster was deleted, please try again.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(e.Request.Context(), silenceFanOutTimeout)
+	defer cancel()
+
+	if err := core.CreateSilence(ctx, e.App, e.Models(), silence); err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	e.SetChange(core.ModelCreate, "silence", silence.Id, nil, silence)
+
+	silenceResponse(e, silence, http.StatusCreated)
+}
+
+// retrySilence creates the silence on the clusters it failed on.
+func retrySilence(e *core.EventRequest) {
+	silence, ok := loadSilence(e)
+	if !ok {
+		return
+	}
+
+	if !allowSilenceTargets(e, data.RoleEditor, silence) {
+		return
+	}
+
+	if silence.Status == data.SilenceExpired {
+		conflict(e, "Silence has expired.")
+		return
+	}
+
+	e.Audit.SetChange("silence", silence.Id, nil, nil)
+
+	ctx, cancel := context.WithTimeout(e.Request.Context(), silenceFanOutTimeout)
+	defer cancel()
+
+	if err := core.CreateSilence(ctx, e.App, e.Models(), silence); err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	silenceResponse(e, silence, http.StatusOK)
+}
+
+// expireSilence expires the silence on all the clusters it was created on.
+// Expiring an expired silence retries the clusters it failed to expire on.
+func expireSilence(e *core.EventRequest) {
+	silence, ok := loadSilence(e)
+	if !ok {
+		return
+	}
+
+	if !allowSilenceTargets(e, data.RoleEditor, silence) {
+		return
+	}
+
+	if silence.Status == data.SilenceExpired && (silence.ExpiredAt == nil || !hasCreatedTargets(silence)) {
+		conflict(e, "Silence has already expired.")
+		return
+	}
+
+	before := *silence
+
+	ctx, cancel := context.WithTimeout(e.Request.Context(), silenceFanOutTimeout)
+	defer cancel()
+
+	if err := core.ExpireSilence(ctx, e.App, e.Models(), silence); err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	e.SetChange(core.ModelUpdate, "silence", silence.Id, &before, silence)
+
+	silenceResponse(e, silence, http.StatusOK)
+}
+
+// allowSilenceTargets reports whether the caller is granted the role on all
+// the clusters the silence targets, and responds with a 403 error when it
+// is not. Deleted clusters are ignored.
+func allowSilenceTargets(e *core.EventRequest, role string, silence *data.Silence) bool {
+	for _, target := range silence.Targets {
+		cluster, err := e.Models().Clusters.GetById(target.ClusterId)
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				continue
+			}
+			internalServerError(e, err)
+			return false
+		}
+
+		if !allow(e, role, cluster.Resource()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// allowsSilenceTargets reports whether the clusters the silence targets are
+// all allowed, deleted clusters being ignored.
+func allowsSilenceTargets(allowed map[string]bool, silence *data.Silence) bool {
+	for _, target := range silence.Targets {
+		if ok, exists := allowed[target.ClusterId]; exists && !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasCreatedTargets(silence *data.Silence) bool {
+	for _, target := range silence.Targets {
+		if target.Status == data.SilenceTargetCreated {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSilence returns the validation error message of the silence, or
+// an empty string when it is valid.
+func validateSilence(silence *data.Silence, now time.Time) string {
+	if len(silence.Matchers) == 0 {
+		return "At least one matcher must be given."
+	}
+
+	matchers, err := alertmanager.ParseMatchers(silence.Matchers)
+	if err != nil {
+		return fmt.Sprintf("Invalid matcher: %v.", err)
+	}
+
+	// Like Alertmanager, refuse silences muting every alert.
+	if alertmanager.MatchesAll(matchers, map[string]string{}) {
+		return "At least one matcher must not match the empty string."
+	}
+
+	for name := range silence.ClusterSelector {
+		if !labelNameRegex.MatchString(name) {
+			return fmt.Sprintf("Invalid cluster selector label name %q.", name)
+		}
+	}
+
+	if !silence.EndsAt.After(silence.StartsAt) {
+		return "Silence must end after it starts."
+	}
+
+	if !silence.EndsAt.After(now) {
+		return "Silence must end in the future."
+	}
+
+	if silence.Comment == "" {
+		return "Comment must be provided."
+	}
+
+	if len(silence.Comment) > maxCommentLen {
+		return fmt.Sprintf("Comment must not be more than %d bytes long.", maxCommentLen)
+	}
+
+	return ""
+}
+
+// silenceResponse responds with the silence, or with a 304 response to the
+// conditional requests of clients already holding it.
+func silenceResponse(e *core.EventRequest, silence *data.Silence, status int) {
+	resp := struct {
+		Silence *data.Silence `json:"silence"`
+	}{
+		Silence: silence,
+	}
+
+	if err := e.JsonConditional(resp, status, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
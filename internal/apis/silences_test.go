@@ -0,0 +1,300 @@
+package apis
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+func TestSilences(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+
+	stagingA := app.NewCluster(team, "payments-staging-a", map[string]string{"env": "staging"})
+	stagingB := app.NewCluster(team, "payments-staging-b", map[string]string{"env": "staging"})
+	prod := app.NewCluster(team, "payments-prod", map[string]string{"env": "production"})
+
+	amA := tests.NewAlertmanager("")
+	defer amA.Close()
+
+	amProd := tests.NewAlertmanager("")
+	defer amProd.Close()
+
+	// The Alertmanager of the second staging cluster is down.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	stagingA.AlertmanagerUrl = amA.Server.URL
+	stagingB.AlertmanagerUrl = down.URL
+	prod.AlertmanagerUrl = amProd.Server.URL
+
+	for _, cluster := range []*data.Cluster{stagingA, stagingB, prod} {
+		_ = app.Clusters.Update(cluster)
+	}
+
+	editor := app.NewUser("editor@example.com")
+	app.Bind(editor, data.RoleEditor, data.ScopeTypeTeam, team.Id)
+	editorToken := app.NewToken(editor, data.ScopeRead, data.ScopeWrite)
+
+	viewer := app.NewUser("viewer@example.com")
+	app.Bind(viewer, data.RoleViewer, data.ScopeTypeTeam, team.Id)
+	viewerToken := app.NewToken(viewer, data.ScopeRead, data.ScopeWrite)
+
+	body := `{
+		"matchers": ["alertname=\"HighLatency\""],
+		"cluster_selector": {"env": "staging"},
+		"duration": "2h",
+		"comment": "Load test"
+	}`
+
+	serveAlertmanagerRequest(t, app, viewerToken, http.MethodPost, "/api/v1/silences", body, http.StatusForbidden)
+
+	rec := serveAlertmanagerRequest(t, app, editorToken, http.MethodPost, "/api/v1/silences", body, http.StatusCreated)
+
+	var resp struct {
+		Silence data.Silence `json:"silence"`
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response body; %v", err)
+	}
+
+	silence := resp.Silence
+
+	if silence.Status != data.SilenceActive || len(silence.Targets) != 2 {
+		t.Fatalf("expected active silence targeting the staging clusters, got %+v", silence)
+	}
+
+	targets := map[string]*data.SilenceTarget{}
+	for _, target := range silence.Targets {
+		targets[target.ClusterId] = target
+	}
+
+	if target := targets[stagingA.Id]; target == nil || target.Status != data.SilenceTargetCreated {
+		t.Fatalf("expected silence to be created on %s, got %+v", stagingA.Name, target)
+	}
+
+	if target := targets[stagingB.Id]; target == nil || target.Status != data.SilenceTargetFailed || target.Error == "" {
+		t.Fatalf("expected silence to fail on %s, got %+v", stagingB.Name, target)
+	}
+
+	created := amA.Silences()
+	if len(created) != 1 || created[0].Matchers[0].Name != "alertname" || created[0].Matchers[0].Value != "HighLatency" {
+		t.Fatalf("expected silence on the staging alertmanager, got %+v", created)
+	}
+
+	if d := created[0].EndsAt.Sub(created[0].StartsAt); d.Hours() != 2 {
+		t.Fatalf("expected silence to last 2h, got %v", d)
+	}
+
+	if len(amProd.Silences()) != 0 {
+		t.Fatal("expected no silence on the production alertmanager")
+	}
+
+	if !app.Logs.Contains("silence target failed") {
+		t.Fatal("expected silence failure to be logged")
+	}
+
+	url := "/api/v1/silences/" + silence.Id
+
+	// Once its Alertmanager is back, retrying only creates the silence on
+	// the cluster it failed on.
+	amB := tests.NewAlertmanager("")
+	defer amB.Close()
+
+	stagingB.AlertmanagerUrl = amB.Server.URL
+	_ = app.Clusters.Update(stagingB)
+
+	serveAlertmanagerRequest(t, app, viewerToken, http.MethodPost, url+"/retry", "", http.StatusForbidden)
+	serveAlertmanagerRequest(t, app, editorToken, http.MethodPost, url+"/retry", "", http.StatusOK)
+
+	if len(amA.Silences()) != 1 || len(amB.Silences()) != 1 {
+		t.Fatalf("expected one silence on each staging alertmanager, got %d and %d", len(amA.Silences()), len(amB.Silences()))
+	}
+
+	rec = serveAlertmanagerRequest(t, app, viewerToken, http.MethodGet, url, "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"status":"created"`})
+
+	if strings.Contains(rec.Body.String(), `"status":"failed"`) {
+		t.Fatalf("expected no failed target, got %s", rec.Body.String())
+	}
+
+	rec = serveAlertmanagerRequest(t, app, viewerToken, http.MethodGet, "/api/v1/silences", "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"id":"` + silence.Id + `"`, `"cluster_selector":{"env":"staging"}`})
+
+	serveAlertmanagerRequest(t, app, viewerToken, http.MethodPost, url+"/expire", "", http.StatusForbidden)
+
+	// The second staging cluster lost its Alertmanager url, the silence
+	// created there is still active.
+	stagingB.AlertmanagerUrl = ""
+	_ = app.Clusters.Update(stagingB)
+
+	rec = serveAlertmanagerRequest(t, app, editorToken, http.MethodPost, url+"/expire", "", http.StatusOK)
+	testBodyContent(t, rec, []string{`"status":"expired"`, `"expired_at":"`})
+
+	if silences := amA.Silences(); !silences[0].Expired {
+		t.Fatalf("expected silence to be expired, got %+v", silences[0])
+	}
+
+	expired, _ := app.Silences.GetById(silence.Id)
+	for _, target := range expired.Targets {
+		if target.ClusterId == stagingB.Id && (target.Status != data.SilenceTargetCreated || target.Error == "") {
+			t.Fatalf("expected target failing to expire to keep its status, got %+v", target)
+		}
+	}
+
+	// Expiring the silence again retries the target once it is reachable.
+	stagingB.AlertmanagerUrl = amB.Server.URL
+	_ = app.Clusters.Update(stagingB)
+
+	serveAlertmanagerRequest(t, app, editorToken, http.MethodPost, url+"/expire", "", http.StatusOK)
+
+	if silences := amB.Silences(); !silences[0].Expired {
+		t.Fatalf("expected silence to be expired, got %+v", silences[0])
+	}
+
+	events := app.Audit.Events()
+	if last := events[len(events)-1]; last.ResourceType != "silence" || last.ResourceId != silence.Id || last.Before == nil {
+		t.Fatalf("expected silence expiry to be audited, got %+v", last)
+	}
+
+	serveAlertmanagerRequest(t, app, editorToken, http.MethodPost, url+"/expire", "", http.StatusConflict)
+	serveAlertmanagerRequest(t, app, editorToken, http.MethodPost, url+"/retry", "", http.StatusConflict)
+}
+
+func TestSilencesAccess(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	payments := app.NewTeam("payments")
+	search := app.NewTeam("search")
+
+	app.NewCluster(payments, "payments-staging", map[string]string{"env": "staging"})
+	app.NewCluster(search, "search-staging", map[string]string{"env": "staging"})
+
+	editor := app.NewUser("editor@example.com")
+	app.Bind(editor, data.RoleEditor, data.ScopeTypeTeam, payments.Id)
+	token := app.NewToken(editor, data.ScopeRead, data.ScopeWrite)
+
+	// The selector matches a cluster of another team.
+	body := `{"matchers": ["alertname=\"X\""], "cluster_selector": {"env": "staging"}, "duration": "1h", "comment": "test"}`
+	serveAlertmanagerRequest(t, app, token, http.MethodPost, "/api/v1/silences", body, http.StatusForbidden)
+
+	if len(app.Audit.Events()) != 1 {
+		t.Fatal("expected denied silence to be audited")
+	}
+
+	// The silence was not created, and no Alertmanager was called.
+	silences, _, _ := app.Silences.List(nil)
+	if len(silences) != 0 {
+		t.Fatalf("expected no silence, got %d", len(silences))
+	}
+}
+
+func TestSilencesVisibility(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	payments := app.NewTeam("payments")
+	search := app.NewTeam("search")
+
+	cluster := app.NewCluster(payments, "payments-staging", map[string]string{"env": "staging"})
+	app.NewCluster(search, "search-staging", map[string]string{"env": "production"})
+
+	am := tests.NewAlertmanager("")
+	defer am.Close()
+
+	cluster.AlertmanagerUrl = am.Server.URL
+	_ = app.Clusters.Update(cluster)
+
+	editor := app.NewUser("editor@example.com")
+	app.Bind(editor, data.RoleEditor, data.ScopeTypeTeam, payments.Id)
+	editorToken := app.NewToken(editor, data.ScopeRead, data.ScopeWrite)
+
+	viewer := app.NewUser("viewer@example.com")
+	app.Bind(viewer, data.RoleViewer, data.ScopeTypeTeam, search.Id)
+	viewerToken := app.NewToken(viewer, data.ScopeRead)
+
+	body := `{"matchers": ["alertname=\"X\""], "cluster_selector": {"env": "staging"}, "duration": "1h", "comment": "test"}`
+	rec := serveAlertmanagerRequest(t, app, editorToken, http.MethodPost, "/api/v1/silences", body, http.StatusCreated)
+
+	var resp struct {
+		Silence data.Silence `json:"silence"`
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response body; %v", err)
+	}
+
+	url := "/api/v1/silences/" + resp.Silence.Id
+
+	rec = serveAlertmanagerRequest(t, app, editorToken, http.MethodGet, "/api/v1/silences", "", http.StatusOK)
+	testBodyContent(t, rec, []string{resp.Silence.Id})
+	serveAlertmanagerRequest(t, app, editorToken, http.MethodGet, url, "", http.StatusOK)
+
+	// The silences of the clusters of another team are hidden.
+	rec = serveAlertmanagerRequest(t, app, viewerToken, http.MethodGet, "/api/v1/silences", "", http.StatusOK)
+	if strings.Contains(rec.Body.String(), resp.Silence.Id) {
+		t.Fatalf("expected silence of another team not to be listed, got %s", rec.Body.String())
+	}
+	serveAlertmanagerRequest(t, app, viewerToken, http.MethodGet, url, "", http.StatusForbidden)
+}
+
+func TestSilencesValidation(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	app.NewCluster(team, "payments-staging", map[string]string{"env": "staging"})
+
+	editor := app.NewUser("editor@example.com")
+	app.Bind(editor, data.RoleEditor, data.ScopeTypeTeam, team.Id)
+	token := app.NewToken(editor, data.ScopeRead, data.ScopeWrite)
+
+	testCases := []struct {
+		name string
+		body string
+		msg  string
+	}{
+		{"no matchers", `{"duration": "1h", "comment": "c"}`, "At least one matcher must be given"},
+		{"invalid matcher", `{"matchers": ["alert name=x"], "duration": "1h", "comment": "c"}`, "Invalid matcher"},
+		{"matches everything", `{"matchers": ["alertname=~\".*\""], "duration": "1h", "comment": "c"}`, "must not match the empty string"},
+		{"no end", `{"matchers": ["alertname=\"X\""], "comment": "c"}`, "Either ends_at or duration must be given"},
+		{"end and duration", `{"matchers": ["alertname=\"X\""], "duration": "1h", "ends_at": "2030-01-01T00:00:00Z", "comment": "c"}`, "Only one of ends_at and duration"},
+		{"invalid duration", `{"matchers": ["alertname=\"X\""], "duration": "2 hours", "comment": "c"}`, "Invalid duration"},
+		{"ended", `{"matchers": ["alertname=\"X\""], "starts_at": "2020-01-01T00:00:00Z", "ends_at": "2020-01-02T00:00:00Z", "comment": "c"}`, "must end in the future"},
+		{"no comment", `{"matchers": ["alertname=\"X\""], "duration": "1h"}`, "Comment must be provided"},
+		{"invalid selector", `{"matchers": ["alertname=\"X\""], "cluster_selector": {"a-b": "c"}, "duration": "1h", "comment": "c"}`, "Invalid cluster selector label name"},
+		{"no cluster", `{"matchers": ["alertname=\"X\""], "cluster_selector": {"env": "production"}, "duration": "1h", "comment": "c"}`, "No cluster matches the cluster selector"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveAlertmanagerRequest(t, app, token, http.MethodPost, "/api/v1/silences", tc.body, http.StatusBadRequest)
+			testBodyContent(t, rec, []string{tc.msg})
+		})
+	}
+}
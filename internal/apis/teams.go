@@ -0,0 +1,171 @@
+package apis
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+const maxNameLen = 100
+
+func listTeams(e *core.EventRequest) {
+	q, apiErr := e.ListQuery()
+	if apiErr != nil {
+		errorResponse(e, apiErr)
+		return
+	}
+
+	teams, next, err := e.Models().Teams.List(q)
+	if err != nil {
+		var lqe *data.ListQueryError
+		switch {
+		case errors.As(err, &lqe):
+			badRequest(e, lqe.Message)
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	permissions, ok := loadPermissions(e)
+	if !ok {
+		return
+	}
+
+	// Only the teams the caller can view are listed, pages may then be
+	// shorter than the limit.
+	visible := make([]*data.Team, 0, len(teams))
+	for _, team := range teams {
+		if permissions.Allows(data.RoleViewer, data.Resource{TeamId: team.Id}) {
+			visible = append(visible, team)
+		}
+	}
+
+	resp := struct {
+		Teams      []*data.Team `json:"teams"`
+		NextCursor string       `json:"next_cursor"`
+	}{
+		Teams:      visible,
+		NextCursor: next,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func createTeam(e *core.EventRequest) {
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	team := &data.Team{
+		Name: strings.TrimSpace(input.Name),
+	}
+
+	if msg := validateName("Team", team.Name); msg != "" {
+		badRequest(e, msg)
+		return
+	}
+
+	if err := e.Models().Teams.Insert(team); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTeamName):
+			conflict(e, "A team with this name already exists.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelCreate, "team", team.Id, nil, team)
+
+	resp := struct {
+		Team *data.Team `json:"team"`
+	}{
+		Team: team,
+	}
+
+	if err := e.Json(resp, http.StatusCreated); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func getTeam(e *core.EventRequest) {
+	team, err := e.Models().Teams.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Team not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	resp := struct {
+		Team *data.Team `json:"team"`
+	}{
+		Team: team,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func deleteTeam(e *core.EventRequest) {
+	team, err := e.Models().Teams.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Team not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	err = e.Models().Teams.Delete(team.Id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Team not found.")
+		case errors.Is(err, data.ErrTeamNotEmpty):
+			conflict(e, "Team still owns clusters.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelDelete, "team", team.Id, team, nil)
+
+	e.NoContent()
+}
+
+// validateName returns the validation error message of a resource name, or
+// an empty string when it is valid.
+func validateName(resource string, name string) string {
+	switch {
+	case name == "":
+		return fmt.Sprintf("%s name must not be empty.", resource)
+	case len(name) > maxNameLen:
+		return fmt.Sprintf("%s name must not be longer than %d characters.", resource, maxNameLen)
+	default:
+		return ""
+	}
+}
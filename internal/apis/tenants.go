@@ -0,0 +1,203 @@
+package apis
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+// scopeTenant runs the handler with the models scoped to the tenant of the
+// authenticated user, and releases them once it is done.
+func scopeTenant(next func(*core.EventRequest)) func(*core.EventRequest) {
+	return func(e *core.EventRequest) {
+		models, release, err := e.Models().Scope(e.Auth.TenantId)
+		if err != nil {
+			internalServerError(e, err)
+			return
+		}
+
+		e.TenantModels = models.Trace(e.Request.Context())
+
+		defer func() {
+			e.TenantModels = nil
+			release()
+		}()
+
+		next(e)
+	}
+}
+
+// operatorResource restricts the route to the operators of the service,
+// which manage the tenants.
+func operatorResource(e *core.EventRequest) (data.Resource, bool) {
+	if !e.Auth.IsOperator {
+		e.App.Logger().WarnContext(e.Request.Context(), "access denied",
+			slog.String("user_id", e.Auth.Id),
+			slog.String("tenant_id", e.Auth.TenantId),
+			slog.String("method", e.Request.Method),
+			slog.String("request", e.Request.RequestURI),
+		)
+		forbidden(e, "You must be an operator of the service to access this resource.")
+		return data.Resource{}, false
+	}
+
+	return data.Resource{}, true
+}
+
+// Tenants are managed across tenants, so the routes of the handlers below
+// are global, their models are not scoped to the tenant of the operator.
+
+func listTenants(e *core.EventRequest) {
+	q, apiErr := e.ListQuery()
+	if apiErr != nil {
+		errorResponse(e, apiErr)
+		return
+	}
+
+	tenants, next, err := e.Models().Tenants.List(q)
+	if err != nil {
+		var lqe *data.ListQueryError
+		switch {
+		case errors.As(err, &lqe):
+			badRequest(e, lqe.Message)
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	resp := struct {
+		Tenants    []*data.Tenant `json:"tenants"`
+		NextCursor string         `json:"next_cursor"`
+	}{
+		Tenants:    tenants,
+		NextCursor: next,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func createTenant(e *core.EventRequest) {
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	tenant := &data.Tenant{
+		Name: strings.TrimSpace(input.Name),
+	}
+
+	if msg := validateName("Tenant", tenant.Name); msg != "" {
+		badRequest(e, msg)
+		return
+	}
+
+	if err := e.Models().Tenants.Insert(tenant); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTenantName):
+			conflict(e, "A tenant with this name already exists.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelCreate, "tenant", tenant.Id, nil, tenant)
+
+	resp := struct {
+		Tenant *data.Tenant `json:"tenant"`
+	}{
+		Tenant: tenant,
+	}
+
+	if err := e.Json(resp, http.StatusCreated); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// addTenantUser moves a user to the tenant, optionally binding it a global
+// role there, e.g. to make the first admin of a new tenant.
+func addTenantUser(e *core.EventRequest) {
+	var input struct {
+		Role string `json:"role"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	if input.Role != "" && !data.IsValidRole(input.Role) {
+		badRequest(e, fmt.Sprintf(
+			"Invalid role %q, must be one of %s.",
+			input.Role, strings.Join(data.Roles, ", "),
+		))
+		return
+	}
+
+	tenant, err := e.Models().Tenants.GetById(e.Request.PathValue("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Tenant not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	before, err := e.Models().Users.GetById(e.Request.PathValue("user_id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "User not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	err = e.Models().Tenants.AddUser(tenant.Id, before.Id, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "User not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	user, err := e.Models().Users.GetById(before.Id)
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	e.SetChange(core.ModelUpdate, "user", user.Id, before, user)
+
+	resp := struct {
+		User *data.User `json:"user"`
+	}{
+		User: user,
+	}
+
+	if err := e.Json(resp, http.StatusOK); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
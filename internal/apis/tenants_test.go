@@ -0,0 +1,228 @@
+package apis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	tenant := app.NewTenant("search-bu")
+
+	payments := app.NewTeam("platform")
+	paymentsCluster := app.NewCluster(payments, "payments-prod", nil)
+
+	search := app.NewTenantTeam(tenant.Id, "platform")
+	app.NewCluster(search, "search-prod", nil)
+
+	operator := app.NewUser("operator@example.com")
+	operator.Roles = []string{data.RoleAdmin}
+
+	admin := app.NewUser("admin@example.com")
+	if err := app.Tenants.AddUser(tenant.Id, admin.Id, data.RoleAdmin); err != nil {
+		t.Fatalf("failed to add user to tenant; %v", err)
+	}
+
+	token := app.NewToken(admin, data.ScopeRead, data.ScopeWrite)
+
+	testCases := []struct {
+		name    string
+		method  string
+		url     string
+		body    string
+		status  int
+		content []string
+	}{
+		{
+			name:    "lists own clusters",
+			method:  http.MethodGet,
+			url:     "/api/v1/clusters",
+			status:  http.StatusOK,
+			content: []string{`"name":"search-prod"`, `"tenant_id":"` + tenant.Id + `"`},
+		},
+		{
+			name:   "cannot read other tenant cluster",
+			method: http.MethodGet,
+			url:    "/api/v1/clusters/" + paymentsCluster.Id,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "cannot delete other tenant cluster",
+			method: http.MethodDelete,
+			url:    "/api/v1/clusters/" + paymentsCluster.Id,
+			status: http.StatusNotFound,
+		},
+		{
+			name:    "cannot add clusters to other tenant team",
+			method:  http.MethodPost,
+			url:     "/api/v1/clusters",
+			body:    `{"name":"search-staging","team_id":"` + payments.Id + `"}`,
+			status:  http.StatusBadRequest,
+			content: []string{`not found`},
+		},
+		{
+			name:    "cannot bind roles to other tenant users",
+			method:  http.MethodPost,
+			url:     "/api/v1/role-bindings",
+			body:    `{"user_id":"` + operator.Id + `","role":"viewer","scope_type":"global"}`,
+			status:  http.StatusBadRequest,
+			content: []string{`not found`},
+		},
+		{
+			name:    "reuses names of other tenants",
+			method:  http.MethodPost,
+			url:     "/api/v1/clusters",
+			body:    `{"name":"payments-prod","team_id":"` + search.Id + `"}`,
+			status:  http.StatusCreated,
+			content: []string{`"tenant_id":"` + tenant.Id + `"`},
+		},
+		{
+			name:   "cannot manage tenants",
+			method: http.MethodGet,
+			url:    "/api/v1/tenants",
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rec := serveTestRequest(app, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status code to be %d, got %d", tc.status, rec.Code)
+			}
+
+			if len(tc.content) > 0 {
+				testBodyContent(t, rec, tc.content)
+			}
+
+			if strings.Contains(rec.Body.String(), "payments-prod\",\"team_id\":\""+payments.Id) {
+				t.Fatal("expected response not to include data of other tenants")
+			}
+		})
+	}
+
+	// The audit events of the tenant are bound to it, and only listed to
+	// its own admins.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/audit", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec := serveTestRequest(app, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	testBodyContent(t, rec, []string{`"tenant_id":"` + tenant.Id + `"`})
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/audit", nil)
+	req.Header.Set("Authorization", "Bearer "+app.NewToken(operator, data.ScopeRead))
+
+	rec = serveTestRequest(app, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if strings.Contains(rec.Body.String(), tenant.Id) {
+		t.Fatal("expected audit events of other tenants not to be listed")
+	}
+}
+
+func TestTenants(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	operator := app.NewUser("operator@example.com")
+	operator.Roles = []string{data.RoleAdmin}
+	operator.IsOperator = true
+	token := app.NewToken(operator, data.ScopeRead, data.ScopeWrite)
+
+	user := app.NewUser("test@example.com")
+	app.Bind(user, data.RoleViewer, data.ScopeTypeGlobal, "")
+	userToken := app.NewToken(user, data.ScopeRead)
+
+	// The admins of the default tenant, which self-registered and single
+	// sign-on users join, are not operators.
+	admin := app.NewUser("admin@example.com")
+	admin.Roles = []string{data.RoleAdmin}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tenants", strings.NewReader(`{"name":"search-bu"}`))
+	req.Header.Set("Authorization", "Bearer "+app.NewToken(admin, data.ScopeRead, data.ScopeWrite))
+
+	if rec := serveTestRequest(app, req); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/tenants", strings.NewReader(`{"name":"search-bu"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec := serveTestRequest(app, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	tenants, _, _ := app.Tenants.List(nil)
+	tenant := tenants[len(tenants)-1]
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/tenants", strings.NewReader(`{"name":"search-bu"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	if rec := serveTestRequest(app, req); rec.Code != http.StatusConflict {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusConflict, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/tenants/"+tenant.Id+"/users/"+user.Id, strings.NewReader(`{"role":"owner"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	if rec := serveTestRequest(app, req); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/tenants/"+tenant.Id+"/users/"+user.Id, strings.NewReader(`{"role":"admin"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rec = serveTestRequest(app, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	testBodyContent(t, rec, []string{`"tenant_id":"` + tenant.Id + `"`})
+
+	// Moving the user revokes the credentials and roles granted in the
+	// previous tenant, and binds the new role.
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/clusters", nil)
+	req.Header.Set("Authorization", "Bearer "+userToken)
+
+	if rec := serveTestRequest(app, req); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	bindings, _ := app.RoleBindings.ListForUser(user.Id)
+	if len(bindings) != 1 || bindings[0].Role != data.RoleAdmin || bindings[0].ScopeType != data.ScopeTypeGlobal {
+		t.Fatalf("expected user to only be a global admin, got %+v", bindings)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/tenants/unknown/users/"+user.Id, strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	if rec := serveTestRequest(app, req); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
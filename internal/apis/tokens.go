@@ -0,0 +1,196 @@
+package apis
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+const (
+	defaultTokenTTL = time.Hour * 24 * 90
+	maxTokenTTL     = time.Hour * 24 * 365
+	maxTokenNameLen = 100
+)
+
+func listTokens(e *core.EventRequest) {
+	q, apiErr := e.ListQuery()
+	if apiErr != nil {
+		errorResponse(e, apiErr)
+		return
+	}
+
+	tokens, next, err := e.Models().Tokens.ListForUser(e.Auth.Id, q)
+	if err != nil {
+		var lqe *data.ListQueryError
+		switch {
+		case errors.As(err, &lqe):
+			badRequest(e, lqe.Message)
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	resp := struct {
+		Tokens     []*data.Token `json:"tokens"`
+		NextCursor string        `json:"next_cursor"`
+	}{
+		Tokens:     tokens,
+		NextCursor: next,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func createToken(e *core.EventRequest) {
+	var input struct {
+		Name      string             `json:"name"`
+		Scopes    []string           `json:"scopes"`
+		Roles     []roleBindingInput `json:"roles"`
+		ExpiresAt *time.Time         `json:"expires_at"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	now := time.Now()
+
+	expiresAt := now.Add(defaultTokenTTL)
+	if input.ExpiresAt != nil {
+		expiresAt = *input.ExpiresAt
+	}
+
+	// A token cannot be used to mint a token that outlives it.
+	if e.Token != nil && expiresAt.After(e.Token.ExpiresAt) {
+		expiresAt = e.Token.ExpiresAt
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+
+	switch {
+	case input.Name == "":
+		badRequest(e, "Token name must not be empty.")
+		return
+	case len(input.Name) > maxTokenNameLen:
+		badRequest(e, fmt.Sprintf("Token name must not be longer than %d characters.", maxTokenNameLen))
+		return
+	case len(input.Scopes) == 0:
+		badRequest(e, "Token must have at least one scope.")
+		return
+	case !expiresAt.After(now):
+		badRequest(e, "Token expiration must be in the future.")
+		return
+	case expiresAt.After(now.Add(maxTokenTTL)):
+		badRequest(e, "Token expiration must be within one year.")
+		return
+	}
+
+	scopes := []string{}
+
+	for _, scope := range input.Scopes {
+		if !slices.Contains(data.Scopes, scope) {
+			badRequest(e, fmt.Sprintf(
+				"Invalid scope %q, must be one of %s.",
+				scope, strings.Join(data.Scopes, ", "),
+			))
+			return
+		}
+
+		if e.Token != nil && !e.Token.HasScope(scope) {
+			forbidden(e, fmt.Sprintf("Token cannot grant the %q scope it does not hold.", scope))
+			return
+		}
+
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	token, err := data.NewToken(e.Auth.Id, input.Name, scopes, expiresAt)
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	// A token restricted to roles only mints tokens restricted to roles it
+	// holds, the ones of the caller token when none are listed, since a
+	// token without roles holds all the roles of its owner.
+	if e.Token != nil && len(e.Token.Roles) > 0 && len(input.Roles) == 0 {
+		for _, role := range e.Token.Roles {
+			token.Roles = append(token.Roles, &data.RoleBinding{
+				Role:      role.Role,
+				ScopeType: role.ScopeType,
+				ScopeId:   role.ScopeId,
+			})
+		}
+	}
+
+	// Tokens can be restricted to roles the caller holds.
+	for _, role := range input.Roles {
+		resource, ok := bindingResource(e, role)
+		if !ok {
+			return
+		}
+
+		if !allow(e, role.Role, resource) {
+			return
+		}
+
+		token.Roles = append(token.Roles, &data.RoleBinding{
+			Role:      role.Role,
+			ScopeType: role.ScopeType,
+			ScopeId:   role.ScopeId,
+		})
+	}
+
+	if err := e.Models().Tokens.Insert(token); err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	// The plaintext is only ever handed to the client.
+	audit := *token
+	audit.Plaintext = ""
+	e.SetChange(core.ModelCreate, "token", token.Id, nil, &audit)
+
+	resp := struct {
+		Token *data.Token `json:"token"`
+	}{
+		Token: token,
+	}
+
+	if err := e.Json(resp, http.StatusCreated); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func revokeToken(e *core.EventRequest) {
+	id := e.Request.PathValue("id")
+
+	err := e.Models().Tokens.DeleteForUser(id, e.Auth.Id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			notFound(e, "Token not found.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelDelete, "token", id, nil, nil)
+
+	e.NoContent()
+}
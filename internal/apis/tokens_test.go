@@ -0,0 +1,205 @@
+package apis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+func TestCreateToken(t *testing.T) {
+	t.Parallel()
+
+	farFuture := time.Now().Add(time.Hour * 24 * 400).Format(time.RFC3339)
+
+	testCases := []struct {
+		name    string
+		scopes  []string
+		body    string
+		status  int
+		content []string
+	}{
+		{
+			name:    "success",
+			scopes:  []string{data.ScopeRead, data.ScopeWrite},
+			body:    `{"name":"ci","scopes":["read","read"]}`,
+			status:  http.StatusCreated,
+			content: []string{`"token":"sh_`, `"name":"ci"`, `"scopes":["read"]`},
+		},
+		{
+			name:    "empty name",
+			scopes:  []string{data.ScopeWrite},
+			body:    `{"name":" ","scopes":["read"]}`,
+			status:  http.StatusBadRequest,
+			content: []string{`"message":"Token name must not be empty."`},
+		},
+		{
+			name:    "no scopes",
+			scopes:  []string{data.ScopeWrite},
+			body:    `{"name":"ci","scopes":[]}`,
+			status:  http.StatusBadRequest,
+			content: []string{`"message":"Token must have at least one scope."`},
+		},
+		{
+			name:    "invalid scope",
+			scopes:  []string{data.ScopeWrite},
+			body:    `{"name":"ci","scopes":["admin"]}`,
+			status:  http.StatusBadRequest,
+			content: []string{`"message":"Invalid scope \"admin\", must be one of read, write."`},
+		},
+		{
+			name:    "expiration in the past",
+			scopes:  []string{data.ScopeWrite},
+			body:    `{"name":"ci","scopes":["read"],"expires_at":"2020-01-01T00:00:00Z"}`,
+			status:  http.StatusBadRequest,
+			content: []string{`"message":"Token expiration must be in the future."`},
+		},
+		{
+			name:    "scope escalation",
+			scopes:  []string{data.ScopeWrite},
+			body:    `{"name":"ci","scopes":["read"],"expires_at":"` + farFuture + `"}`,
+			status:  http.StatusForbidden,
+			content: []string{`"message":"Token cannot grant the \"read\" scope it does not hold."`},
+		},
+		{
+			name:    "malformed body",
+			scopes:  []string{data.ScopeWrite},
+			body:    `{"name":`,
+			status:  http.StatusBadRequest,
+			content: []string{`"message":"Malformed json content in request body."`},
+		},
+		{
+			name:    "read only token",
+			scopes:  []string{data.ScopeRead},
+			body:    `{"name":"ci","scopes":["read"]}`,
+			status:  http.StatusForbidden,
+			content: []string{`"message":"Token is missing the \"write\" scope."`},
+		},
+	}
+
+	for _, tc := range testCases {
+		headers := map[string]string{}
+
+		s := apiTestScenario{
+			name:    tc.name,
+			method:  http.MethodPost,
+			url:     "/api/v1/tokens",
+			body:    strings.NewReader(tc.body),
+			headers: headers,
+			status:  tc.status,
+			content: tc.content,
+			beforeTestFunc: func(_ testing.TB, app *tests.TestApp) {
+				user := app.NewUser("test@example.com")
+				headers["Authorization"] = "Bearer " + app.NewToken(user, tc.scopes...)
+			},
+		}
+
+		s.test(t)
+	}
+}
+
+func TestListTokens(t *testing.T) {
+	t.Parallel()
+
+	headers := map[string]string{}
+
+	s := apiTestScenario{
+		method:  http.MethodGet,
+		url:     "/api/v1/tokens?sort=-created_at",
+		headers: headers,
+		status:  http.StatusOK,
+		content: []string{`"tokens":[{`, `"name":"test"`, `"next_cursor":""`},
+		beforeTestFunc: func(t testing.TB, app *tests.TestApp) {
+			user := app.NewUser("test@example.com")
+			headers["Authorization"] = "Bearer " + app.NewToken(user, data.ScopeRead)
+
+			// Tokens of other users must not be listed.
+			other := app.NewUser("other@example.com")
+			app.NewToken(other, data.ScopeRead)
+
+			tokens, _, _ := app.Tokens.ListForUser(user.Id, nil)
+			if len(tokens) != 1 {
+				t.Fatalf("expected 1 token, got %d", len(tokens))
+			}
+		},
+	}
+
+	s.test(t)
+
+	s = apiTestScenario{
+		method:  http.MethodGet,
+		url:     "/api/v1/tokens?limit=0",
+		headers: headers,
+		status:  http.StatusBadRequest,
+		content: []string{`"message":"Limit must be a number between 1 and 500."`},
+		beforeTestFunc: func(_ testing.TB, app *tests.TestApp) {
+			user := app.NewUser("test@example.com")
+			headers["Authorization"] = "Bearer " + app.NewToken(user, data.ScopeRead)
+		},
+	}
+
+	s.test(t)
+}
+
+func TestRevokeToken(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		own     bool
+		status  int
+		content []string
+	}{
+		{
+			name:   "own token",
+			own:    true,
+			status: http.StatusNoContent,
+		},
+		{
+			name:    "token of another user",
+			own:     false,
+			status:  http.StatusNotFound,
+			content: []string{`"message":"Token not found."`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, err := tests.NewTestApp()
+			if err != nil {
+				t.Fatalf("failed to initialize test app instance; %v", err)
+			}
+
+			user := app.NewUser("test@example.com")
+			plaintext := app.NewToken(user, data.ScopeWrite)
+
+			owner := user
+			if !tc.own {
+				owner = app.NewUser("other@example.com")
+				app.NewToken(owner, data.ScopeWrite)
+			}
+
+			tokens, _, _ := app.Tokens.ListForUser(owner.Id, nil)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/tokens/"+tokens[0].Id, nil)
+			req.Header.Set("Authorization", "Bearer "+plaintext)
+
+			rec := serveTestRequest(app, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status code to be %d, got %d", tc.status, rec.Code)
+			}
+
+			testBodyContent(t, rec, tc.content)
+
+			_, err = app.Tokens.GetForToken(plaintext)
+			if revoked := err != nil; revoked != tc.own {
+				t.Fatalf("expected token revoked to be %v, got %v", tc.own, revoked)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package apis
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/tracing"
+)
+
+// traceRequest runs the request in a server span, continuing the trace of
+// the caller if any. It runs before the other middlewares so that their
+// queries, e.g. the ones authenticating the request, are part of the span.
+func traceRequest(e *core.EventRequest, next http.Handler) {
+	ctx := tracing.Extract(e.Request.Context(), e.Request.Header)
+
+	ctx, span := e.App.Tracer().Start(ctx, e.Request.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", e.Request.Method),
+			attribute.String("url.path", e.Request.URL.Path),
+		),
+	)
+	defer span.End()
+
+	sw := &statusResponseWriter{ResponseWriter: e.Response, status: http.StatusOK}
+
+	next.ServeHTTP(sw, e.Request.WithContext(ctx))
+
+	span.SetAttributes(attribute.Int("http.response.status_code", sw.status))
+
+	if sw.status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(sw.status))
+	}
+}
+
+// routed names the span of the request after the pattern of its route, only
+// known once the request is matched.
+func routed(next func(*core.EventRequest)) func(*core.EventRequest) {
+	return func(e *core.EventRequest) {
+		_, route, _ := strings.Cut(e.Request.Pattern, " ")
+
+		span := trace.SpanFromContext(e.Request.Context())
+		span.SetName(e.Request.Pattern)
+		span.SetAttributes(attribute.String("http.route", route))
+
+		next(e)
+	}
+}
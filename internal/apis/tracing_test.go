@@ -0,0 +1,93 @@
+package apis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+func TestTracing(t *testing.T) {
+	t.Parallel()
+
+	exporter := tracetest.NewInMemoryExporter()
+
+	app, err := tests.NewTestAppWithConfig(tests.TestAppConfig{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+	})
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	team := app.NewTeam("payments")
+	cluster := app.NewCluster(team, "payments-staging", map[string]string{"env": "staging"})
+
+	// The Alertmanager of the cluster is down.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	cluster.AlertmanagerUrl = down.URL
+	_ = app.Clusters.Update(cluster)
+
+	editor := app.NewUser("editor@example.com")
+	app.Bind(editor, data.RoleEditor, data.ScopeTypeTeam, team.Id)
+	token := app.NewToken(editor, data.ScopeRead, data.ScopeWrite)
+
+	body := `{
+		"matchers": ["alertname=\"HighLatency\""],
+		"cluster_selector": {"env": "staging"},
+		"duration": "2h",
+		"comment": "Load test"
+	}`
+
+	// The request continues the trace of the caller.
+	traceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/silences", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+
+	rec := serveTestRequest(app, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status code to be %d, got %d (%s)", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	var server, client sdktrace.ReadOnlySpan
+
+	for _, span := range exporter.GetSpans().Snapshots() {
+		if span.SpanContext().TraceID().String() != traceId {
+			t.Fatalf("expected span %s to be part of the trace of the caller", span.Name())
+		}
+
+		switch span.SpanKind() {
+		case trace.SpanKindServer:
+			server = span
+		case trace.SpanKindClient:
+			client = span
+		}
+	}
+
+	if server == nil || server.Name() != "POST /api/v1/silences" {
+		t.Fatalf("expected server span named after the route, got %v", server)
+	}
+
+	if client == nil || client.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatalf("expected client span of the Alertmanager call to be a child of the server span")
+	}
+
+	if client.Status().Code != codes.Error {
+		t.Fatalf("expected client span to record the failed call, got %v", client.Status())
+	}
+
+	if !app.Logs.Contains("trace_id=" + traceId) {
+		t.Fatal("expected the logs of the request to include its trace id")
+	}
+}
@@ -0,0 +1,232 @@
+package apis
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/mailer"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/security"
+)
+
+const (
+	activationTokenTTL = time.Hour * 72
+	minPasswordLen     = 8
+	maxPasswordLen     = 128
+)
+
+func registerUser(e *core.EventRequest) {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(input.Email))
+
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		badRequest(e, "Email must be a valid email address.")
+		return
+	}
+
+	if len(input.Password) < minPasswordLen || len(input.Password) > maxPasswordLen {
+		badRequest(e, fmt.Sprintf(
+			"Password must be between %d and %d characters long.",
+			minPasswordLen, maxPasswordLen,
+		))
+		return
+	}
+
+	hash, err := security.HashPassword(input.Password)
+	if err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	user := &data.User{
+		Email:        email,
+		IsActivated:  false,
+		PasswordHash: hash,
+	}
+
+	if err := e.Models().Users.Insert(user); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			conflict(e, "A user with this email address already exists.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelCreate, "user", user.Id, nil, user)
+
+	if err := sendActivationToken(e, user); err != nil {
+		internalServerError(e, err)
+		return
+	}
+
+	resp := struct {
+		User *data.User `json:"user"`
+	}{
+		User: user,
+	}
+
+	if err := e.Json(resp, http.StatusCreated); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// listUsers lists the users of the tenant of the caller, sorted, filtered
+// and paginated by the list query.
+func listUsers(e *core.EventRequest) {
+	q, apiErr := e.ListQuery()
+	if apiErr != nil {
+		errorResponse(e, apiErr)
+		return
+	}
+
+	users, next, err := e.Models().Users.List(q)
+	if err != nil {
+		var lqe *data.ListQueryError
+		switch {
+		case errors.As(err, &lqe):
+			badRequest(e, lqe.Message)
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	resp := struct {
+		Users      []*data.User `json:"users"`
+		NextCursor string       `json:"next_cursor"`
+	}{
+		Users:      users,
+		NextCursor: next,
+	}
+
+	if err := e.JsonConditional(resp, http.StatusOK, time.Time{}); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func activateUser(e *core.EventRequest) {
+	var input struct {
+		Token string `json:"token"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	user, err := e.Models().ActivationTokens.Activate(strings.TrimSpace(input.Token))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			badRequest(e, "Invalid or expired activation token.")
+		default:
+			internalServerError(e, err)
+		}
+		return
+	}
+
+	e.SetChange(core.ModelUpdate, "user", user.Id, nil, user)
+
+	resp := struct {
+		User *data.User `json:"user"`
+	}{
+		User: user,
+	}
+
+	if err := e.Json(resp, http.StatusOK); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+// resendActivationToken sends a new activation token to a user that has not
+// activated the account yet. It always responds with the same message, so
+// that it cannot be used to find out which emails are registered.
+func resendActivationToken(e *core.EventRequest) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	if err := e.Unmarshal(&input, nil); err != nil {
+		unmarshalError(e, err)
+		return
+	}
+
+	user, err := e.Models().Users.GetByEmail(strings.ToLower(strings.TrimSpace(input.Email)))
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		internalServerError(e, err)
+		return
+	}
+
+	if user != nil && !user.IsActivated {
+		if err := sendActivationToken(e, user); err != nil {
+			internalServerError(e, err)
+			return
+		}
+	}
+
+	resp := struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	}{
+		Status:  http.StatusAccepted,
+		Message: "An activation email will be sent if the account exists and is not activated yet.",
+	}
+
+	if err := e.Json(resp, resp.Status); err != nil {
+		internalServerError(e, err)
+		return
+	}
+}
+
+func sendActivationToken(e *core.EventRequest, user *data.User) error {
+	token, err := data.NewActivationToken(user.Id, activationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	if err := e.Models().ActivationTokens.Insert(token); err != nil {
+		return err
+	}
+
+	message := &mailer.Message{
+		To:      user.Email,
+		Subject: "Activate your ScopeHouse account",
+		Text: fmt.Sprintf(
+			"Your account activation token is:\n\n%s\n\n"+
+				"Send it in a PUT /api/v1/users/activate request body as {\"token\": \"...\"}.\n"+
+				"The token expires in %d hours.\n",
+			token.Plaintext, int(activationTokenTTL.Hours()),
+		),
+	}
+
+	// A failed delivery must not fail the request, the user can ask for a
+	// new activation token.
+	if err := e.App.Mailer().Send(message); err != nil {
+		e.App.Logger().ErrorContext(e.Request.Context(), "activation email delivery failed",
+			slog.String("user_id", user.Id),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package apis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+func TestRegisterUser(t *testing.T) {
+	t.Parallel()
+
+	scenarios := []apiTestScenario{
+		{
+			name:    "invalid email",
+			method:  http.MethodPost,
+			url:     "/api/v1/users",
+			body:    strings.NewReader(`{"email":"not-an-email","password":"secret-password"}`),
+			status:  http.StatusBadRequest,
+			content: []string{`"message":"Email must be a valid email address."`},
+		},
+		{
+			name:    "short password",
+			method:  http.MethodPost,
+			url:     "/api/v1/users",
+			body:    strings.NewReader(`{"email":"test@example.com","password":"short"}`),
+			status:  http.StatusBadRequest,
+			content: []string{`"message":"Password must be between 8 and 128 characters long."`},
+		},
+		{
+			name:    "duplicate email",
+			method:  http.MethodPost,
+			url:     "/api/v1/users",
+			body:    strings.NewReader(`{"email":"test@example.com","password":"secret-password"}`),
+			status:  http.StatusConflict,
+			content: []string{`"message":"A user with this email address already exists."`},
+			beforeTestFunc: func(_ testing.TB, app *tests.TestApp) {
+				app.NewUser("test@example.com")
+			},
+		},
+	}
+
+	for _, s := range scenarios {
+		s.test(t)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	tenant := app.NewTenant("search-bu")
+
+	admin := app.NewUser("admin@example.com")
+	if err := app.Tenants.AddUser(tenant.Id, admin.Id, data.RoleAdmin); err != nil {
+		t.Fatalf("failed to add user to tenant; %v", err)
+	}
+
+	viewer := app.NewUser("viewer@example.com")
+	if err := app.Tenants.AddUser(tenant.Id, viewer.Id, data.RoleViewer); err != nil {
+		t.Fatalf("failed to add user to tenant; %v", err)
+	}
+
+	app.NewUser("other@example.com")
+
+	testCases := []struct {
+		name    string
+		token   string
+		url     string
+		status  int
+		content []string
+	}{
+		{
+			name:    "users of the tenant",
+			token:   app.NewToken(admin, data.ScopeRead),
+			url:     "/api/v1/users?sort=-email&filter=email:like:example",
+			status:  http.StatusOK,
+			content: []string{`"email":"admin@example.com"`, `"email":"viewer@example.com"`, `"next_cursor":""`},
+		},
+		{
+			name:    "invalid limit",
+			token:   app.NewToken(admin, data.ScopeRead),
+			url:     "/api/v1/users?limit=0",
+			status:  http.StatusBadRequest,
+			content: []string{`"message":"Limit must be a number between 1 and 500."`},
+		},
+		{
+			name:    "viewer",
+			token:   app.NewToken(viewer, data.ScopeRead),
+			url:     "/api/v1/users",
+			status:  http.StatusForbidden,
+			content: []string{`"message":"You must have the \"admin\" role to access this resource."`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+
+			rec := serveTestRequest(app, req)
+			if rec.Code != tc.status {
+				t.Fatalf("expected status code to be %d, got %d", tc.status, rec.Code)
+			}
+
+			testBodyContent(t, rec, tc.content)
+
+			if strings.Contains(rec.Body.String(), "other@example.com") {
+				t.Fatal("expected users of other tenants not to be listed")
+			}
+		})
+	}
+}
+
+func TestUserActivation(t *testing.T) {
+	t.Parallel()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	body := strings.NewReader(`{"email":"Test@Example.com","password":"secret-password"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", body)
+	rec := serveTestRequest(app, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	testBodyContent(t, rec, []string{`"email":"test@example.com"`, `"is_activated":false`})
+
+	messages := app.TestMailer.Messages()
+	if len(messages) != 1 || messages[0].To != "test@example.com" {
+		t.Fatalf("expected one activation email to test@example.com, got %v", messages)
+	}
+
+	// The activation token is on its own line after the message intro.
+	lines := strings.Split(messages[0].Text, "\n")
+	token := lines[2]
+
+	// Signing in is not possible before the account is activated.
+	body = strings.NewReader(`{"email":"test@example.com","password":"secret-password"}`)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", body)
+	rec = serveTestRequest(app, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	body = strings.NewReader(`{"token":"invalid"}`)
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/users/activate", body)
+	rec = serveTestRequest(app, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	body = strings.NewReader(`{"token":"` + token + `"}`)
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/users/activate", body)
+	rec = serveTestRequest(app, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	testBodyContent(t, rec, []string{`"is_activated":true`})
+
+	// The token is single use.
+	body = strings.NewReader(`{"token":"` + token + `"}`)
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/users/activate", body)
+	rec = serveTestRequest(app, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	body = strings.NewReader(`{"email":"test@example.com","password":"secret-password"}`)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", body)
+	rec = serveTestRequest(app, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code to be %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestResendActivationToken(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		email     string
+		activated bool
+		sent      int
+	}{
+		{"not activated", "test@example.com", false, 1},
+		{"already activated", "test@example.com", true, 0},
+		{"unknown email", "unknown@example.com", false, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app, err := tests.NewTestApp()
+			if err != nil {
+				t.Fatalf("failed to initialize test app instance; %v", err)
+			}
+
+			user := app.NewUser("test@example.com")
+			user.IsActivated = tc.activated
+
+			body := strings.NewReader(`{"email":"` + tc.email + `"}`)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/users/activation", body)
+			rec := serveTestRequest(app, req)
+
+			if rec.Code != http.StatusAccepted {
+				t.Fatalf("expected status code to be %d, got %d", http.StatusAccepted, rec.Code)
+			}
+
+			if sent := len(app.TestMailer.Messages()); sent != tc.sent {
+				t.Fatalf("expected %d emails to be sent, got %d", tc.sent, sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,564 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/prometheus"
+)
+
+// DefaultAlertPollInterval is the interval between two polls of the alerts
+// of the clusters.
+const DefaultAlertPollInterval = time.Second * 30
+
+const (
+	// maxConcurrentPolls bounds the clusters polled at the same time.
+	maxConcurrentPolls = 8
+
+	// alertHistoryLease is the lease electing the replica recording the
+	// history of the alerts.
+	alertHistoryLease = "alert-history"
+)
+
+// The states of the alerts.
+const (
+	AlertPending    = "pending"
+	AlertFiring     = "firing"
+	AlertSuppressed = "suppressed"
+)
+
+// The services the alerts are polled from.
+const (
+	AlertSourceAlertmanager = "alertmanager"
+	AlertSourcePrometheus   = "prometheus"
+)
+
+// Alert is an alert of a cluster, normalized whichever service it was
+// polled from. Alerts are `pending` in Prometheus until they have been
+// active long enough, then `firing`, or `suppressed` by the silences and
+// inhibitions of Alertmanager.
+type Alert struct {
+	Fingerprint  string            `json:"fingerprint"`
+	ClusterId    string            `json:"cluster_id"`
+	ClusterName  string            `json:"cluster_name"`
+	TeamId       string            `json:"team_id"`
+	Name         string            `json:"name"`
+	Severity     string            `json:"severity"`
+	State        string            `json:"state"`
+	Source       string            `json:"source"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"starts_at"`
+	SilencedBy   []string          `json:"silenced_by,omitempty"`
+	InhibitedBy  []string          `json:"inhibited_by,omitempty"`
+	GeneratorUrl string            `json:"generator_url,omitempty"`
+}
+
+// ClusterAlerts are the alerts of a cluster as of its last poll. Errors
+// holds the error of each service that could not be polled, whose alerts of
+// the previous poll are kept.
+type ClusterAlerts struct {
+	ClusterId string            `json:"cluster_id"`
+	PolledAt  time.Time         `json:"polled_at"`
+	Errors    map[string]string `json:"errors,omitempty"`
+	Alerts    []*Alert          `json:"-"`
+
+	sources map[string][]*Alert
+}
+
+// AlertPoller polls the alerts of the Alertmanager and Prometheus of every
+// cluster, and caches them. Each replica polls and caches the alerts on its
+// own, and the one holding the alert history lease records their state
+// transitions.
+type AlertPoller struct {
+	app      App
+	interval *workerInterval
+	holder   string
+
+	cacheMu sync.RWMutex
+	cache   map[string]*ClusterAlerts
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+
+	heartbeat heartbeat
+}
+
+// NewAlertPoller creates a poller of the alerts of the clusters of the app
+// running at the interval, the default one when not positive.
+func NewAlertPoller(app App, interval time.Duration) *AlertPoller {
+	if interval <= 0 {
+		interval = DefaultAlertPollInterval
+	}
+
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+
+	return &AlertPoller{
+		app:      app,
+		interval: newWorkerInterval(interval),
+		holder:   hex.EncodeToString(b),
+		cache:    map[string]*ClusterAlerts{},
+	}
+}
+
+// Start polls the alerts in the background until the poller is stopped.
+func (p *AlertPoller) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stop != nil {
+		return
+	}
+
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	p.heartbeat.beat()
+
+	go p.loop(p.stop, p.done)
+}
+
+// Stop stops the poller, waiting for its current poll, and releases its
+// lease so that another replica takes over right away.
+func (p *AlertPoller) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stop == nil {
+		return
+	}
+
+	close(p.stop)
+	<-p.done
+
+	p.stop = nil
+	p.done = nil
+
+	p.heartbeat.reset()
+
+	if err := p.app.Models().Leases.Release(alertHistoryLease, p.holder); err != nil {
+		p.app.Logger().Error("alert history lease release failed", slog.Any("error", err))
+	}
+}
+
+// SetInterval changes the interval between two polls of the alerts, the
+// default one when not positive. A running poller runs right away, then at
+// the new interval.
+func (p *AlertPoller) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultAlertPollInterval
+	}
+
+	p.interval.set(interval)
+}
+
+// Alive fails when the poller is not running, or when its current poll is
+// stuck.
+func (p *AlertPoller) Alive() error {
+	return p.heartbeat.alive(p.interval.get())
+}
+
+func (p *AlertPoller) loop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(p.interval.get())
+	defer ticker.Stop()
+
+	for {
+		p.heartbeat.beat()
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.interval.get())
+
+		if err := p.Poll(ctx); err != nil {
+			p.app.Logger().Error("alert poll failed", slog.Any("error", err))
+		}
+
+		cancel()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-p.interval.changed:
+			ticker.Reset(p.interval.get())
+		}
+	}
+}
+
+// Poll polls the alerts of all the clusters of all the tenants, replaces
+// the cached ones, and records their transitions unless another replica
+// holds the alert history lease. Clusters with neither an Alertmanager nor
+// a Prometheus url are not polled.
+func (p *AlertPoller) Poll(ctx context.Context) error {
+	ctx, span := p.app.Tracer().Start(ctx, "alerts.poll")
+	defer span.End()
+
+	models := p.app.Models().Trace(ctx)
+
+	clusters, err := SelectClusters(models, nil)
+	if err != nil {
+		return err
+	}
+
+	clusters = slices.DeleteFunc(clusters, func(cluster *data.Cluster) bool {
+		return cluster.AlertmanagerUrl == "" && cluster.PrometheusUrl == ""
+	})
+
+	start := time.Now()
+
+	results := make([]*ClusterAlerts, len(clusters))
+	sem := make(chan struct{}, maxConcurrentPolls)
+
+	var wg sync.WaitGroup
+
+	for i, cluster := range clusters {
+		previous, _ := p.ClusterAlerts(cluster.Id)
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i] = p.pollCluster(ctx, models, cluster, previous)
+		}()
+	}
+
+	wg.Wait()
+
+	p.app.Metrics().AlertPollDuration.Observe(time.Since(start).Seconds())
+
+	cache := make(map[string]*ClusterAlerts, len(results))
+	for _, result := range results {
+		cache[result.ClusterId] = result
+	}
+
+	p.cacheMu.Lock()
+	p.cache = cache
+	p.cacheMu.Unlock()
+
+	held, err := models.Leases.Acquire(alertHistoryLease, p.holder, p.interval.get()*3)
+	if err != nil {
+		return err
+	}
+
+	if !held {
+		return nil
+	}
+
+	var errs []error
+
+	for _, result := range results {
+		if err := p.recordHistory(models, result); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// recordHistory records the transitions of the alerts of the cluster since
+// its previous poll. Alerts that are no longer polled are resolved. Polls
+// that failed on a service are not recorded, since the alerts of the
+// service would be resolved.
+func (p *AlertPoller) recordHistory(models *data.Models, result *ClusterAlerts) error {
+	if len(result.Errors) > 0 {
+		return nil
+	}
+
+	states, err := models.AlertHistory.States(result.ClusterId)
+	if err != nil {
+		return err
+	}
+
+	previous := map[string]*data.AlertState{}
+	for _, state := range states {
+		previous[state.Fingerprint] = state
+	}
+
+	transitions := []*data.AlertTransition{}
+	seen := map[string]bool{}
+
+	for _, alert := range result.Alerts {
+		if seen[alert.Fingerprint] {
+			continue
+		}
+		seen[alert.Fingerprint] = true
+
+		// Suppressed alerts are still firing, they are only not notified.
+		state := data.AlertStateFiring
+		if alert.State == AlertPending {
+			state = data.AlertStatePending
+		}
+
+		prev, ok := previous[alert.Fingerprint]
+		if ok && prev.State == state {
+			continue
+		}
+
+		t := &data.AlertTransition{
+			ClusterId:   result.ClusterId,
+			Fingerprint: alert.Fingerprint,
+			Rule:        alert.Name,
+			Severity:    alert.Severity,
+			Labels:      alert.Labels,
+			State:       state,
+			ObservedAt:  result.PolledAt,
+		}
+
+		if ok {
+			t.PreviousState = prev.State
+		}
+
+		transitions = append(transitions, t)
+	}
+
+	for _, state := range states {
+		if seen[state.Fingerprint] {
+			continue
+		}
+
+		transitions = append(transitions, &data.AlertTransition{
+			ClusterId:     result.ClusterId,
+			Fingerprint:   state.Fingerprint,
+			Rule:          state.Rule,
+			Severity:      state.Severity,
+			Labels:        state.Labels,
+			State:         data.AlertStateResolved,
+			PreviousState: state.State,
+			ObservedAt:    result.PolledAt,
+		})
+	}
+
+	return models.AlertHistory.Record(transitions)
+}
+
+// ClusterAlerts returns the cached alerts of the cluster, and whether it was
+// polled.
+func (p *AlertPoller) ClusterAlerts(clusterId string) (*ClusterAlerts, bool) {
+	p.cacheMu.RLock()
+	defer p.cacheMu.RUnlock()
+
+	alerts, ok := p.cache[clusterId]
+	return alerts, ok
+}
+
+func (p *AlertPoller) pollCluster(ctx context.Context, models *data.Models, cluster *data.Cluster, previous *ClusterAlerts) *ClusterAlerts {
+	ctx, span := p.app.Tracer().Start(ctx, "alerts.poll_cluster",
+		trace.WithAttributes(attribute.String("cluster.id", cluster.Id)),
+	)
+	defer span.End()
+
+	result := &ClusterAlerts{
+		ClusterId: cluster.Id,
+		PolledAt:  time.Now().UTC(),
+		Errors:    map[string]string{},
+		sources:   map[string][]*Alert{},
+	}
+
+	logger := p.app.Logger().With(
+		slog.String("cluster_id", cluster.Id),
+		slog.String("tenant_id", cluster.TenantId),
+	)
+
+	poll := func(source string, fetch func() ([]*Alert, error)) {
+		alerts, err := fetch()
+		p.app.Metrics().AlertPolls.WithLabelValues(source, MetricResult(err)).Inc()
+
+		if err == nil {
+			result.sources[source] = alerts
+			return
+		}
+
+		logger.WarnContext(ctx, "alert poll of cluster failed", slog.String("source", source), slog.Any("error", err))
+
+		span.RecordError(err, trace.WithAttributes(attribute.String("source", source)))
+		span.SetStatus(codes.Error, err.Error())
+
+		result.Errors[source] = err.Error()
+		if previous != nil {
+			result.sources[source] = previous.sources[source]
+		}
+	}
+
+	// The sources fail along with the credentials of the cluster.
+	client, clientErr := ClusterHttpClient(p.app, models, cluster.Id)
+
+	if cluster.AlertmanagerUrl != "" {
+		poll(AlertSourceAlertmanager, func() ([]*Alert, error) {
+			if clientErr != nil {
+				return nil, clientErr
+			}
+			alerts, err := alertmanager.NewClient(cluster.AlertmanagerUrl, client).Alerts(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return normalizeAlertmanagerAlerts(cluster, alerts), nil
+		})
+	}
+
+	if cluster.PrometheusUrl != "" {
+		poll(AlertSourcePrometheus, func() ([]*Alert, error) {
+			if clientErr != nil {
+				return nil, clientErr
+			}
+			alerts, err := prometheus.NewClient(cluster.PrometheusUrl, client).Alerts(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return normalizePrometheusAlerts(cluster, alerts), nil
+		})
+	}
+
+	result.Alerts = mergeAlerts(result.sources[AlertSourceAlertmanager], result.sources[AlertSourcePrometheus])
+
+	return result
+}
+
+func newAlert(cluster *data.Cluster, source string, labels map[string]string, annotations map[string]string) *Alert {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	return &Alert{
+		ClusterId:   cluster.Id,
+		ClusterName: cluster.Name,
+		TeamId:      cluster.TeamId,
+		Name:        labels["alertname"],
+		Severity:    labels["severity"],
+		Source:      source,
+		Labels:      labels,
+		Annotations: annotations,
+	}
+}
+
+func normalizeAlertmanagerAlerts(cluster *data.Cluster, alerts []*alertmanager.Alert) []*Alert {
+	normalized := make([]*Alert, 0, len(alerts))
+
+	for _, a := range alerts {
+		alert := newAlert(cluster, AlertSourceAlertmanager, a.Labels, a.Annotations)
+		alert.Fingerprint = a.Fingerprint
+		alert.StartsAt = a.StartsAt
+		alert.GeneratorUrl = a.GeneratorUrl
+		alert.SilencedBy = a.Status.SilencedBy
+		alert.InhibitedBy = a.Status.InhibitedBy
+
+		switch a.Status.State {
+		case "suppressed":
+			alert.State = AlertSuppressed
+		default:
+			alert.State = AlertFiring
+		}
+
+		if alert.Fingerprint == "" {
+			alert.Fingerprint = fingerprint(alert.Labels)
+		}
+
+		normalized = append(normalized, alert)
+	}
+
+	return normalized
+}
+
+func normalizePrometheusAlerts(cluster *data.Cluster, alerts []*prometheus.Alert) []*Alert {
+	normalized := make([]*Alert, 0, len(alerts))
+
+	for _, a := range alerts {
+		alert := newAlert(cluster, AlertSourcePrometheus, a.Labels, a.Annotations)
+		alert.Fingerprint = fingerprint(alert.Labels)
+		alert.StartsAt = a.ActiveAt
+
+		switch a.State {
+		case "firing":
+			alert.State = AlertFiring
+		case "pending":
+			alert.State = AlertPending
+		default:
+			// Inactive alerts are not reported.
+			continue
+		}
+
+		normalized = append(normalized, alert)
+	}
+
+	return normalized
+}
+
+// mergeAlerts merges the alerts of the Alertmanager and of the Prometheus of
+// a cluster. The firing alerts of Prometheus are also in Alertmanager, with
+// the external labels of Prometheus added, unless they were not sent yet.
+// The ones of Alertmanager are kept since they tell whether they are
+// suppressed, with the fingerprint of the Prometheus ones so that alerts
+// keep their fingerprint from pending to firing.
+func mergeAlerts(amAlerts []*Alert, promAlerts []*Alert) []*Alert {
+	merged := make([]*Alert, 0, len(amAlerts)+len(promAlerts))
+
+	for _, alert := range amAlerts {
+		a := *alert
+
+		if i := slices.IndexFunc(promAlerts, func(prom *Alert) bool {
+			return hasLabels(a.Labels, prom.Labels)
+		}); i >= 0 {
+			a.Fingerprint = promAlerts[i].Fingerprint
+		}
+
+		merged = append(merged, &a)
+	}
+
+	for _, alert := range promAlerts {
+		if alert.State == AlertFiring && slices.ContainsFunc(amAlerts, func(a *Alert) bool {
+			return hasLabels(a.Labels, alert.Labels)
+		}) {
+			continue
+		}
+
+		merged = append(merged, alert)
+	}
+
+	slices.SortFunc(merged, func(a, b *Alert) int {
+		return cmp.Or(
+			b.StartsAt.Compare(a.StartsAt),
+			cmp.Compare(a.Fingerprint, b.Fingerprint),
+		)
+	})
+
+	return merged
+}
+
+// fingerprint returns a hash of the labels, identifying the alerts that have
+// no fingerprint.
+func fingerprint(labels map[string]string) string {
+	h := fnv.New64a()
+
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		_, _ = h.Write([]byte(name))
+		_, _ = h.Write([]byte{0xff})
+		_, _ = h.Write([]byte(labels[name]))
+		_, _ = h.Write([]byte{0xff})
+	}
+
+	return strconv.FormatUint(h.Sum64(), 16)
+}
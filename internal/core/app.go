@@ -1,9 +1,18 @@
 package core
 
 import (
+	"context"
 	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/hook"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/mailer"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/oidc"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/secrets"
 )
 
 type App interface {
@@ -13,9 +22,67 @@ type App interface {
 	// Models returns the default app data.models instance.
 	Models() *data.Models
 
+	// Mailer returns the default app mailer.
+	Mailer() mailer.Mailer
+
+	// OIDC returns the single sign-on provider, or nil when it is not configured.
+	OIDC() *oidc.Provider
+
+	// SecureCookies reports whether the cookies of the API, e.g. the one of
+	// the sessions, are only sent over https.
+	SecureCookies() bool
+
+	// HttpClient returns the client of the calls to the services of the
+	// clusters, e.g. their Alertmanager.
+	HttpClient() *http.Client
+
+	// Secrets returns the keyring of the secrets stored at rest, e.g. the
+	// credentials of the clusters, or nil when it is not configured.
+	Secrets() *secrets.Keyring
+
+	// AlertmanagerSyncer returns the backend syncing the Alertmanager
+	// configurations of the clusters, or nil when it is not configured.
+	AlertmanagerSyncer() alertmanager.Syncer
+
+	// Scheduler returns the scheduler of the maintenance windows.
+	Scheduler() *Scheduler
+
+	// AlertPoller returns the poller caching the alerts of the clusters.
+	AlertPoller() *AlertPoller
+
+	// Metrics returns the Prometheus metrics of the app.
+	Metrics() *Metrics
+
+	// Tracer returns the tracer of the spans of the app.
+	Tracer() trace.Tracer
+
+	// Health returns the registry of the health checks of the app.
+	Health() *Health
+
+	// OnBeforeBootstrap returns the hook triggered before the app is
+	// bootstrapped, its errors abort the bootstrap.
+	OnBeforeBootstrap() *hook.Hook[*BootstrapEvent]
+
+	// OnAfterBootstrap returns the hook triggered once the app is
+	// bootstrapped, its errors fail the bootstrap.
+	OnAfterBootstrap() *hook.Hook[*BootstrapEvent]
+
+	// OnServe returns the hook triggered before the API is served, e.g. to
+	// add routes, its errors abort serving.
+	OnServe() *hook.Hook[*ServeEvent]
+
+	// OnModelChange returns the hook triggered when a request creates,
+	// updates or deletes a model.
+	OnModelChange() *hook.Hook[*ModelEvent]
+
 	// Bootstrap initializes the application.
 	Bootstrap() error
 
-	// OnShutdown run jobs before the application shuts down.
-	OnShutdown()
+	// ShutdownHooks returns the registry of the jobs run when the app shuts
+	// down.
+	ShutdownHooks() *ShutdownHooks
+
+	// OnShutdown runs the shutdown hooks of the app, bounded by ctx, and
+	// returns their errors.
+	OnShutdown(ctx context.Context) error
 }
@@ -1,34 +1,152 @@
 package core
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"log/slog"
+	"net/http"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
 	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/hook"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/mailer"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/oidc"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/secrets"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/tracing"
 )
 
 // Ensures that the ScopeHouse implements the App interface.
 var _ App = (*BaseApp)(nil)
 
 type BaseApp struct {
-	logger *slog.Logger
+	// logger is replaced when the log settings are reloaded.
+	logger atomic.Pointer[slog.Logger]
 	models *data.Models
+	mailer mailer.Mailer
+	oidc   *oidc.Provider
+	client *http.Client
+	syncer alertmanager.Syncer
+	keys   *secrets.Keyring
+
+	insecureCookies bool
+
+	scheduler *Scheduler
+	poller    *AlertPoller
+	metrics   *Metrics
+	tracer    trace.Tracer
+	health    *Health
+	shutdown  *ShutdownHooks
+
+	onBeforeBootstrap *hook.Hook[*BootstrapEvent]
+	onAfterBootstrap  *hook.Hook[*BootstrapEvent]
+	onServe           *hook.Hook[*ServeEvent]
+	onModelChange     *hook.Hook[*ModelEvent]
 }
 
-func NewBaseApp(db *sql.DB, logger *slog.Logger) *BaseApp {
+type BaseAppConfig struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+	Mailer mailer.Mailer
+
+	// OIDC is the single sign-on provider, nil when it is not configured.
+	OIDC *oidc.Provider
+
+	// HttpClient calls the services of the clusters, a client with the
+	// default timeout, tracing the calls, is used when nil.
+	HttpClient *http.Client
+
+	// TracerProvider provides the tracer of the spans of the app, the spans
+	// are discarded when nil.
+	TracerProvider trace.TracerProvider
+
+	// AlertmanagerSyncer syncs the Alertmanager configurations of the
+	// clusters, nil when it is not configured.
+	AlertmanagerSyncer alertmanager.Syncer
+
+	// Secrets encrypts the secrets stored at rest, e.g. the credentials of
+	// the clusters, which cannot be stored when nil.
+	Secrets *secrets.Keyring
+
+	// InsecureCookies sends the cookies of the API over plain http too,
+	// e.g. in development. They are only sent over https otherwise.
+	InsecureCookies bool
+
+	// SchedulerInterval is the interval between two runs of the scheduler,
+	// DefaultSchedulerInterval when not positive.
+	SchedulerInterval time.Duration
+
+	// AlertPollInterval is the interval between two polls of the alerts of
+	// the clusters, DefaultAlertPollInterval when not positive.
+	AlertPollInterval time.Duration
+}
+
+// defaultHttpTimeout bounds the calls to the services of the clusters when
+// no client is configured.
+const defaultHttpTimeout = time.Second * 10
+
+func NewBaseApp(config BaseAppConfig) *BaseApp {
+	if config.TracerProvider == nil {
+		config.TracerProvider = noop.NewTracerProvider()
+	}
+
+	tracer := config.TracerProvider.Tracer(tracing.InstrumentationName)
+
 	app := &BaseApp{
-		logger: logger,
-		models: data.NewModels(db),
+		models: data.NewModels(config.DB, tracer),
+		mailer: config.Mailer,
+		oidc:   config.OIDC,
+		client: config.HttpClient,
+		syncer: config.AlertmanagerSyncer,
+		keys:   config.Secrets,
+		tracer: tracer,
+
+		insecureCookies: config.InsecureCookies,
+
+		onBeforeBootstrap: &hook.Hook[*BootstrapEvent]{},
+		onAfterBootstrap:  &hook.Hook[*BootstrapEvent]{},
+		onServe:           &hook.Hook[*ServeEvent]{},
+		onModelChange:     &hook.Hook[*ModelEvent]{},
+	}
+
+	app.logger.Store(config.Logger)
+
+	app.metrics = NewMetrics(config.DB)
+
+	if app.client == nil {
+		app.client = &http.Client{
+			Timeout:   defaultHttpTimeout,
+			Transport: tracing.NewTransport(nil, tracer),
+		}
 	}
 
+	app.scheduler = NewScheduler(app, config.SchedulerInterval)
+	app.poller = NewAlertPoller(app, config.AlertPollInterval)
+
+	app.health = NewHealth()
+	registerHealthChecks(app, app.health)
+
+	app.shutdown = NewShutdownHooks()
+	app.shutdown.Register(stopHook("scheduler", app.scheduler.Stop))
+	app.shutdown.Register(stopHook("alert_poller", app.poller.Stop))
+
 	return app
 }
 
 // Logger returns the default app logger.
 func (app *BaseApp) Logger() *slog.Logger {
-	return app.logger
+	return app.logger.Load()
+}
+
+// SetLogger replaces the default app logger, e.g. once the log settings
+// changed.
+func (app *BaseApp) SetLogger(logger *slog.Logger) {
+	app.logger.Store(logger)
 }
 
 // Models returns the default app data.models instance.
@@ -36,9 +154,96 @@ func (app *BaseApp) Models() *data.Models {
 	return app.models
 }
 
+// Mailer returns the default app mailer.
+func (app *BaseApp) Mailer() mailer.Mailer {
+	return app.mailer
+}
+
+// OIDC returns the single sign-on provider, or nil when it is not configured.
+func (app *BaseApp) OIDC() *oidc.Provider {
+	return app.oidc
+}
+
+// SecureCookies reports whether the cookies of the API, e.g. the one of the
+// sessions, are only sent over https.
+func (app *BaseApp) SecureCookies() bool {
+	return !app.insecureCookies
+}
+
+// HttpClient returns the client of the calls to the services of the
+// clusters, e.g. their Alertmanager.
+func (app *BaseApp) HttpClient() *http.Client {
+	return app.client
+}
+
+// Secrets returns the keyring of the secrets stored at rest, or nil when it
+// is not configured.
+func (app *BaseApp) Secrets() *secrets.Keyring {
+	return app.keys
+}
+
+// AlertmanagerSyncer returns the backend syncing the Alertmanager
+// configurations of the clusters, or nil when it is not configured.
+func (app *BaseApp) AlertmanagerSyncer() alertmanager.Syncer {
+	return app.syncer
+}
+
+// Scheduler returns the scheduler of the maintenance windows.
+func (app *BaseApp) Scheduler() *Scheduler {
+	return app.scheduler
+}
+
+// AlertPoller returns the poller caching the alerts of the clusters.
+func (app *BaseApp) AlertPoller() *AlertPoller {
+	return app.poller
+}
+
+// Metrics returns the Prometheus metrics of the app.
+func (app *BaseApp) Metrics() *Metrics {
+	return app.metrics
+}
+
+// Tracer returns the tracer of the spans of the app.
+func (app *BaseApp) Tracer() trace.Tracer {
+	return app.tracer
+}
+
+// Health returns the registry of the health checks of the app.
+func (app *BaseApp) Health() *Health {
+	return app.health
+}
+
+// OnBeforeBootstrap returns the hook triggered before the app is
+// bootstrapped, its errors abort the bootstrap.
+func (app *BaseApp) OnBeforeBootstrap() *hook.Hook[*BootstrapEvent] {
+	return app.onBeforeBootstrap
+}
+
+// OnAfterBootstrap returns the hook triggered once the app is bootstrapped,
+// its errors fail the bootstrap.
+func (app *BaseApp) OnAfterBootstrap() *hook.Hook[*BootstrapEvent] {
+	return app.onAfterBootstrap
+}
+
+// OnServe returns the hook triggered before the API is served, e.g. to add
+// routes, its errors abort serving.
+func (app *BaseApp) OnServe() *hook.Hook[*ServeEvent] {
+	return app.onServe
+}
+
+// OnModelChange returns the hook triggered when a request creates, updates
+// or deletes a model.
+func (app *BaseApp) OnModelChange() *hook.Hook[*ModelEvent] {
+	return app.onModelChange
+}
+
 // Bootstrap initializes the application.
 func (app *BaseApp) Bootstrap() error {
-	if app.logger == nil {
+	if err := app.onBeforeBootstrap.Trigger(&BootstrapEvent{App: app}); err != nil {
+		return err
+	}
+
+	if app.Logger() == nil {
 		return errors.New("logger not initialized")
 	}
 
@@ -46,17 +251,21 @@ func (app *BaseApp) Bootstrap() error {
 		return errors.New("models not initialized")
 	}
 
-	return nil
+	if app.mailer == nil {
+		return errors.New("mailer not initialized")
+	}
+
+	return app.onAfterBootstrap.Trigger(&BootstrapEvent{App: app})
+}
+
+// ShutdownHooks returns the registry of the jobs run when the app shuts
+// down.
+func (app *BaseApp) ShutdownHooks() *ShutdownHooks {
+	return app.shutdown
 }
 
-// OnShutdown run jobs before the application shuts down.
-func (app *BaseApp) OnShutdown() {
-	func() {
-		app.Logger().Info("...running sample A shutdown func()...")
-		time.Sleep(time.Millisecond * 10)
-	}()
-	func() {
-		app.Logger().Info("...running sample B shutdown func()...")
-		time.Sleep(time.Millisecond * 10)
-	}()
+// OnShutdown runs the shutdown hooks of the app, bounded by ctx, and returns
+// their errors.
+func (app *BaseApp) OnShutdown(ctx context.Context) error {
+	return app.shutdown.Run(ctx, app.Logger())
 }
@@ -0,0 +1,236 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/secrets"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/tracing"
+)
+
+// ErrSecretsNotConfigured is returned when storing or using the credentials
+// of a cluster without the key encrypting them.
+var ErrSecretsNotConfigured = errors.New("secret store not configured")
+
+// ClusterCredentials are the credentials the services of a cluster, its
+// Alertmanager and Prometheus, are called with: either a bearer token or
+// basic auth, and a client certificate for mutual TLS.
+type ClusterCredentials struct {
+	BearerToken string     `json:"bearer_token,omitempty"`
+	BasicAuth   *BasicAuth `json:"basic_auth,omitempty"`
+	TLS         *ClientTLS `json:"tls,omitempty"`
+}
+
+type BasicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// ClientTLS is the PEM encoded client certificate and key of mutual TLS,
+// along with the CA of the certificates of the services when they are not
+// signed by a trusted one.
+type ClientTLS struct {
+	Certificate string `json:"certificate"`
+	Key         string `json:"key"`
+	CA          string `json:"ca,omitempty"`
+}
+
+// Config returns the TLS configuration presenting the client certificate.
+func (c *ClientTLS) Config() (*tls.Config, error) {
+	certificate, err := tls.X509KeyPair([]byte(c.Certificate), []byte(c.Key))
+	if err != nil {
+		return nil, fmt.Errorf("client certificate invalid; %v", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.CA != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(c.CA)) {
+			return nil, errors.New("ca certificate invalid")
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// SaveClusterCredentials encrypts the credentials of the cluster with a
+// data key of their own, wrapped with the master key, and saves them. Both
+// are bound to the cluster, so that they cannot be copied to another one.
+func SaveClusterCredentials(app App, models *data.Models, clusterId string, credentials *ClusterCredentials) (*data.ClusterCredential, error) {
+	keyring := app.Secrets()
+	if keyring == nil {
+		return nil, ErrSecretsNotConfigured
+	}
+
+	plaintext, err := json.Marshal(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope, err := keyring.Seal(plaintext, []byte(clusterId))
+	if err != nil {
+		return nil, err
+	}
+
+	credential := &data.ClusterCredential{
+		ClusterId:  clusterId,
+		KeyId:      envelope.KeyId,
+		WrappedKey: envelope.WrappedKey,
+		Ciphertext: envelope.Ciphertext,
+	}
+
+	if err := models.ClusterCredentials.Upsert(credential); err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+// LoadClusterCredentials returns the decrypted credentials of the cluster,
+// or nil when it has none.
+func LoadClusterCredentials(app App, models *data.Models, clusterId string) (*ClusterCredentials, error) {
+	credential, err := models.ClusterCredentials.GetByClusterId(clusterId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	keyring := app.Secrets()
+	if keyring == nil {
+		return nil, ErrSecretsNotConfigured
+	}
+
+	plaintext, err := keyring.Open(clusterEnvelope(credential), []byte(clusterId))
+	if err != nil {
+		return nil, err
+	}
+
+	var credentials ClusterCredentials
+
+	if err := json.Unmarshal(plaintext, &credentials); err != nil {
+		return nil, err
+	}
+
+	return &credentials, nil
+}
+
+// ClusterHttpClient returns the client calling the services of the cluster
+// with its credentials, the client of the app when it has none.
+func ClusterHttpClient(app App, models *data.Models, clusterId string) (*http.Client, error) {
+	credentials, err := LoadClusterCredentials(app, models, clusterId)
+	if err != nil {
+		return nil, err
+	}
+
+	client := app.HttpClient()
+
+	if credentials == nil {
+		return client, nil
+	}
+
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	// The client certificate needs a transport of its own, which does not
+	// keep its connections since the clients are not reused.
+	if credentials.TLS != nil {
+		config, err := credentials.TLS.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		transport.DisableKeepAlives = true
+
+		next = tracing.NewTransport(transport, app.Tracer())
+	}
+
+	c := *client
+	c.Transport = &credentialsTransport{next: next, credentials: credentials}
+
+	return &c, nil
+}
+
+// RotateClusterCredentials wraps the data keys of the credentials of the
+// clusters with the current master key of the keyring, so that the previous
+// master keys can be dropped. It returns the number of credentials rotated.
+// The credentials updated meanwhile, with the current master key, are
+// skipped.
+func RotateClusterCredentials(keyring *secrets.Keyring, models *data.Models) (int, error) {
+	credentials, err := models.ClusterCredentials.ListNotWrappedWith(keyring.KeyId())
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+
+	for _, credential := range credentials {
+		envelope := clusterEnvelope(credential)
+
+		if _, err := keyring.Rewrap(envelope, []byte(credential.ClusterId)); err != nil {
+			return rotated, fmt.Errorf("cluster %s credentials rotate failed; %w", credential.ClusterId, err)
+		}
+
+		credential.KeyId = envelope.KeyId
+		credential.WrappedKey = envelope.WrappedKey
+		credential.Ciphertext = envelope.Ciphertext
+
+		if err := models.ClusterCredentials.UpdateKey(credential); err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				continue
+			default:
+				return rotated, err
+			}
+		}
+
+		rotated++
+	}
+
+	return rotated, nil
+}
+
+func clusterEnvelope(credential *data.ClusterCredential) *secrets.Envelope {
+	return &secrets.Envelope{
+		KeyId:      credential.KeyId,
+		WrappedKey: credential.WrappedKey,
+		Ciphertext: credential.Ciphertext,
+	}
+}
+
+// credentialsTransport authenticates the requests with the credentials.
+type credentialsTransport struct {
+	next        http.RoundTripper
+	credentials *ClusterCredentials
+}
+
+func (t *credentialsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	// The client certificate, if any, is presented by the next transport.
+	switch {
+	case t.credentials.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+t.credentials.BearerToken)
+	case t.credentials.BasicAuth != nil:
+		req.SetBasicAuth(t.credentials.BasicAuth.Username, t.credentials.BasicAuth.Password)
+	}
+
+	return t.next.RoundTrip(req)
+}
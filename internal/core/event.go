@@ -1,8 +1,69 @@
 package core
 
-import "github.com/dlbarduzzi/scopehouse/internal/tools/event"
+import (
+	"context"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
 
 type EventRequest struct {
 	App App
+
+	// Auth is the authenticated user of the request, nil for anonymous requests.
+	Auth *data.User
+
+	// Token is the API token used to authenticate the request, nil when the
+	// request is anonymous or was authenticated by other means.
+	Token *data.Token
+
+	// Session is the session used to authenticate the request, nil when the
+	// request is anonymous or was authenticated by other means.
+	Session *data.Session
+
+	// Permissions are the roles granted to the authenticated user, loaded
+	// when the request is first authorized.
+	Permissions *data.Permissions
+
+	// Audit is the audit log entry of mutating requests, nil for safe
+	// requests. Handlers record the resource they change on it.
+	Audit *data.AuditEvent
+
+	// TenantModels are the models scoped to the tenant of the authenticated
+	// user, nil for anonymous requests.
+	TenantModels *data.Models
+
 	event.Event
 }
+
+// Models returns the models handlers must use, scoped to the tenant of the
+// authenticated user, or the unscoped models of the app for anonymous
+// requests. Their queries are traced as part of the request.
+func (e *EventRequest) Models() *data.Models {
+	if e.TenantModels != nil {
+		return e.TenantModels
+	}
+	return e.App.Models().Trace(e.Request.Context())
+}
+
+// authContextKey identifies the authentication state stored in the request context.
+type authContextKey struct{}
+
+// AuthState is the authenticated user of a request along with the
+// credential, either a token or a session, used to authenticate.
+type AuthState struct {
+	User    *data.User
+	Token   *data.Token
+	Session *data.Session
+}
+
+// ContextWithAuth returns a copy of ctx carrying the authentication state.
+func ContextWithAuth(ctx context.Context, auth AuthState) context.Context {
+	return context.WithValue(ctx, authContextKey{}, auth)
+}
+
+// AuthFromContext returns the authentication state stored in ctx.
+func AuthFromContext(ctx context.Context) AuthState {
+	auth, _ := ctx.Value(authContextKey{}).(AuthState)
+	return auth
+}
@@ -0,0 +1,267 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/migrations"
+)
+
+// DefaultHealthCheckTimeout bounds the health checks registered without a
+// timeout.
+const DefaultHealthCheckTimeout = time.Second * 2
+
+// The statuses of the health checks.
+const (
+	HealthStatusOk     = "ok"
+	HealthStatusFailed = "failed"
+)
+
+// HealthCheck is a named check of the health of the app.
+type HealthCheck struct {
+	Name string
+
+	// Liveness checks fail when the app must be restarted, e.g. when a
+	// background worker is stuck. The other checks fail when the app cannot
+	// serve requests, e.g. when the database is down, and only take it out
+	// of the load balancer.
+	Liveness bool
+
+	// Timeout bounds the check, DefaultHealthCheckTimeout when not
+	// positive.
+	Timeout time.Duration
+
+	Check func(ctx context.Context) error
+}
+
+// HealthCheckResult is the outcome of a health check.
+type HealthCheckResult struct {
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Latency float64 `json:"latency_seconds"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// HealthReport is the outcome of the health checks of the app, failed when
+// any of them failed.
+type HealthReport struct {
+	Status string               `json:"status"`
+	Checks []*HealthCheckResult `json:"checks"`
+}
+
+// Health is the registry of the health checks of the app.
+type Health struct {
+	mu     sync.RWMutex
+	checks []*HealthCheck
+}
+
+// NewHealth creates an empty registry of health checks.
+func NewHealth() *Health {
+	return &Health{}
+}
+
+// Register adds the check to the registry, replacing the check with the
+// same name if any.
+func (h *Health) Register(check *HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks = slices.DeleteFunc(h.checks, func(c *HealthCheck) bool {
+		return c.Name == check.Name
+	})
+
+	h.checks = append(h.checks, check)
+}
+
+// Live runs the liveness checks.
+func (h *Health) Live(ctx context.Context) *HealthReport {
+	return h.run(ctx, func(check *HealthCheck) bool {
+		return check.Liveness
+	})
+}
+
+// Ready runs all the checks, an app that must be restarted cannot serve
+// requests either.
+func (h *Health) Ready(ctx context.Context) *HealthReport {
+	return h.run(ctx, func(*HealthCheck) bool {
+		return true
+	})
+}
+
+// run runs the selected checks concurrently, and reports them in the order
+// they were registered.
+func (h *Health) run(ctx context.Context, selected func(*HealthCheck) bool) *HealthReport {
+	h.mu.RLock()
+	checks := slices.Clone(h.checks)
+	h.mu.RUnlock()
+
+	checks = slices.DeleteFunc(checks, func(check *HealthCheck) bool {
+		return !selected(check)
+	})
+
+	report := &HealthReport{
+		Status: HealthStatusOk,
+		Checks: make([]*HealthCheckResult, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+
+	for i, check := range checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = runHealthCheck(ctx, check)
+		}()
+	}
+
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != HealthStatusOk {
+			report.Status = HealthStatusFailed
+		}
+	}
+
+	return report
+}
+
+func runHealthCheck(ctx context.Context, check *HealthCheck) *HealthCheckResult {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	// Checks ignoring their context are still bounded.
+	errc := make(chan error, 1)
+	go func() { errc <- check.Check(ctx) }()
+
+	var err error
+
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	result := &HealthCheckResult{
+		Name:    check.Name,
+		Status:  HealthStatusOk,
+		Latency: time.Since(start).Seconds(),
+	}
+
+	if err != nil {
+		result.Status = HealthStatusFailed
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// registerHealthChecks registers the checks of the services of the app: the
+// database, its schema and the background workers.
+func registerHealthChecks(app App, health *Health) {
+	health.Register(&HealthCheck{
+		Name: "database",
+		Check: func(ctx context.Context) error {
+			return app.Models().Schema.Ping(ctx)
+		},
+	})
+
+	health.Register(&HealthCheck{
+		Name: "migrations",
+		Check: func(ctx context.Context) error {
+			return checkSchemaVersion(ctx, app)
+		},
+	})
+
+	health.Register(&HealthCheck{
+		Name:     "scheduler",
+		Liveness: true,
+		Check: func(context.Context) error {
+			return app.Scheduler().Alive()
+		},
+	})
+
+	health.Register(&HealthCheck{
+		Name:     "alert_poller",
+		Liveness: true,
+		Check: func(context.Context) error {
+			return app.AlertPoller().Alive()
+		},
+	})
+}
+
+// checkSchemaVersion fails when the schema of the database is behind the
+// migrations of the app. A schema ahead is fine, it was migrated by a newer
+// replica during a rollout, and migrations are kept backward compatible.
+func checkSchemaVersion(ctx context.Context, app App) error {
+	latest, err := migrations.Latest()
+	if err != nil {
+		return err
+	}
+
+	v, err := app.Models().Schema.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	if v.Dirty {
+		return fmt.Errorf("migration %d failed and must be fixed", v.Version)
+	}
+
+	if v.Version < latest {
+		return fmt.Errorf("schema version %d is behind version %d", v.Version, latest)
+	}
+
+	return nil
+}
+
+// heartbeat records the last run of a background worker, which is alive as
+// long as it ran recently.
+type heartbeat struct {
+	mu sync.Mutex
+	at time.Time
+}
+
+func (h *heartbeat) beat() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.at = time.Now()
+}
+
+func (h *heartbeat) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.at = time.Time{}
+}
+
+// alive fails when the worker does not run, or did not run for three of
+// its intervals. The runs of the workers are bounded by their interval, so
+// this only happens when the loop itself is stuck, not when the external
+// services they call are slow.
+func (h *heartbeat) alive(interval time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.at.IsZero() {
+		return errors.New("not running")
+	}
+
+	if since := time.Since(h.at); since > interval*3 {
+		return fmt.Errorf("last ran %s ago", since.Round(time.Second))
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// BootstrapEvent is triggered before and after the app is bootstrapped.
+type BootstrapEvent struct {
+	App App
+}
+
+// ServeRouter adds routes and middlewares to the API before it is served.
+// The routes go through the same authentication, tenant scoping, audit log
+// and metrics as the ones of the API.
+type ServeRouter interface {
+	// Route registers the handler of the pattern, e.g.
+	// `GET /api/v1/integrations/{id}`. Callers must be authenticated and,
+	// unless role is empty, granted it on any resource. The handler checks
+	// the role on the resource it acts on.
+	Route(pattern string, role string, handler func(*EventRequest))
+
+	// PublicRoute registers the handler of the pattern, requested
+	// anonymously.
+	PublicRoute(pattern string, handler func(*EventRequest))
+
+	// Use registers a global middleware. It runs before the ones of the
+	// API, so the request is not authenticated yet.
+	Use(fn func(*EventRequest, http.Handler))
+}
+
+// ServeEvent is triggered once the router and the server of the API are
+// created, before the server starts.
+type ServeEvent struct {
+	App    App
+	Router ServeRouter
+	Server *http.Server
+}
+
+// The actions of the model changes.
+const (
+	ModelCreate = "create"
+	ModelUpdate = "update"
+	ModelDelete = "delete"
+)
+
+// ModelEvent is the change of a model by a request, triggered once the
+// change is saved.
+type ModelEvent struct {
+	App App
+
+	// Request is the request changing the model.
+	Request *EventRequest
+
+	// Action is either ModelCreate, ModelUpdate or ModelDelete.
+	Action string
+
+	// Type is the type of the model, e.g. `cluster`, as recorded in the
+	// audit log.
+	Type string
+	Id   string
+
+	// Before and After are the model before and after the change, Before
+	// being nil when it was created or is not loaded, e.g. a revoked token,
+	// and After when it was deleted. Models holding secrets, e.g. the
+	// tokens, are redacted.
+	Before any
+	After  any
+}
+
+// SetChange records the model changed by the request in its audit log
+// event, and triggers the model change hooks of the app. Since the change
+// is saved already, the errors of the hooks are logged only.
+func (e *EventRequest) SetChange(action string, modelType string, id string, before any, after any) {
+	e.Audit.SetChange(modelType, id, before, after)
+
+	err := e.App.OnModelChange().Trigger(&ModelEvent{
+		App:     e.App,
+		Request: e,
+		Action:  action,
+		Type:    modelType,
+		Id:      id,
+		Before:  before,
+		After:   after,
+	})
+	if err != nil {
+		e.App.Logger().ErrorContext(e.Request.Context(), "model change hook failed",
+			slog.String("action", action),
+			slog.String("model_type", modelType),
+			slog.String("model_id", id),
+			slog.Any("error", err),
+		)
+	}
+}
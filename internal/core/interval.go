@@ -0,0 +1,36 @@
+package core
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// workerInterval is the interval between two runs of a background worker,
+// which can change while the worker runs.
+type workerInterval struct {
+	d atomic.Int64
+
+	// changed signals the worker to reset its ticker.
+	changed chan struct{}
+}
+
+func newWorkerInterval(d time.Duration) *workerInterval {
+	i := &workerInterval{changed: make(chan struct{}, 1)}
+	i.d.Store(int64(d))
+	return i
+}
+
+func (i *workerInterval) get() time.Duration {
+	return time.Duration(i.d.Load())
+}
+
+func (i *workerInterval) set(d time.Duration) {
+	if time.Duration(i.d.Swap(int64(d))) == d {
+		return
+	}
+
+	select {
+	case i.changed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace prefixes the names of the metrics of the app.
+const metricsNamespace = "scopehouse"
+
+// The results of the operations counted by the metrics.
+const (
+	MetricResultSuccess = "success"
+	MetricResultFailure = "failure"
+)
+
+// Metrics are the Prometheus metrics of the app, along with the ones of the
+// Go runtime, the process and the database connection pool. Each app has
+// its own registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	// HttpRequests counts the requests by method, route pattern and
+	// status.
+	HttpRequests *prometheus.CounterVec
+
+	// HttpRequestDuration observes the duration of the requests by method
+	// and route pattern.
+	HttpRequestDuration *prometheus.HistogramVec
+
+	// HttpRequestsInFlight is the number of requests being served.
+	HttpRequestsInFlight prometheus.Gauge
+
+	// AuditFailures counts the mutating requests whose audit event could not
+	// be recorded.
+	AuditFailures prometheus.Counter
+
+	// AlertmanagerSyncs counts the syncs of the Alertmanager configurations
+	// by result.
+	AlertmanagerSyncs *prometheus.CounterVec
+
+	// SilenceTargetCalls counts the calls to the Alertmanagers the silences
+	// target, by operation and result.
+	SilenceTargetCalls *prometheus.CounterVec
+
+	// SchedulerRuns counts the runs of the scheduler by result.
+	SchedulerRuns *prometheus.CounterVec
+
+	// MaintenanceOccurrences counts the occurrences of the maintenance
+	// windows, fired or missed.
+	MaintenanceOccurrences *prometheus.CounterVec
+
+	// AlertPolls counts the polls of the alerts of the clusters by source
+	// and result.
+	AlertPolls *prometheus.CounterVec
+
+	// AlertPollDuration observes the duration of the polls of all the
+	// clusters.
+	AlertPollDuration prometheus.Histogram
+}
+
+// NewMetrics creates the metrics of an app, collecting the stats of the
+// connection pool of db when not nil.
+func NewMetrics(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		HttpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+
+		HttpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of the HTTP requests by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+
+		HttpRequestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests being served.",
+		}),
+
+		AuditFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "audit_failures_total",
+			Help:      "Number of mutating requests whose audit event could not be recorded.",
+		}),
+
+		AlertmanagerSyncs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "alertmanager_syncs_total",
+			Help:      "Number of syncs of the Alertmanager configurations by result.",
+		}, []string{"result"}),
+
+		SilenceTargetCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "silence_target_calls_total",
+			Help:      "Number of calls to the Alertmanagers targeted by silences by operation and result.",
+		}, []string{"operation", "result"}),
+
+		SchedulerRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "scheduler_runs_total",
+			Help:      "Number of runs of the maintenance window scheduler by result.",
+		}, []string{"result"}),
+
+		MaintenanceOccurrences: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "maintenance_window_occurrences_total",
+			Help:      "Number of occurrences of the maintenance windows by outcome, fired or missed.",
+		}, []string{"outcome"}),
+
+		AlertPolls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "alert_polls_total",
+			Help:      "Number of polls of the alerts of the clusters by source and result.",
+		}, []string{"source", "result"}),
+
+		AlertPollDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "alert_poll_duration_seconds",
+			Help:      "Duration of the polls of the alerts of all the clusters.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.HttpRequests,
+		m.HttpRequestDuration,
+		m.HttpRequestsInFlight,
+		m.AuditFailures,
+		m.AlertmanagerSyncs,
+		m.SilenceTargetCalls,
+		m.SchedulerRuns,
+		m.MaintenanceOccurrences,
+		m.AlertPolls,
+		m.AlertPollDuration,
+	)
+
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, metricsNamespace))
+	}
+
+	return m
+}
+
+// Handler returns the handler serving the metrics in the Prometheus
+// exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// MetricResult returns the result label of an operation that ended with
+// err.
+func MetricResult(err error) string {
+	if err != nil {
+		return MetricResultFailure
+	}
+	return MetricResultSuccess
+}
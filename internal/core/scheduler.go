@@ -0,0 +1,316 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+const (
+	// DefaultSchedulerInterval is the interval between two runs of the
+	// scheduler.
+	DefaultSchedulerInterval = time.Second * 30
+
+	// maintenanceLookahead is how long before the start of an occurrence of
+	// a maintenance window its silence is created, so that it is active in
+	// every Alertmanager when the window starts.
+	maintenanceLookahead = time.Minute * 5
+
+	// schedulerLease is the lease electing the replica running the
+	// scheduler.
+	schedulerLease = "scheduler"
+)
+
+// Scheduler silences the clusters during the maintenance windows. It keeps
+// no state of its own, the windows record their next occurrence, so it
+// resumes where it left off after a restart. When several replicas run, the
+// one holding the scheduler lease runs it, and each occurrence is silenced
+// once whichever replica fires it.
+type Scheduler struct {
+	app      App
+	interval *workerInterval
+	holder   string
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+
+	heartbeat heartbeat
+}
+
+// NewScheduler creates a scheduler of the app running at the interval, the
+// default one when not positive.
+func NewScheduler(app App, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultSchedulerInterval
+	}
+
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+
+	return &Scheduler{
+		app:      app,
+		interval: newWorkerInterval(interval),
+		holder:   hex.EncodeToString(b),
+	}
+}
+
+// Start runs the scheduler in the background until it is stopped.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	s.heartbeat.beat()
+
+	go s.loop(s.stop, s.done)
+}
+
+// Stop stops the scheduler, waiting for its current run, and releases its
+// lease so that another replica takes over right away.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop == nil {
+		return
+	}
+
+	close(s.stop)
+	<-s.done
+
+	s.stop = nil
+	s.done = nil
+
+	s.heartbeat.reset()
+
+	if err := s.app.Models().Leases.Release(schedulerLease, s.holder); err != nil {
+		s.app.Logger().Error("scheduler lease release failed", slog.Any("error", err))
+	}
+}
+
+// SetInterval changes the interval between two runs of the scheduler, the
+// default one when not positive. A running scheduler runs right away, then at
+// the new interval.
+func (s *Scheduler) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSchedulerInterval
+	}
+
+	s.interval.set(interval)
+}
+
+// Alive fails when the scheduler is not running, or when its loop is
+// stuck. Runs are bounded by the interval, slow Alertmanagers do not fail it.
+func (s *Scheduler) Alive() error {
+	return s.heartbeat.alive(s.interval.get())
+}
+
+func (s *Scheduler) loop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.interval.get())
+	defer ticker.Stop()
+
+	for {
+		s.heartbeat.beat()
+
+		if err := s.Run(time.Now()); err != nil {
+			s.app.Logger().Error("scheduler run failed", slog.Any("error", err))
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-s.interval.changed:
+			ticker.Reset(s.interval.get())
+		}
+	}
+}
+
+// Run fires the maintenance windows due at the given time, and retries the
+// silences of windows that failed on some clusters, unless another replica
+// holds the scheduler lease.
+func (s *Scheduler) Run(now time.Time) error {
+	// The whole run is bounded by the interval, so that slow Alertmanagers
+	// delay the silences, retried at the next run, and not the heartbeat.
+	ctx, cancel := context.WithTimeout(context.Background(), s.interval.get())
+	defer cancel()
+
+	ctx, span := s.app.Tracer().Start(ctx, "scheduler.run")
+	defer span.End()
+
+	models := s.app.Models().Trace(ctx)
+
+	held, err := models.Leases.Acquire(schedulerLease, s.holder, s.interval.get()*3)
+	if err != nil {
+		return err
+	}
+
+	if !held {
+		return nil
+	}
+
+	err = s.run(ctx, models, now)
+	s.app.Metrics().SchedulerRuns.WithLabelValues(MetricResult(err)).Inc()
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// run fires the windows due and retries the unsynced silences, once the
+// lease is held.
+func (s *Scheduler) run(ctx context.Context, models *data.Models, now time.Time) error {
+	windows, err := models.MaintenanceWindows.ListDue(now.Add(maintenanceLookahead))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, window := range windows {
+		if err := s.fire(ctx, window, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	silences, err := models.Silences.ListUnsynced()
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	for _, silence := range silences {
+		if err := s.inTenant(ctx, silence.TenantId, func(models *data.Models) error {
+			return CreateSilence(ctx, s.app, models, silence)
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// fire silences the next occurrence of the window, and advances the window
+// to the following one. Occurrences that ended already, e.g. while no
+// replica was running, are skipped.
+func (s *Scheduler) fire(ctx context.Context, window *data.MaintenanceWindow, now time.Time) error {
+	logger := s.app.Logger().With(
+		slog.String("maintenance_window_id", window.Id),
+		slog.String("tenant_id", window.TenantId),
+	)
+
+	occurrence := *window.NextRunAt
+
+	next, err := window.NextRun(occurrence)
+	if err != nil {
+		return err
+	}
+
+	duration, err := window.ParseDuration()
+	if err != nil {
+		return err
+	}
+
+	return s.inTenant(ctx, window.TenantId, func(models *data.Models) error {
+		if end := occurrence.Add(duration); end.After(now) {
+			silence, err := s.silenceOccurrence(ctx, models, window, occurrence, end)
+			if err != nil {
+				return err
+			}
+
+			if silence != nil {
+				s.app.Metrics().MaintenanceOccurrences.WithLabelValues("fired").Inc()
+				logger.InfoContext(ctx, "maintenance window fired",
+					slog.String("silence_id", silence.Id),
+					slog.Time("starts_at", silence.StartsAt),
+					slog.Int("clusters", len(silence.Targets)),
+				)
+			}
+		} else {
+			s.app.Metrics().MaintenanceOccurrences.WithLabelValues("missed").Inc()
+			logger.WarnContext(ctx, "maintenance window occurrence missed", slog.Time("starts_at", occurrence))
+		}
+
+		// Another replica may have advanced the window meanwhile, the
+		// occurrence was silenced once anyway.
+		if _, err := models.MaintenanceWindows.Advance(window, next); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// silenceOccurrence creates the silence of the occurrence of the window on
+// the clusters matching its selector. It returns nil when the occurrence was
+// already silenced, or when no cluster matches.
+func (s *Scheduler) silenceOccurrence(ctx context.Context, models *data.Models, window *data.MaintenanceWindow, start, end time.Time) (*data.Silence, error) {
+	clusters, err := SelectClusters(models, window.ClusterSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(clusters) == 0 {
+		s.app.Logger().WarnContext(ctx, "maintenance window matches no cluster", slog.String("maintenance_window_id", window.Id))
+		return nil, nil
+	}
+
+	silence := &data.Silence{
+		Matchers:            window.Matchers,
+		ClusterSelector:     window.ClusterSelector,
+		StartsAt:            start,
+		EndsAt:              end,
+		Comment:             "Maintenance window " + window.Name + ": " + window.Comment,
+		MaintenanceWindowId: window.Id,
+	}
+
+	for _, cluster := range clusters {
+		silence.Targets = append(silence.Targets, &data.SilenceTarget{ClusterId: cluster.Id})
+	}
+
+	if err := models.Silences.Insert(silence); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateSilence):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	if err := CreateSilence(ctx, s.app, models, silence); err != nil {
+		return nil, err
+	}
+
+	return silence, nil
+}
+
+// inTenant runs fn with the models scoped to the tenant, traced as part of
+// the span of ctx.
+func (s *Scheduler) inTenant(ctx context.Context, tenantId string, fn func(models *data.Models) error) error {
+	models, release, err := s.app.Models().Scope(tenantId)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return fn(models.Trace(ctx))
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownHookTimeout bounds the shutdown hooks registered without a
+// timeout.
+const DefaultShutdownHookTimeout = time.Second * 10
+
+// ShutdownHook is a named job run when the app shuts down, e.g. stopping a
+// background worker once its current run is done.
+type ShutdownHook struct {
+	Name string
+
+	// Priority orders the hooks, the ones with a higher priority run first.
+	// Hooks of the same priority run in the reverse order they were
+	// registered, so that components stop before the ones they depend on.
+	// Hooks flushing what the others produce, e.g. the spans, use a negative
+	// priority.
+	Priority int
+
+	// Timeout bounds the hook, DefaultShutdownHookTimeout when not positive.
+	Timeout time.Duration
+
+	Run func(ctx context.Context) error
+}
+
+// ShutdownHooks is the registry of the shutdown hooks of the app.
+type ShutdownHooks struct {
+	mu    sync.Mutex
+	hooks []*ShutdownHook
+}
+
+// NewShutdownHooks creates an empty registry of shutdown hooks.
+func NewShutdownHooks() *ShutdownHooks {
+	return &ShutdownHooks{}
+}
+
+// Register adds the hook to the registry.
+func (h *ShutdownHooks) Register(hook *ShutdownHook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.hooks = append(h.hooks, hook)
+}
+
+// Run runs the hooks one at a time, by priority, each bounded by its
+// timeout and all of them by ctx. Hooks still run after a previous one
+// failed, their errors are logged and joined. Once ctx is done, the
+// remaining hooks fail without running.
+func (h *ShutdownHooks) Run(ctx context.Context, logger *slog.Logger) error {
+	h.mu.Lock()
+	hooks := slices.Clone(h.hooks)
+	h.mu.Unlock()
+
+	slices.Reverse(hooks)
+
+	slices.SortStableFunc(hooks, func(a, b *ShutdownHook) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+
+	var errs []error
+
+	for _, hook := range hooks {
+		start := time.Now()
+
+		if err := runShutdownHook(ctx, hook); err != nil {
+			logger.Error("shutdown hook failed", slog.String("hook", hook.Name), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("shutdown hook %s failed; %w", hook.Name, err))
+			continue
+		}
+
+		logger.Info("shutdown hook done",
+			slog.String("hook", hook.Name),
+			slog.Duration("duration", time.Since(start)),
+		)
+	}
+
+	return errors.Join(errs...)
+}
+
+func runShutdownHook(ctx context.Context, hook *ShutdownHook) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownHookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Hooks ignoring their context are still bounded, they are left running
+	// in the background.
+	errc := make(chan error, 1)
+	go func() { errc <- hook.Run(ctx) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stopHook returns the hook stopping a background worker, e.g. the
+// scheduler, once its current run is done.
+func stopHook(name string, stop func()) *ShutdownHook {
+	return &ShutdownHook{
+		Name: name,
+		Run: func(context.Context) error {
+			stop()
+			return nil
+		},
+	}
+}
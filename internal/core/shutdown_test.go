@@ -0,0 +1,121 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownHooks(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu  sync.Mutex
+		ran []string
+	)
+
+	hook := func(name string, priority int, err error) *ShutdownHook {
+		return &ShutdownHook{
+			Name:     name,
+			Priority: priority,
+			Run: func(context.Context) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				ran = append(ran, name)
+				return err
+			},
+		}
+	}
+
+	hooks := NewShutdownHooks()
+	hooks.Register(hook("database", 0, nil))
+	hooks.Register(hook("tracing", -1, nil))
+	hooks.Register(hook("scheduler", 0, errors.New("lease release failed")))
+	hooks.Register(hook("poller", 0, nil))
+	hooks.Register(hook("server", 10, nil))
+
+	// The hook ignores its context, it is abandoned once it times out.
+	hooks.Register(&ShutdownHook{
+		Name:    "stuck",
+		Timeout: time.Millisecond * 10,
+		Run: func(context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	})
+
+	var logs bytes.Buffer
+
+	err := hooks.Run(context.Background(), slog.New(slog.NewTextHandler(&logs, nil)))
+
+	expected := []string{"server", "poller", "scheduler", "database", "tracing"}
+	if !slices.Equal(ran, expected) {
+		t.Fatalf("expected hooks to run in order %v, got %v", expected, ran)
+	}
+
+	if err == nil {
+		t.Fatal("expected the errors of the hooks")
+	}
+
+	for _, msg := range []string{
+		"shutdown hook scheduler failed; lease release failed",
+		"shutdown hook stuck failed; context deadline exceeded",
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected error to contain %q, got %v", msg, err)
+		}
+	}
+
+	for _, line := range []string{
+		`msg="shutdown hook failed" hook=scheduler error="lease release failed"`,
+		`msg="shutdown hook done" hook=tracing`,
+	} {
+		if !strings.Contains(logs.String(), line) {
+			t.Fatalf("expected logs to contain %s, got %s", line, logs.String())
+		}
+	}
+}
+
+func TestShutdownHooksDeadline(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+
+	ran := false
+
+	hooks := NewShutdownHooks()
+	hooks.Register(&ShutdownHook{
+		Name: "late",
+		Run: func(context.Context) error {
+			ran = true
+			return nil
+		},
+	})
+	hooks.Register(&ShutdownHook{
+		Name: "slow",
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	start := time.Now()
+
+	err := hooks.Run(ctx, slog.New(slog.DiscardHandler))
+
+	if time.Since(start) > time.Second {
+		t.Fatal("expected hooks to be bounded by the context")
+	}
+
+	if ran || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected remaining hooks to fail without running, got %v", err)
+	}
+}
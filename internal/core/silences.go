@@ -0,0 +1,266 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// silenceConcurrency bounds the number of Alertmanagers called at once when
+// fanning a silence out to its clusters.
+const silenceConcurrency = 8
+
+// SelectClusters returns the clusters having all the labels of the
+// selector, all the clusters when it is empty.
+func SelectClusters(models *data.Models, selector map[string]string) ([]*data.Cluster, error) {
+	q := &event.ListQuery{Limit: event.MaxListLimit}
+	selected := []*data.Cluster{}
+
+	for {
+		clusters, next, err := models.Clusters.List(q)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cluster := range clusters {
+			if hasLabels(cluster.Labels, selector) {
+				selected = append(selected, cluster)
+			}
+		}
+
+		if next == "" {
+			return selected, nil
+		}
+
+		q.Cursor = next
+	}
+}
+
+func hasLabels(labels map[string]string, selector map[string]string) bool {
+	for name, value := range selector {
+		if v, ok := labels[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateSilence creates the silence on the Alertmanager of the targets it
+// was not created on yet, those that failed included, and records the
+// outcome for each of them. It only returns an error when the outcome
+// cannot be recorded.
+func CreateSilence(ctx context.Context, app App, models *data.Models, silence *data.Silence) error {
+	matchers, err := alertmanager.ParseMatchers(silence.Matchers)
+	if err != nil {
+		return err
+	}
+
+	amSilence := &alertmanager.Silence{
+		Matchers:  alertmanager.SilenceMatchers(matchers),
+		StartsAt:  silence.StartsAt,
+		EndsAt:    silence.EndsAt,
+		CreatedBy: "scopehouse (silence " + silence.Id + ")",
+		Comment:   silence.Comment,
+	}
+
+	targets := []*data.SilenceTarget{}
+
+	for _, target := range silence.Targets {
+		if target.Status == data.SilenceTargetPending || target.Status == data.SilenceTargetFailed {
+			targets = append(targets, target)
+		}
+	}
+
+	return fanOutSilence(ctx, app, models, silence, "create", targets, func(ctx context.Context, client *alertmanager.Client, target *data.SilenceTarget) {
+		id, err := client.CreateSilence(ctx, amSilence)
+		if err != nil {
+			target.Status = data.SilenceTargetFailed
+			target.Error = err.Error()
+			return
+		}
+
+		target.AlertmanagerSilenceId = id
+		target.Status = data.SilenceTargetCreated
+		target.Error = ""
+	})
+}
+
+// ExpireSilence expires the silence on the Alertmanager of the targets it
+// was created on, records the outcome for each of them, and records the
+// silence as expired. Targets failing to expire keep their status, so that
+// expiring the silence again retries them.
+func ExpireSilence(ctx context.Context, app App, models *data.Models, silence *data.Silence) error {
+	targets := []*data.SilenceTarget{}
+
+	for _, target := range silence.Targets {
+		switch target.Status {
+		case data.SilenceTargetCreated:
+			targets = append(targets, target)
+		case data.SilenceTargetPending, data.SilenceTargetFailed:
+			// Never created, there is nothing to expire.
+			target.Status = data.SilenceTargetExpired
+			target.Error = ""
+
+			if err := models.Silences.UpdateTarget(target); err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+				return err
+			}
+		}
+	}
+
+	err := fanOutSilence(ctx, app, models, silence, "expire", targets, func(ctx context.Context, client *alertmanager.Client, target *data.SilenceTarget) {
+		if err := client.ExpireSilence(ctx, target.AlertmanagerSilenceId); err != nil {
+			target.Error = err.Error()
+			return
+		}
+
+		target.Status = data.SilenceTargetExpired
+		target.Error = ""
+	})
+	if err != nil {
+		return err
+	}
+
+	return models.Silences.Expire(silence)
+}
+
+// fanOutSilence calls fn with the Alertmanager client of each target
+// concurrently, then saves the targets. The clusters are loaded and the
+// targets saved one at a time, since the models may be bound to a single
+// connection. The targets whose client cannot be built record the error,
+// and are failed when creating the silence only: those failing to expire
+// keep their status.
+func fanOutSilence(
+	ctx context.Context,
+	app App,
+	models *data.Models,
+	silence *data.Silence,
+	operation string,
+	targets []*data.SilenceTarget,
+	fn func(ctx context.Context, client *alertmanager.Client, target *data.SilenceTarget),
+) error {
+	clients := make([]*alertmanager.Client, len(targets))
+
+	fail := func(target *data.SilenceTarget, msg string) {
+		if operation == "create" {
+			target.Status = data.SilenceTargetFailed
+		}
+		target.Error = msg
+	}
+
+	for i, target := range targets {
+		cluster, err := models.Clusters.GetById(target.ClusterId)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				// The cluster was deleted along with the target.
+				continue
+			default:
+				return err
+			}
+		}
+
+		if cluster.AlertmanagerUrl == "" {
+			fail(target, "cluster has no Alertmanager url")
+			continue
+		}
+
+		client, err := ClusterHttpClient(app, models, cluster.Id)
+		if err != nil {
+			fail(target, fmt.Sprintf("cluster credentials failed; %v", err))
+			continue
+		}
+
+		clients[i] = alertmanager.NewClient(cluster.AlertmanagerUrl, client)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, silenceConcurrency)
+
+	for i, target := range targets {
+		if clients[i] == nil {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fn(ctx, clients[i], target)
+		}()
+	}
+
+	wg.Wait()
+
+	for i, target := range targets {
+		if clients[i] != nil {
+			result := MetricResultSuccess
+			if target.Error != "" {
+				result = MetricResultFailure
+			}
+			app.Metrics().SilenceTargetCalls.WithLabelValues(operation, result).Inc()
+		}
+
+		if target.Error != "" {
+			app.Logger().WarnContext(ctx, "silence target failed",
+				slog.String("silence_id", silence.Id),
+				slog.String("cluster_id", target.ClusterId),
+				slog.String("error", target.Error),
+			)
+		}
+
+		if err := models.Silences.UpdateTarget(target); err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExpireMaintenanceSilences expires the silences of the maintenance window
+// that have not expired yet, e.g. when the window is disabled or deleted.
+func ExpireMaintenanceSilences(ctx context.Context, app App, models *data.Models, window *data.MaintenanceWindow) error {
+	q := &event.ListQuery{
+		Limit: event.MaxListLimit,
+		Filters: []event.ListFilter{
+			{Field: "maintenance_window_id", Operator: "eq", Value: window.Id},
+		},
+	}
+
+	var silences []*data.Silence
+
+	for {
+		page, next, err := models.Silences.List(q)
+		if err != nil {
+			return err
+		}
+
+		for _, silence := range page {
+			if silence.MaintenanceWindowId == window.Id && silence.Status != data.SilenceExpired {
+				silences = append(silences, silence)
+			}
+		}
+
+		if next == "" {
+			break
+		}
+
+		q.Cursor = next
+	}
+
+	for _, silence := range silences {
+		if err := ExpireSilence(ctx, app, models, silence); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
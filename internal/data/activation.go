@@ -0,0 +1,112 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ActivationTokenStore interface {
+	Insert(token *ActivationToken) error
+	Activate(plaintext string) (*User, error)
+}
+
+// ActivationToken is a one-time token sent to users to activate their account.
+type ActivationToken struct {
+	Plaintext string
+	Hash      []byte
+	UserId    string
+	ExpiresAt time.Time
+}
+
+func NewActivationToken(userId string, ttl time.Duration) (*ActivationToken, error) {
+	plaintext, err := generateSecret("")
+	if err != nil {
+		return nil, err
+	}
+
+	token := &ActivationToken{
+		Plaintext: plaintext,
+		Hash:      hashToken(plaintext),
+		UserId:    userId,
+		ExpiresAt: time.Now().Add(ttl).UTC(),
+	}
+
+	return token, nil
+}
+
+type ActivationTokenModel struct {
+	DB Querier
+}
+
+func (m ActivationTokenModel) Insert(token *ActivationToken) error {
+	query := `
+		INSERT INTO activation_tokens (hash, user_id, expires_at)
+		VALUES ($1, $2, $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, token.Hash, token.UserId, token.ExpiresAt)
+
+	return err
+}
+
+// Activate flips the `is_activated` flag of the user the unexpired token was
+// issued to, and deletes all the activation tokens of that user.
+func (m ActivationTokenModel) Activate(plaintext string) (*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `
+		UPDATE users
+		SET is_activated = true, updated_at = now()
+		WHERE id = (
+			SELECT user_id
+			FROM activation_tokens
+			WHERE hash = $1 AND expires_at > now()
+		)
+		RETURNING id, email, is_activated, roles, created_at, updated_at`
+
+	var user User
+
+	err = tx.QueryRowContext(ctx, query, hashToken(plaintext)).Scan(
+		&user.Id,
+		&user.Email,
+		&user.IsActivated,
+		pq.Array(&user.Roles),
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	query = `
+		DELETE FROM activation_tokens
+		WHERE user_id = $1`
+
+	if _, err := tx.ExecContext(ctx, query, user.Id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
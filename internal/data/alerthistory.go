@@ -0,0 +1,332 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// The states of the alerts recorded in their history.
+const (
+	AlertStatePending  = "pending"
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+)
+
+type AlertHistoryStore interface {
+	States(clusterId string) ([]*AlertState, error)
+	Record(transitions []*AlertTransition) error
+	RuleStats(rule string, q *StatsQuery) (*RuleStats, error)
+	Noisiest(q *StatsQuery, limit int) ([]*RuleStats, error)
+}
+
+// AlertState is the last observed state of an alert of a cluster that is
+// not resolved, since its last transition.
+type AlertState struct {
+	ClusterId   string            `json:"cluster_id"`
+	Fingerprint string            `json:"fingerprint"`
+	Rule        string            `json:"rule"`
+	Severity    string            `json:"severity"`
+	Labels      map[string]string `json:"labels"`
+	State       string            `json:"state"`
+	Since       time.Time         `json:"since"`
+}
+
+// AlertTransition is a change of the state of an alert of a cluster. The
+// previous state is empty when the alert was not observed before.
+type AlertTransition struct {
+	Id            string            `json:"id"`
+	TenantId      string            `json:"tenant_id"`
+	ClusterId     string            `json:"cluster_id"`
+	Fingerprint   string            `json:"fingerprint"`
+	Rule          string            `json:"rule"`
+	Severity      string            `json:"severity"`
+	Labels        map[string]string `json:"labels"`
+	State         string            `json:"state"`
+	PreviousState string            `json:"previous_state"`
+	ObservedAt    time.Time         `json:"observed_at"`
+}
+
+// StatsQuery restricts the statistics to the transitions observed since a
+// time, and to clusters, all of them when ClusterIds is nil. Alerts firing
+// again less than the flap interval after they resolved are flapping.
+type StatsQuery struct {
+	Since        time.Time
+	ClusterIds   []string
+	FlapInterval time.Duration
+}
+
+// RuleStats are the statistics of the alerts of a rule, i.e. the alerts with
+// the same name. Flappiness is the share of the times they fired that were
+// flaps, from 0 to 1. MeanTimeFiring only accounts for the alerts that
+// resolved since.
+type RuleStats struct {
+	Rule           string     `json:"rule"`
+	FireCount      int        `json:"fire_count"`
+	FlapCount      int        `json:"flap_count"`
+	Flappiness     float64    `json:"flappiness"`
+	MeanTimeFiring float64    `json:"mean_time_firing_seconds"`
+	Clusters       int        `json:"clusters"`
+	LastFiredAt    *time.Time `json:"last_fired_at"`
+}
+
+type AlertHistoryModel struct {
+	DB Querier
+}
+
+func (m AlertHistoryModel) States(clusterId string) ([]*AlertState, error) {
+	if !isUUID(clusterId) {
+		return []*AlertState{}, nil
+	}
+
+	query := `
+		SELECT cluster_id, fingerprint, rule, severity, labels, state, since
+		FROM alert_states
+		WHERE cluster_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, clusterId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	states := []*AlertState{}
+
+	for rows.Next() {
+		var state AlertState
+		var labels []byte
+
+		err := rows.Scan(
+			&state.ClusterId,
+			&state.Fingerprint,
+			&state.Rule,
+			&state.Severity,
+			&labels,
+			&state.State,
+			&state.Since,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(labels, &state.Labels); err != nil {
+			return nil, err
+		}
+
+		states = append(states, &state)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// Record saves the transitions, and the resulting states of their alerts,
+// in the tenant of their cluster. Transitions of deleted clusters are
+// ignored.
+func (m AlertHistoryModel) Record(transitions []*AlertTransition) error {
+	if len(transitions) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	insert := `
+		INSERT INTO alert_transitions (
+			tenant_id, cluster_id, fingerprint, rule, severity, labels, state,
+			previous_state, observed_at
+		)
+		SELECT tenant_id, id, $2, $3, $4, $5, $6, $7, $8
+		FROM clusters
+		WHERE id = $1
+		RETURNING id, tenant_id`
+
+	upsert := `
+		INSERT INTO alert_states (
+			tenant_id, cluster_id, fingerprint, rule, severity, labels, state, since
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (cluster_id, fingerprint) DO UPDATE
+		SET rule = EXCLUDED.rule, severity = EXCLUDED.severity, labels = EXCLUDED.labels,
+			state = EXCLUDED.state, since = EXCLUDED.since`
+
+	remove := `
+		DELETE FROM alert_states
+		WHERE cluster_id = $1 AND fingerprint = $2`
+
+	for _, t := range transitions {
+		if t.Labels == nil {
+			t.Labels = map[string]string{}
+		}
+
+		labels, err := json.Marshal(t.Labels)
+		if err != nil {
+			return err
+		}
+
+		args := []any{
+			t.ClusterId,
+			t.Fingerprint,
+			t.Rule,
+			t.Severity,
+			labels,
+			t.State,
+			t.PreviousState,
+			t.ObservedAt,
+		}
+
+		err = tx.QueryRowContext(ctx, insert, args...).Scan(&t.Id, &t.TenantId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return err
+		}
+
+		switch t.State {
+		case AlertStateResolved:
+			_, err = tx.ExecContext(ctx, remove, t.ClusterId, t.Fingerprint)
+		default:
+			_, err = tx.ExecContext(ctx, upsert,
+				t.TenantId, t.ClusterId, t.Fingerprint, t.Rule, t.Severity, labels, t.State, t.ObservedAt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ruleStatsQuery computes the statistics of the rules from the transitions
+// of their alerts. The transitions before the period are only read to find
+// the alerts resolving less than the flap interval before firing again.
+const ruleStatsQuery = `
+	WITH transitions AS (
+		SELECT rule, cluster_id, state, observed_at,
+			lead(observed_at) OVER alert AS next_observed_at,
+			max(observed_at) FILTER (WHERE state = 'resolved') OVER (
+				alert ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+			) AS resolved_at
+		FROM alert_transitions
+		WHERE ($1 = '' OR rule = $1)
+			AND ($2::uuid[] IS NULL OR cluster_id = ANY($2::uuid[]))
+			AND observed_at >= $3::timestamptz - $4 * interval '1 second'
+		WINDOW alert AS (PARTITION BY cluster_id, fingerprint ORDER BY observed_at, id)
+	), fired AS (
+		SELECT * FROM transitions
+		WHERE state = 'firing' AND observed_at >= $3
+	)
+	SELECT rule,
+		count(*),
+		count(*) FILTER (WHERE observed_at - resolved_at < $4 * interval '1 second'),
+		COALESCE(avg(extract(epoch FROM next_observed_at - observed_at)), 0)::float8,
+		count(DISTINCT cluster_id),
+		max(observed_at)
+	FROM fired
+	GROUP BY rule
+	ORDER BY count(*) DESC, rule
+	LIMIT $5`
+
+func (m AlertHistoryModel) queryStats(rule string, q *StatsQuery, limit int) ([]*RuleStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	args := []any{rule, pq.Array(q.ClusterIds), q.Since, q.FlapInterval.Seconds(), limit}
+
+	rows, err := m.DB.QueryContext(ctx, ruleStatsQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	stats := []*RuleStats{}
+
+	for rows.Next() {
+		var s RuleStats
+
+		err := rows.Scan(
+			&s.Rule,
+			&s.FireCount,
+			&s.FlapCount,
+			&s.MeanTimeFiring,
+			&s.Clusters,
+			&s.LastFiredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.FireCount > 0 {
+			s.Flappiness = float64(s.FlapCount) / float64(s.FireCount)
+		}
+
+		stats = append(stats, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// RuleStats returns the statistics of the rule. The last time it fired is
+// looked up beyond the period of the query. It returns ErrRecordNotFound
+// when no alert of the rule was ever observed.
+func (m AlertHistoryModel) RuleStats(rule string, q *StatsQuery) (*RuleStats, error) {
+	query := `
+		SELECT count(*) > 0, max(observed_at) FILTER (WHERE state = 'firing')
+		FROM alert_transitions
+		WHERE rule = $1 AND ($2::uuid[] IS NULL OR cluster_id = ANY($2::uuid[]))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	var observed bool
+	var lastFiredAt *time.Time
+
+	if err := m.DB.QueryRowContext(ctx, query, rule, pq.Array(q.ClusterIds)).Scan(&observed, &lastFiredAt); err != nil {
+		return nil, err
+	}
+
+	if !observed {
+		return nil, ErrRecordNotFound
+	}
+
+	stats, err := m.queryStats(rule, q, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &RuleStats{Rule: rule}
+	if len(stats) > 0 {
+		s = stats[0]
+	}
+
+	s.LastFiredAt = lastFiredAt
+
+	return s, nil
+}
+
+// Noisiest returns the statistics of the rules whose alerts fired the most
+// during the period of the query, the noisiest first.
+func (m AlertHistoryModel) Noisiest(q *StatsQuery, limit int) ([]*RuleStats, error) {
+	return m.queryStats("", q, limit)
+}
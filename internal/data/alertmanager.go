@@ -0,0 +1,136 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+)
+
+type AlertmanagerConfigStore interface {
+	GetByClusterId(clusterId string) (*AlertmanagerConfig, error)
+	Upsert(config *AlertmanagerConfig) error
+	UpdateSync(config *AlertmanagerConfig) error
+}
+
+// AlertmanagerConfig is the routing configuration of the Alertmanager of a
+// cluster. SyncedAt is the time it was last synced to the Alertmanager, nil
+// when it changed since, and SyncError the error of the last failed sync.
+type AlertmanagerConfig struct {
+	ClusterId string               `json:"cluster_id"`
+	TenantId  string               `json:"tenant_id"`
+	Config    *alertmanager.Config `json:"config"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	SyncedAt  *time.Time           `json:"synced_at,omitempty"`
+	SyncError string               `json:"sync_error,omitempty"`
+}
+
+type AlertmanagerConfigModel struct {
+	DB Querier
+}
+
+func (m AlertmanagerConfigModel) GetByClusterId(clusterId string) (*AlertmanagerConfig, error) {
+	if !isUUID(clusterId) {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT cluster_id, tenant_id, config, updated_at, synced_at, sync_error
+		FROM alertmanager_configs
+		WHERE cluster_id = $1`
+
+	var config AlertmanagerConfig
+	var raw []byte
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, clusterId).Scan(
+		&config.ClusterId,
+		&config.TenantId,
+		&raw,
+		&config.UpdatedAt,
+		&config.SyncedAt,
+		&config.SyncError,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := json.Unmarshal(raw, &config.Config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// Upsert saves the configuration of the cluster, and clears its sync state.
+// Configurations are stored in the tenant of their cluster.
+func (m AlertmanagerConfigModel) Upsert(config *AlertmanagerConfig) error {
+	query := `
+		INSERT INTO alertmanager_configs (cluster_id, tenant_id, config)
+		SELECT id, tenant_id, $2
+		FROM clusters
+		WHERE id = $1
+		ON CONFLICT (cluster_id) DO UPDATE
+		SET config = EXCLUDED.config, updated_at = now(), synced_at = NULL, sync_error = ''
+		RETURNING tenant_id, updated_at`
+
+	raw, err := json.Marshal(config.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, config.ClusterId, raw).Scan(&config.TenantId, &config.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	config.SyncedAt = nil
+	config.SyncError = ""
+
+	return nil
+}
+
+// UpdateSync saves the sync state of the configuration.
+func (m AlertmanagerConfigModel) UpdateSync(config *AlertmanagerConfig) error {
+	query := `
+		UPDATE alertmanager_configs
+		SET synced_at = $1, sync_error = $2
+		WHERE cluster_id = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, config.SyncedAt, config.SyncError, config.ClusterId)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,568 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// auditGenesisHash is the previous hash of the first audit event.
+var auditGenesisHash = strings.Repeat("0", 64)
+
+type AuditStore interface {
+	Insert(event *AuditEvent) error
+	List(q *event.ListQuery) ([]*AuditEvent, string, error)
+	Verify() (*AuditVerification, error)
+}
+
+// AuditEvent records a mutating API call. Events are chained by including
+// the hash of the previous event of their tenant in their own, so that
+// altering or deleting an event breaks the chain of its tenant.
+//
+// The events recorded before the chains were split per tenant have no
+// ChainSeq, they form a single chain spanning all the tenants.
+type AuditEvent struct {
+	Seq          int64           `json:"seq"`
+	ChainSeq     int64           `json:"chain_seq,omitempty"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	TenantId     string          `json:"tenant_id,omitempty"`
+	ActorUserId  string          `json:"actor_user_id,omitempty"`
+	ActorTokenId string          `json:"actor_token_id,omitempty"`
+	ActorEmail   string          `json:"actor_email,omitempty"`
+	Method       string          `json:"method"`
+	Route        string          `json:"route"`
+	Path         string          `json:"path"`
+	Status       int             `json:"status"`
+	RemoteAddr   string          `json:"remote_addr"`
+	UserAgent    string          `json:"user_agent"`
+	ResourceType string          `json:"resource_type,omitempty"`
+	ResourceId   string          `json:"resource_id,omitempty"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	PrevHash     string          `json:"prev_hash"`
+	Hash         string          `json:"hash"`
+}
+
+// SetChange records the resource changed by the request along with its
+// state before and after the change, nil when it did not exist. It is a
+// no-op on a nil event, so handlers can call it unconditionally.
+func (e *AuditEvent) SetChange(resourceType string, resourceId string, before any, after any) {
+	if e == nil {
+		return
+	}
+
+	e.ResourceType = resourceType
+	e.ResourceId = resourceId
+	e.Before = auditState(before)
+	e.After = auditState(after)
+}
+
+func auditState(state any) json.RawMessage {
+	if state == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil || string(b) == "null" {
+		return nil
+	}
+
+	return b
+}
+
+// AuditHead is the last event of an audit chain. It is recorded along with
+// the event, so that deleting the last events of a chain, which leaves the
+// rest of the chain valid, is detected.
+type AuditHead struct {
+	Chain    string
+	Position int64
+	Hash     string
+}
+
+// Chain links the event after the head of the chain of its tenant, nil for
+// the first event, and computes its hash. The sequence number of the event,
+// ordering the events of all the tenants, must be set beforehand.
+func (e *AuditEvent) Chain(head *AuditHead) {
+	e.ChainSeq = 1
+	e.PrevHash = auditGenesisHash
+
+	if head != nil {
+		e.ChainSeq = head.Position + 1
+		e.PrevHash = head.Hash
+	}
+
+	// Postgres stores timestamps with a microsecond precision.
+	e.OccurredAt = e.OccurredAt.UTC().Truncate(time.Microsecond)
+	e.Hash = e.computeHash()
+}
+
+// Head returns the head of the chain of the event, nil for a nil event.
+func (e *AuditEvent) Head() *AuditHead {
+	if e == nil {
+		return nil
+	}
+
+	chain, position := e.chain()
+
+	return &AuditHead{Chain: chain, Position: position, Hash: e.Hash}
+}
+
+// chain returns the key of the chain of the event, and its position in the
+// chain.
+func (e *AuditEvent) chain() (string, int64) {
+	if e.ChainSeq == 0 {
+		return "", e.Seq
+	}
+	return auditTenantChain(e.TenantId), e.ChainSeq
+}
+
+// auditTenantChain returns the key of the chain of the tenant, empty for
+// the events of anonymous requests.
+func auditTenantChain(tenantId string) string {
+	return "tenant:" + tenantId
+}
+
+func (e *AuditEvent) computeHash() string {
+	c := *e
+	c.Hash = ""
+	c.OccurredAt = c.OccurredAt.UTC()
+
+	// Marshaling the event never fails, its states are valid json.
+	b, _ := json.Marshal(c)
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditVerification is the result of the audit chain verification.
+type AuditVerification struct {
+	Valid  bool  `json:"valid"`
+	Events int64 `json:"events"`
+
+	// BrokenAt is the sequence number of the first event that does not
+	// chain to the previous one, or of the last event of a chain missing
+	// the events up to its head.
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// AuditVerifier checks the chains of audit events fed in sequence order,
+// those of any number of tenants.
+type AuditVerifier struct {
+	result AuditVerification
+
+	// prev holds the last event of each chain.
+	prev map[string]*AuditEvent
+}
+
+func NewAuditVerifier() *AuditVerifier {
+	return &AuditVerifier{
+		result: AuditVerification{Valid: true},
+		prev:   map[string]*AuditEvent{},
+	}
+}
+
+// Next checks the next event, and reports whether the chain is still valid.
+func (v *AuditVerifier) Next(e *AuditEvent) bool {
+	if !v.result.Valid {
+		return false
+	}
+
+	chain, position := e.chain()
+
+	seq, prevHash := int64(1), auditGenesisHash
+	if prev, ok := v.prev[chain]; ok {
+		_, prevPosition := prev.chain()
+		seq, prevHash = prevPosition+1, prev.Hash
+	}
+
+	switch {
+	case position != seq:
+		v.fail(e, fmt.Sprintf("expected event %d, events are missing", seq))
+	case e.PrevHash != prevHash:
+		v.fail(e, "previous hash does not match the previous event")
+	case e.Hash != e.computeHash():
+		v.fail(e, "hash does not match the event content")
+	default:
+		v.result.Events++
+		v.prev[chain] = e
+	}
+
+	return v.result.Valid
+}
+
+// Heads checks that the chains fed so far end at their heads, once all
+// their events are fed, and reports whether they are still valid.
+func (v *AuditVerifier) Heads(heads []*AuditHead) bool {
+	byChain := map[string]*AuditHead{}
+	for _, head := range heads {
+		byChain[head.Chain] = head
+	}
+
+	chains := slices.Sorted(maps.Keys(byChain))
+	for chain := range v.prev {
+		if _, ok := byChain[chain]; !ok {
+			chains = append(chains, chain)
+		}
+	}
+
+	for _, chain := range chains {
+		if !v.result.Valid {
+			return false
+		}
+
+		head, last := byChain[chain], v.prev[chain]
+
+		var position int64
+		if last != nil {
+			_, position = last.chain()
+		}
+
+		switch {
+		case head == nil:
+			v.fail(last, "chain has no head")
+		case position < head.Position:
+			v.fail(last, fmt.Sprintf("expected event %d, events are missing", position+1))
+		case position > head.Position:
+			v.fail(last, "event is past the head of the chain")
+		case last.Hash != head.Hash:
+			v.fail(last, "hash does not match the head of the chain")
+		}
+	}
+
+	return v.result.Valid
+}
+
+func (v *AuditVerifier) fail(e *AuditEvent, reason string) {
+	v.result.Valid = false
+	v.result.Reason = reason
+
+	if e != nil {
+		v.result.BrokenAt = e.Seq
+	}
+}
+
+// Result returns the verification result of the events fed so far.
+func (v *AuditVerifier) Result() *AuditVerification {
+	result := v.result
+	return &result
+}
+
+type AuditModel struct {
+	DB Querier
+}
+
+// Insert appends the event to the audit log, chained after the head of the
+// chain of its tenant, and moves the head to the event. The chain is locked
+// until the event is committed so that concurrent inserts are chained one
+// after the other.
+func (m AuditModel) Insert(event *AuditEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "audit_events:"+event.TenantId)
+	if err != nil {
+		return err
+	}
+
+	// The events are chained after the head rather than the last event, so
+	// that the events deleted from the end of the chain are not papered over.
+	query := `SELECT chain, position, hash FROM audit_heads WHERE chain = $1`
+
+	var last AuditHead
+	var head *AuditHead
+
+	err = tx.QueryRowContext(ctx, query, auditTenantChain(event.TenantId)).Scan(&last.Chain, &last.Position, &last.Hash)
+	switch {
+	case err == nil:
+		head = &last
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	// The sequence numbers are drawn once the chain is locked, so that the
+	// events of a chain are in sequence order.
+	if err := tx.QueryRowContext(ctx, `SELECT nextval('audit_events_seq')`).Scan(&event.Seq); err != nil {
+		return err
+	}
+
+	event.Chain(head)
+
+	query = `
+		INSERT INTO audit_events (
+			seq, chain_seq, occurred_at, tenant_id, actor_user_id, actor_token_id, actor_email, method, route,
+			path, status, remote_addr, user_agent, resource_type, resource_id, before, after, prev_hash, hash
+		)
+		VALUES (
+			$1, $2, $3, NULLIF($4, '')::uuid, NULLIF($5, '')::uuid, NULLIF($6, '')::uuid, $7, $8, $9,
+			$10, $11, $12, $13, $14, $15, $16, $17, $18, $19
+		)`
+
+	args := []any{
+		event.Seq,
+		event.ChainSeq,
+		event.OccurredAt,
+		event.TenantId,
+		event.ActorUserId,
+		event.ActorTokenId,
+		event.ActorEmail,
+		event.Method,
+		event.Route,
+		event.Path,
+		event.Status,
+		event.RemoteAddr,
+		event.UserAgent,
+		event.ResourceType,
+		event.ResourceId,
+		nullJson(event.Before),
+		nullJson(event.After),
+		event.PrevHash,
+		event.Hash,
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	head = event.Head()
+
+	query = `
+		INSERT INTO audit_heads (chain, tenant_id, position, hash)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4)
+		ON CONFLICT (chain) DO UPDATE SET position = EXCLUDED.position, hash = EXCLUDED.hash`
+
+	if _, err := tx.ExecContext(ctx, query, head.Chain, event.TenantId, head.Position, head.Hash); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// nullJson returns the json state as a query argument, NULL when empty.
+func nullJson(state json.RawMessage) any {
+	if len(state) == 0 {
+		return nil
+	}
+	return string(state)
+}
+
+const auditColumns = `
+	seq, COALESCE(chain_seq, 0), occurred_at, COALESCE(tenant_id::text, ''), COALESCE(actor_user_id::text, ''),
+	COALESCE(actor_token_id::text, ''), actor_email, method, route, path, status, remote_addr, user_agent, resource_type,
+	resource_id, before, after, prev_hash, hash`
+
+func scanAuditEvent(scan func(dest ...any) error) (*AuditEvent, error) {
+	var event AuditEvent
+	var before, after []byte
+
+	err := scan(
+		&event.Seq,
+		&event.ChainSeq,
+		&event.OccurredAt,
+		&event.TenantId,
+		&event.ActorUserId,
+		&event.ActorTokenId,
+		&event.ActorEmail,
+		&event.Method,
+		&event.Route,
+		&event.Path,
+		&event.Status,
+		&event.RemoteAddr,
+		&event.UserAgent,
+		&event.ResourceType,
+		&event.ResourceId,
+		&before,
+		&after,
+		&event.PrevHash,
+		&event.Hash,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(before) > 0 {
+		event.Before = before
+	}
+
+	if len(after) > 0 {
+		event.After = after
+	}
+
+	event.OccurredAt = event.OccurredAt.UTC()
+
+	return &event, nil
+}
+
+var auditListSpec = listSpec{
+	fields: map[string]listField{
+		"seq":            {column: "seq", kind: fieldInt, sortable: true, filterable: true},
+		"occurred_at":    {column: "occurred_at", kind: fieldTime, sortable: true, filterable: true},
+		"tenant_id":      {column: "tenant_id::text", kind: fieldString, filterable: true},
+		"actor_user_id":  {column: "actor_user_id::text", kind: fieldString, filterable: true},
+		"actor_token_id": {column: "actor_token_id::text", kind: fieldString, filterable: true},
+		"actor_email":    {column: "actor_email", kind: fieldString, filterable: true},
+		"method":         {column: "method", kind: fieldString, filterable: true},
+		"route":          {column: "route", kind: fieldString, filterable: true},
+		"path":           {column: "path", kind: fieldString, filterable: true},
+		"status":         {column: "status", kind: fieldInt, filterable: true},
+		"resource_type":  {column: "resource_type", kind: fieldString, filterable: true},
+		"resource_id":    {column: "resource_id", kind: fieldString, filterable: true},
+	},
+	idColumn:    "seq",
+	idKind:      fieldInt,
+	defaultSort: "seq",
+	defaultDesc: true,
+}
+
+// List returns a page of audit events matching the list query, the most
+// recent first by default.
+func (m AuditModel) List(q *event.ListQuery) ([]*AuditEvent, string, error) {
+	lq, err := auditListSpec.build(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := lq.sql(`SELECT ` + auditColumns + ` FROM audit_events`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, lq.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = rows.Close() }()
+
+	events := []*AuditEvent{}
+
+	for rows.Next() {
+		event, err := scanAuditEvent(rows.Scan)
+		if err != nil {
+			return nil, "", err
+		}
+
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(events) <= lq.limit {
+		return events, "", nil
+	}
+
+	events = events[:lq.limit]
+	last := events[len(events)-1]
+
+	var value any
+
+	switch lq.sort {
+	case "occurred_at":
+		value = last.OccurredAt
+	default:
+		value = last.Seq
+	}
+
+	return events, lq.nextCursor(value, strconv.FormatInt(last.Seq, 10)), nil
+}
+
+// Verify walks the audit log and checks the chains of hashes of the events
+// the models see, up to their heads: the chain of their tenant on scoped
+// models, all the chains otherwise. The chain recorded before the chains
+// were split per tenant spans all the tenants, it is only checked by the
+// models not scoped.
+func (m AuditModel) Verify() (*AuditVerification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// The events and the heads are read from the same snapshot, so that the
+	// events inserted meanwhile are not missing.
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `
+		SELECT ` + auditColumns + `
+		FROM audit_events
+		WHERE chain_seq IS NOT NULL OR scopehouse_system()
+		ORDER BY seq`
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	verifier := NewAuditVerifier()
+
+	for rows.Next() {
+		event, err := scanAuditEvent(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		if !verifier.Next(event) {
+			return verifier.Result(), nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	heads, err := queryAuditHeads(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier.Heads(heads)
+
+	return verifier.Result(), nil
+}
+
+// queryAuditHeads returns the heads of the chains the transaction sees.
+func queryAuditHeads(ctx context.Context, tx Tx) ([]*AuditHead, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT chain, position, hash FROM audit_heads`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	heads := []*AuditHead{}
+
+	for rows.Next() {
+		var head AuditHead
+
+		if err := rows.Scan(&head.Chain, &head.Position, &head.Hash); err != nil {
+			return nil, err
+		}
+
+		heads = append(heads, &head)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return heads, nil
+}
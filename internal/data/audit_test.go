@@ -0,0 +1,216 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func newAuditChain(n int) []*AuditEvent {
+	events := []*AuditEvent{}
+
+	var prev *AuditEvent
+	for i := range n {
+		e := &AuditEvent{
+			OccurredAt: time.Now(),
+			Method:     "POST",
+			Route:      "/api/v1/clusters",
+			Path:       "/api/v1/clusters",
+			Status:     201,
+		}
+		e.SetChange("cluster", "id", nil, map[string]int{"n": i})
+		e.Seq = int64(i + 1)
+		e.Chain(prev.Head())
+
+		events = append(events, e)
+		prev = e
+	}
+
+	return events
+}
+
+// verifyAuditChain checks the events up to the heads of their chains, the
+// last events before they were tampered with.
+func verifyAuditChain(events []*AuditEvent, heads []*AuditHead) *AuditVerification {
+	v := NewAuditVerifier()
+	for _, e := range events {
+		if !v.Next(e) {
+			return v.Result()
+		}
+	}
+	v.Heads(heads)
+	return v.Result()
+}
+
+func TestAuditChain(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		tamper   func(events []*AuditEvent) []*AuditEvent
+		valid    bool
+		brokenAt int64
+	}{
+		{
+			name:   "untouched",
+			tamper: func(events []*AuditEvent) []*AuditEvent { return events },
+			valid:  true,
+		},
+		{
+			name: "altered content",
+			tamper: func(events []*AuditEvent) []*AuditEvent {
+				events[1].ActorEmail = "someone-else@example.com"
+				return events
+			},
+			brokenAt: 2,
+		},
+		{
+			name: "altered state",
+			tamper: func(events []*AuditEvent) []*AuditEvent {
+				events[2].After = []byte(`{"n":100}`)
+				return events
+			},
+			brokenAt: 3,
+		},
+		{
+			name: "deleted event",
+			tamper: func(events []*AuditEvent) []*AuditEvent {
+				return append(events[:1], events[2:]...)
+			},
+			brokenAt: 3,
+		},
+		{
+			name: "deleted and renumbered event",
+			tamper: func(events []*AuditEvent) []*AuditEvent {
+				events = append(events[:1], events[2:]...)
+				for i, e := range events {
+					e.Seq = int64(i + 1)
+					e.ChainSeq = int64(i + 1)
+				}
+				return events
+			},
+			brokenAt: 2,
+		},
+		{
+			name: "deleted first event",
+			tamper: func(events []*AuditEvent) []*AuditEvent {
+				return events[1:]
+			},
+			brokenAt: 2,
+		},
+		{
+			name: "deleted last events",
+			tamper: func(events []*AuditEvent) []*AuditEvent {
+				return events[:2]
+			},
+			brokenAt: 2,
+		},
+		{
+			name: "deleted all events",
+			tamper: func(events []*AuditEvent) []*AuditEvent {
+				return nil
+			},
+		},
+		{
+			name: "replaced last event",
+			tamper: func(events []*AuditEvent) []*AuditEvent {
+				events[3].ActorEmail = "someone-else@example.com"
+				events[3].Hash = events[3].computeHash()
+				return events
+			},
+			brokenAt: 4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			events := newAuditChain(4)
+			heads := []*AuditHead{events[3].Head()}
+
+			result := verifyAuditChain(tc.tamper(events), heads)
+
+			if result.Valid != tc.valid {
+				t.Fatalf("expected valid to be %v, got %v (%s)", tc.valid, result.Valid, result.Reason)
+			}
+
+			if result.BrokenAt != tc.brokenAt {
+				t.Fatalf("expected chain to break at %d, got %d", tc.brokenAt, result.BrokenAt)
+			}
+		})
+	}
+}
+
+func TestAuditChainsPerTenant(t *testing.T) {
+	t.Parallel()
+
+	// The events chained before the chains were split per tenant, without
+	// a chain sequence number, form a single chain across the tenants.
+	legacy := []*AuditEvent{
+		{Seq: 1, TenantId: "a", PrevHash: auditGenesisHash},
+		{Seq: 2, TenantId: "b"},
+	}
+	legacy[0].Hash = legacy[0].computeHash()
+	legacy[1].PrevHash = legacy[0].Hash
+	legacy[1].Hash = legacy[1].computeHash()
+
+	now := time.Now()
+
+	newEvents := func() []*AuditEvent {
+		events := append([]*AuditEvent{}, legacy...)
+		prev := map[string]*AuditEvent{}
+
+		for i, tenantId := range []string{"a", "b", "a", "", "b", "a"} {
+			e := &AuditEvent{Seq: int64(i + 3), TenantId: tenantId, OccurredAt: now}
+			e.Chain(prev[tenantId].Head())
+
+			events = append(events, e)
+			prev[tenantId] = e
+		}
+
+		return events
+	}
+
+	heads := []*AuditHead{legacy[1].Head()}
+	for _, i := range []int{5, 6, 7} {
+		heads = append(heads, newEvents()[i].Head())
+	}
+
+	if result := verifyAuditChain(newEvents(), heads); !result.Valid || result.Events != 8 {
+		t.Fatalf("expected the 8 events to be valid, got %+v", result)
+	}
+
+	// Deleting the second event of tenant a breaks its chain at the third,
+	// with the sequence number 8.
+	events := newEvents()
+	events = append(events[:4], events[5:]...)
+
+	if result := verifyAuditChain(events, heads); result.Valid || result.BrokenAt != 8 {
+		t.Fatalf("expected chain to break at 8, got %+v", result)
+	}
+
+	// Deleting the last event of tenant a leaves its chain valid up to its
+	// head.
+	events = newEvents()[:7]
+
+	if result := verifyAuditChain(events, heads); result.Valid || result.BrokenAt != 5 {
+		t.Fatalf("expected chain to break at 5, got %+v", result)
+	}
+}
+
+func TestAuditEventSetChange(t *testing.T) {
+	t.Parallel()
+
+	// Handlers record changes unconditionally, even on safe requests.
+	var nilEvent *AuditEvent
+	nilEvent.SetChange("cluster", "id", nil, nil)
+
+	e := &AuditEvent{}
+	e.SetChange("cluster", "id", nil, &Cluster{Name: "prod"})
+
+	if e.Before != nil {
+		t.Fatalf("expected before state to be empty, got %s", e.Before)
+	}
+
+	if len(e.After) == 0 {
+		t.Fatal("expected after state to be recorded")
+	}
+}
@@ -0,0 +1,342 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+const (
+	ChangeRequestPending  = "pending"
+	ChangeRequestApproved = "approved"
+	ChangeRequestRejected = "rejected"
+
+	// ChangeRequestExpired is the status of pending requests past their
+	// expiry, it is never stored.
+	ChangeRequestExpired = "expired"
+)
+
+const (
+	ChangeActionUpdate = "update"
+	ChangeActionDelete = "delete"
+)
+
+// ErrChangeRequestClosed is returned when reviewing a change request that is
+// no longer in the expected status, e.g. it was reviewed meanwhile or has
+// expired.
+var ErrChangeRequestClosed = errors.New("change request is closed")
+
+type ChangeRequestStore interface {
+	Insert(cr *ChangeRequest) error
+	GetById(id string) (*ChangeRequest, error)
+	List(q *event.ListQuery) ([]*ChangeRequest, string, error)
+	UpdateStatus(cr *ChangeRequest, from string) error
+	InsertComment(comment *ChangeRequestComment) error
+	ListComments(changeRequestId string) ([]*ChangeRequestComment, error)
+}
+
+// ChangeRequest is a change to a resource of a production cluster awaiting
+// the approval of another user. Before and After are the states of the
+// resource when the change was requested and once applied, After being
+// empty for deletions.
+type ChangeRequest struct {
+	Id           string          `json:"id"`
+	TenantId     string          `json:"tenant_id"`
+	ClusterId    string          `json:"cluster_id"`
+	ResourceType string          `json:"resource_type"`
+	ResourceId   string          `json:"resource_id"`
+	Action       string          `json:"action"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	Status       string          `json:"status"`
+	RequestedBy  string          `json:"requested_by"`
+	ReviewedBy   string          `json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time      `json:"reviewed_at,omitempty"`
+	ExpiresAt    time.Time       `json:"expires_at"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// ChangeRequestComment is a comment left on a change request, e.g. by a
+// reviewer asking for details.
+type ChangeRequestComment struct {
+	Id              string    `json:"id"`
+	ChangeRequestId string    `json:"change_request_id"`
+	UserId          string    `json:"user_id"`
+	Body            string    `json:"body"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type ChangeRequestModel struct {
+	DB Querier
+}
+
+// changeRequestStatus is the status of a change request, pending requests
+// past their expiry being expired.
+const changeRequestStatus = `(CASE WHEN status = 'pending' AND expires_at <= now() THEN 'expired' ELSE status END)`
+
+const changeRequestColumns = `
+	id, tenant_id, COALESCE(cluster_id::text, ''), resource_type, resource_id, action, before, after,
+	` + changeRequestStatus + `, requested_by, COALESCE(reviewed_by::text, ''), reviewed_at,
+	expires_at, created_at`
+
+func scanChangeRequest(scan func(dest ...any) error) (*ChangeRequest, error) {
+	var cr ChangeRequest
+	var before, after []byte
+
+	err := scan(
+		&cr.Id,
+		&cr.TenantId,
+		&cr.ClusterId,
+		&cr.ResourceType,
+		&cr.ResourceId,
+		&cr.Action,
+		&before,
+		&after,
+		&cr.Status,
+		&cr.RequestedBy,
+		&cr.ReviewedBy,
+		&cr.ReviewedAt,
+		&cr.ExpiresAt,
+		&cr.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(before) > 0 {
+		cr.Before = before
+	}
+
+	if len(after) > 0 {
+		cr.After = after
+	}
+
+	return &cr, nil
+}
+
+func (m ChangeRequestModel) Insert(cr *ChangeRequest) error {
+	query := `
+		INSERT INTO change_requests (
+			cluster_id, resource_type, resource_id, action, before, after, requested_by, expires_at
+		)
+		VALUES (NULLIF($1, '')::uuid, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, tenant_id, status, created_at`
+
+	args := []any{
+		cr.ClusterId,
+		cr.ResourceType,
+		cr.ResourceId,
+		cr.Action,
+		nullJson(cr.Before),
+		nullJson(cr.After),
+		cr.RequestedBy,
+		cr.ExpiresAt,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&cr.Id,
+		&cr.TenantId,
+		&cr.Status,
+		&cr.CreatedAt,
+	)
+}
+
+func (m ChangeRequestModel) GetById(id string) (*ChangeRequest, error) {
+	if !isUUID(id) {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT ` + changeRequestColumns + ` FROM change_requests WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	cr, err := scanChangeRequest(m.DB.QueryRowContext(ctx, query, id).Scan)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return cr, nil
+}
+
+var changeRequestListSpec = listSpec{
+	fields: map[string]listField{
+		"cluster_id":    {column: "cluster_id::text", kind: fieldString, filterable: true},
+		"resource_type": {column: "resource_type", kind: fieldString, filterable: true},
+		"resource_id":   {column: "resource_id", kind: fieldString, filterable: true},
+		"action":        {column: "action", kind: fieldString, filterable: true},
+		"status":        {column: changeRequestStatus, kind: fieldString, filterable: true},
+		"requested_by":  {column: "requested_by::text", kind: fieldString, filterable: true},
+		"reviewed_by":   {column: "reviewed_by::text", kind: fieldString, filterable: true},
+		"expires_at":    {column: "expires_at", kind: fieldTime, sortable: true, filterable: true},
+		"created_at":    {column: "created_at", kind: fieldTime, sortable: true, filterable: true},
+	},
+	idColumn:    "id",
+	idKind:      fieldUUID,
+	defaultSort: "created_at",
+	defaultDesc: true,
+}
+
+// List returns a page of change requests matching the list query, the most
+// recent first by default.
+func (m ChangeRequestModel) List(q *event.ListQuery) ([]*ChangeRequest, string, error) {
+	lq, err := changeRequestListSpec.build(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := lq.sql(`SELECT ` + changeRequestColumns + ` FROM change_requests`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, lq.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = rows.Close() }()
+
+	crs := []*ChangeRequest{}
+
+	for rows.Next() {
+		cr, err := scanChangeRequest(rows.Scan)
+		if err != nil {
+			return nil, "", err
+		}
+
+		crs = append(crs, cr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(crs) <= lq.limit {
+		return crs, "", nil
+	}
+
+	crs = crs[:lq.limit]
+	last := crs[len(crs)-1]
+
+	var value any
+
+	switch lq.sort {
+	case "expires_at":
+		value = last.ExpiresAt
+	default:
+		value = last.CreatedAt
+	}
+
+	return crs, lq.nextCursor(value, last.Id), nil
+}
+
+// UpdateStatus moves the change request from the given status to its own,
+// recording its reviewer. It returns ErrChangeRequestClosed when the request
+// is no longer in the given status, pending requests past their expiry not
+// being pending anymore.
+func (m ChangeRequestModel) UpdateStatus(cr *ChangeRequest, from string) error {
+	if !isUUID(cr.Id) {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE change_requests
+		SET status = $1,
+			reviewed_by = NULLIF($2, '')::uuid,
+			reviewed_at = CASE WHEN $2 = '' THEN NULL ELSE now() END
+		WHERE id = $3 AND status = $4 AND (status <> 'pending' OR expires_at > now())
+		RETURNING reviewed_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, cr.Status, cr.ReviewedBy, cr.Id, from).Scan(&cr.ReviewedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrChangeRequestClosed
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ChangeRequestModel) InsertComment(comment *ChangeRequestComment) error {
+	if !isUUID(comment.ChangeRequestId) {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		INSERT INTO change_request_comments (change_request_id, user_id, body)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	args := []any{comment.ChangeRequestId, comment.UserId, comment.Body}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&comment.Id, &comment.CreatedAt)
+}
+
+// ListComments returns all the comments of the change request, the oldest
+// first.
+func (m ChangeRequestModel) ListComments(changeRequestId string) ([]*ChangeRequestComment, error) {
+	comments := []*ChangeRequestComment{}
+
+	if !isUUID(changeRequestId) {
+		return comments, nil
+	}
+
+	query := `
+		SELECT id, change_request_id, user_id, body, created_at
+		FROM change_request_comments
+		WHERE change_request_id = $1
+		ORDER BY created_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, changeRequestId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var comment ChangeRequestComment
+
+		err := rows.Scan(
+			&comment.Id,
+			&comment.ChangeRequestId,
+			&comment.UserId,
+			&comment.Body,
+			&comment.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, &comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
@@ -0,0 +1,300 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+var ErrDuplicateClusterName = errors.New("duplicate cluster name")
+
+type ClusterStore interface {
+	Insert(cluster *Cluster) error
+	GetById(id string) (*Cluster, error)
+	List(q *event.ListQuery) ([]*Cluster, string, error)
+	Update(cluster *Cluster) error
+	Delete(id string) error
+}
+
+// Cluster is a Prometheus cluster owned by a team. Labels describe the
+// cluster, e.g. `env=production`. The Alertmanager of the cluster is only
+// managed when its url is set, and the alerts of the cluster are only polled
+// from the services whose url is set.
+type Cluster struct {
+	Id              string            `json:"id"`
+	TenantId        string            `json:"tenant_id"`
+	Name            string            `json:"name"`
+	TeamId          string            `json:"team_id"`
+	Labels          map[string]string `json:"labels"`
+	AlertmanagerUrl string            `json:"alertmanager_url"`
+	PrometheusUrl   string            `json:"prometheus_url"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// IsProduction reports whether the cluster is labeled `env=production`.
+// Changes to production clusters must be approved by a second user.
+func (c *Cluster) IsProduction() bool {
+	return c.Labels["env"] == "production"
+}
+
+// Resource returns the cluster as an access control resource.
+func (c *Cluster) Resource() Resource {
+	return Resource{TeamId: c.TeamId, ClusterId: c.Id}
+}
+
+type ClusterModel struct {
+	DB Querier
+}
+
+func (m ClusterModel) Insert(cluster *Cluster) error {
+	query := `
+		INSERT INTO clusters (name, team_id, labels, alertmanager_url, prometheus_url)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, tenant_id, created_at, updated_at`
+
+	if cluster.Labels == nil {
+		cluster.Labels = map[string]string{}
+	}
+
+	labels, err := json.Marshal(cluster.Labels)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, cluster.Name, cluster.TeamId, labels, cluster.AlertmanagerUrl, cluster.PrometheusUrl).Scan(
+		&cluster.Id,
+		&cluster.TenantId,
+		&cluster.CreatedAt,
+		&cluster.UpdatedAt,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Constraint == "clusters_tenant_id_name_key":
+			return ErrDuplicateClusterName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ClusterModel) GetById(id string) (*Cluster, error) {
+	if !isUUID(id) {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, tenant_id, name, team_id, labels, alertmanager_url, prometheus_url, created_at, updated_at
+		FROM clusters
+		WHERE id = $1`
+
+	var cluster Cluster
+	var labels []byte
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&cluster.Id,
+		&cluster.TenantId,
+		&cluster.Name,
+		&cluster.TeamId,
+		&labels,
+		&cluster.AlertmanagerUrl,
+		&cluster.PrometheusUrl,
+		&cluster.CreatedAt,
+		&cluster.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := json.Unmarshal(labels, &cluster.Labels); err != nil {
+		return nil, err
+	}
+
+	return &cluster, nil
+}
+
+var clusterListSpec = listSpec{
+	fields: map[string]listField{
+		"name":       {column: "name", kind: fieldString, sortable: true, filterable: true},
+		"team_id":    {column: "team_id::text", kind: fieldString, filterable: true},
+		"created_at": {column: "created_at", kind: fieldTime, sortable: true, filterable: true},
+		"updated_at": {column: "updated_at", kind: fieldTime, sortable: true, filterable: true},
+	},
+	idColumn:    "id",
+	idKind:      fieldUUID,
+	defaultSort: "name",
+}
+
+// List returns a page of clusters matching the list query, along with the
+// cursor of the next page or an empty string when it is the last one.
+func (m ClusterModel) List(q *event.ListQuery) ([]*Cluster, string, error) {
+	lq, err := clusterListSpec.build(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := lq.sql(`
+		SELECT id, tenant_id, name, team_id, labels, alertmanager_url, prometheus_url, created_at, updated_at
+		FROM clusters`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, lq.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = rows.Close() }()
+
+	clusters := []*Cluster{}
+
+	for rows.Next() {
+		var cluster Cluster
+		var labels []byte
+
+		err := rows.Scan(
+			&cluster.Id,
+			&cluster.TenantId,
+			&cluster.Name,
+			&cluster.TeamId,
+			&labels,
+			&cluster.AlertmanagerUrl,
+			&cluster.PrometheusUrl,
+			&cluster.CreatedAt,
+			&cluster.UpdatedAt,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if err := json.Unmarshal(labels, &cluster.Labels); err != nil {
+			return nil, "", err
+		}
+
+		clusters = append(clusters, &cluster)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(clusters) <= lq.limit {
+		return clusters, "", nil
+	}
+
+	clusters = clusters[:lq.limit]
+	last := clusters[len(clusters)-1]
+
+	var value any
+
+	switch lq.sort {
+	case "created_at":
+		value = last.CreatedAt
+	case "updated_at":
+		value = last.UpdatedAt
+	default:
+		value = last.Name
+	}
+
+	return clusters, lq.nextCursor(value, last.Id), nil
+}
+
+// Update saves the cluster name, labels and service urls.
+func (m ClusterModel) Update(cluster *Cluster) error {
+	query := `
+		UPDATE clusters
+		SET name = $1, labels = $2, alertmanager_url = $3, prometheus_url = $4, updated_at = now()
+		WHERE id = $5
+		RETURNING updated_at`
+
+	if cluster.Labels == nil {
+		cluster.Labels = map[string]string{}
+	}
+
+	labels, err := json.Marshal(cluster.Labels)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, cluster.Name, labels, cluster.AlertmanagerUrl, cluster.PrometheusUrl, cluster.Id).Scan(&cluster.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case errors.As(err, &pqErr) && pqErr.Constraint == "clusters_tenant_id_name_key":
+			return ErrDuplicateClusterName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the cluster along with the role bindings on it.
+func (m ClusterModel) Delete(id string) error {
+	if !isUUID(id) {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `
+		DELETE FROM clusters
+		WHERE id = $1`
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	query = `
+		DELETE FROM role_bindings
+		WHERE scope_type = 'cluster' AND scope_id = $1`
+
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var (
+	migrationTablePattern = regexp.MustCompile(`(?:CREATE TABLE IF NOT EXISTS|ALTER TABLE) (\w+)`)
+	migrationCheckPattern = regexp.MustCompile(`CHECK \((\w+) IN \(([^)]*)\)\)`)
+)
+
+// migrationChecks returns the values allowed by the CHECK constraints of
+// the schema once all the migrations are applied, by `table.column`.
+func migrationChecks(t *testing.T) map[string][]string {
+	t.Helper()
+
+	files := os.DirFS("../../migrations")
+
+	names, err := fs.Glob(files, "*.up.sql")
+	if err != nil {
+		t.Fatalf("failed to list migrations; %v", err)
+	}
+
+	// The names are sorted by version, the later checks replace the
+	// earlier ones.
+	slices.Sort(names)
+
+	checks := map[string][]string{}
+
+	for _, name := range names {
+		b, err := fs.ReadFile(files, name)
+		if err != nil {
+			t.Fatalf("failed to read migration; %v", err)
+		}
+
+		for _, statement := range strings.Split(string(b), ";") {
+			table := migrationTablePattern.FindStringSubmatch(statement)
+			if table == nil {
+				continue
+			}
+
+			for _, check := range migrationCheckPattern.FindAllStringSubmatch(statement, -1) {
+				var values []string
+				for _, value := range strings.Split(check[2], ",") {
+					values = append(values, strings.Trim(strings.TrimSpace(value), "'"))
+				}
+				checks[table[1]+"."+check[1]] = values
+			}
+		}
+	}
+
+	return checks
+}
+
+func TestStatusConstantsMatchMigrations(t *testing.T) {
+	t.Parallel()
+
+	checks := migrationChecks(t)
+
+	expected := map[string][]string{
+		"silence_targets.status": {
+			SilenceTargetPending,
+			SilenceTargetCreated,
+			SilenceTargetFailed,
+			SilenceTargetExpired,
+		},
+		"change_requests.status": {
+			ChangeRequestPending,
+			ChangeRequestApproved,
+			ChangeRequestRejected,
+		},
+		"change_requests.action": {
+			ChangeActionUpdate,
+			ChangeActionDelete,
+		},
+		"role_bindings.scope_type": {
+			ScopeTypeGlobal,
+			ScopeTypeTeam,
+			ScopeTypeCluster,
+		},
+	}
+
+	for column, values := range expected {
+		allowed, ok := checks[column]
+		if !ok {
+			t.Fatalf("expected a check constraint on %s", column)
+		}
+
+		for _, value := range values {
+			if !slices.Contains(allowed, value) {
+				t.Errorf("expected %s to allow %q, got %v", column, value, allowed)
+			}
+		}
+	}
+}
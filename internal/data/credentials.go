@@ -0,0 +1,230 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type ClusterCredentialStore interface {
+	GetByClusterId(clusterId string) (*ClusterCredential, error)
+	ListNotWrappedWith(keyId string) ([]*ClusterCredential, error)
+	Upsert(credential *ClusterCredential) error
+	UpdateKey(credential *ClusterCredential) error
+	Delete(clusterId string) error
+}
+
+// ClusterCredential holds the encrypted credentials the services of a
+// cluster are called with. The credentials are encrypted and decrypted by
+// the app, the database only sees their ciphertext and their data key,
+// wrapped with the master key of KeyId.
+type ClusterCredential struct {
+	ClusterId  string    `json:"cluster_id"`
+	TenantId   string    `json:"tenant_id"`
+	KeyId      string    `json:"key_id"`
+	WrappedKey []byte    `json:"-"`
+	Ciphertext []byte    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+const clusterCredentialColumns = `cluster_id, tenant_id, key_id, wrapped_key, ciphertext, created_at, updated_at`
+
+func scanClusterCredential(scan func(dest ...any) error) (*ClusterCredential, error) {
+	var credential ClusterCredential
+
+	err := scan(
+		&credential.ClusterId,
+		&credential.TenantId,
+		&credential.KeyId,
+		&credential.WrappedKey,
+		&credential.Ciphertext,
+		&credential.CreatedAt,
+		&credential.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+type ClusterCredentialModel struct {
+	DB Querier
+}
+
+func (m ClusterCredentialModel) GetByClusterId(clusterId string) (*ClusterCredential, error) {
+	if !isUUID(clusterId) {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + clusterCredentialColumns + `
+		FROM cluster_credentials
+		WHERE cluster_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	credential, err := scanClusterCredential(m.DB.QueryRowContext(ctx, query, clusterId).Scan)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return credential, nil
+}
+
+// ListNotWrappedWith returns the credentials whose data key is not wrapped
+// with the master key of keyId, to rotate them. Unscoped models return the
+// credentials of all the tenants.
+func (m ClusterCredentialModel) ListNotWrappedWith(keyId string) ([]*ClusterCredential, error) {
+	query := `
+		SELECT ` + clusterCredentialColumns + `
+		FROM cluster_credentials
+		WHERE key_id <> $1
+		ORDER BY cluster_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, keyId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	credentials := []*ClusterCredential{}
+
+	for rows.Next() {
+		credential, err := scanClusterCredential(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		credentials = append(credentials, credential)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// Upsert saves the credentials of the cluster, stored in the tenant of the
+// cluster.
+func (m ClusterCredentialModel) Upsert(credential *ClusterCredential) error {
+	query := `
+		INSERT INTO cluster_credentials (cluster_id, tenant_id, key_id, wrapped_key, ciphertext)
+		SELECT id, tenant_id, $2, $3, $4
+		FROM clusters
+		WHERE id = $1
+		ON CONFLICT (cluster_id) DO UPDATE
+		SET key_id = EXCLUDED.key_id, wrapped_key = EXCLUDED.wrapped_key,
+			ciphertext = EXCLUDED.ciphertext, updated_at = now()
+		RETURNING tenant_id, created_at, updated_at`
+
+	if !isUUID(credential.ClusterId) {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	args := []any{
+		credential.ClusterId,
+		credential.KeyId,
+		credential.WrappedKey,
+		credential.Ciphertext,
+	}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&credential.TenantId,
+		&credential.CreatedAt,
+		&credential.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateKey saves the rewrapped data key of the credentials, leaving their
+// updated_at as is since they did not change. It returns ErrRecordNotFound
+// when they were updated or deleted since they were read.
+func (m ClusterCredentialModel) UpdateKey(credential *ClusterCredential) error {
+	if !isUUID(credential.ClusterId) {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE cluster_credentials
+		SET key_id = $1, wrapped_key = $2, ciphertext = $3
+		WHERE cluster_id = $4 AND updated_at = $5`
+
+	args := []any{
+		credential.KeyId,
+		credential.WrappedKey,
+		credential.Ciphertext,
+		credential.ClusterId,
+		credential.UpdatedAt,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m ClusterCredentialModel) Delete(clusterId string) error {
+	if !isUUID(clusterId) {
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM cluster_credentials WHERE cluster_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, clusterId)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
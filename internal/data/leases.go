@@ -0,0 +1,60 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type LeaseStore interface {
+	Acquire(name string, holder string, ttl time.Duration) (bool, error)
+	Release(name string, holder string) error
+}
+
+type LeaseModel struct {
+	DB Querier
+}
+
+// Acquire takes the lease for the holder until the ttl elapses, and reports
+// whether it got it. Holders renew their lease by acquiring it again before
+// it expires, other holders only get it once it has.
+func (m LeaseModel) Acquire(name string, holder string, ttl time.Duration) (bool, error) {
+	query := `
+		INSERT INTO leases (name, holder, expires_at)
+		VALUES ($1, $2, now() + $3 * interval '1 millisecond')
+		ON CONFLICT (name) DO UPDATE
+		SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE leases.holder = EXCLUDED.holder OR leases.expires_at <= now()
+		RETURNING holder`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	var got string
+
+	err := m.DB.QueryRowContext(ctx, query, name, holder, ttl.Milliseconds()).Scan(&got)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return got == holder, nil
+}
+
+// Release gives up the lease, when held by the holder, so that another one
+// can take it without waiting for it to expire.
+func (m LeaseModel) Release(name string, holder string) error {
+	query := `DELETE FROM leases WHERE name = $1 AND holder = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, name, holder)
+
+	return err
+}
@@ -0,0 +1,276 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// ListQueryError reports an invalid sort field, filter or cursor in a list
+// query. Its message is safe to be returned to clients.
+type ListQueryError struct {
+	Message string
+}
+
+func (e *ListQueryError) Error() string {
+	return e.Message
+}
+
+func listQueryError(format string, args ...any) *ListQueryError {
+	return &ListQueryError{Message: fmt.Sprintf(format, args...)}
+}
+
+type fieldType int
+
+const (
+	fieldString fieldType = iota
+	fieldBool
+	fieldInt
+	fieldTime
+	fieldUUID
+)
+
+type listField struct {
+	column     string
+	kind       fieldType
+	sortable   bool
+	filterable bool
+}
+
+// listSpec describes the fields of a table that can be used to sort and
+// filter list queries. Sortable columns must not be nullable.
+type listSpec struct {
+	fields      map[string]listField
+	idColumn    string
+	idKind      fieldType
+	defaultSort string
+	defaultDesc bool
+}
+
+var listOperators = map[string]string{
+	"eq":   "=",
+	"ne":   "<>",
+	"gt":   ">",
+	"gte":  ">=",
+	"lt":   "<",
+	"lte":  "<=",
+	"like": "ILIKE",
+}
+
+// listQuery is a list query translated to SQL clauses. All values are
+// passed as query arguments, only column names taken from the spec are
+// ever written in the SQL text.
+type listQuery struct {
+	spec  listSpec
+	sort  string
+	desc  bool
+	limit int
+	where []string
+	args  []any
+}
+
+type listCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	Id    string `json:"id"`
+}
+
+func (s listSpec) build(q *event.ListQuery) (*listQuery, error) {
+	lq := &listQuery{
+		spec:  s,
+		sort:  s.defaultSort,
+		desc:  s.defaultDesc,
+		limit: event.DefaultListLimit,
+		where: []string{},
+		args:  []any{},
+	}
+
+	if q == nil {
+		return lq, nil
+	}
+
+	if q.Limit > 0 {
+		lq.limit = q.Limit
+	}
+
+	if q.Sort != "" {
+		field, ok := s.fields[q.Sort]
+		if !ok || !field.sortable {
+			return nil, listQueryError("Invalid sort field %q.", q.Sort)
+		}
+		lq.sort = q.Sort
+		lq.desc = q.Desc
+	}
+
+	for _, f := range q.Filters {
+		field, ok := s.fields[f.Field]
+		if !ok || !field.filterable {
+			return nil, listQueryError("Invalid filter field %q.", f.Field)
+		}
+
+		op, ok := listOperators[f.Operator]
+		if !ok {
+			return nil, listQueryError("Invalid filter operator %q.", f.Operator)
+		}
+
+		if op == "ILIKE" && field.kind != fieldString {
+			return nil, listQueryError("Filter operator like is not supported by field %q.", f.Field)
+		}
+
+		value, err := parseFieldValue(field.kind, f.Value)
+		if err != nil {
+			return nil, listQueryError("Invalid value %q for filter field %q.", f.Value, f.Field)
+		}
+
+		if op == "ILIKE" {
+			value = "%" + escapeLike(f.Value) + "%"
+		}
+
+		lq.where = append(lq.where, fmt.Sprintf("%s %s %s", field.column, op, lq.arg(value)))
+	}
+
+	if q.Cursor != "" {
+		if err := lq.applyCursor(q.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	return lq, nil
+}
+
+// arg adds a query argument and returns its placeholder.
+func (lq *listQuery) arg(value any) string {
+	lq.args = append(lq.args, value)
+	return fmt.Sprintf("$%d", len(lq.args))
+}
+
+func (lq *listQuery) applyCursor(raw string) error {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return listQueryError("Invalid cursor.")
+	}
+
+	var c listCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return listQueryError("Invalid cursor.")
+	}
+
+	if c.Sort != lq.sort || c.Desc != lq.desc {
+		return listQueryError("Cursor does not match the requested sort order.")
+	}
+
+	value, err := parseFieldValue(lq.spec.fields[lq.sort].kind, c.Value)
+	if err != nil {
+		return listQueryError("Invalid cursor.")
+	}
+
+	id, err := parseFieldValue(lq.spec.idKind, c.Id)
+	if err != nil {
+		return listQueryError("Invalid cursor.")
+	}
+
+	op := ">"
+	if lq.desc {
+		op = "<"
+	}
+
+	lq.where = append(lq.where, fmt.Sprintf(
+		"(%s, %s) %s (%s, %s)",
+		lq.spec.fields[lq.sort].column, lq.spec.idColumn, op, lq.arg(value), lq.arg(id),
+	))
+
+	return nil
+}
+
+// whereClause returns the WHERE clause of the query, or an empty string
+// when there are no conditions.
+func (lq *listQuery) whereClause() string {
+	if len(lq.where) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(lq.where, " AND ")
+}
+
+// orderClause returns the ORDER BY clause of the query. The id column is
+// used as a tie-breaker so that rows with equal sort values keep a stable
+// order across pages.
+func (lq *listQuery) orderClause() string {
+	dir := "ASC"
+	if lq.desc {
+		dir = "DESC"
+	}
+
+	return fmt.Sprintf(
+		"ORDER BY %s %s, %s %s",
+		lq.spec.fields[lq.sort].column, dir, lq.spec.idColumn, dir,
+	)
+}
+
+// limitClause returns the LIMIT clause of the query. One extra row is
+// fetched to find out whether there is a next page.
+func (lq *listQuery) limitClause() string {
+	return "LIMIT " + lq.arg(lq.limit+1)
+}
+
+// sql assembles the full query for the given SELECT and FROM statement.
+func (lq *listQuery) sql(selectFrom string) string {
+	return strings.Join([]string{
+		selectFrom,
+		lq.whereClause(),
+		lq.orderClause(),
+		lq.limitClause(),
+	}, "\n")
+}
+
+// nextCursor returns the cursor pointing after the row with the given sort
+// value and id.
+func (lq *listQuery) nextCursor(value any, id string) string {
+	c := listCursor{
+		Sort:  lq.sort,
+		Desc:  lq.desc,
+		Value: formatFieldValue(value),
+		Id:    id,
+	}
+
+	// Marshaling a struct of strings and bools never fails.
+	b, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseFieldValue(kind fieldType, value string) (any, error) {
+	switch kind {
+	case fieldBool:
+		return strconv.ParseBool(value)
+	case fieldInt:
+		return strconv.ParseInt(value, 10, 64)
+	case fieldTime:
+		return time.Parse(time.RFC3339Nano, value)
+	case fieldUUID:
+		if !isUUID(value) {
+			return nil, fmt.Errorf("invalid uuid %q", value)
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+func formatFieldValue(value any) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,194 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+var testListSpec = listSpec{
+	fields: map[string]listField{
+		"name":       {column: "name", kind: fieldString, sortable: true, filterable: true},
+		"active":     {column: "is_active", kind: fieldBool, filterable: true},
+		"count":      {column: "count", kind: fieldInt, sortable: true, filterable: true},
+		"created_at": {column: "created_at", kind: fieldTime, sortable: true, filterable: true},
+	},
+	idColumn:    "id",
+	idKind:      fieldUUID,
+	defaultSort: "created_at",
+}
+
+func TestListSpecBuild(t *testing.T) {
+	t.Parallel()
+
+	tm := time.Date(2025, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	testCases := []struct {
+		name  string
+		query *event.ListQuery
+		sql   string
+		args  []any
+	}{
+		{
+			name:  "nil query",
+			query: nil,
+			sql:   "SELECT * FROM t\n\nORDER BY created_at ASC, id ASC\nLIMIT $1",
+			args:  []any{event.DefaultListLimit + 1},
+		},
+		{
+			name: "sort and filters",
+			query: &event.ListQuery{
+				Limit: 10,
+				Sort:  "name",
+				Desc:  true,
+				Filters: []event.ListFilter{
+					{Field: "name", Operator: "like", Value: "50%_off"},
+					{Field: "active", Operator: "eq", Value: "true"},
+					{Field: "count", Operator: "gte", Value: "3"},
+					{Field: "created_at", Operator: "lt", Value: "2025-01-02T03:04:05Z"},
+				},
+			},
+			sql: "SELECT * FROM t\n" +
+				"WHERE name ILIKE $1 AND is_active = $2 AND count >= $3 AND created_at < $4\n" +
+				"ORDER BY name DESC, id DESC\n" +
+				"LIMIT $5",
+			args: []any{`%50\%\_off%`, true, int64(3), tm, 11},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lq, err := testListSpec.build(tc.query)
+			if err != nil {
+				t.Fatalf("expected error to be nil, got %v", err)
+			}
+
+			sql := lq.sql("SELECT * FROM t")
+			if sql != tc.sql {
+				t.Fatalf("expected sql to be \n%s \ngot \n%s", tc.sql, sql)
+			}
+
+			if !reflect.DeepEqual(lq.args, tc.args) {
+				t.Fatalf("expected args to be %#v, got %#v", tc.args, lq.args)
+			}
+		})
+	}
+}
+
+func TestListSpecBuildErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		query   *event.ListQuery
+		message string
+	}{
+		{
+			name:    "unknown sort field",
+			query:   &event.ListQuery{Sort: "password"},
+			message: `Invalid sort field "password".`,
+		},
+		{
+			name:    "field not sortable",
+			query:   &event.ListQuery{Sort: "active"},
+			message: `Invalid sort field "active".`,
+		},
+		{
+			name: "unknown filter field",
+			query: &event.ListQuery{Filters: []event.ListFilter{
+				{Field: "name; DROP TABLE t", Operator: "eq", Value: "x"},
+			}},
+			message: `Invalid filter field "name; DROP TABLE t".`,
+		},
+		{
+			name: "like on non string field",
+			query: &event.ListQuery{Filters: []event.ListFilter{
+				{Field: "count", Operator: "like", Value: "1"},
+			}},
+			message: `Filter operator like is not supported by field "count".`,
+		},
+		{
+			name: "invalid filter value",
+			query: &event.ListQuery{Filters: []event.ListFilter{
+				{Field: "created_at", Operator: "eq", Value: "yesterday"},
+			}},
+			message: `Invalid value "yesterday" for filter field "created_at".`,
+		},
+		{
+			name:    "malformed cursor",
+			query:   &event.ListQuery{Cursor: "!!!"},
+			message: "Invalid cursor.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := testListSpec.build(tc.query)
+
+			var lqe *ListQueryError
+			if !errors.As(err, &lqe) {
+				t.Fatalf("expected list query error, got %v", err)
+			}
+
+			if lqe.Message != tc.message {
+				t.Fatalf("expected error message to be %q, got %q", tc.message, lqe.Message)
+			}
+		})
+	}
+}
+
+func TestListQueryCursor(t *testing.T) {
+	t.Parallel()
+
+	lq, err := testListSpec.build(&event.ListQuery{Sort: "count", Desc: true, Limit: 5})
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	id := "9a4c1f6e-3b2d-4c8e-9f1a-2b3c4d5e6f70"
+	cursor := lq.nextCursor(int64(42), id)
+
+	next, err := testListSpec.build(&event.ListQuery{
+		Sort:   "count",
+		Desc:   true,
+		Limit:  5,
+		Cursor: cursor,
+	})
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	sql := "SELECT * FROM t\n" +
+		"WHERE (count, id) < ($1, $2)\n" +
+		"ORDER BY count DESC, id DESC\n" +
+		"LIMIT $3"
+
+	if got := next.sql("SELECT * FROM t"); got != sql {
+		t.Fatalf("expected sql to be \n%s \ngot \n%s", sql, got)
+	}
+
+	args := []any{int64(42), id, 6}
+	if !reflect.DeepEqual(next.args, args) {
+		t.Fatalf("expected args to be %#v, got %#v", args, next.args)
+	}
+
+	// A cursor must not be reused with a different sort order.
+	_, err = testListSpec.build(&event.ListQuery{Sort: "count", Cursor: cursor})
+
+	var lqe *ListQueryError
+	if !errors.As(err, &lqe) {
+		t.Fatalf("expected list query error, got %v", err)
+	}
+
+	// The id of a cursor must match the id column, it is not queried
+	// otherwise.
+	invalid := lq.nextCursor(int64(42), "abc")
+
+	_, err = testListSpec.build(&event.ListQuery{Sort: "count", Desc: true, Cursor: invalid})
+	if !errors.As(err, &lqe) || lqe.Message != "Invalid cursor." {
+		t.Fatalf("expected invalid cursor error, got %v", err)
+	}
+}
@@ -0,0 +1,446 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/schedule"
+)
+
+var ErrDuplicateMaintenanceWindowName = errors.New("duplicate maintenance window name")
+
+type MaintenanceWindowStore interface {
+	Insert(window *MaintenanceWindow) error
+	GetById(id string) (*MaintenanceWindow, error)
+	List(q *event.ListQuery) ([]*MaintenanceWindow, string, error)
+	ListDue(before time.Time) ([]*MaintenanceWindow, error)
+	Update(window *MaintenanceWindow) error
+	Advance(window *MaintenanceWindow, next *time.Time) (bool, error)
+	Delete(id string) error
+}
+
+// MaintenanceWindow silences the clusters matching its selector during each
+// occurrence of its schedule, a cron expression or RRULE evaluated in its
+// time zone. NextRunAt is the start of its next occurrence, nil when its
+// schedule has no more.
+type MaintenanceWindow struct {
+	Id              string            `json:"id"`
+	TenantId        string            `json:"tenant_id"`
+	Name            string            `json:"name"`
+	ScheduleKind    string            `json:"schedule_kind"`
+	Schedule        string            `json:"schedule"`
+	Timezone        string            `json:"timezone"`
+	Duration        string            `json:"duration"`
+	Matchers        []string          `json:"matchers"`
+	ClusterSelector map[string]string `json:"cluster_selector"`
+	Comment         string            `json:"comment"`
+	Enabled         bool              `json:"enabled"`
+	NextRunAt       *time.Time        `json:"next_run_at"`
+	CreatedBy       string            `json:"created_by,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// ParseSchedule parses the schedule of the window, recurrence rules without
+// a start starting the day the window was created.
+func (w *MaintenanceWindow) ParseSchedule() (schedule.Schedule, error) {
+	loc, err := schedule.LoadLocation(w.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	start := w.CreatedAt
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	return schedule.Parse(w.ScheduleKind, w.Schedule, loc, start)
+}
+
+// ParseDuration parses the duration of the occurrences of the window, e.g.
+// `2h`.
+func (w *MaintenanceWindow) ParseDuration() (time.Duration, error) {
+	return alertmanager.ParseDuration(w.Duration)
+}
+
+// NextRun returns the start of the first occurrence of the window after t,
+// or nil when there is none.
+func (w *MaintenanceWindow) NextRun(t time.Time) (*time.Time, error) {
+	s, err := w.ParseSchedule()
+	if err != nil {
+		return nil, err
+	}
+
+	next := s.Next(t)
+	if next.IsZero() {
+		return nil, nil
+	}
+
+	next = next.UTC()
+
+	return &next, nil
+}
+
+type MaintenanceWindowModel struct {
+	DB Querier
+}
+
+const maintenanceWindowColumns = `
+	id, tenant_id, name, schedule_kind, schedule, timezone, duration, matchers,
+	cluster_selector, comment, enabled, next_run_at, COALESCE(created_by::text, ''),
+	created_at, updated_at`
+
+func scanMaintenanceWindow(scan func(dest ...any) error) (*MaintenanceWindow, error) {
+	var window MaintenanceWindow
+	var matchers, selector []byte
+
+	err := scan(
+		&window.Id,
+		&window.TenantId,
+		&window.Name,
+		&window.ScheduleKind,
+		&window.Schedule,
+		&window.Timezone,
+		&window.Duration,
+		&matchers,
+		&selector,
+		&window.Comment,
+		&window.Enabled,
+		&window.NextRunAt,
+		&window.CreatedBy,
+		&window.CreatedAt,
+		&window.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(matchers, &window.Matchers); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(selector, &window.ClusterSelector); err != nil {
+		return nil, err
+	}
+
+	return &window, nil
+}
+
+func (m MaintenanceWindowModel) Insert(window *MaintenanceWindow) error {
+	query := `
+		INSERT INTO maintenance_windows (
+			name, schedule_kind, schedule, timezone, duration, matchers,
+			cluster_selector, comment, enabled, next_run_at, created_by
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, '')::uuid)
+		RETURNING id, tenant_id, created_at, updated_at`
+
+	if window.ClusterSelector == nil {
+		window.ClusterSelector = map[string]string{}
+	}
+
+	matchers, err := json.Marshal(window.Matchers)
+	if err != nil {
+		return err
+	}
+
+	selector, err := json.Marshal(window.ClusterSelector)
+	if err != nil {
+		return err
+	}
+
+	args := []any{
+		window.Name,
+		window.ScheduleKind,
+		window.Schedule,
+		window.Timezone,
+		window.Duration,
+		matchers,
+		selector,
+		window.Comment,
+		window.Enabled,
+		window.NextRunAt,
+		window.CreatedBy,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&window.Id,
+		&window.TenantId,
+		&window.CreatedAt,
+		&window.UpdatedAt,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Constraint == "maintenance_windows_tenant_id_name_key":
+			return ErrDuplicateMaintenanceWindowName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m MaintenanceWindowModel) GetById(id string) (*MaintenanceWindow, error) {
+	if !isUUID(id) {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT ` + maintenanceWindowColumns + ` FROM maintenance_windows WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	window, err := scanMaintenanceWindow(m.DB.QueryRowContext(ctx, query, id).Scan)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return window, nil
+}
+
+var maintenanceWindowListSpec = listSpec{
+	fields: map[string]listField{
+		"name":       {column: "name", kind: fieldString, sortable: true, filterable: true},
+		"enabled":    {column: "enabled", kind: fieldBool, filterable: true},
+		"created_at": {column: "created_at", kind: fieldTime, sortable: true, filterable: true},
+		"updated_at": {column: "updated_at", kind: fieldTime, sortable: true, filterable: true},
+	},
+	idColumn:    "id",
+	idKind:      fieldUUID,
+	defaultSort: "name",
+}
+
+// List returns a page of maintenance windows matching the list query, along
+// with the cursor of the next page or an empty string when it is the last
+// one.
+func (m MaintenanceWindowModel) List(q *event.ListQuery) ([]*MaintenanceWindow, string, error) {
+	lq, err := maintenanceWindowListSpec.build(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := lq.sql(`SELECT ` + maintenanceWindowColumns + ` FROM maintenance_windows`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, lq.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = rows.Close() }()
+
+	windows := []*MaintenanceWindow{}
+
+	for rows.Next() {
+		window, err := scanMaintenanceWindow(rows.Scan)
+		if err != nil {
+			return nil, "", err
+		}
+
+		windows = append(windows, window)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(windows) <= lq.limit {
+		return windows, "", nil
+	}
+
+	windows = windows[:lq.limit]
+	last := windows[len(windows)-1]
+
+	var value any
+
+	switch lq.sort {
+	case "created_at":
+		value = last.CreatedAt
+	case "updated_at":
+		value = last.UpdatedAt
+	default:
+		value = last.Name
+	}
+
+	return windows, lq.nextCursor(value, last.Id), nil
+}
+
+// ListDue returns the enabled windows whose next occurrence starts before
+// the given time, the earliest first. Unscoped models return the windows of
+// all the tenants.
+func (m MaintenanceWindowModel) ListDue(before time.Time) ([]*MaintenanceWindow, error) {
+	query := `
+		SELECT ` + maintenanceWindowColumns + `
+		FROM maintenance_windows
+		WHERE enabled AND next_run_at <= $1
+		ORDER BY next_run_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	windows := []*MaintenanceWindow{}
+
+	for rows.Next() {
+		window, err := scanMaintenanceWindow(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		windows = append(windows, window)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return windows, nil
+}
+
+// Update saves the window, its next run included.
+func (m MaintenanceWindowModel) Update(window *MaintenanceWindow) error {
+	if !isUUID(window.Id) {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE maintenance_windows
+		SET name = $1, schedule_kind = $2, schedule = $3, timezone = $4, duration = $5,
+			matchers = $6, cluster_selector = $7, comment = $8, enabled = $9,
+			next_run_at = $10, updated_at = now()
+		WHERE id = $11
+		RETURNING updated_at`
+
+	if window.ClusterSelector == nil {
+		window.ClusterSelector = map[string]string{}
+	}
+
+	matchers, err := json.Marshal(window.Matchers)
+	if err != nil {
+		return err
+	}
+
+	selector, err := json.Marshal(window.ClusterSelector)
+	if err != nil {
+		return err
+	}
+
+	args := []any{
+		window.Name,
+		window.ScheduleKind,
+		window.Schedule,
+		window.Timezone,
+		window.Duration,
+		matchers,
+		selector,
+		window.Comment,
+		window.Enabled,
+		window.NextRunAt,
+		window.Id,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&window.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case errors.As(err, &pqErr) && pqErr.Constraint == "maintenance_windows_tenant_id_name_key":
+			return ErrDuplicateMaintenanceWindowName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Advance moves the next run of the window forward, unless it changed since
+// the window was read, e.g. because another replica advanced it or the
+// window was updated. It reports whether the window was advanced.
+func (m MaintenanceWindowModel) Advance(window *MaintenanceWindow, next *time.Time) (bool, error) {
+	if !isUUID(window.Id) {
+		return false, ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE maintenance_windows
+		SET next_run_at = $1
+		WHERE id = $2 AND next_run_at IS NOT DISTINCT FROM $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, next, window.Id, window.NextRunAt)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if n == 0 {
+		return false, nil
+	}
+
+	window.NextRunAt = next
+
+	return true, nil
+}
+
+func (m MaintenanceWindowModel) Delete(id string) error {
+	if !isUUID(id) {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM maintenance_windows
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
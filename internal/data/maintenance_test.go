@@ -0,0 +1,103 @@
+package data
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+)
+
+// TestMaintenanceWindowModelScheduling covers the queries of the scheduler:
+// the windows due, moving them to their next occurrence once, and the
+// lease electing the replica running it.
+func TestMaintenanceWindowModelScheduling(t *testing.T) {
+	t.Parallel()
+
+	models := newTestModels(t)
+	scoped, _ := newTestTenant(t, models)
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	due := now.Add(-time.Minute)
+	later := now.Add(time.Hour)
+
+	windows := []*MaintenanceWindow{
+		{Name: "Due", NextRunAt: &due, Enabled: true},
+		{Name: "Later", NextRunAt: &later, Enabled: true},
+		{Name: "Disabled", NextRunAt: &due, Enabled: false},
+	}
+
+	for _, window := range windows {
+		window.ScheduleKind = "cron"
+		window.Schedule = "0 2 * * SUN"
+		window.Timezone = "UTC"
+		window.Duration = "1h"
+		window.Matchers = []string{`severity="warning"`}
+		window.Comment = window.Name
+
+		if err := scoped.MaintenanceWindows.Insert(window); err != nil {
+			t.Fatalf("failed to insert maintenance window; %v", err)
+		}
+	}
+
+	listed, err := scoped.MaintenanceWindows.ListDue(now)
+	if err != nil {
+		t.Fatalf("failed to list due maintenance windows; %v", err)
+	}
+
+	if len(listed) != 1 || listed[0].Id != windows[0].Id || !listed[0].NextRunAt.Equal(due) {
+		t.Fatalf("expected the due window only, got %+v", listed)
+	}
+
+	// Only one of two replicas firing the same occurrence advances it.
+	replica := *listed[0]
+	next := due.Add(7 * 24 * time.Hour)
+
+	if advanced, err := scoped.MaintenanceWindows.Advance(listed[0], &next); err != nil || !advanced {
+		t.Fatalf("expected window to be advanced, got %t; %v", advanced, err)
+	}
+
+	if advanced, err := scoped.MaintenanceWindows.Advance(&replica, &next); err != nil || advanced {
+		t.Fatalf("expected window not to be advanced twice, got %t; %v", advanced, err)
+	}
+
+	if listed, _ := scoped.MaintenanceWindows.ListDue(now); len(listed) != 0 {
+		t.Fatalf("expected no due window, got %+v", listed)
+	}
+
+	// The leases are shared by the tenants, each test uses its own.
+	lease := "test-scheduler-" + rand.Text()
+
+	if held, err := models.Leases.Acquire(lease, "replica-1", time.Minute); err != nil || !held {
+		t.Fatalf("expected lease to be acquired, got %t; %v", held, err)
+	}
+
+	if held, _ := models.Leases.Acquire(lease, "replica-2", time.Minute); held {
+		t.Fatal("expected lease to be held by the first replica")
+	}
+
+	if held, _ := models.Leases.Acquire(lease, "replica-1", time.Minute); !held {
+		t.Fatal("expected lease to be renewed by its holder")
+	}
+
+	if err := models.Leases.Release(lease, "replica-2"); err != nil {
+		t.Fatalf("failed to release lease; %v", err)
+	}
+
+	if held, _ := models.Leases.Acquire(lease, "replica-2", time.Minute); held {
+		t.Fatal("expected lease not to be released by another replica")
+	}
+
+	if err := models.Leases.Release(lease, "replica-1"); err != nil {
+		t.Fatalf("failed to release lease; %v", err)
+	}
+
+	if held, _ := models.Leases.Acquire(lease, "replica-2", time.Millisecond); !held {
+		t.Fatal("expected released lease to be acquired")
+	}
+
+	time.Sleep(time.Millisecond * 10)
+
+	if held, _ := models.Leases.Acquire(lease, "replica-1", time.Minute); !held {
+		t.Fatal("expected expired lease to be acquired")
+	}
+}
@@ -1,18 +1,212 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"regexp"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 var ErrRecordNotFound = errors.New("record not found")
 
+// Querier runs the queries of the models, along with their transactions.
+// The models query a sql.DB or a sql.Conn through it, traced.
+type Querier interface {
+	PingContext(ctx context.Context) error
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Tx runs the queries of a transaction of the models, it wraps a sql.Tx.
+type Tx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Commit() error
+	Rollback() error
+}
+
+// conn is the connection the models query, it is implemented by both sql.DB
+// and sql.Conn.
+type conn interface {
+	PingContext(ctx context.Context) error
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// SystemParams returns the run-time parameters of the connections of the
+// pool, which run as the system role so that the models not scoped to a
+// tenant see the rows of all the tenants. The row level security policies
+// of the tables match no rows on connections with neither a tenant nor the
+// system role.
+func SystemParams() map[string]string {
+	return map[string]string{"scopehouse.role": "system"}
+}
+
+// ScopeFunc returns the models restricted to the data of a tenant, along
+// with the function releasing them once they are no longer used.
+type ScopeFunc func(tenantId string) (*Models, func(), error)
+
+// TraceFunc returns the models tracing their queries as children of the
+// span of ctx.
+type TraceFunc func(ctx context.Context) *Models
+
 type Models struct {
-	Users UserStore
+	Users               UserStore
+	Tokens              TokenStore
+	Sessions            SessionStore
+	ActivationTokens    ActivationTokenStore
+	Teams               TeamStore
+	Clusters            ClusterStore
+	RoleBindings        RoleBindingStore
+	Audit               AuditStore
+	Tenants             TenantStore
+	ChangeRequests      ChangeRequestStore
+	AlertmanagerConfigs AlertmanagerConfigStore
+	Silences            SilenceStore
+	MaintenanceWindows  MaintenanceWindowStore
+	Leases              LeaseStore
+	AlertHistory        AlertHistoryStore
+	Schema              SchemaStore
+	ClusterCredentials  ClusterCredentialStore
+
+	// Scope restricts the models to a tenant. The models it is called on
+	// are not restricted, they are meant for the requests that cannot be
+	// bound to a tenant, such as signing in.
+	Scope ScopeFunc
+
+	// Trace returns the models tracing their queries as children of the
+	// span of a context, e.g. the one of a request. The models it is called
+	// on trace their queries as new traces.
+	Trace TraceFunc
 }
 
-func NewModels(db *sql.DB) *Models {
+// NewModels creates the models querying db, and tracing their queries with
+// the tracer, which discards the spans when nil.
+func NewModels(db *sql.DB, tracer trace.Tracer) *Models {
+	if tracer == nil {
+		tracer = noop.NewTracerProvider().Tracer("")
+	}
+
+	scope := scopeFunc(db, tracer)
+
+	models := newModels(db, tracer, nil)
+	models.Scope = scope
+	models.Trace = traceFunc(db, tracer, scope)
+
+	return models
+}
+
+// traceFunc returns the function creating the models querying q traced as
+// children of the span of a context, restricted by scope.
+func traceFunc(q conn, tracer trace.Tracer, scope ScopeFunc) TraceFunc {
+	var fn TraceFunc
+
+	fn = func(ctx context.Context) *Models {
+		models := newModels(q, tracer, ctx)
+		models.Scope = scope
+		models.Trace = fn
+		return models
+	}
+
+	return fn
+}
+
+func newModels(q conn, tracer trace.Tracer, parent context.Context) *Models {
+	db := &tracedQuerier{
+		tracedQueries: tracedQueries{queries: q, tracer: tracer, parent: parent},
+		conn:          q,
+	}
+
 	return &Models{
-		Users: UserModel{DB: db},
+		Users:               UserModel{DB: db},
+		Tokens:              TokenModel{DB: db},
+		Sessions:            SessionModel{DB: db},
+		ActivationTokens:    ActivationTokenModel{DB: db},
+		Teams:               TeamModel{DB: db},
+		Clusters:            ClusterModel{DB: db},
+		RoleBindings:        RoleBindingModel{DB: db},
+		Audit:               AuditModel{DB: db},
+		Tenants:             TenantModel{DB: db},
+		ChangeRequests:      ChangeRequestModel{DB: db},
+		AlertmanagerConfigs: AlertmanagerConfigModel{DB: db},
+		Silences:            SilenceModel{DB: db},
+		MaintenanceWindows:  MaintenanceWindowModel{DB: db},
+		Leases:              LeaseModel{DB: db},
+		AlertHistory:        AlertHistoryModel{DB: db},
+		Schema:              SchemaModel{DB: db},
+		ClusterCredentials:  ClusterCredentialModel{DB: db},
 	}
 }
+
+// ErrScoped is returned when scoping models that already are.
+var ErrScoped = errors.New("models are already scoped to a tenant")
+
+// scopeFunc pins a connection of the pool, and sets its tenant for the row
+// level security policies of the tables to only match the tenant rows. The
+// system role of the connection is dropped along the way, both are reset
+// before the connection goes back to the pool.
+func scopeFunc(db *sql.DB, tracer trace.Tracer) ScopeFunc {
+	return func(tenantId string) (*Models, func(), error) {
+		if !isUUID(tenantId) {
+			return nil, nil, ErrRecordNotFound
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		query := `SELECT set_config('scopehouse.tenant_id', $1, false), set_config('scopehouse.role', '', false)`
+
+		_, err = conn.ExecContext(ctx, query, tenantId)
+		if err != nil {
+			_ = conn.Close()
+			return nil, nil, err
+		}
+
+		scoped := func(string) (*Models, func(), error) {
+			return nil, nil, ErrScoped
+		}
+
+		models := newModels(conn, tracer, nil)
+		models.Scope = scoped
+		models.Trace = traceFunc(conn, tracer, scoped)
+
+		release := func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+			defer cancel()
+
+			if _, err := conn.ExecContext(ctx, `RESET scopehouse.tenant_id; RESET scopehouse.role`); err != nil {
+				// Never hand a connection still bound to the tenant back
+				// to the pool, have it closed instead.
+				_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+			}
+
+			_ = conn.Close()
+		}
+
+		return models, release, nil
+	}
+}
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// isUUID reports whether id is a well-formed uuid. Ids taken from requests
+// are checked before querying uuid columns, which would otherwise fail with
+// a syntax error instead of matching no rows.
+func isUUID(id string) bool {
+	return uuidRegex.MatchString(id)
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/database"
+)
+
+// testDatabaseUrlEnv is the environment variable of the url of the Postgres
+// database the models are tested against, migrated to the latest version.
+// Its role must not bypass the row level security policies, e.g. be a
+// superuser. The tests needing it are skipped when it is not set.
+const testDatabaseUrlEnv = "SH_TEST_DATABASE_URL"
+
+// newTestDB connects to the test database with the run-time parameters.
+func newTestDB(t *testing.T, params map[string]string) *sql.DB {
+	t.Helper()
+
+	url := os.Getenv(testDatabaseUrlEnv)
+	if url == "" {
+		t.Skipf("%s is not set", testDatabaseUrlEnv)
+	}
+
+	db, err := database.New(database.Config{Url: url, RuntimeParams: params})
+	if err != nil {
+		t.Fatalf("failed to connect to the test database; %v", err)
+	}
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+// newTestModels returns the models of the test database, connected as the
+// system role like the ones of the service.
+func newTestModels(t *testing.T) *Models {
+	t.Helper()
+
+	return NewModels(newTestDB(t, SystemParams()), nil)
+}
+
+// newTestTenant creates a tenant of its own for a test, along with a team
+// and a cluster labeled `env=staging`, and returns the models scoped to it.
+// The rows of the tests are left in the database, the tenants referencing
+// them cannot be deleted.
+func newTestTenant(t *testing.T, models *Models) (*Models, *Cluster) {
+	t.Helper()
+
+	tenant := &Tenant{Name: "test-" + rand.Text()}
+	if err := models.Tenants.Insert(tenant); err != nil {
+		t.Fatalf("failed to insert tenant; %v", err)
+	}
+
+	scoped, release, err := models.Scope(tenant.Id)
+	if err != nil {
+		t.Fatalf("failed to scope models; %v", err)
+	}
+
+	t.Cleanup(release)
+
+	team := &Team{Name: "payments"}
+	if err := scoped.Teams.Insert(team); err != nil {
+		t.Fatalf("failed to insert team; %v", err)
+	}
+
+	cluster := &Cluster{
+		Name:   "payments-staging",
+		TeamId: team.Id,
+		Labels: map[string]string{"env": "staging"},
+	}
+
+	if err := scoped.Clusters.Insert(cluster); err != nil {
+		t.Fatalf("failed to insert cluster; %v", err)
+	}
+
+	if cluster.TenantId != tenant.Id {
+		t.Fatalf("expected cluster of tenant %s, got %s", tenant.Id, cluster.TenantId)
+	}
+
+	return scoped, cluster
+}
@@ -0,0 +1,294 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+const (
+	ScopeTypeGlobal  = "global"
+	ScopeTypeTeam    = "team"
+	ScopeTypeCluster = "cluster"
+)
+
+// ScopeTypes lists the valid role binding scope types.
+var ScopeTypes = []string{ScopeTypeGlobal, ScopeTypeTeam, ScopeTypeCluster}
+
+var ErrDuplicateRoleBinding = errors.New("duplicate role binding")
+
+type RoleBindingStore interface {
+	Insert(binding *RoleBinding) error
+	GetById(id string) (*RoleBinding, error)
+	ListForUser(userId string) ([]*RoleBinding, error)
+	List(q *event.ListQuery) ([]*RoleBinding, string, error)
+	Delete(id string) error
+}
+
+// RoleBinding grants a role to a user on every resource (global scope), on
+// a team and its clusters, or on a single cluster. Bindings with a token id
+// only apply to requests authenticated with that token.
+type RoleBinding struct {
+	Id        string    `json:"id"`
+	UserId    string    `json:"user_id"`
+	TokenId   string    `json:"token_id,omitempty"`
+	Role      string    `json:"role"`
+	ScopeType string    `json:"scope_type"`
+	ScopeId   string    `json:"scope_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Covers reports whether the binding scope includes the resource.
+func (b *RoleBinding) Covers(resource Resource) bool {
+	switch b.ScopeType {
+	case ScopeTypeGlobal:
+		return true
+	case ScopeTypeTeam:
+		return resource.TeamId != "" && b.ScopeId == resource.TeamId
+	case ScopeTypeCluster:
+		return resource.ClusterId != "" && b.ScopeId == resource.ClusterId
+	default:
+		return false
+	}
+}
+
+// Resource returns the resource the binding scope is bound to.
+func (b *RoleBinding) Resource() Resource {
+	switch b.ScopeType {
+	case ScopeTypeTeam:
+		return Resource{TeamId: b.ScopeId}
+	case ScopeTypeCluster:
+		return Resource{ClusterId: b.ScopeId}
+	default:
+		return Resource{}
+	}
+}
+
+type RoleBindingModel struct {
+	DB Querier
+}
+
+func (m RoleBindingModel) Insert(binding *RoleBinding) error {
+	query := `
+		INSERT INTO role_bindings (user_id, token_id, role, scope_type, scope_id)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, NULLIF($5, '')::uuid)
+		RETURNING id, created_at`
+
+	args := []any{
+		binding.UserId,
+		binding.TokenId,
+		binding.Role,
+		binding.ScopeType,
+		binding.ScopeId,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&binding.Id, &binding.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateRoleBinding
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m RoleBindingModel) GetById(id string) (*RoleBinding, error) {
+	if !isUUID(id) {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, user_id, COALESCE(token_id::text, ''), role, scope_type, COALESCE(scope_id::text, ''), created_at
+		FROM role_bindings
+		WHERE id = $1`
+
+	var binding RoleBinding
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&binding.Id,
+		&binding.UserId,
+		&binding.TokenId,
+		&binding.Role,
+		&binding.ScopeType,
+		&binding.ScopeId,
+		&binding.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &binding, nil
+}
+
+// ListForUser returns all the bindings of the user, including the ones of
+// its tokens.
+func (m RoleBindingModel) ListForUser(userId string) ([]*RoleBinding, error) {
+	query := `
+		SELECT id, user_id, COALESCE(token_id::text, ''), role, scope_type, COALESCE(scope_id::text, ''), created_at
+		FROM role_bindings
+		WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	bindings := []*RoleBinding{}
+
+	for rows.Next() {
+		var binding RoleBinding
+
+		err := rows.Scan(
+			&binding.Id,
+			&binding.UserId,
+			&binding.TokenId,
+			&binding.Role,
+			&binding.ScopeType,
+			&binding.ScopeId,
+			&binding.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		bindings = append(bindings, &binding)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return bindings, nil
+}
+
+var roleBindingListSpec = listSpec{
+	fields: map[string]listField{
+		"user_id":    {column: "user_id::text", kind: fieldString, filterable: true},
+		"role":       {column: "role", kind: fieldString, sortable: true, filterable: true},
+		"scope_type": {column: "scope_type", kind: fieldString, filterable: true},
+		"scope_id":   {column: "scope_id::text", kind: fieldString, filterable: true},
+		"created_at": {column: "created_at", kind: fieldTime, sortable: true, filterable: true},
+	},
+	idColumn:    "id",
+	idKind:      fieldUUID,
+	defaultSort: "created_at",
+}
+
+// List returns a page of the user bindings matching the list query. Token
+// bindings are listed along with their token.
+func (m RoleBindingModel) List(q *event.ListQuery) ([]*RoleBinding, string, error) {
+	lq, err := roleBindingListSpec.build(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	lq.where = append(lq.where, "token_id IS NULL")
+
+	query := lq.sql(`
+		SELECT id, user_id, role, scope_type, COALESCE(scope_id::text, ''), created_at
+		FROM role_bindings`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, lq.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = rows.Close() }()
+
+	bindings := []*RoleBinding{}
+
+	for rows.Next() {
+		var binding RoleBinding
+
+		err := rows.Scan(
+			&binding.Id,
+			&binding.UserId,
+			&binding.Role,
+			&binding.ScopeType,
+			&binding.ScopeId,
+			&binding.CreatedAt,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+
+		bindings = append(bindings, &binding)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(bindings) <= lq.limit {
+		return bindings, "", nil
+	}
+
+	bindings = bindings[:lq.limit]
+	last := bindings[len(bindings)-1]
+
+	var value any
+
+	switch lq.sort {
+	case "role":
+		value = last.Role
+	default:
+		value = last.CreatedAt
+	}
+
+	return bindings, lq.nextCursor(value, last.Id), nil
+}
+
+func (m RoleBindingModel) Delete(id string) error {
+	if !isUUID(id) {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM role_bindings
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
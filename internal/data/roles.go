@@ -0,0 +1,104 @@
+package data
+
+import "slices"
+
+const (
+	RoleViewer   = "viewer"
+	RoleEditor   = "editor"
+	RoleApprover = "approver"
+	RoleAdmin    = "admin"
+)
+
+// Roles lists the valid user roles, from the least to the most privileged.
+// Each role grants everything the previous ones do.
+var Roles = []string{RoleViewer, RoleEditor, RoleApprover, RoleAdmin}
+
+// IsValidRole reports whether role is one of the known roles.
+func IsValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+// RoleIncludes reports whether the held role grants the required one.
+func RoleIncludes(held string, required string) bool {
+	h := slices.Index(Roles, held)
+	return h != -1 && h >= slices.Index(Roles, required)
+}
+
+// Resource identifies what a request acts on for access control. Empty
+// ids stand for resources that are not owned by a team or cluster, which
+// only global bindings cover.
+type Resource struct {
+	TeamId    string
+	ClusterId string
+}
+
+// Permissions resolves the roles of an authenticated request.
+type Permissions struct {
+	user  []*RoleBinding
+	token []*RoleBinding
+}
+
+// NewPermissions builds the permissions of the user from its bindings and
+// global roles. Bindings of the given token, if any, narrow them down so
+// that the token can only do what both the token and its owner are granted.
+// Bindings of other tokens are ignored.
+func NewPermissions(user *User, token *Token, bindings []*RoleBinding) *Permissions {
+	p := &Permissions{}
+
+	for _, role := range user.Roles {
+		p.user = append(p.user, &RoleBinding{
+			UserId:    user.Id,
+			Role:      role,
+			ScopeType: ScopeTypeGlobal,
+		})
+	}
+
+	for _, b := range bindings {
+		switch {
+		case b.TokenId == "":
+			p.user = append(p.user, b)
+		case token != nil && b.TokenId == token.Id:
+			p.token = append(p.token, b)
+		}
+	}
+
+	return p
+}
+
+// Allows reports whether the role is granted on the resource.
+func (p *Permissions) Allows(role string, resource Resource) bool {
+	if !grants(p.user, role, resource) {
+		return false
+	}
+
+	return len(p.token) == 0 || grants(p.token, role, resource)
+}
+
+// AllowsAnywhere reports whether the role is granted on any resource.
+func (p *Permissions) AllowsAnywhere(role string) bool {
+	if !grantsAnywhere(p.user, role) {
+		return false
+	}
+
+	return len(p.token) == 0 || grantsAnywhere(p.token, role)
+}
+
+func grants(bindings []*RoleBinding, role string, resource Resource) bool {
+	for _, b := range bindings {
+		if RoleIncludes(b.Role, role) && b.Covers(resource) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func grantsAnywhere(bindings []*RoleBinding, role string) bool {
+	for _, b := range bindings {
+		if RoleIncludes(b.Role, role) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,104 @@
+package data
+
+import "testing"
+
+func TestRoleIncludes(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		held     string
+		required string
+		expected bool
+	}{
+		{RoleAdmin, RoleViewer, true},
+		{RoleApprover, RoleEditor, true},
+		{RoleEditor, RoleEditor, true},
+		{RoleEditor, RoleApprover, false},
+		{RoleViewer, RoleAdmin, false},
+		{"unknown", RoleViewer, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.held+"_"+tc.required, func(t *testing.T) {
+			if got := RoleIncludes(tc.held, tc.required); got != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	t.Parallel()
+
+	teamA := Resource{TeamId: "team-a"}
+	teamB := Resource{TeamId: "team-b"}
+	clusterA := Resource{TeamId: "team-a", ClusterId: "cluster-a"}
+	clusterB := Resource{TeamId: "team-b", ClusterId: "cluster-b"}
+	global := Resource{}
+
+	user := &User{Id: "user"}
+	token := &Token{Id: "token", UserId: "user"}
+
+	bindings := []*RoleBinding{
+		{UserId: "user", Role: RoleEditor, ScopeType: ScopeTypeTeam, ScopeId: "team-a"},
+		{UserId: "user", Role: RoleAdmin, ScopeType: ScopeTypeCluster, ScopeId: "cluster-b"},
+		{UserId: "user", TokenId: "token", Role: RoleViewer, ScopeType: ScopeTypeTeam, ScopeId: "team-a"},
+		{UserId: "user", TokenId: "other", Role: RoleAdmin, ScopeType: ScopeTypeGlobal},
+	}
+
+	testCases := []struct {
+		name     string
+		user     *User
+		token    *Token
+		role     string
+		resource Resource
+		expected bool
+	}{
+		{"team role covers team", user, nil, RoleEditor, teamA, true},
+		{"team role covers team clusters", user, nil, RoleEditor, clusterA, true},
+		{"team role does not cover other teams", user, nil, RoleViewer, teamB, false},
+		{"team role is not global", user, nil, RoleViewer, global, false},
+		{"role below requirement", user, nil, RoleApprover, clusterA, false},
+		{"cluster role covers cluster", user, nil, RoleAdmin, clusterB, true},
+		{"cluster role does not cover team", user, nil, RoleViewer, teamB, false},
+		{"token narrows roles", user, token, RoleEditor, clusterA, false},
+		{"token keeps granted roles", user, token, RoleViewer, clusterA, true},
+		{"token excludes other scopes", user, token, RoleAdmin, clusterB, false},
+		{"other token bindings are ignored", user, nil, RoleAdmin, global, false},
+		{"user global roles", &User{Id: "user", Roles: []string{RoleViewer}}, nil, RoleViewer, clusterB, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewPermissions(tc.user, tc.token, bindings)
+
+			if got := p.Allows(tc.role, tc.resource); got != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestPermissionsAllowsAnywhere(t *testing.T) {
+	t.Parallel()
+
+	user := &User{Id: "user"}
+	token := &Token{Id: "token", UserId: "user"}
+
+	bindings := []*RoleBinding{
+		{UserId: "user", Role: RoleAdmin, ScopeType: ScopeTypeTeam, ScopeId: "team-a"},
+		{UserId: "user", TokenId: "token", Role: RoleViewer, ScopeType: ScopeTypeTeam, ScopeId: "team-a"},
+	}
+
+	if !NewPermissions(user, nil, bindings).AllowsAnywhere(RoleAdmin) {
+		t.Fatal("expected user to be admin somewhere")
+	}
+
+	if NewPermissions(user, token, bindings).AllowsAnywhere(RoleEditor) {
+		t.Fatal("expected token not to be editor anywhere")
+	}
+
+	if NewPermissions(&User{Id: "other"}, nil, nil).AllowsAnywhere(RoleViewer) {
+		t.Fatal("expected user without bindings not to be granted any role")
+	}
+}
@@ -0,0 +1,52 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// SchemaVersion is the version of the last migration applied to the
+// schema of the database.
+type SchemaVersion struct {
+	Version uint
+	// Dirty reports whether the migration failed halfway, and must be
+	// fixed by hand.
+	Dirty bool
+}
+
+// SchemaStore checks the database the models query. Its methods are bounded
+// by the context, since they are meant for health checks.
+type SchemaStore interface {
+	Ping(ctx context.Context) error
+	Version(ctx context.Context) (*SchemaVersion, error)
+}
+
+type SchemaModel struct {
+	DB Querier
+}
+
+// Ping checks that the database can be reached.
+func (m SchemaModel) Ping(ctx context.Context) error {
+	return m.DB.PingContext(ctx)
+}
+
+// Version returns the version of the schema recorded by golang-migrate. It
+// returns ErrRecordNotFound when no migration was applied.
+func (m SchemaModel) Version(ctx context.Context) (*SchemaVersion, error) {
+	query := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+
+	var v SchemaVersion
+
+	err := m.DB.QueryRowContext(ctx, query).Scan(&v.Version, &v.Dirty)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &v, nil
+}
@@ -0,0 +1,121 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type SessionStore interface {
+	Insert(session *Session) error
+	GetForToken(plaintext string) (*Session, error)
+	Delete(id string) error
+}
+
+// Session is a server-side session of a user signed in with a password.
+// The plaintext value is sent to the browser in a cookie, while the CSRF
+// token must be echoed in a header by cookie authenticated mutations.
+type Session struct {
+	Id        string    `json:"id"`
+	Plaintext string    `json:"-"`
+	Hash      []byte    `json:"-"`
+	UserId    string    `json:"user_id"`
+	CsrfToken string    `json:"csrf_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewSession(userId string, ttl time.Duration) (*Session, error) {
+	plaintext, err := generateSecret("")
+	if err != nil {
+		return nil, err
+	}
+
+	csrfToken, err := generateSecret("")
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		Plaintext: plaintext,
+		Hash:      hashToken(plaintext),
+		UserId:    userId,
+		CsrfToken: csrfToken,
+		ExpiresAt: time.Now().Add(ttl).UTC(),
+	}
+
+	return session, nil
+}
+
+type SessionModel struct {
+	DB Querier
+}
+
+func (m SessionModel) Insert(session *Session) error {
+	query := `
+		INSERT INTO sessions (hash, user_id, csrf_token, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	args := []any{
+		session.Hash,
+		session.UserId,
+		session.CsrfToken,
+		session.ExpiresAt,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&session.Id, &session.CreatedAt)
+}
+
+// GetForToken returns the unexpired session matching the given plaintext.
+func (m SessionModel) GetForToken(plaintext string) (*Session, error) {
+	query := `
+		SELECT id, hash, user_id, csrf_token, expires_at, created_at
+		FROM sessions
+		WHERE hash = $1 AND expires_at > now()`
+
+	var session Session
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, hashToken(plaintext)).Scan(
+		&session.Id,
+		&session.Hash,
+		&session.UserId,
+		&session.CsrfToken,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &session, nil
+}
+
+func (m SessionModel) Delete(id string) error {
+	if !isUUID(id) {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM sessions
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+
+	return err
+}
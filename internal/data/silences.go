@@ -0,0 +1,474 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+const (
+	SilencePending = "pending"
+	SilenceActive  = "active"
+	SilenceExpired = "expired"
+)
+
+const (
+	// SilenceTargetPending is the status of the clusters the silence was
+	// not created on yet.
+	SilenceTargetPending = "pending"
+	SilenceTargetCreated = "created"
+	SilenceTargetFailed  = "failed"
+	SilenceTargetExpired = "expired"
+)
+
+// ErrDuplicateSilence is returned when a maintenance window already
+// silenced the occurrence.
+var ErrDuplicateSilence = errors.New("duplicate silence")
+
+type SilenceStore interface {
+	Insert(silence *Silence) error
+	GetById(id string) (*Silence, error)
+	List(q *event.ListQuery) ([]*Silence, string, error)
+	ListUnsynced() ([]*Silence, error)
+	UpdateTarget(target *SilenceTarget) error
+	Expire(silence *Silence) error
+}
+
+// Silence mutes the alerts matching all its matchers on the Alertmanager of
+// the clusters it targets, the ones matching its selector when it was
+// created. Its status is derived from its times, like in Alertmanager, and
+// ExpiredAt is set when it was expired before its end. Silences of the
+// occurrences of maintenance windows are created by the scheduler.
+type Silence struct {
+	Id                  string            `json:"id"`
+	TenantId            string            `json:"tenant_id"`
+	Matchers            []string          `json:"matchers"`
+	ClusterSelector     map[string]string `json:"cluster_selector"`
+	StartsAt            time.Time         `json:"starts_at"`
+	EndsAt              time.Time         `json:"ends_at"`
+	Comment             string            `json:"comment"`
+	CreatedBy           string            `json:"created_by,omitempty"`
+	MaintenanceWindowId string            `json:"maintenance_window_id,omitempty"`
+	Status              string            `json:"status"`
+	ExpiredAt           *time.Time        `json:"expired_at,omitempty"`
+	CreatedAt           time.Time         `json:"created_at"`
+	Targets             []*SilenceTarget  `json:"targets"`
+}
+
+// SilenceTarget is a cluster a silence targets. AlertmanagerSilenceId is the
+// id of the silence in the Alertmanager of the cluster once created, and
+// Error the error of the last failed call to it.
+type SilenceTarget struct {
+	SilenceId             string    `json:"-"`
+	ClusterId             string    `json:"cluster_id"`
+	AlertmanagerSilenceId string    `json:"alertmanager_silence_id,omitempty"`
+	Status                string    `json:"status"`
+	Error                 string    `json:"error,omitempty"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+type SilenceModel struct {
+	DB Querier
+}
+
+// silenceStatus is the status of a silence, silences expired before their
+// end being expired.
+const silenceStatus = `(CASE
+	WHEN expired_at IS NOT NULL OR ends_at <= now() THEN 'expired'
+	WHEN starts_at > now() THEN 'pending'
+	ELSE 'active' END)`
+
+const silenceColumns = `
+	id, tenant_id, matchers, cluster_selector, starts_at, ends_at, comment,
+	COALESCE(created_by::text, ''), COALESCE(maintenance_window_id::text, ''),
+	` + silenceStatus + `, expired_at, created_at`
+
+func scanSilence(scan func(dest ...any) error) (*Silence, error) {
+	var silence Silence
+	var matchers, selector []byte
+
+	err := scan(
+		&silence.Id,
+		&silence.TenantId,
+		&matchers,
+		&selector,
+		&silence.StartsAt,
+		&silence.EndsAt,
+		&silence.Comment,
+		&silence.CreatedBy,
+		&silence.MaintenanceWindowId,
+		&silence.Status,
+		&silence.ExpiredAt,
+		&silence.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(matchers, &silence.Matchers); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(selector, &silence.ClusterSelector); err != nil {
+		return nil, err
+	}
+
+	silence.Targets = []*SilenceTarget{}
+
+	return &silence, nil
+}
+
+// Insert saves the silence along with its targets. It returns
+// ErrDuplicateSilence when the occurrence of its maintenance window was
+// already silenced.
+func (m SilenceModel) Insert(silence *Silence) error {
+	if silence.ClusterSelector == nil {
+		silence.ClusterSelector = map[string]string{}
+	}
+
+	matchers, err := json.Marshal(silence.Matchers)
+	if err != nil {
+		return err
+	}
+
+	selector, err := json.Marshal(silence.ClusterSelector)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `
+		INSERT INTO silences (
+			matchers, cluster_selector, starts_at, ends_at, comment, created_by, maintenance_window_id
+		)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid, NULLIF($7, '')::uuid)
+		RETURNING id, tenant_id, ` + silenceStatus + `, created_at`
+
+	args := []any{
+		matchers,
+		selector,
+		silence.StartsAt,
+		silence.EndsAt,
+		silence.Comment,
+		silence.CreatedBy,
+		silence.MaintenanceWindowId,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&silence.Id,
+		&silence.TenantId,
+		&silence.Status,
+		&silence.CreatedAt,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Constraint == "silences_maintenance_window_id_starts_at_key":
+			return ErrDuplicateSilence
+		default:
+			return err
+		}
+	}
+
+	query = `
+		INSERT INTO silence_targets (silence_id, cluster_id, status)
+		VALUES ($1, $2, $3)
+		RETURNING updated_at`
+
+	for _, target := range silence.Targets {
+		target.SilenceId = silence.Id
+		target.Status = SilenceTargetPending
+
+		err := tx.QueryRowContext(ctx, query, silence.Id, target.ClusterId, target.Status).Scan(&target.UpdatedAt)
+		if err != nil {
+			var pqErr *pq.Error
+			switch {
+			case errors.As(err, &pqErr) && pqErr.Code == "23503":
+				// The cluster was deleted meanwhile.
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m SilenceModel) GetById(id string) (*Silence, error) {
+	if !isUUID(id) {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT ` + silenceColumns + ` FROM silences WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	silence, err := scanSilence(m.DB.QueryRowContext(ctx, query, id).Scan)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := m.loadTargets(ctx, silence); err != nil {
+		return nil, err
+	}
+
+	return silence, nil
+}
+
+var silenceListSpec = listSpec{
+	fields: map[string]listField{
+		"status":                {column: silenceStatus, kind: fieldString, filterable: true},
+		"created_by":            {column: "created_by::text", kind: fieldString, filterable: true},
+		"maintenance_window_id": {column: "maintenance_window_id::text", kind: fieldString, filterable: true},
+		"starts_at":             {column: "starts_at", kind: fieldTime, sortable: true, filterable: true},
+		"ends_at":               {column: "ends_at", kind: fieldTime, sortable: true, filterable: true},
+		"created_at":            {column: "created_at", kind: fieldTime, sortable: true, filterable: true},
+	},
+	idColumn:    "id",
+	idKind:      fieldUUID,
+	defaultSort: "created_at",
+	defaultDesc: true,
+}
+
+// List returns a page of silences matching the list query, the most recent
+// first by default, along with their targets.
+func (m SilenceModel) List(q *event.ListQuery) ([]*Silence, string, error) {
+	lq, err := silenceListSpec.build(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := lq.sql(`SELECT ` + silenceColumns + ` FROM silences`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, lq.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = rows.Close() }()
+
+	silences := []*Silence{}
+
+	for rows.Next() {
+		silence, err := scanSilence(rows.Scan)
+		if err != nil {
+			return nil, "", err
+		}
+
+		silences = append(silences, silence)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+
+	if len(silences) > lq.limit {
+		silences = silences[:lq.limit]
+		last := silences[len(silences)-1]
+
+		var value any
+
+		switch lq.sort {
+		case "starts_at":
+			value = last.StartsAt
+		case "ends_at":
+			value = last.EndsAt
+		default:
+			value = last.CreatedAt
+		}
+
+		next = lq.nextCursor(value, last.Id)
+	}
+
+	if err := m.loadTargets(ctx, silences...); err != nil {
+		return nil, "", err
+	}
+
+	return silences, next, nil
+}
+
+// ListUnsynced returns the silences of maintenance windows that did not
+// end yet, and that are not created on all their targets, e.g. because an
+// Alertmanager was down.
+func (m SilenceModel) ListUnsynced() ([]*Silence, error) {
+	query := `
+		SELECT ` + silenceColumns + `
+		FROM silences
+		WHERE maintenance_window_id IS NOT NULL
+			AND expired_at IS NULL
+			AND ends_at > now()
+			AND id IN (SELECT silence_id FROM silence_targets WHERE status IN ('pending', 'failed'))
+		ORDER BY starts_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	silences := []*Silence{}
+
+	for rows.Next() {
+		silence, err := scanSilence(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		silences = append(silences, silence)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := m.loadTargets(ctx, silences...); err != nil {
+		return nil, err
+	}
+
+	return silences, nil
+}
+
+// loadTargets sets the targets of the silences.
+func (m SilenceModel) loadTargets(ctx context.Context, silences ...*Silence) error {
+	if len(silences) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(silences))
+	byId := make(map[string]*Silence, len(silences))
+
+	for _, silence := range silences {
+		ids = append(ids, silence.Id)
+		byId[silence.Id] = silence
+	}
+
+	query := `
+		SELECT silence_id, cluster_id, alertmanager_silence_id, status, error, updated_at
+		FROM silence_targets
+		WHERE silence_id = ANY($1::uuid[])
+		ORDER BY cluster_id`
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var target SilenceTarget
+
+		err := rows.Scan(
+			&target.SilenceId,
+			&target.ClusterId,
+			&target.AlertmanagerSilenceId,
+			&target.Status,
+			&target.Error,
+			&target.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		if silence, ok := byId[target.SilenceId]; ok {
+			silence.Targets = append(silence.Targets, &target)
+		}
+	}
+
+	return rows.Err()
+}
+
+// UpdateTarget saves the Alertmanager silence id, status and error of the
+// target.
+func (m SilenceModel) UpdateTarget(target *SilenceTarget) error {
+	if !isUUID(target.SilenceId) || !isUUID(target.ClusterId) {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE silence_targets
+		SET alertmanager_silence_id = $1, status = $2, error = $3, updated_at = now()
+		WHERE silence_id = $4 AND cluster_id = $5
+		RETURNING updated_at`
+
+	args := []any{
+		target.AlertmanagerSilenceId,
+		target.Status,
+		target.Error,
+		target.SilenceId,
+		target.ClusterId,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&target.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Expire records the silence as expired, keeping the time it was first
+// expired when it already was.
+func (m SilenceModel) Expire(silence *Silence) error {
+	if !isUUID(silence.Id) {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE silences
+		SET expired_at = COALESCE(expired_at, now())
+		WHERE id = $1
+		RETURNING expired_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, silence.Id).Scan(&silence.ExpiredAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	silence.Status = SilenceExpired
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSilenceModel(t *testing.T) {
+	t.Parallel()
+
+	models := newTestModels(t)
+	scoped, cluster := newTestTenant(t, models)
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	window := &MaintenanceWindow{
+		Name:         "Weekly upgrade",
+		ScheduleKind: "cron",
+		Schedule:     "0 2 * * SUN",
+		Timezone:     "UTC",
+		Duration:     "1h",
+		Matchers:     []string{`alertname="NodeUpgrade"`},
+		Comment:      "Node upgrades",
+		Enabled:      true,
+	}
+
+	if err := scoped.MaintenanceWindows.Insert(window); err != nil {
+		t.Fatalf("failed to insert maintenance window; %v", err)
+	}
+
+	newSilence := func() *Silence {
+		return &Silence{
+			Matchers:            []string{`alertname="NodeUpgrade"`},
+			ClusterSelector:     map[string]string{"env": "staging"},
+			StartsAt:            now,
+			EndsAt:              now.Add(time.Hour),
+			Comment:             "Node upgrades",
+			MaintenanceWindowId: window.Id,
+			Targets:             []*SilenceTarget{{ClusterId: cluster.Id}},
+		}
+	}
+
+	silence := newSilence()
+
+	if err := scoped.Silences.Insert(silence); err != nil {
+		t.Fatalf("failed to insert silence; %v", err)
+	}
+
+	if silence.TenantId != cluster.TenantId || silence.Status != SilenceActive {
+		t.Fatalf("expected active silence of the tenant, got %+v", silence)
+	}
+
+	// An occurrence of a window is only silenced once.
+	if err := scoped.Silences.Insert(newSilence()); !errors.Is(err, ErrDuplicateSilence) {
+		t.Fatalf("expected duplicate silence error, got %v", err)
+	}
+
+	stored, err := scoped.Silences.GetById(silence.Id)
+	if err != nil {
+		t.Fatalf("failed to get silence; %v", err)
+	}
+
+	if len(stored.Targets) != 1 || stored.Targets[0].Status != SilenceTargetPending {
+		t.Fatalf("expected a pending target, got %+v", stored.Targets)
+	}
+
+	unsynced, err := scoped.Silences.ListUnsynced()
+	if err != nil {
+		t.Fatalf("failed to list unsynced silences; %v", err)
+	}
+
+	if len(unsynced) != 1 || unsynced[0].Id != silence.Id {
+		t.Fatalf("expected the silence to be unsynced, got %+v", unsynced)
+	}
+
+	target := stored.Targets[0]
+	target.AlertmanagerSilenceId = "am-silence"
+	target.Status = SilenceTargetCreated
+
+	if err := scoped.Silences.UpdateTarget(target); err != nil {
+		t.Fatalf("failed to update silence target; %v", err)
+	}
+
+	if unsynced, _ := scoped.Silences.ListUnsynced(); len(unsynced) != 0 {
+		t.Fatalf("expected no unsynced silence, got %+v", unsynced)
+	}
+
+	missing := &SilenceTarget{SilenceId: silence.Id, ClusterId: window.Id, Status: SilenceTargetFailed}
+	if err := scoped.Silences.UpdateTarget(missing); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected record not found error, got %v", err)
+	}
+
+	if err := scoped.Silences.Expire(silence); err != nil {
+		t.Fatalf("failed to expire silence; %v", err)
+	}
+
+	stored, err = scoped.Silences.GetById(silence.Id)
+	if err != nil {
+		t.Fatalf("failed to get silence; %v", err)
+	}
+
+	if stored.Status != SilenceExpired || stored.ExpiredAt == nil {
+		t.Fatalf("expected silence to be expired, got %+v", stored)
+	}
+
+	// The silences of a tenant are not seen by the others.
+	other, _ := newTestTenant(t, models)
+
+	if _, err := other.Silences.GetById(silence.Id); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected record not found error, got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+var (
+	ErrDuplicateTeamName = errors.New("duplicate team name")
+	ErrTeamNotEmpty      = errors.New("team still owns clusters")
+)
+
+type TeamStore interface {
+	Insert(team *Team) error
+	GetById(id string) (*Team, error)
+	List(q *event.ListQuery) ([]*Team, string, error)
+	Delete(id string) error
+}
+
+// Team owns clusters. Users are made members of a team by binding them a
+// role on it.
+type Team struct {
+	Id        string    `json:"id"`
+	TenantId  string    `json:"tenant_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TeamModel struct {
+	DB Querier
+}
+
+func (m TeamModel) Insert(team *Team) error {
+	query := `
+		INSERT INTO teams (name)
+		VALUES ($1)
+		RETURNING id, tenant_id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, team.Name).Scan(&team.Id, &team.TenantId, &team.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Constraint == "teams_tenant_id_name_key":
+			return ErrDuplicateTeamName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m TeamModel) GetById(id string) (*Team, error) {
+	if !isUUID(id) {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, tenant_id, name, created_at
+		FROM teams
+		WHERE id = $1`
+
+	var team Team
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&team.Id,
+		&team.TenantId,
+		&team.Name,
+		&team.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &team, nil
+}
+
+var teamListSpec = listSpec{
+	fields: map[string]listField{
+		"name":       {column: "name", kind: fieldString, sortable: true, filterable: true},
+		"created_at": {column: "created_at", kind: fieldTime, sortable: true, filterable: true},
+	},
+	idColumn:    "id",
+	idKind:      fieldUUID,
+	defaultSort: "name",
+}
+
+// List returns a page of teams matching the list query, along with the
+// cursor of the next page or an empty string when it is the last one.
+func (m TeamModel) List(q *event.ListQuery) ([]*Team, string, error) {
+	lq, err := teamListSpec.build(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := lq.sql(`
+		SELECT id, tenant_id, name, created_at
+		FROM teams`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, lq.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = rows.Close() }()
+
+	teams := []*Team{}
+
+	for rows.Next() {
+		var team Team
+
+		if err := rows.Scan(&team.Id, &team.TenantId, &team.Name, &team.CreatedAt); err != nil {
+			return nil, "", err
+		}
+
+		teams = append(teams, &team)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(teams) <= lq.limit {
+		return teams, "", nil
+	}
+
+	teams = teams[:lq.limit]
+	last := teams[len(teams)-1]
+
+	var value any
+
+	switch lq.sort {
+	case "created_at":
+		value = last.CreatedAt
+	default:
+		value = last.Name
+	}
+
+	return teams, lq.nextCursor(value, last.Id), nil
+}
+
+// Delete removes the team along with the role bindings on it. Teams that
+// still own clusters cannot be deleted.
+func (m TeamModel) Delete(id string) error {
+	if !isUUID(id) {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `
+		DELETE FROM teams
+		WHERE id = $1`
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrTeamNotEmpty
+		default:
+			return err
+		}
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	query = `
+		DELETE FROM role_bindings
+		WHERE scope_type = 'team' AND scope_id = $1`
+
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,221 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// DefaultTenantId is the id of the tenant self-registered and single sign-on
+// users join. Its global admins operate the service, and manage the other
+// tenants.
+const DefaultTenantId = "00000000-0000-0000-0000-000000000001"
+
+var ErrDuplicateTenantName = errors.New("duplicate tenant name")
+
+type TenantStore interface {
+	Insert(tenant *Tenant) error
+	GetById(id string) (*Tenant, error)
+	List(q *event.ListQuery) ([]*Tenant, string, error)
+	AddUser(tenantId string, userId string, role string) error
+}
+
+// Tenant isolates the data of an organization sharing the service with
+// others. Every user belongs to a single tenant, and only sees its data.
+type Tenant struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TenantModel struct {
+	DB Querier
+}
+
+func (m TenantModel) Insert(tenant *Tenant) error {
+	query := `
+		INSERT INTO tenants (name)
+		VALUES ($1)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, tenant.Name).Scan(&tenant.Id, &tenant.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Constraint == "tenants_name_key":
+			return ErrDuplicateTenantName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m TenantModel) GetById(id string) (*Tenant, error) {
+	if !isUUID(id) {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, name, created_at
+		FROM tenants
+		WHERE id = $1`
+
+	var tenant Tenant
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&tenant.Id,
+		&tenant.Name,
+		&tenant.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &tenant, nil
+}
+
+var tenantListSpec = listSpec{
+	fields: map[string]listField{
+		"name":       {column: "name", kind: fieldString, sortable: true, filterable: true},
+		"created_at": {column: "created_at", kind: fieldTime, sortable: true, filterable: true},
+	},
+	idColumn:    "id",
+	idKind:      fieldUUID,
+	defaultSort: "name",
+}
+
+// List returns a page of tenants matching the list query, along with the
+// cursor of the next page or an empty string when it is the last one.
+func (m TenantModel) List(q *event.ListQuery) ([]*Tenant, string, error) {
+	lq, err := tenantListSpec.build(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := lq.sql(`
+		SELECT id, name, created_at
+		FROM tenants`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, lq.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = rows.Close() }()
+
+	tenants := []*Tenant{}
+
+	for rows.Next() {
+		var tenant Tenant
+
+		if err := rows.Scan(&tenant.Id, &tenant.Name, &tenant.CreatedAt); err != nil {
+			return nil, "", err
+		}
+
+		tenants = append(tenants, &tenant)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(tenants) <= lq.limit {
+		return tenants, "", nil
+	}
+
+	tenants = tenants[:lq.limit]
+	last := tenants[len(tenants)-1]
+
+	var value any
+
+	switch lq.sort {
+	case "created_at":
+		value = last.CreatedAt
+	default:
+		value = last.Name
+	}
+
+	return tenants, lq.nextCursor(value, last.Id), nil
+}
+
+// AddUser makes the user a member of the tenant, and binds it the global
+// role in the tenant unless role is empty. Users moved from another tenant
+// lose their role bindings, tokens and sessions, which were granted there,
+// as well as their operator flag.
+func (m TenantModel) AddUser(tenantId string, userId string, role string) error {
+	if !isUUID(tenantId) || !isUUID(userId) {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `
+		UPDATE users u
+		SET tenant_id = $1, is_operator = u.is_operator AND prev.tenant_id = $1, updated_at = now()
+		FROM (SELECT id, tenant_id FROM users WHERE id = $2 FOR UPDATE) prev
+		WHERE u.id = prev.id
+		RETURNING prev.tenant_id`
+
+	var prevTenantId string
+
+	err = tx.QueryRowContext(ctx, query, tenantId, userId).Scan(&prevTenantId)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if prevTenantId != tenantId {
+		for _, table := range []string{"role_bindings", "tokens", "sessions"} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, userId); err != nil {
+				return err
+			}
+		}
+	}
+
+	if role != "" {
+		query = `
+			INSERT INTO role_bindings (user_id, role, scope_type)
+			VALUES ($1, $2, 'global')
+			ON CONFLICT DO NOTHING`
+
+		if _, err := tx.ExecContext(ctx, query, userId, role); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
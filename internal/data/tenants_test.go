@@ -0,0 +1,68 @@
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	t.Parallel()
+
+	models := newTestModels(t)
+
+	scoped, cluster := newTestTenant(t, models)
+	other, otherCluster := newTestTenant(t, models)
+
+	// The models scoped to a tenant only see its rows.
+	if _, err := other.Clusters.GetById(cluster.Id); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected record not found error, got %v", err)
+	}
+
+	if _, err := other.Tenants.GetById(cluster.TenantId); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected record not found error, got %v", err)
+	}
+
+	clusters, _, err := other.Clusters.List(nil)
+	if err != nil {
+		t.Fatalf("failed to list clusters; %v", err)
+	}
+
+	if len(clusters) != 1 || clusters[0].Id != otherCluster.Id {
+		t.Fatalf("expected the cluster of the tenant only, got %+v", clusters)
+	}
+
+	teams, _, err := scoped.Teams.List(nil)
+	if err != nil {
+		t.Fatalf("failed to list teams; %v", err)
+	}
+
+	if len(teams) != 1 || teams[0].TenantId != cluster.TenantId {
+		t.Fatalf("expected the team of the tenant only, got %+v", teams)
+	}
+
+	// Connections with neither a tenant nor the system role see no rows at
+	// all, the policies fail closed.
+	unscoped := NewModels(newTestDB(t, nil), nil)
+
+	if _, err := unscoped.Clusters.GetById(cluster.Id); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected record not found error, got %v", err)
+	}
+
+	clusters, _, err = unscoped.Clusters.List(nil)
+	if err != nil {
+		t.Fatalf("failed to list clusters; %v", err)
+	}
+
+	if len(clusters) != 0 {
+		t.Fatalf("expected no cluster, got %+v", clusters)
+	}
+
+	tenants, _, err := unscoped.Tenants.List(nil)
+	if err != nil {
+		t.Fatalf("failed to list tenants; %v", err)
+	}
+
+	if len(tenants) != 0 {
+		t.Fatalf("expected no tenant, got %+v", tenants)
+	}
+}
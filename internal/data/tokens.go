@@ -0,0 +1,314 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+const (
+	// ScopeRead allows a token to call read-only (GET and HEAD) endpoints.
+	ScopeRead = "read"
+
+	// ScopeWrite allows a token to call mutating endpoints.
+	ScopeWrite = "write"
+)
+
+// TokenPrefix is prepended to every plaintext token so that leaked tokens
+// are easy to spot by secret scanners.
+const TokenPrefix = "sh_"
+
+// Scopes lists all the valid token scopes.
+var Scopes = []string{ScopeRead, ScopeWrite}
+
+type TokenStore interface {
+	Insert(token *Token) error
+	GetForToken(plaintext string) (*Token, error)
+	ListForUser(userId string, q *event.ListQuery) ([]*Token, string, error)
+	DeleteForUser(id string, userId string) error
+	Touch(id string) error
+}
+
+// Token is an API token of a user. Roles, when set, restrict the token to
+// those role bindings instead of the ones of its owner.
+type Token struct {
+	Id         string         `json:"id"`
+	Plaintext  string         `json:"token,omitempty"`
+	Hash       []byte         `json:"-"`
+	UserId     string         `json:"user_id"`
+	Name       string         `json:"name"`
+	Scopes     []string       `json:"scopes"`
+	Roles      []*RoleBinding `json:"roles,omitempty"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// NewToken generates a token with a random plaintext value. Only the hash
+// of the plaintext is ever stored, so the plaintext must be handed to the
+// client right after the token is inserted.
+func NewToken(userId string, name string, scopes []string, expiresAt time.Time) (*Token, error) {
+	plaintext, err := generateSecret(TokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{
+		Plaintext: plaintext,
+		Hash:      hashToken(plaintext),
+		UserId:    userId,
+		Name:      strings.TrimSpace(name),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt.UTC(),
+	}
+
+	return token, nil
+}
+
+// HasScope reports whether the token was granted the given scope.
+func (t *Token) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// generateSecret returns a random url-safe string with the given prefix.
+func generateSecret(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hash under which a plaintext secret is stored.
+func hashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+type TokenModel struct {
+	DB Querier
+}
+
+// Insert stores the token along with its role bindings.
+func (m TokenModel) Insert(token *Token) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `
+		INSERT INTO tokens (hash, user_id, name, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	args := []any{
+		token.Hash,
+		token.UserId,
+		token.Name,
+		pq.Array(token.Scopes),
+		token.ExpiresAt,
+	}
+
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&token.Id, &token.CreatedAt); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO role_bindings (user_id, token_id, role, scope_type, scope_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid)
+		RETURNING id, created_at`
+
+	for _, binding := range token.Roles {
+		binding.UserId = token.UserId
+		binding.TokenId = token.Id
+
+		args := []any{
+			binding.UserId,
+			binding.TokenId,
+			binding.Role,
+			binding.ScopeType,
+			binding.ScopeId,
+		}
+
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(&binding.Id, &binding.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetForToken returns the unexpired token matching the given plaintext.
+func (m TokenModel) GetForToken(plaintext string) (*Token, error) {
+	query := `
+		SELECT id, hash, user_id, name, scopes, expires_at, last_used_at, created_at
+		FROM tokens
+		WHERE hash = $1 AND expires_at > now()`
+
+	var token Token
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, hashToken(plaintext)).Scan(
+		&token.Id,
+		&token.Hash,
+		&token.UserId,
+		&token.Name,
+		pq.Array(&token.Scopes),
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &token, nil
+}
+
+var tokenListSpec = listSpec{
+	fields: map[string]listField{
+		"name":       {column: "name", kind: fieldString, sortable: true, filterable: true},
+		"expires_at": {column: "expires_at", kind: fieldTime, sortable: true, filterable: true},
+		"created_at": {column: "created_at", kind: fieldTime, sortable: true, filterable: true},
+	},
+	idColumn:    "id",
+	idKind:      fieldUUID,
+	defaultSort: "created_at",
+}
+
+// ListForUser returns a page of the user tokens, including expired ones.
+func (m TokenModel) ListForUser(userId string, q *event.ListQuery) ([]*Token, string, error) {
+	lq, err := tokenListSpec.build(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	lq.where = append(lq.where, "user_id = "+lq.arg(userId))
+
+	query := lq.sql(`
+		SELECT id, user_id, name, scopes, expires_at, last_used_at, created_at
+		FROM tokens`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, lq.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = rows.Close() }()
+
+	tokens := []*Token{}
+
+	for rows.Next() {
+		var token Token
+
+		err := rows.Scan(
+			&token.Id,
+			&token.UserId,
+			&token.Name,
+			pq.Array(&token.Scopes),
+			&token.ExpiresAt,
+			&token.LastUsedAt,
+			&token.CreatedAt,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+
+		tokens = append(tokens, &token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(tokens) <= lq.limit {
+		return tokens, "", nil
+	}
+
+	tokens = tokens[:lq.limit]
+	last := tokens[len(tokens)-1]
+
+	var value any
+
+	switch lq.sort {
+	case "name":
+		value = last.Name
+	case "expires_at":
+		value = last.ExpiresAt
+	default:
+		value = last.CreatedAt
+	}
+
+	return tokens, lq.nextCursor(value, last.Id), nil
+}
+
+// DeleteForUser revokes the token with the given id, as long as it belongs
+// to the given user.
+func (m TokenModel) DeleteForUser(id string, userId string) error {
+	if !isUUID(id) {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM tokens
+		WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userId)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Touch records the token usage. Writes are throttled to once a minute
+// per token to keep frequently polling clients from hammering the table.
+func (m TokenModel) Touch(id string) error {
+	query := `
+		UPDATE tokens
+		SET last_used_at = now()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+
+	return err
+}
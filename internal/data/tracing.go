@@ -0,0 +1,151 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queries runs queries, it is implemented by sql.DB, sql.Conn and sql.Tx.
+type queries interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// tracedQueries traces queries. The models run their queries with a context
+// of their own, bounding their duration, so the parent of the spans is the
+// span of the parent context, e.g. the one of a request.
+type tracedQueries struct {
+	queries
+	tracer trace.Tracer
+	parent context.Context
+}
+
+func (q *tracedQueries) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := q.start(ctx, query)
+	defer span.End()
+
+	res, err := q.queries.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+
+	return res, err
+}
+
+// QueryContext traces the query until its first rows are available, the
+// time spent reading the rows is not part of the span.
+func (q *tracedQueries) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := q.start(ctx, query)
+	defer span.End()
+
+	rows, err := q.queries.QueryContext(ctx, query, args...)
+	endSpan(span, err)
+
+	return rows, err
+}
+
+func (q *tracedQueries) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := q.start(ctx, query)
+	defer span.End()
+
+	row := q.queries.QueryRowContext(ctx, query, args...)
+	endSpan(span, row.Err())
+
+	return row
+}
+
+func (q *tracedQueries) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	return q.startSpan(ctx, queryOperation(query), attribute.String("db.query.text", query))
+}
+
+func (q *tracedQueries) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if q.parent != nil {
+		ctx = trace.ContextWithSpan(ctx, trace.SpanFromContext(q.parent))
+	}
+
+	return q.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system.name", "postgresql")),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// tracedQuerier traces the queries of the models, and their transactions
+// as the parents of the spans of their queries.
+type tracedQuerier struct {
+	tracedQueries
+	conn conn
+}
+
+func (q *tracedQuerier) PingContext(ctx context.Context) error {
+	return q.conn.PingContext(ctx)
+}
+
+// BeginTx traces the transaction until it is committed or rolled back.
+func (q *tracedQuerier) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	txCtx, span := q.startSpan(ctx, "TRANSACTION")
+
+	tx, err := q.conn.BeginTx(ctx, opts)
+	if err != nil {
+		endSpan(span, err)
+		span.End()
+		return nil, err
+	}
+
+	return &tracedTx{
+		tracedQueries: tracedQueries{queries: tx, tracer: q.tracer, parent: txCtx},
+		tx:            tx,
+		span:          span,
+	}, nil
+}
+
+// tracedTx traces the queries of a transaction as children of its span.
+type tracedTx struct {
+	tracedQueries
+	tx   *sql.Tx
+	span trace.Span
+}
+
+func (tx *tracedTx) Commit() error {
+	err := tx.tx.Commit()
+	tx.end(err)
+
+	return err
+}
+
+// Rollback ends the span of the transaction unless it already ended, the
+// models roll back their transactions deferred, after committing them.
+func (tx *tracedTx) Rollback() error {
+	err := tx.tx.Rollback()
+	if !errors.Is(err, sql.ErrTxDone) {
+		tx.end(err)
+	}
+
+	return err
+}
+
+func (tx *tracedTx) end(err error) {
+	endSpan(tx.span, err)
+	tx.span.End()
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// queryOperation returns the statement of the query, e.g. `SELECT`, naming
+// its span.
+func queryOperation(query string) string {
+	if fields := strings.Fields(query); len(fields) > 0 {
+		return strings.ToUpper(fields[0])
+	}
+	return "QUERY"
+}
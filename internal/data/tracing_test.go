@@ -0,0 +1,152 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// failingConnector connects to a database failing all the queries with its
+// error, transactions begin and commit.
+type failingConnector struct {
+	err error
+}
+
+func (c failingConnector) Connect(context.Context) (driver.Conn, error) {
+	return failingConn(c), nil
+}
+
+func (c failingConnector) Driver() driver.Driver {
+	return nil
+}
+
+type failingConn struct {
+	err error
+}
+
+func (c failingConn) Prepare(string) (driver.Stmt, error) {
+	return nil, c.err
+}
+
+func (c failingConn) Close() error {
+	return nil
+}
+
+func (c failingConn) Begin() (driver.Tx, error) {
+	return failingTx{}, nil
+}
+
+type failingTx struct{}
+
+func (failingTx) Commit() error {
+	return nil
+}
+
+func (failingTx) Rollback() error {
+	return nil
+}
+
+func newFailingQuerier(t *testing.T, tracer trace.Tracer, parent context.Context) *tracedQuerier {
+	t.Helper()
+
+	db := sql.OpenDB(failingConnector{err: errors.New("connection refused")})
+	t.Cleanup(func() { _ = db.Close() })
+
+	return &tracedQuerier{
+		tracedQueries: tracedQueries{queries: db, tracer: tracer, parent: parent},
+		conn:          db,
+	}
+}
+
+func TestTracedQuerier(t *testing.T) {
+	t.Parallel()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+
+	parent, span := tracer.Start(context.Background(), "request")
+	defer span.End()
+
+	q := newFailingQuerier(t, tracer, parent)
+
+	// The models query with a context of their own.
+	_, _ = q.ExecContext(context.Background(), "\n\tDELETE FROM leases WHERE name = $1", "scheduler")
+	_, _ = q.QueryContext(context.Background(), "select 1")
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	for i, name := range []string{"DELETE", "SELECT"} {
+		if spans[i].Name != name {
+			t.Fatalf("expected span %d to be named %s, got %s", i, name, spans[i].Name)
+		}
+
+		if spans[i].Parent.SpanID() != span.SpanContext().SpanID() {
+			t.Fatalf("expected span %d to be a child of the request span", i)
+		}
+
+		if spans[i].Status.Code != codes.Error || spans[i].Status.Description != "connection refused" {
+			t.Fatalf("expected span %d to record the error, got %+v", i, spans[i].Status)
+		}
+	}
+}
+
+func TestTracedQuerierTransaction(t *testing.T) {
+	t.Parallel()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+
+	parent, span := tracer.Start(context.Background(), "request")
+	defer span.End()
+
+	q := newFailingQuerier(t, tracer, parent)
+
+	tx, err := q.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	_, _ = tx.ExecContext(context.Background(), "UPDATE leases SET holder = $1", "replica")
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	// The deferred rollback of the models does not end the span again.
+	if err := tx.Rollback(); !errors.Is(err, sql.ErrTxDone) {
+		t.Fatalf("expected tx done error, got %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	query, transaction := spans[0], spans[1]
+
+	if query.Name != "UPDATE" || transaction.Name != "TRANSACTION" {
+		t.Fatalf("expected UPDATE and TRANSACTION spans, got %s and %s", query.Name, transaction.Name)
+	}
+
+	if query.Parent.SpanID() != transaction.SpanContext.SpanID() {
+		t.Fatal("expected query span to be a child of the transaction span")
+	}
+
+	if transaction.Parent.SpanID() != span.SpanContext().SpanID() {
+		t.Fatal("expected transaction span to be a child of the request span")
+	}
+
+	if query.Status.Code != codes.Error || transaction.Status.Code == codes.Error {
+		t.Fatalf("expected only the query span to record the error, got %+v and %+v", query.Status, transaction.Status)
+	}
+}
@@ -5,27 +5,124 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
 )
 
+var ErrDuplicateEmail = errors.New("duplicate email")
+
 type UserStore interface {
+	Insert(user *User) error
+	GetById(id string) (*User, error)
 	GetByEmail(email string) (*User, error)
+	Update(user *User) error
+	SetOperator(id string, isOperator bool) error
+	SetPassword(id string, passwordHash string) error
+	List(q *event.ListQuery) ([]*User, string, error)
 }
 
+// User is an account of the service. PasswordHash is empty for users that
+// cannot sign in with a password, such as users provisioned by single
+// sign-on. Operators manage the tenants of the service, whatever their own
+// tenant.
 type User struct {
-	Id          string    `json:"id"`
-	Email       string    `json:"email"`
-	IsActivated bool      `json:"is_activated"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	Id           string    `json:"id"`
+	TenantId     string    `json:"tenant_id"`
+	Email        string    `json:"email"`
+	IsActivated  bool      `json:"is_activated"`
+	IsOperator   bool      `json:"is_operator"`
+	Roles        []string  `json:"roles"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB Querier
+}
+
+func (m UserModel) Insert(user *User) error {
+	query := `
+		INSERT INTO users (email, is_activated, roles, password_hash)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING id, tenant_id, created_at, updated_at`
+
+	if user.Roles == nil {
+		user.Roles = []string{}
+	}
+
+	args := []any{
+		user.Email,
+		user.IsActivated,
+		pq.Array(user.Roles),
+		user.PasswordHash,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.Id,
+		&user.TenantId,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Constraint == "users_email_key":
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m UserModel) GetById(id string) (*User, error) {
+	if !isUUID(id) {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, tenant_id, email, is_activated, is_operator, roles, COALESCE(password_hash, ''), created_at, updated_at
+		FROM users
+		WHERE id = $1`
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&user.Id,
+		&user.TenantId,
+		&user.Email,
+		&user.IsActivated,
+		&user.IsOperator,
+		pq.Array(&user.Roles),
+		&user.PasswordHash,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
 }
 
 func (m UserModel) GetByEmail(email string) (*User, error) {
 	query := `
-		SELECT id, email, is_activated, created_at, updated_at
+		SELECT id, tenant_id, email, is_activated, is_operator, roles, COALESCE(password_hash, ''), created_at, updated_at
 		FROM users
 		WHERE email = $1`
 
@@ -36,8 +133,12 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 
 	err := m.DB.QueryRowContext(ctx, query, email).Scan(
 		&user.Id,
+		&user.TenantId,
 		&user.Email,
 		&user.IsActivated,
+		&user.IsOperator,
+		pq.Array(&user.Roles),
+		&user.PasswordHash,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -52,3 +153,180 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 
 	return &user, nil
 }
+
+// Update saves the user activation state and roles.
+func (m UserModel) Update(user *User) error {
+	query := `
+		UPDATE users
+		SET is_activated = $1, roles = $2, updated_at = now()
+		WHERE id = $3
+		RETURNING updated_at`
+
+	if user.Roles == nil {
+		user.Roles = []string{}
+	}
+
+	args := []any{
+		user.IsActivated,
+		pq.Array(user.Roles),
+		user.Id,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetOperator grants or revokes the operator flag of the user.
+func (m UserModel) SetOperator(id string, isOperator bool) error {
+	if !isUUID(id) {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE users
+		SET is_operator = $1, updated_at = now()
+		WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, isOperator, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// SetPassword saves the password hash of the user, an empty hash removing
+// the password so that the user can no longer sign in with one.
+func (m UserModel) SetPassword(id string, passwordHash string) error {
+	if !isUUID(id) {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE users
+		SET password_hash = NULLIF($1, ''), updated_at = now()
+		WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, passwordHash, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+var userListSpec = listSpec{
+	fields: map[string]listField{
+		"email":        {column: "email", kind: fieldString, sortable: true, filterable: true},
+		"is_activated": {column: "is_activated", kind: fieldBool, filterable: true},
+		"created_at":   {column: "created_at", kind: fieldTime, sortable: true, filterable: true},
+		"updated_at":   {column: "updated_at", kind: fieldTime, sortable: true, filterable: true},
+	},
+	idColumn:    "id",
+	idKind:      fieldUUID,
+	defaultSort: "created_at",
+}
+
+// List returns a page of users matching the list query, along with the
+// cursor of the next page or an empty string when it is the last one.
+func (m UserModel) List(q *event.ListQuery) ([]*User, string, error) {
+	lq, err := userListSpec.build(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := lq.sql(`
+		SELECT id, tenant_id, email, is_activated, is_operator, roles, created_at, updated_at
+		FROM users`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, lq.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = rows.Close() }()
+
+	users := []*User{}
+
+	for rows.Next() {
+		var user User
+
+		err := rows.Scan(
+			&user.Id,
+			&user.TenantId,
+			&user.Email,
+			&user.IsActivated,
+			&user.IsOperator,
+			pq.Array(&user.Roles),
+			&user.CreatedAt,
+			&user.UpdatedAt,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(users) <= lq.limit {
+		return users, "", nil
+	}
+
+	users = users[:lq.limit]
+	last := users[len(users)-1]
+
+	var value any
+
+	switch lq.sort {
+	case "email":
+		value = last.Email
+	case "updated_at":
+		value = last.UpdatedAt
+	default:
+		value = last.CreatedAt
+	}
+
+	return users, lq.nextCursor(value, last.Id), nil
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+func TestUserModelList(t *testing.T) {
+	t.Parallel()
+
+	models := newTestModels(t)
+	scoped, cluster := newTestTenant(t, models)
+
+	// The emails are unique across the tenants.
+	domain := "@" + cluster.TenantId + ".example.com"
+
+	for _, name := range []string{"carol", "alice", "bob"} {
+		user := &User{Email: name + domain, IsActivated: name != "bob"}
+		if err := scoped.Users.Insert(user); err != nil {
+			t.Fatalf("failed to insert user; %v", err)
+		}
+	}
+
+	// The users of the other tenants are never listed.
+	if err := models.Users.Insert(&User{Email: "dave" + domain}); err != nil {
+		t.Fatalf("failed to insert user; %v", err)
+	}
+
+	emails := func(users []*User) string {
+		var names []string
+		for _, user := range users {
+			names = append(names, strings.TrimSuffix(user.Email, domain))
+		}
+		return strings.Join(names, ",")
+	}
+
+	q := &event.ListQuery{Limit: 2, Sort: "email", Desc: true}
+
+	users, next, err := scoped.Users.List(q)
+	if err != nil {
+		t.Fatalf("failed to list users; %v", err)
+	}
+
+	if got := emails(users); got != "carol,bob" || next == "" {
+		t.Fatalf("expected first page carol,bob with a cursor, got %s %q", got, next)
+	}
+
+	cursor := next
+	q.Cursor = cursor
+
+	users, next, err = scoped.Users.List(q)
+	if err != nil {
+		t.Fatalf("failed to list users; %v", err)
+	}
+
+	if got := emails(users); got != "alice" || next != "" {
+		t.Fatalf("expected last page alice, got %s %q", got, next)
+	}
+
+	filtered := func(filter event.ListFilter) string {
+		t.Helper()
+
+		users, _, err := scoped.Users.List(&event.ListQuery{
+			Limit:   event.DefaultListLimit,
+			Filters: []event.ListFilter{filter},
+		})
+		if err != nil {
+			t.Fatalf("failed to list users; %v", err)
+		}
+
+		return emails(users)
+	}
+
+	if got := filtered(event.ListFilter{Field: "is_activated", Operator: "eq", Value: "false"}); got != "bob" {
+		t.Fatalf("expected inactive users bob, got %s", got)
+	}
+
+	if got := filtered(event.ListFilter{Field: "email", Operator: "like", Value: "ar"}); got != "carol" {
+		t.Fatalf("expected users matching carol, got %s", got)
+	}
+
+	// The password hashes cannot be filtered on, and a cursor is bound to
+	// the sort it was returned for.
+	invalid := []*event.ListQuery{
+		{Filters: []event.ListFilter{{Field: "password_hash", Operator: "eq", Value: "x"}}},
+		{Limit: 2, Sort: "created_at", Cursor: cursor},
+	}
+
+	for _, q := range invalid {
+		var lqe *ListQueryError
+		if _, _, err := scoped.Users.List(q); !errors.As(err, &lqe) {
+			t.Fatalf("expected list query error, got %v", err)
+		}
+	}
+}
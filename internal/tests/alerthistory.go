@@ -0,0 +1,258 @@
+package tests
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+// AlertHistoryStore is an in-memory data.AlertHistoryStore used by tests.
+type AlertHistoryStore struct {
+	mu          sync.Mutex
+	clusters    *ClusterStore
+	states      []*data.AlertState
+	transitions []*data.AlertTransition
+}
+
+// Transitions returns the recorded transitions, the oldest first.
+func (s *AlertHistoryStore) Transitions() []data.AlertTransition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transitions := []data.AlertTransition{}
+	for _, t := range s.transitions {
+		transitions = append(transitions, *t)
+	}
+
+	return transitions
+}
+
+func (s *AlertHistoryStore) States(clusterId string) ([]*data.AlertState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := []*data.AlertState{}
+
+	for _, state := range s.states {
+		if state.ClusterId == clusterId {
+			c := *state
+			states = append(states, &c)
+		}
+	}
+
+	return states, nil
+}
+
+func (s *AlertHistoryStore) Record(transitions []*data.AlertTransition) error {
+	for _, t := range transitions {
+		cluster, err := s.clusters.GetById(t.ClusterId)
+		if err != nil {
+			continue
+		}
+
+		s.mu.Lock()
+
+		t.Id = newId()
+		t.TenantId = cluster.TenantId
+
+		c := *t
+		s.transitions = append(s.transitions, &c)
+
+		s.states = slices.DeleteFunc(s.states, func(state *data.AlertState) bool {
+			return state.ClusterId == t.ClusterId && state.Fingerprint == t.Fingerprint
+		})
+
+		if t.State != data.AlertStateResolved {
+			s.states = append(s.states, &data.AlertState{
+				ClusterId:   t.ClusterId,
+				Fingerprint: t.Fingerprint,
+				Rule:        t.Rule,
+				Severity:    t.Severity,
+				Labels:      t.Labels,
+				State:       t.State,
+				Since:       t.ObservedAt,
+			})
+		}
+
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (s *AlertHistoryStore) RuleStats(rule string, q *data.StatsQuery) (*data.RuleStats, error) {
+	return s.ruleStats("", rule, q)
+}
+
+func (s *AlertHistoryStore) Noisiest(q *data.StatsQuery, limit int) ([]*data.RuleStats, error) {
+	return s.noisiest("", q, limit), nil
+}
+
+func (s *AlertHistoryStore) ruleStats(tenantId string, rule string, q *data.StatsQuery) (*data.RuleStats, error) {
+	observed := false
+	var lastFiredAt *time.Time
+
+	for _, t := range s.matching(tenantId, rule, q.ClusterIds) {
+		observed = true
+
+		if t.State == data.AlertStateFiring && (lastFiredAt == nil || t.ObservedAt.After(*lastFiredAt)) {
+			at := t.ObservedAt
+			lastFiredAt = &at
+		}
+	}
+
+	if !observed {
+		return nil, data.ErrRecordNotFound
+	}
+
+	stats := &data.RuleStats{Rule: rule}
+
+	for _, st := range s.stats(tenantId, rule, q) {
+		stats = st
+	}
+
+	stats.LastFiredAt = lastFiredAt
+
+	return stats, nil
+}
+
+func (s *AlertHistoryStore) noisiest(tenantId string, q *data.StatsQuery, limit int) []*data.RuleStats {
+	stats := s.stats(tenantId, "", q)
+
+	slices.SortFunc(stats, func(a, b *data.RuleStats) int {
+		return cmp.Or(cmp.Compare(b.FireCount, a.FireCount), cmp.Compare(a.Rule, b.Rule))
+	})
+
+	if len(stats) > limit {
+		stats = stats[:limit]
+	}
+
+	return stats
+}
+
+// matching returns the transitions of the tenant, all of them when empty,
+// the rule, when not empty, and the clusters, when not nil.
+func (s *AlertHistoryStore) matching(tenantId string, rule string, clusterIds []string) []*data.AlertTransition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transitions := []*data.AlertTransition{}
+
+	for _, t := range s.transitions {
+		if (tenantId == "" || t.TenantId == tenantId) &&
+			(rule == "" || t.Rule == rule) &&
+			(clusterIds == nil || slices.Contains(clusterIds, t.ClusterId)) {
+			transitions = append(transitions, t)
+		}
+	}
+
+	return transitions
+}
+
+// stats computes the statistics of the rules that fired during the period
+// of the query, like the data.AlertHistoryModel query.
+func (s *AlertHistoryStore) stats(tenantId string, rule string, q *data.StatsQuery) []*data.RuleStats {
+	type alertKey struct{ clusterId, fingerprint string }
+
+	alerts := map[alertKey][]*data.AlertTransition{}
+	for _, t := range s.matching(tenantId, rule, q.ClusterIds) {
+		key := alertKey{t.ClusterId, t.Fingerprint}
+		alerts[key] = append(alerts[key], t)
+	}
+
+	type ruleTotals struct {
+		stats    *data.RuleStats
+		firing   time.Duration
+		resolved int
+		clusters map[string]bool
+	}
+
+	totals := map[string]*ruleTotals{}
+
+	for _, transitions := range alerts {
+		var resolvedAt *time.Time
+
+		for i, t := range transitions {
+			if t.State == data.AlertStateResolved {
+				at := t.ObservedAt
+				resolvedAt = &at
+				continue
+			}
+
+			if t.State != data.AlertStateFiring || t.ObservedAt.Before(q.Since) {
+				continue
+			}
+
+			rt, ok := totals[t.Rule]
+			if !ok {
+				rt = &ruleTotals{stats: &data.RuleStats{Rule: t.Rule}, clusters: map[string]bool{}}
+				totals[t.Rule] = rt
+			}
+
+			rt.stats.FireCount++
+			rt.clusters[t.ClusterId] = true
+
+			if resolvedAt != nil && t.ObservedAt.Sub(*resolvedAt) < q.FlapInterval {
+				rt.stats.FlapCount++
+			}
+
+			if i+1 < len(transitions) {
+				rt.firing += transitions[i+1].ObservedAt.Sub(t.ObservedAt)
+				rt.resolved++
+			}
+
+			if last := rt.stats.LastFiredAt; last == nil || t.ObservedAt.After(*last) {
+				at := t.ObservedAt
+				rt.stats.LastFiredAt = &at
+			}
+		}
+	}
+
+	stats := []*data.RuleStats{}
+
+	for _, rt := range totals {
+		rt.stats.Clusters = len(rt.clusters)
+		rt.stats.Flappiness = float64(rt.stats.FlapCount) / float64(rt.stats.FireCount)
+
+		if rt.resolved > 0 {
+			rt.stats.MeanTimeFiring = rt.firing.Seconds() / float64(rt.resolved)
+		}
+
+		stats = append(stats, rt.stats)
+	}
+
+	return stats
+}
+
+// tenantAlertHistoryStore only matches the history of the tenant.
+type tenantAlertHistoryStore struct {
+	*AlertHistoryStore
+	clusters *tenantClusterStore
+	tenantId string
+}
+
+func (s *tenantAlertHistoryStore) States(clusterId string) ([]*data.AlertState, error) {
+	if _, err := s.clusters.GetById(clusterId); err != nil {
+		return []*data.AlertState{}, nil
+	}
+	return s.AlertHistoryStore.States(clusterId)
+}
+
+func (s *tenantAlertHistoryStore) Record(transitions []*data.AlertTransition) error {
+	transitions = slices.DeleteFunc(slices.Clone(transitions), func(t *data.AlertTransition) bool {
+		_, err := s.clusters.GetById(t.ClusterId)
+		return err != nil
+	})
+	return s.AlertHistoryStore.Record(transitions)
+}
+
+func (s *tenantAlertHistoryStore) RuleStats(rule string, q *data.StatsQuery) (*data.RuleStats, error) {
+	return s.ruleStats(s.tenantId, rule, q)
+}
+
+func (s *tenantAlertHistoryStore) Noisiest(q *data.StatsQuery, limit int) ([]*data.RuleStats, error) {
+	return s.noisiest(s.tenantId, q, limit), nil
+}
@@ -0,0 +1,297 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+)
+
+// AlertmanagerConfigStore is an in-memory data.AlertmanagerConfigStore used
+// by tests.
+type AlertmanagerConfigStore struct {
+	mu       sync.Mutex
+	clusters *ClusterStore
+	configs  map[string]*data.AlertmanagerConfig
+}
+
+func (s *AlertmanagerConfigStore) GetByClusterId(clusterId string) (*data.AlertmanagerConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config, ok := s.configs[clusterId]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	c := *config
+	return &c, nil
+}
+
+func (s *AlertmanagerConfigStore) Upsert(config *data.AlertmanagerConfig) error {
+	cluster, err := s.clusters.GetById(config.ClusterId)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.configs == nil {
+		s.configs = map[string]*data.AlertmanagerConfig{}
+	}
+
+	config.TenantId = cluster.TenantId
+	config.UpdatedAt = time.Now().UTC()
+	config.SyncedAt = nil
+	config.SyncError = ""
+
+	c := *config
+	s.configs[config.ClusterId] = &c
+
+	return nil
+}
+
+func (s *AlertmanagerConfigStore) UpdateSync(config *data.AlertmanagerConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.configs[config.ClusterId]
+	if !ok {
+		return data.ErrRecordNotFound
+	}
+
+	stored.SyncedAt = config.SyncedAt
+	stored.SyncError = config.SyncError
+
+	return nil
+}
+
+// tenantAlertmanagerConfigStore only matches the configurations of the
+// tenant.
+type tenantAlertmanagerConfigStore struct {
+	*AlertmanagerConfigStore
+	clusters *tenantClusterStore
+}
+
+func (s *tenantAlertmanagerConfigStore) GetByClusterId(clusterId string) (*data.AlertmanagerConfig, error) {
+	if _, err := s.clusters.GetById(clusterId); err != nil {
+		return nil, err
+	}
+	return s.AlertmanagerConfigStore.GetByClusterId(clusterId)
+}
+
+func (s *tenantAlertmanagerConfigStore) Upsert(config *data.AlertmanagerConfig) error {
+	if _, err := s.clusters.GetById(config.ClusterId); err != nil {
+		return err
+	}
+	return s.AlertmanagerConfigStore.Upsert(config)
+}
+
+func (s *tenantAlertmanagerConfigStore) UpdateSync(config *data.AlertmanagerConfig) error {
+	if _, err := s.clusters.GetById(config.ClusterId); err != nil {
+		return err
+	}
+	return s.AlertmanagerConfigStore.UpdateSync(config)
+}
+
+// Alertmanager is a local Alertmanager for tests. On reload, it loads its
+// configuration from the file it was created with, e.g. the one synced by
+// an alertmanager.FileSyncer. It keeps the silences created on it, and
+// serves the alerts it is given, and records the Authorization header of
+// the requests.
+type Alertmanager struct {
+	Server *httptest.Server
+
+	mu             sync.Mutex
+	configFile     string
+	original       string
+	reloads        int
+	silences       []*AlertmanagerSilence
+	alerts         []*alertmanager.Alert
+	authorizations []string
+	delay          time.Duration
+}
+
+// AlertmanagerSilence is a silence created on an Alertmanager.
+type AlertmanagerSilence struct {
+	alertmanager.Silence
+
+	Expired bool
+}
+
+func NewAlertmanager(configFile string) *Alertmanager {
+	am := &Alertmanager{configFile: configFile}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /-/reload", am.reload)
+	mux.HandleFunc("GET /api/v2/status", am.status)
+	mux.HandleFunc("POST /api/v2/silences", am.createSilence)
+	mux.HandleFunc("DELETE /api/v2/silence/{id}", am.expireSilence)
+	mux.HandleFunc("GET /api/v2/alerts", am.listAlerts)
+
+	am.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		am.mu.Lock()
+		am.authorizations = append(am.authorizations, r.Header.Get("Authorization"))
+		delay := am.delay
+		am.mu.Unlock()
+
+		// The body is read first, the context of the request is only done
+		// on a closed connection once it is.
+		b, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(b))
+
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	}))
+
+	return am
+}
+
+func (am *Alertmanager) Close() {
+	am.Server.Close()
+}
+
+// Config returns the configuration the Alertmanager loaded last.
+func (am *Alertmanager) Config() string {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	return am.original
+}
+
+// Reloads returns the number of reloads of the Alertmanager.
+func (am *Alertmanager) Reloads() int {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	return am.reloads
+}
+
+// Silences returns the silences created on the Alertmanager.
+func (am *Alertmanager) Silences() []AlertmanagerSilence {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	silences := []AlertmanagerSilence{}
+	for _, silence := range am.silences {
+		silences = append(silences, *silence)
+	}
+
+	return silences
+}
+
+// Authorizations returns the Authorization header of the requests made to
+// the Alertmanager, empty for the ones without it.
+func (am *Alertmanager) Authorizations() []string {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	return append([]string{}, am.authorizations...)
+}
+
+// SetAlerts sets the alerts served by the Alertmanager.
+func (am *Alertmanager) SetAlerts(alerts ...*alertmanager.Alert) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	am.alerts = alerts
+}
+
+// SetDelay delays the responses of the Alertmanager, e.g. to test slow
+// ones.
+func (am *Alertmanager) SetDelay(delay time.Duration) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	am.delay = delay
+}
+
+func (am *Alertmanager) reload(w http.ResponseWriter, _ *http.Request) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	am.reloads++
+
+	b, err := os.ReadFile(am.configFile)
+	if err != nil {
+		http.Error(w, "failed to reload config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	am.original = string(b)
+}
+
+func (am *Alertmanager) status(w http.ResponseWriter, _ *http.Request) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	resp := map[string]any{
+		"config": map[string]string{"original": am.original},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (am *Alertmanager) createSilence(w http.ResponseWriter, r *http.Request) {
+	var silence alertmanager.Silence
+
+	if err := json.NewDecoder(r.Body).Decode(&silence); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(silence.Matchers) == 0 || silence.Comment == "" || silence.CreatedBy == "" {
+		http.Error(w, "silence invalid", http.StatusBadRequest)
+		return
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	silence.Id = newId()
+	am.silences = append(am.silences, &AlertmanagerSilence{Silence: silence})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"silenceID": silence.Id})
+}
+
+func (am *Alertmanager) expireSilence(w http.ResponseWriter, r *http.Request) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	for _, silence := range am.silences {
+		if silence.Id == r.PathValue("id") {
+			silence.Expired = true
+			return
+		}
+	}
+
+	http.Error(w, "silence not found", http.StatusNotFound)
+}
+
+func (am *Alertmanager) listAlerts(w http.ResponseWriter, _ *http.Request) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	alerts := am.alerts
+	if alerts == nil {
+		alerts = []*alertmanager.Alert{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(alerts)
+}
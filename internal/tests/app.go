@@ -1,29 +1,276 @@
 package tests
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/logging"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/oidc"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/secrets"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/security"
 )
 
 type TestApp struct {
 	*core.BaseApp
+
+	Users            *UserStore
+	Tokens           *TokenStore
+	Sessions         *SessionStore
+	ActivationTokens *ActivationTokenStore
+	Teams            *TeamStore
+	Clusters         *ClusterStore
+	RoleBindings     *RoleBindingStore
+	Audit            *AuditStore
+	Tenants          *TenantStore
+	ChangeRequests   *ChangeRequestStore
+	Silences         *SilenceStore
+	Leases           *LeaseStore
+	TestMailer       *Mailer
+	Logs             *Logs
+
+	AlertmanagerConfigs *AlertmanagerConfigStore
+	MaintenanceWindows  *MaintenanceWindowStore
+	AlertHistory        *AlertHistoryStore
+	Schema              *SchemaStore
+	ClusterCredentials  *ClusterCredentialStore
 }
 
 func NewTestApp() (*TestApp, error) {
+	return NewTestAppWithConfig(TestAppConfig{})
+}
+
+// NewTestAppWithOIDC creates a test app signing users in with the given
+// single sign-on provider, e.g. the one of an OIDCIssuer.
+func NewTestAppWithOIDC(provider *oidc.Provider) (*TestApp, error) {
+	return NewTestAppWithConfig(TestAppConfig{OIDC: provider})
+}
+
+// TestAppConfig holds the optional services of a test app.
+type TestAppConfig struct {
+	OIDC               *oidc.Provider
+	AlertmanagerSyncer alertmanager.Syncer
+
+	// Secrets encrypts the credentials of the clusters, a keyring with a
+	// fixed master key when nil.
+	Secrets *secrets.Keyring
+
+	// TracerProvider records the spans of the app, e.g. one exporting them
+	// to a tracetest.InMemoryExporter.
+	TracerProvider trace.TracerProvider
+
+	// InsecureCookies sends the cookies of the API over plain http too.
+	InsecureCookies bool
+}
+
+func NewTestAppWithConfig(config TestAppConfig) (*TestApp, error) {
 	db := &sql.DB{}
-	logger := slog.New(slog.DiscardHandler)
+	logs := &Logs{}
+	logger := slog.New(logging.NewTraceHandler(slog.NewTextHandler(logs, nil)))
+	mailer := &Mailer{}
+
+	keyring := config.Secrets
+	if keyring == nil {
+		k, err := secrets.NewKeyring(bytes.Repeat([]byte{0x5c}, secrets.KeySize))
+		if err != nil {
+			return nil, err
+		}
+		keyring = k
+	}
+
+	app := core.NewBaseApp(core.BaseAppConfig{
+		DB:     db,
+		Logger: logger,
+		Mailer: mailer,
+		OIDC:   config.OIDC,
 
-	app := core.NewBaseApp(db, logger)
+		AlertmanagerSyncer: config.AlertmanagerSyncer,
+		Secrets:            keyring,
+		TracerProvider:     config.TracerProvider,
+		InsecureCookies:    config.InsecureCookies,
+	})
 
 	if err := app.Bootstrap(); err != nil {
 		return nil, err
 	}
 
+	users := &UserStore{}
+	clusters := &ClusterStore{}
+	bindings := &RoleBindingStore{}
+	tokens := &TokenStore{bindings: bindings}
+	sessions := &SessionStore{}
+
 	t := &TestApp{
-		BaseApp: app,
+		BaseApp:          app,
+		Users:            users,
+		Tokens:           tokens,
+		Sessions:         sessions,
+		ActivationTokens: &ActivationTokenStore{users: users},
+		Teams:            &TeamStore{clusters: clusters},
+		Clusters:         clusters,
+		RoleBindings:     bindings,
+		Audit:            &AuditStore{},
+		Tenants:          newTenantStore(users, tokens, sessions, bindings),
+		ChangeRequests:   &ChangeRequestStore{},
+		Silences:         &SilenceStore{},
+		Leases:           &LeaseStore{},
+		TestMailer:       mailer,
+		Logs:             logs,
+
+		AlertmanagerConfigs: &AlertmanagerConfigStore{clusters: clusters},
+		MaintenanceWindows:  &MaintenanceWindowStore{},
+		AlertHistory:        &AlertHistoryStore{clusters: clusters},
+		Schema:              &SchemaStore{},
+		ClusterCredentials:  &ClusterCredentialStore{clusters: clusters},
 	}
 
+	// Replace the database backed stores with in-memory ones.
+	t.Models().Users = t.Users
+	t.Models().Tokens = t.Tokens
+	t.Models().Sessions = t.Sessions
+	t.Models().ActivationTokens = t.ActivationTokens
+	t.Models().Teams = t.Teams
+	t.Models().Clusters = t.Clusters
+	t.Models().RoleBindings = t.RoleBindings
+	t.Models().Audit = t.Audit
+	t.Models().Tenants = t.Tenants
+	t.Models().ChangeRequests = t.ChangeRequests
+	t.Models().AlertmanagerConfigs = t.AlertmanagerConfigs
+	t.Models().Silences = t.Silences
+	t.Models().MaintenanceWindows = t.MaintenanceWindows
+	t.Models().Leases = t.Leases
+	t.Models().AlertHistory = t.AlertHistory
+	t.Models().Schema = t.Schema
+	t.Models().ClusterCredentials = t.ClusterCredentials
+	t.Models().Scope = t.scope
+	t.Models().Trace = func(context.Context) *data.Models { return t.Models() }
+
 	return t, nil
 }
+
+// NewUser creates an activated user with the given email.
+func (t *TestApp) NewUser(email string) *data.User {
+	user := &data.User{
+		Email:       email,
+		IsActivated: true,
+	}
+
+	if err := t.Users.Insert(user); err != nil {
+		panic(err)
+	}
+
+	return user
+}
+
+// NewUserWithPassword creates an activated user that can sign in with the
+// given email and password.
+func (t *TestApp) NewUserWithPassword(email string, password string) *data.User {
+	hash, err := security.HashPassword(password)
+	if err != nil {
+		panic(err)
+	}
+
+	user := &data.User{
+		Email:        email,
+		IsActivated:  true,
+		PasswordHash: hash,
+	}
+
+	if err := t.Users.Insert(user); err != nil {
+		panic(err)
+	}
+
+	return user
+}
+
+// NewToken creates a token for the user with the given scopes, valid for
+// one hour, and returns its plaintext value.
+func (t *TestApp) NewToken(user *data.User, scopes ...string) string {
+	token, err := data.NewToken(user.Id, "test", scopes, time.Now().Add(time.Hour))
+	if err != nil {
+		panic(err)
+	}
+
+	_ = t.Tokens.Insert(token)
+
+	return token.Plaintext
+}
+
+// NewSession creates a session for the user, valid for one hour.
+func (t *TestApp) NewSession(user *data.User) *data.Session {
+	session, err := data.NewSession(user.Id, time.Hour)
+	if err != nil {
+		panic(err)
+	}
+
+	_ = t.Sessions.Insert(session)
+
+	return session
+}
+
+// NewTeam creates a team with the given name in the default tenant.
+func (t *TestApp) NewTeam(name string) *data.Team {
+	return t.NewTenantTeam(data.DefaultTenantId, name)
+}
+
+// NewTenantTeam creates a team with the given name in the tenant.
+func (t *TestApp) NewTenantTeam(tenantId string, name string) *data.Team {
+	team := &data.Team{TenantId: tenantId, Name: name}
+
+	if err := t.Teams.Insert(team); err != nil {
+		panic(err)
+	}
+
+	return team
+}
+
+// NewCluster creates a cluster owned by the team.
+func (t *TestApp) NewCluster(team *data.Team, name string, labels map[string]string) *data.Cluster {
+	cluster := &data.Cluster{
+		TenantId: team.TenantId,
+		Name:     name,
+		TeamId:   team.Id,
+		Labels:   labels,
+	}
+
+	if err := t.Clusters.Insert(cluster); err != nil {
+		panic(err)
+	}
+
+	return cluster
+}
+
+// NewTenant creates a tenant with the given name.
+func (t *TestApp) NewTenant(name string) *data.Tenant {
+	tenant := &data.Tenant{Name: name}
+
+	if err := t.Tenants.Insert(tenant); err != nil {
+		panic(err)
+	}
+
+	return tenant
+}
+
+// Bind grants the role to the user on the scope, scopeId being empty for
+// the global scope.
+func (t *TestApp) Bind(user *data.User, role string, scopeType string, scopeId string) *data.RoleBinding {
+	binding := &data.RoleBinding{
+		UserId:    user.Id,
+		Role:      role,
+		ScopeType: scopeType,
+		ScopeId:   scopeId,
+	}
+
+	if err := t.RoleBindings.Insert(binding); err != nil {
+		panic(err)
+	}
+
+	return binding
+}
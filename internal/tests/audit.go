@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// AuditStore is an in-memory data.AuditStore used by tests. Inserts fail
+// with the error set by SetError, if any.
+type AuditStore struct {
+	mu     sync.Mutex
+	err    error
+	seq    int64
+	events []*data.AuditEvent
+
+	// heads holds the last event inserted for each tenant.
+	heads map[string]*data.AuditEvent
+}
+
+// SetError makes the inserts fail with err, or succeed again when err is
+// nil.
+func (s *AuditStore) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+func (s *AuditStore) Insert(e *data.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	if s.heads == nil {
+		s.heads = map[string]*data.AuditEvent{}
+	}
+
+	s.seq++
+	e.Seq = s.seq
+	e.Chain(s.heads[e.TenantId].Head())
+
+	s.events = append(s.events, e)
+	s.heads[e.TenantId] = e
+
+	return nil
+}
+
+// List returns all the events, the most recent first, it ignores the list
+// query.
+func (s *AuditStore) List(_ *event.ListQuery) ([]*data.AuditEvent, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := slices.Clone(s.events)
+	slices.Reverse(events)
+
+	return events, "", nil
+}
+
+func (s *AuditStore) Verify() (*data.AuditVerification, error) {
+	return s.verify(func(*data.AuditEvent) bool { return true })
+}
+
+// verify checks the chains of the events matching match, up to their
+// heads.
+func (s *AuditStore) verify(match func(e *data.AuditEvent) bool) (*data.AuditVerification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verifier := data.NewAuditVerifier()
+
+	for _, e := range s.events {
+		if !match(e) {
+			continue
+		}
+
+		if !verifier.Next(e) {
+			return verifier.Result(), nil
+		}
+	}
+
+	heads := []*data.AuditHead{}
+	for _, e := range s.heads {
+		if match(e) {
+			heads = append(heads, e.Head())
+		}
+	}
+
+	verifier.Heads(heads)
+
+	return verifier.Result(), nil
+}
+
+// Events returns the stored events in sequence order, so that tests can
+// tamper with them.
+func (s *AuditStore) Events() []*data.AuditEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.events)
+}
+
+// Delete removes the event at the given index, bypassing the append-only
+// guarantees of the real store. The heads of the chains are left as is.
+func (s *AuditStore) Delete(i int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = slices.Delete(s.events, i, i+1)
+}
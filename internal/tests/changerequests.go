@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// ChangeRequestStore is an in-memory data.ChangeRequestStore used by tests.
+type ChangeRequestStore struct {
+	mu       sync.Mutex
+	requests []*data.ChangeRequest
+	comments []*data.ChangeRequestComment
+}
+
+// withStatus returns a copy of the request with its expiry applied.
+func withStatus(cr *data.ChangeRequest) *data.ChangeRequest {
+	c := *cr
+	if c.Status == data.ChangeRequestPending && !c.ExpiresAt.After(time.Now()) {
+		c.Status = data.ChangeRequestExpired
+	}
+	return &c
+}
+
+func (s *ChangeRequestStore) Insert(cr *data.ChangeRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cr.Id = newId()
+	cr.Status = data.ChangeRequestPending
+	cr.CreatedAt = time.Now().UTC()
+
+	c := *cr
+	s.requests = append(s.requests, &c)
+
+	return nil
+}
+
+func (s *ChangeRequestStore) GetById(id string) (*data.ChangeRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, cr := range s.requests {
+		if cr.Id == id {
+			return withStatus(cr), nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+// List returns all the requests, the most recent first, it ignores the list
+// query.
+func (s *ChangeRequestStore) List(_ *event.ListQuery) ([]*data.ChangeRequest, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	crs := []*data.ChangeRequest{}
+
+	for _, cr := range slices.Backward(s.requests) {
+		crs = append(crs, withStatus(cr))
+	}
+
+	return crs, "", nil
+}
+
+func (s *ChangeRequestStore) UpdateStatus(cr *data.ChangeRequest, from string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.requests {
+		if stored.Id != cr.Id {
+			continue
+		}
+
+		if withStatus(stored).Status != from {
+			return data.ErrChangeRequestClosed
+		}
+
+		stored.Status = cr.Status
+		stored.ReviewedBy = cr.ReviewedBy
+		stored.ReviewedAt = nil
+
+		if cr.ReviewedBy != "" {
+			now := time.Now().UTC()
+			stored.ReviewedAt = &now
+		}
+
+		cr.ReviewedAt = stored.ReviewedAt
+
+		return nil
+	}
+
+	return data.ErrChangeRequestClosed
+}
+
+func (s *ChangeRequestStore) InsertComment(comment *data.ChangeRequestComment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment.Id = newId()
+	comment.CreatedAt = time.Now().UTC()
+
+	s.comments = append(s.comments, comment)
+
+	return nil
+}
+
+func (s *ChangeRequestStore) ListComments(changeRequestId string) ([]*data.ChangeRequestComment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comments := []*data.ChangeRequestComment{}
+
+	for _, comment := range s.comments {
+		if comment.ChangeRequestId == changeRequestId {
+			comments = append(comments, comment)
+		}
+	}
+
+	return comments, nil
+}
+
+// Expire moves the expiry of the request to the past.
+func (s *ChangeRequestStore) Expire(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, cr := range s.requests {
+		if cr.Id == id {
+			cr.ExpiresAt = time.Now().Add(-time.Minute)
+		}
+	}
+}
+
+// tenantChangeRequestStore only matches the change requests of the tenant.
+type tenantChangeRequestStore struct {
+	*ChangeRequestStore
+	tenantId string
+}
+
+func (s *tenantChangeRequestStore) Insert(cr *data.ChangeRequest) error {
+	cr.TenantId = s.tenantId
+	return s.ChangeRequestStore.Insert(cr)
+}
+
+func (s *tenantChangeRequestStore) GetById(id string) (*data.ChangeRequest, error) {
+	cr, err := s.ChangeRequestStore.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if cr.TenantId != s.tenantId {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return cr, nil
+}
+
+func (s *tenantChangeRequestStore) List(q *event.ListQuery) ([]*data.ChangeRequest, string, error) {
+	crs, next, err := s.ChangeRequestStore.List(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	crs = slices.DeleteFunc(crs, func(cr *data.ChangeRequest) bool {
+		return cr.TenantId != s.tenantId
+	})
+
+	return crs, next, nil
+}
+
+func (s *tenantChangeRequestStore) UpdateStatus(cr *data.ChangeRequest, from string) error {
+	if _, err := s.GetById(cr.Id); err != nil {
+		return data.ErrChangeRequestClosed
+	}
+	return s.ChangeRequestStore.UpdateStatus(cr, from)
+}
+
+func (s *tenantChangeRequestStore) InsertComment(comment *data.ChangeRequestComment) error {
+	if _, err := s.GetById(comment.ChangeRequestId); err != nil {
+		return errTenantCheck
+	}
+	return s.ChangeRequestStore.InsertComment(comment)
+}
+
+func (s *tenantChangeRequestStore) ListComments(changeRequestId string) ([]*data.ChangeRequestComment, error) {
+	if _, err := s.GetById(changeRequestId); err != nil {
+		return []*data.ChangeRequestComment{}, nil
+	}
+	return s.ChangeRequestStore.ListComments(changeRequestId)
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+// ClusterCredentialStore is an in-memory data.ClusterCredentialStore used
+// by tests.
+type ClusterCredentialStore struct {
+	mu          sync.Mutex
+	clusters    *ClusterStore
+	credentials map[string]*data.ClusterCredential
+}
+
+func (s *ClusterCredentialStore) GetByClusterId(clusterId string) (*data.ClusterCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credential, ok := s.credentials[clusterId]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	c := *credential
+	return &c, nil
+}
+
+func (s *ClusterCredentialStore) ListNotWrappedWith(keyId string) ([]*data.ClusterCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credentials := []*data.ClusterCredential{}
+
+	for _, credential := range s.credentials {
+		if credential.KeyId != keyId {
+			c := *credential
+			credentials = append(credentials, &c)
+		}
+	}
+
+	slices.SortFunc(credentials, func(a, b *data.ClusterCredential) int {
+		return strings.Compare(a.ClusterId, b.ClusterId)
+	})
+
+	return credentials, nil
+}
+
+func (s *ClusterCredentialStore) Upsert(credential *data.ClusterCredential) error {
+	cluster, err := s.clusters.GetById(credential.ClusterId)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.credentials == nil {
+		s.credentials = map[string]*data.ClusterCredential{}
+	}
+
+	now := time.Now().UTC()
+
+	credential.TenantId = cluster.TenantId
+	credential.CreatedAt = now
+	credential.UpdatedAt = now
+
+	if stored, ok := s.credentials[credential.ClusterId]; ok {
+		credential.CreatedAt = stored.CreatedAt
+	}
+
+	c := *credential
+	s.credentials[credential.ClusterId] = &c
+
+	return nil
+}
+
+func (s *ClusterCredentialStore) UpdateKey(credential *data.ClusterCredential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.credentials[credential.ClusterId]
+	if !ok || !stored.UpdatedAt.Equal(credential.UpdatedAt) {
+		return data.ErrRecordNotFound
+	}
+
+	stored.KeyId = credential.KeyId
+	stored.WrappedKey = credential.WrappedKey
+	stored.Ciphertext = credential.Ciphertext
+
+	return nil
+}
+
+func (s *ClusterCredentialStore) Delete(clusterId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.credentials[clusterId]; !ok {
+		return data.ErrRecordNotFound
+	}
+
+	delete(s.credentials, clusterId)
+
+	return nil
+}
+
+// tenantClusterCredentialStore only matches the credentials of the clusters
+// of the tenant.
+type tenantClusterCredentialStore struct {
+	*ClusterCredentialStore
+	clusters *tenantClusterStore
+}
+
+func (s *tenantClusterCredentialStore) GetByClusterId(clusterId string) (*data.ClusterCredential, error) {
+	if _, err := s.clusters.GetById(clusterId); err != nil {
+		return nil, err
+	}
+	return s.ClusterCredentialStore.GetByClusterId(clusterId)
+}
+
+func (s *tenantClusterCredentialStore) ListNotWrappedWith(keyId string) ([]*data.ClusterCredential, error) {
+	all, err := s.ClusterCredentialStore.ListNotWrappedWith(keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := []*data.ClusterCredential{}
+
+	for _, credential := range all {
+		if _, err := s.clusters.GetById(credential.ClusterId); err == nil {
+			credentials = append(credentials, credential)
+		}
+	}
+
+	return credentials, nil
+}
+
+func (s *tenantClusterCredentialStore) Upsert(credential *data.ClusterCredential) error {
+	if _, err := s.clusters.GetById(credential.ClusterId); err != nil {
+		return err
+	}
+	return s.ClusterCredentialStore.Upsert(credential)
+}
+
+func (s *tenantClusterCredentialStore) UpdateKey(credential *data.ClusterCredential) error {
+	if _, err := s.clusters.GetById(credential.ClusterId); err != nil {
+		return err
+	}
+	return s.ClusterCredentialStore.UpdateKey(credential)
+}
+
+func (s *tenantClusterCredentialStore) Delete(clusterId string) error {
+	if _, err := s.clusters.GetById(clusterId); err != nil {
+		return err
+	}
+	return s.ClusterCredentialStore.Delete(clusterId)
+}
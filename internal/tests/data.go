@@ -0,0 +1,566 @@
+package tests
+
+import (
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// Ensures that the in-memory stores implement the data store interfaces.
+var (
+	_ data.UserStore               = (*UserStore)(nil)
+	_ data.TokenStore              = (*TokenStore)(nil)
+	_ data.SessionStore            = (*SessionStore)(nil)
+	_ data.ActivationTokenStore    = (*ActivationTokenStore)(nil)
+	_ data.TeamStore               = (*TeamStore)(nil)
+	_ data.ClusterStore            = (*ClusterStore)(nil)
+	_ data.RoleBindingStore        = (*RoleBindingStore)(nil)
+	_ data.AlertHistoryStore       = (*AlertHistoryStore)(nil)
+	_ data.AlertmanagerConfigStore = (*AlertmanagerConfigStore)(nil)
+	_ data.AuditStore              = (*AuditStore)(nil)
+	_ data.ChangeRequestStore      = (*ChangeRequestStore)(nil)
+	_ data.ClusterCredentialStore  = (*ClusterCredentialStore)(nil)
+	_ data.MaintenanceWindowStore  = (*MaintenanceWindowStore)(nil)
+	_ data.LeaseStore              = (*LeaseStore)(nil)
+	_ data.SchemaStore             = (*SchemaStore)(nil)
+	_ data.SilenceStore            = (*SilenceStore)(nil)
+	_ data.TenantStore             = (*TenantStore)(nil)
+)
+
+// newId returns a random uuid v4.
+func newId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// UserStore is an in-memory data.UserStore used by tests.
+type UserStore struct {
+	mu    sync.Mutex
+	users []*data.User
+}
+
+func (s *UserStore) Insert(user *data.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == user.Email {
+			return data.ErrDuplicateEmail
+		}
+	}
+
+	if user.Roles == nil {
+		user.Roles = []string{}
+	}
+
+	if user.TenantId == "" {
+		user.TenantId = data.DefaultTenantId
+	}
+
+	user.Id = newId()
+	user.CreatedAt = time.Now().UTC()
+	user.UpdatedAt = user.CreatedAt
+
+	s.users = append(s.users, user)
+
+	return nil
+}
+
+func (s *UserStore) GetById(id string) (*data.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Id == id {
+			return user, nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+func (s *UserStore) GetByEmail(email string) (*data.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+func (s *UserStore) Update(user *data.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, u := range s.users {
+		if u.Id == user.Id {
+			user.UpdatedAt = time.Now().UTC()
+			s.users[i] = user
+			return nil
+		}
+	}
+
+	return data.ErrRecordNotFound
+}
+
+func (s *UserStore) SetOperator(id string, isOperator bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Id == id {
+			user.IsOperator = isOperator
+			user.UpdatedAt = time.Now().UTC()
+			return nil
+		}
+	}
+
+	return data.ErrRecordNotFound
+}
+
+func (s *UserStore) SetPassword(id string, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Id == id {
+			user.PasswordHash = passwordHash
+			user.UpdatedAt = time.Now().UTC()
+			return nil
+		}
+	}
+
+	return data.ErrRecordNotFound
+}
+
+// List returns all the users, it ignores the list query.
+func (s *UserStore) List(_ *event.ListQuery) ([]*data.User, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.users), "", nil
+}
+
+// TokenStore is an in-memory data.TokenStore used by tests.
+type TokenStore struct {
+	mu       sync.Mutex
+	bindings *RoleBindingStore
+	tokens   []*data.Token
+}
+
+func (s *TokenStore) Insert(token *data.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.Id = newId()
+	token.CreatedAt = time.Now().UTC()
+
+	for _, binding := range token.Roles {
+		binding.UserId = token.UserId
+		binding.TokenId = token.Id
+
+		if err := s.bindings.Insert(binding); err != nil {
+			return err
+		}
+	}
+
+	s.tokens = append(s.tokens, token)
+
+	return nil
+}
+
+func (s *TokenStore) GetForToken(plaintext string) (*data.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.tokens {
+		if token.Plaintext == plaintext && token.ExpiresAt.After(time.Now()) {
+			return token, nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+// ListForUser returns all the user tokens, it ignores the list query.
+func (s *TokenStore) ListForUser(userId string, _ *event.ListQuery) ([]*data.Token, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := []*data.Token{}
+
+	for _, token := range s.tokens {
+		if token.UserId == userId {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens, "", nil
+}
+
+func (s *TokenStore) DeleteForUser(id string, userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, token := range s.tokens {
+		if token.Id == id && token.UserId == userId {
+			s.tokens = slices.Delete(s.tokens, i, i+1)
+			return nil
+		}
+	}
+
+	return data.ErrRecordNotFound
+}
+
+func (s *TokenStore) Touch(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.tokens {
+		if token.Id == id {
+			now := time.Now().UTC()
+			token.LastUsedAt = &now
+		}
+	}
+
+	return nil
+}
+
+// SessionStore is an in-memory data.SessionStore used by tests.
+type SessionStore struct {
+	mu       sync.Mutex
+	sessions []*data.Session
+}
+
+func (s *SessionStore) Insert(session *data.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.Id = newId()
+	session.CreatedAt = time.Now().UTC()
+
+	s.sessions = append(s.sessions, session)
+
+	return nil
+}
+
+func (s *SessionStore) GetForToken(plaintext string) (*data.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.Plaintext == plaintext && session.ExpiresAt.After(time.Now()) {
+			return session, nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+func (s *SessionStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions = slices.DeleteFunc(s.sessions, func(session *data.Session) bool {
+		return session.Id == id
+	})
+
+	return nil
+}
+
+// ActivationTokenStore is an in-memory data.ActivationTokenStore used by tests.
+type ActivationTokenStore struct {
+	mu     sync.Mutex
+	users  *UserStore
+	tokens []*data.ActivationToken
+}
+
+func (s *ActivationTokenStore) Insert(token *data.ActivationToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = append(s.tokens, token)
+
+	return nil
+}
+
+func (s *ActivationTokenStore) Activate(plaintext string) (*data.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.tokens {
+		if token.Plaintext != plaintext || !token.ExpiresAt.After(time.Now()) {
+			continue
+		}
+
+		user, err := s.users.GetById(token.UserId)
+		if err != nil {
+			return nil, err
+		}
+
+		user.IsActivated = true
+
+		s.tokens = slices.DeleteFunc(s.tokens, func(t *data.ActivationToken) bool {
+			return t.UserId == user.Id
+		})
+
+		return user, nil
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+// TeamStore is an in-memory data.TeamStore used by tests.
+type TeamStore struct {
+	mu       sync.Mutex
+	clusters *ClusterStore
+	teams    []*data.Team
+}
+
+func (s *TeamStore) Insert(team *data.Team) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.teams {
+		if t.TenantId == team.TenantId && t.Name == team.Name {
+			return data.ErrDuplicateTeamName
+		}
+	}
+
+	team.Id = newId()
+	team.CreatedAt = time.Now().UTC()
+
+	s.teams = append(s.teams, team)
+
+	return nil
+}
+
+func (s *TeamStore) GetById(id string) (*data.Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, team := range s.teams {
+		if team.Id == id {
+			return team, nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+// List returns all the teams, it ignores the list query.
+func (s *TeamStore) List(_ *event.ListQuery) ([]*data.Team, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.teams), "", nil
+}
+
+func (s *TeamStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clusters.mu.Lock()
+	owns := slices.ContainsFunc(s.clusters.clusters, func(c *data.Cluster) bool {
+		return c.TeamId == id
+	})
+	s.clusters.mu.Unlock()
+
+	for i, team := range s.teams {
+		if team.Id != id {
+			continue
+		}
+
+		if owns {
+			return data.ErrTeamNotEmpty
+		}
+
+		s.teams = slices.Delete(s.teams, i, i+1)
+		return nil
+	}
+
+	return data.ErrRecordNotFound
+}
+
+// ClusterStore is an in-memory data.ClusterStore used by tests.
+type ClusterStore struct {
+	mu       sync.Mutex
+	clusters []*data.Cluster
+}
+
+func (s *ClusterStore) Insert(cluster *data.Cluster) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.clusters {
+		if c.TenantId == cluster.TenantId && c.Name == cluster.Name {
+			return data.ErrDuplicateClusterName
+		}
+	}
+
+	if cluster.Labels == nil {
+		cluster.Labels = map[string]string{}
+	}
+
+	cluster.Id = newId()
+	cluster.CreatedAt = time.Now().UTC()
+	cluster.UpdatedAt = cluster.CreatedAt
+
+	s.clusters = append(s.clusters, cluster)
+
+	return nil
+}
+
+func (s *ClusterStore) GetById(id string) (*data.Cluster, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, cluster := range s.clusters {
+		if cluster.Id == id {
+			c := *cluster
+			return &c, nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+// List returns all the clusters, it ignores the list query.
+func (s *ClusterStore) List(_ *event.ListQuery) ([]*data.Cluster, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.clusters), "", nil
+}
+
+func (s *ClusterStore) Update(cluster *data.Cluster) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.clusters {
+		if c.Id != cluster.Id && c.TenantId == cluster.TenantId && c.Name == cluster.Name {
+			return data.ErrDuplicateClusterName
+		}
+	}
+
+	for i, c := range s.clusters {
+		if c.Id == cluster.Id {
+			cluster.UpdatedAt = time.Now().UTC()
+			s.clusters[i] = cluster
+			return nil
+		}
+	}
+
+	return data.ErrRecordNotFound
+}
+
+func (s *ClusterStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, cluster := range s.clusters {
+		if cluster.Id == id {
+			s.clusters = slices.Delete(s.clusters, i, i+1)
+			return nil
+		}
+	}
+
+	return data.ErrRecordNotFound
+}
+
+// RoleBindingStore is an in-memory data.RoleBindingStore used by tests.
+type RoleBindingStore struct {
+	mu       sync.Mutex
+	bindings []*data.RoleBinding
+}
+
+func (s *RoleBindingStore) Insert(binding *data.RoleBinding) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range s.bindings {
+		if b.UserId == binding.UserId && b.TokenId == binding.TokenId && b.Role == binding.Role &&
+			b.ScopeType == binding.ScopeType && b.ScopeId == binding.ScopeId {
+			return data.ErrDuplicateRoleBinding
+		}
+	}
+
+	binding.Id = newId()
+	binding.CreatedAt = time.Now().UTC()
+
+	s.bindings = append(s.bindings, binding)
+
+	return nil
+}
+
+func (s *RoleBindingStore) GetById(id string) (*data.RoleBinding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, binding := range s.bindings {
+		if binding.Id == id {
+			return binding, nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+func (s *RoleBindingStore) ListForUser(userId string) ([]*data.RoleBinding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bindings := []*data.RoleBinding{}
+
+	for _, binding := range s.bindings {
+		if binding.UserId == userId {
+			bindings = append(bindings, binding)
+		}
+	}
+
+	return bindings, nil
+}
+
+// List returns all the user bindings, it ignores the list query.
+func (s *RoleBindingStore) List(_ *event.ListQuery) ([]*data.RoleBinding, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bindings := []*data.RoleBinding{}
+
+	for _, binding := range s.bindings {
+		if binding.TokenId == "" {
+			bindings = append(bindings, binding)
+		}
+	}
+
+	return bindings, "", nil
+}
+
+func (s *RoleBindingStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, binding := range s.bindings {
+		if binding.Id == id {
+			s.bindings = slices.Delete(s.bindings, i, i+1)
+			return nil
+		}
+	}
+
+	return data.ErrRecordNotFound
+}
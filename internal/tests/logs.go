@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// Logs is an io.Writer that records the app log lines.
+type Logs struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *Logs) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.buf.Write(p)
+}
+
+// Contains reports whether any log line contains all the given substrings.
+func (l *Logs) Contains(substrs ...string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for line := range strings.Lines(l.buf.String()) {
+		found := true
+		for _, s := range substrs {
+			if !strings.Contains(line, s) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,31 @@
+package tests
+
+import (
+	"sync"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/mailer"
+)
+
+// Mailer is a mailer.Mailer that records the sent messages instead of
+// delivering them.
+type Mailer struct {
+	mu       sync.Mutex
+	messages []*mailer.Message
+}
+
+func (m *Mailer) Send(message *mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, message)
+
+	return nil
+}
+
+// Messages returns the messages sent so far.
+func (m *Mailer) Messages() []*mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]*mailer.Message{}, m.messages...)
+}
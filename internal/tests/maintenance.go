@@ -0,0 +1,275 @@
+package tests
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// MaintenanceWindowStore is an in-memory data.MaintenanceWindowStore used by
+// tests.
+type MaintenanceWindowStore struct {
+	mu      sync.Mutex
+	windows []*data.MaintenanceWindow
+}
+
+func copyMaintenanceWindow(window *data.MaintenanceWindow) *data.MaintenanceWindow {
+	c := *window
+	return &c
+}
+
+func (s *MaintenanceWindowStore) Insert(window *data.MaintenanceWindow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range s.windows {
+		if w.TenantId == window.TenantId && w.Name == window.Name {
+			return data.ErrDuplicateMaintenanceWindowName
+		}
+	}
+
+	if window.ClusterSelector == nil {
+		window.ClusterSelector = map[string]string{}
+	}
+
+	window.Id = newId()
+	window.CreatedAt = time.Now().UTC()
+	window.UpdatedAt = window.CreatedAt
+
+	s.windows = append(s.windows, copyMaintenanceWindow(window))
+
+	return nil
+}
+
+func (s *MaintenanceWindowStore) GetById(id string) (*data.MaintenanceWindow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, window := range s.windows {
+		if window.Id == id {
+			return copyMaintenanceWindow(window), nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+// List returns all the windows, it ignores the list query.
+func (s *MaintenanceWindowStore) List(_ *event.ListQuery) ([]*data.MaintenanceWindow, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	windows := []*data.MaintenanceWindow{}
+	for _, window := range s.windows {
+		windows = append(windows, copyMaintenanceWindow(window))
+	}
+
+	return windows, "", nil
+}
+
+func (s *MaintenanceWindowStore) ListDue(before time.Time) ([]*data.MaintenanceWindow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	windows := []*data.MaintenanceWindow{}
+
+	for _, window := range s.windows {
+		if window.Enabled && window.NextRunAt != nil && !window.NextRunAt.After(before) {
+			windows = append(windows, copyMaintenanceWindow(window))
+		}
+	}
+
+	slices.SortFunc(windows, func(a, b *data.MaintenanceWindow) int {
+		return a.NextRunAt.Compare(*b.NextRunAt)
+	})
+
+	return windows, nil
+}
+
+func (s *MaintenanceWindowStore) Update(window *data.MaintenanceWindow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, w := range s.windows {
+		if w.Id != window.Id {
+			continue
+		}
+
+		for _, other := range s.windows {
+			if other.Id != w.Id && other.TenantId == w.TenantId && other.Name == window.Name {
+				return data.ErrDuplicateMaintenanceWindowName
+			}
+		}
+
+		window.UpdatedAt = time.Now().UTC()
+
+		s.windows[i] = copyMaintenanceWindow(window)
+
+		return nil
+	}
+
+	return data.ErrRecordNotFound
+}
+
+func (s *MaintenanceWindowStore) Advance(window *data.MaintenanceWindow, next *time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range s.windows {
+		if w.Id != window.Id {
+			continue
+		}
+
+		switch {
+		case w.NextRunAt == nil && window.NextRunAt == nil:
+		case w.NextRunAt == nil || window.NextRunAt == nil || !w.NextRunAt.Equal(*window.NextRunAt):
+			return false, nil
+		}
+
+		w.NextRunAt = next
+		window.NextRunAt = next
+
+		return true, nil
+	}
+
+	return false, data.ErrRecordNotFound
+}
+
+func (s *MaintenanceWindowStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, window := range s.windows {
+		if window.Id == id {
+			s.windows = slices.Delete(s.windows, i, i+1)
+			return nil
+		}
+	}
+
+	return data.ErrRecordNotFound
+}
+
+// tenantMaintenanceWindowStore only matches the windows of the tenant.
+type tenantMaintenanceWindowStore struct {
+	*MaintenanceWindowStore
+	tenantId string
+}
+
+func (s *tenantMaintenanceWindowStore) Insert(window *data.MaintenanceWindow) error {
+	window.TenantId = s.tenantId
+	return s.MaintenanceWindowStore.Insert(window)
+}
+
+func (s *tenantMaintenanceWindowStore) GetById(id string) (*data.MaintenanceWindow, error) {
+	window, err := s.MaintenanceWindowStore.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if window.TenantId != s.tenantId {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return window, nil
+}
+
+func (s *tenantMaintenanceWindowStore) List(q *event.ListQuery) ([]*data.MaintenanceWindow, string, error) {
+	windows, next, err := s.MaintenanceWindowStore.List(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	windows = slices.DeleteFunc(windows, func(w *data.MaintenanceWindow) bool {
+		return w.TenantId != s.tenantId
+	})
+
+	return windows, next, nil
+}
+
+func (s *tenantMaintenanceWindowStore) ListDue(before time.Time) ([]*data.MaintenanceWindow, error) {
+	windows, err := s.MaintenanceWindowStore.ListDue(before)
+	if err != nil {
+		return nil, err
+	}
+
+	windows = slices.DeleteFunc(windows, func(w *data.MaintenanceWindow) bool {
+		return w.TenantId != s.tenantId
+	})
+
+	return windows, nil
+}
+
+func (s *tenantMaintenanceWindowStore) Update(window *data.MaintenanceWindow) error {
+	if _, err := s.GetById(window.Id); err != nil {
+		return err
+	}
+	return s.MaintenanceWindowStore.Update(window)
+}
+
+func (s *tenantMaintenanceWindowStore) Advance(window *data.MaintenanceWindow, next *time.Time) (bool, error) {
+	if _, err := s.GetById(window.Id); err != nil {
+		return false, err
+	}
+	return s.MaintenanceWindowStore.Advance(window, next)
+}
+
+func (s *tenantMaintenanceWindowStore) Delete(id string) error {
+	if _, err := s.GetById(id); err != nil {
+		return err
+	}
+	return s.MaintenanceWindowStore.Delete(id)
+}
+
+// LeaseStore is an in-memory data.LeaseStore used by tests.
+type LeaseStore struct {
+	mu     sync.Mutex
+	leases map[string]*lease
+}
+
+type lease struct {
+	holder    string
+	expiresAt time.Time
+}
+
+func (s *LeaseStore) Acquire(name string, holder string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.leases == nil {
+		s.leases = map[string]*lease{}
+	}
+
+	now := time.Now()
+
+	if l, ok := s.leases[name]; ok && l.holder != holder && l.expiresAt.After(now) {
+		return false, nil
+	}
+
+	s.leases[name] = &lease{holder: holder, expiresAt: now.Add(ttl)}
+
+	return true, nil
+}
+
+func (s *LeaseStore) Release(name string, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, ok := s.leases[name]; ok && l.holder == holder {
+		delete(s.leases, name)
+	}
+
+	return nil
+}
+
+// Expire expires the lease, as if its holder stopped renewing it.
+func (s *LeaseStore) Expire(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, ok := s.leases[name]; ok {
+		l.expiresAt = time.Now().Add(-time.Second)
+	}
+}
@@ -0,0 +1,245 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/oidc"
+)
+
+const (
+	OIDCClientId     = "scopehouse"
+	OIDCClientSecret = "secret"
+	OIDCRedirectUrl  = "https://scopehouse.local/api/v1/auth/oidc/callback"
+)
+
+// OIDCIdentity holds the claims of the id tokens issued by the mock issuer.
+// The email_verified claim is omitted when EmailVerified is nil.
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified any
+	Groups        []string
+}
+
+// OIDCIssuer is a local OpenID Connect issuer for tests. Its authorization
+// endpoint immediately redirects back with a code for the current identity,
+// and its token endpoint enforces the PKCE S256 challenge.
+type OIDCIssuer struct {
+	Server *httptest.Server
+
+	mu       sync.Mutex
+	identity OIDCIdentity
+	codes    map[string]oidcCode
+	key      *rsa.PrivateKey
+}
+
+type oidcCode struct {
+	challenge string
+	nonce     string
+	identity  OIDCIdentity
+}
+
+func NewOIDCIssuer() *OIDCIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	issuer := &OIDCIssuer{
+		codes: map[string]oidcCode{},
+		key:   key,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("GET /keys", issuer.keys)
+	mux.HandleFunc("GET /authorize", issuer.authorize)
+	mux.HandleFunc("POST /token", issuer.token)
+
+	issuer.Server = httptest.NewServer(mux)
+
+	return issuer
+}
+
+func (i *OIDCIssuer) Close() {
+	i.Server.Close()
+}
+
+// SetIdentity sets the identity of the next users to sign in.
+func (i *OIDCIssuer) SetIdentity(identity OIDCIdentity) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.identity = identity
+}
+
+// Provider returns a provider for the issuer, mapping groups to roles.
+func (i *OIDCIssuer) Provider(groupRoles map[string]string) *oidc.Provider {
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		IssuerUrl:    i.Server.URL,
+		ClientId:     OIDCClientId,
+		ClientSecret: OIDCClientSecret,
+		RedirectUrl:  OIDCRedirectUrl,
+		GroupRoles:   groupRoles,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return provider
+}
+
+func (i *OIDCIssuer) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJson(w, http.StatusOK, map[string]any{
+		"issuer":                                i.Server.URL,
+		"authorization_endpoint":                i.Server.URL + "/authorize",
+		"token_endpoint":                        i.Server.URL + "/token",
+		"jwks_uri":                              i.Server.URL + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (i *OIDCIssuer) keys(w http.ResponseWriter, _ *http.Request) {
+	writeJson(w, http.StatusOK, jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{{
+			Key:       &i.key.PublicKey,
+			KeyID:     "test",
+			Algorithm: string(jose.RS256),
+			Use:       "sig",
+		}},
+	})
+}
+
+func (i *OIDCIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("client_id") != OIDCClientId || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := newId()
+
+	i.mu.Lock()
+	i.codes[code] = oidcCode{
+		challenge: query.Get("code_challenge"),
+		nonce:     query.Get("nonce"),
+		identity:  i.identity,
+	}
+	i.mu.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect uri", http.StatusBadRequest)
+		return
+	}
+
+	redirect.RawQuery = url.Values{
+		"code":  {code},
+		"state": {query.Get("state")},
+	}.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (i *OIDCIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid token request", http.StatusBadRequest)
+		return
+	}
+
+	clientId, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientId, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	if clientId != OIDCClientId || clientSecret != OIDCClientSecret {
+		writeJson(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	i.mu.Lock()
+	code, ok := i.codes[r.PostForm.Get("code")]
+	delete(i.codes, r.PostForm.Get("code"))
+	i.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := i.sign(code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, http.StatusOK, map[string]any{
+		"access_token": newId(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (i *OIDCIssuer) sign(code oidcCode) (string, error) {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: i.key, KeyID: "test"}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	claims := map[string]any{
+		"iss":    i.Server.URL,
+		"sub":    code.identity.Subject,
+		"aud":    OIDCClientId,
+		"iat":    now.Unix(),
+		"exp":    now.Add(time.Hour).Unix(),
+		"nonce":  code.nonce,
+		"email":  code.identity.Email,
+		"groups": code.identity.Groups,
+	}
+
+	if code.identity.EmailVerified != nil {
+		claims["email_verified"] = code.identity.EmailVerified
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return signed.CompactSerialize()
+}
+
+func writeJson(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
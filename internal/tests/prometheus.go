@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tools/prometheus"
+)
+
+// Prometheus is a local Prometheus for tests, serving the alerts it is
+// given.
+type Prometheus struct {
+	Server *httptest.Server
+
+	mu     sync.Mutex
+	alerts []*prometheus.Alert
+}
+
+func NewPrometheus() *Prometheus {
+	p := &Prometheus{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/alerts", p.listAlerts)
+
+	p.Server = httptest.NewServer(mux)
+
+	return p
+}
+
+func (p *Prometheus) Close() {
+	p.Server.Close()
+}
+
+// SetAlerts sets the alerts served by the Prometheus.
+func (p *Prometheus) SetAlerts(alerts ...*prometheus.Alert) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.alerts = alerts
+}
+
+func (p *Prometheus) listAlerts(w http.ResponseWriter, _ *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	alerts := p.alerts
+	if alerts == nil {
+		alerts = []*prometheus.Alert{}
+	}
+
+	resp := map[string]any{
+		"status": "success",
+		"data":   map[string]any{"alerts": alerts},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
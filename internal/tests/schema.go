@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/migrations"
+)
+
+// SchemaStore is an in-memory data.SchemaStore used by tests. The database
+// is up, and migrated to the latest version, until told otherwise.
+type SchemaStore struct {
+	mu      sync.Mutex
+	err     error
+	version *data.SchemaVersion
+}
+
+// SetError makes the database unreachable with err, or reachable again when
+// err is nil.
+func (s *SchemaStore) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+// SetVersion sets the version the schema is migrated to.
+func (s *SchemaStore) SetVersion(version uint, dirty bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.version = &data.SchemaVersion{Version: version, Dirty: dirty}
+}
+
+func (s *SchemaStore) Ping(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	return ctx.Err()
+}
+
+func (s *SchemaStore) Version(ctx context.Context) (*data.SchemaVersion, error) {
+	if err := s.Ping(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.version != nil {
+		v := *s.version
+		return &v, nil
+	}
+
+	latest, err := migrations.Latest()
+	if err != nil {
+		return nil, err
+	}
+
+	return &data.SchemaVersion{Version: latest}, nil
+}
@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// SilenceStore is an in-memory data.SilenceStore used by tests.
+type SilenceStore struct {
+	mu       sync.Mutex
+	silences []*data.Silence
+}
+
+// copySilence returns a copy of the silence with its status applied.
+func copySilence(silence *data.Silence) *data.Silence {
+	c := *silence
+	c.Targets = []*data.SilenceTarget{}
+
+	for _, target := range silence.Targets {
+		t := *target
+		c.Targets = append(c.Targets, &t)
+	}
+
+	now := time.Now()
+
+	switch {
+	case c.ExpiredAt != nil || !c.EndsAt.After(now):
+		c.Status = data.SilenceExpired
+	case c.StartsAt.After(now):
+		c.Status = data.SilencePending
+	default:
+		c.Status = data.SilenceActive
+	}
+
+	return &c
+}
+
+func (s *SilenceStore) Insert(silence *data.Silence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if silence.ClusterSelector == nil {
+		silence.ClusterSelector = map[string]string{}
+	}
+
+	for _, stored := range s.silences {
+		if silence.MaintenanceWindowId != "" &&
+			stored.MaintenanceWindowId == silence.MaintenanceWindowId &&
+			stored.StartsAt.Equal(silence.StartsAt) {
+			return data.ErrDuplicateSilence
+		}
+	}
+
+	silence.Id = newId()
+	silence.CreatedAt = time.Now().UTC()
+
+	for _, target := range silence.Targets {
+		target.SilenceId = silence.Id
+		target.Status = data.SilenceTargetPending
+		target.UpdatedAt = silence.CreatedAt
+	}
+
+	c := copySilence(silence)
+	silence.Status = c.Status
+
+	s.silences = append(s.silences, c)
+
+	return nil
+}
+
+func (s *SilenceStore) GetById(id string) (*data.Silence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, silence := range s.silences {
+		if silence.Id == id {
+			return copySilence(silence), nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+// List returns all the silences, the most recent first, it ignores the list
+// query.
+func (s *SilenceStore) List(_ *event.ListQuery) ([]*data.Silence, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	silences := []*data.Silence{}
+
+	for _, silence := range slices.Backward(s.silences) {
+		silences = append(silences, copySilence(silence))
+	}
+
+	return silences, "", nil
+}
+
+func (s *SilenceStore) ListUnsynced() ([]*data.Silence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	silences := []*data.Silence{}
+
+	for _, silence := range s.silences {
+		c := copySilence(silence)
+
+		if c.MaintenanceWindowId == "" || c.Status == data.SilenceExpired {
+			continue
+		}
+
+		for _, target := range c.Targets {
+			if target.Status == data.SilenceTargetPending || target.Status == data.SilenceTargetFailed {
+				silences = append(silences, c)
+				break
+			}
+		}
+	}
+
+	return silences, nil
+}
+
+func (s *SilenceStore) UpdateTarget(target *data.SilenceTarget) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, silence := range s.silences {
+		if silence.Id != target.SilenceId {
+			continue
+		}
+
+		for _, stored := range silence.Targets {
+			if stored.ClusterId == target.ClusterId {
+				target.UpdatedAt = time.Now().UTC()
+
+				*stored = *target
+				return nil
+			}
+		}
+	}
+
+	return data.ErrRecordNotFound
+}
+
+func (s *SilenceStore) Expire(silence *data.Silence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.silences {
+		if stored.Id != silence.Id {
+			continue
+		}
+
+		if stored.ExpiredAt == nil {
+			now := time.Now().UTC()
+			stored.ExpiredAt = &now
+		}
+
+		silence.ExpiredAt = stored.ExpiredAt
+		silence.Status = data.SilenceExpired
+
+		return nil
+	}
+
+	return data.ErrRecordNotFound
+}
+
+// tenantSilenceStore only matches the silences of the tenant.
+type tenantSilenceStore struct {
+	*SilenceStore
+	tenantId string
+}
+
+func (s *tenantSilenceStore) Insert(silence *data.Silence) error {
+	silence.TenantId = s.tenantId
+	return s.SilenceStore.Insert(silence)
+}
+
+func (s *tenantSilenceStore) GetById(id string) (*data.Silence, error) {
+	silence, err := s.SilenceStore.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if silence.TenantId != s.tenantId {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return silence, nil
+}
+
+func (s *tenantSilenceStore) List(q *event.ListQuery) ([]*data.Silence, string, error) {
+	silences, next, err := s.SilenceStore.List(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	silences = slices.DeleteFunc(silences, func(silence *data.Silence) bool {
+		return silence.TenantId != s.tenantId
+	})
+
+	return silences, next, nil
+}
+
+func (s *tenantSilenceStore) ListUnsynced() ([]*data.Silence, error) {
+	silences, err := s.SilenceStore.ListUnsynced()
+	if err != nil {
+		return nil, err
+	}
+
+	silences = slices.DeleteFunc(silences, func(silence *data.Silence) bool {
+		return silence.TenantId != s.tenantId
+	})
+
+	return silences, nil
+}
+
+func (s *tenantSilenceStore) UpdateTarget(target *data.SilenceTarget) error {
+	if _, err := s.GetById(target.SilenceId); err != nil {
+		return err
+	}
+	return s.SilenceStore.UpdateTarget(target)
+}
+
+func (s *tenantSilenceStore) Expire(silence *data.Silence) error {
+	if _, err := s.GetById(silence.Id); err != nil {
+		return err
+	}
+	return s.SilenceStore.Expire(silence)
+}
@@ -0,0 +1,445 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/event"
+)
+
+// TenantStore is an in-memory data.TenantStore used by tests. It starts with
+// the default tenant.
+type TenantStore struct {
+	mu       sync.Mutex
+	users    *UserStore
+	tokens   *TokenStore
+	sessions *SessionStore
+	bindings *RoleBindingStore
+	tenants  []*data.Tenant
+}
+
+func newTenantStore(users *UserStore, tokens *TokenStore, sessions *SessionStore, bindings *RoleBindingStore) *TenantStore {
+	return &TenantStore{
+		users:    users,
+		tokens:   tokens,
+		sessions: sessions,
+		bindings: bindings,
+		tenants: []*data.Tenant{
+			{Id: data.DefaultTenantId, Name: "default", CreatedAt: time.Now().UTC()},
+		},
+	}
+}
+
+func (s *TenantStore) Insert(tenant *data.Tenant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tenants {
+		if t.Name == tenant.Name {
+			return data.ErrDuplicateTenantName
+		}
+	}
+
+	tenant.Id = newId()
+	tenant.CreatedAt = time.Now().UTC()
+
+	s.tenants = append(s.tenants, tenant)
+
+	return nil
+}
+
+func (s *TenantStore) GetById(id string) (*data.Tenant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tenant := range s.tenants {
+		if tenant.Id == id {
+			return tenant, nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+// List returns all the tenants, it ignores the list query.
+func (s *TenantStore) List(_ *event.ListQuery) ([]*data.Tenant, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.tenants), "", nil
+}
+
+func (s *TenantStore) AddUser(tenantId string, userId string, role string) error {
+	if _, err := s.GetById(tenantId); err != nil {
+		return err
+	}
+
+	user, err := s.users.GetById(userId)
+	if err != nil {
+		return err
+	}
+
+	if user.TenantId != tenantId {
+		user.TenantId = tenantId
+		user.IsOperator = false
+
+		s.bindings.mu.Lock()
+		s.bindings.bindings = slices.DeleteFunc(s.bindings.bindings, func(b *data.RoleBinding) bool {
+			return b.UserId == userId
+		})
+		s.bindings.mu.Unlock()
+
+		s.tokens.mu.Lock()
+		s.tokens.tokens = slices.DeleteFunc(s.tokens.tokens, func(t *data.Token) bool {
+			return t.UserId == userId
+		})
+		s.tokens.mu.Unlock()
+
+		s.sessions.mu.Lock()
+		s.sessions.sessions = slices.DeleteFunc(s.sessions.sessions, func(session *data.Session) bool {
+			return session.UserId == userId
+		})
+		s.sessions.mu.Unlock()
+	}
+
+	if role != "" {
+		binding := &data.RoleBinding{
+			UserId:    userId,
+			Role:      role,
+			ScopeType: data.ScopeTypeGlobal,
+		}
+
+		err := s.bindings.Insert(binding)
+		if err != nil && !errors.Is(err, data.ErrDuplicateRoleBinding) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scope returns the in-memory models restricted to the tenant, standing in
+// for the row level security policies of the database. The stores of the
+// tokens, sessions and activation tokens are shared, their queries are
+// already bound to a user.
+func (t *TestApp) scope(tenantId string) (*data.Models, func(), error) {
+	if _, err := t.Tenants.GetById(tenantId); err != nil {
+		return nil, nil, err
+	}
+
+	users := &tenantUserStore{UserStore: t.Users, tenantId: tenantId}
+	clusters := &tenantClusterStore{ClusterStore: t.Clusters, tenantId: tenantId}
+
+	models := &data.Models{
+		Users:            users,
+		Tokens:           t.Tokens,
+		Sessions:         t.Sessions,
+		ActivationTokens: t.ActivationTokens,
+		Teams:            &tenantTeamStore{TeamStore: t.Teams, tenantId: tenantId},
+		Clusters:         clusters,
+		RoleBindings:     &tenantRoleBindingStore{RoleBindingStore: t.RoleBindings, users: users},
+		Audit:            &tenantAuditStore{AuditStore: t.Audit, tenantId: tenantId},
+		Tenants:          &tenantTenantStore{TenantStore: t.Tenants, tenantId: tenantId},
+		ChangeRequests:   &tenantChangeRequestStore{ChangeRequestStore: t.ChangeRequests, tenantId: tenantId},
+		Silences:         &tenantSilenceStore{SilenceStore: t.Silences, tenantId: tenantId},
+		Leases:           t.Leases,
+
+		AlertmanagerConfigs: &tenantAlertmanagerConfigStore{
+			AlertmanagerConfigStore: t.AlertmanagerConfigs,
+			clusters:                clusters,
+		},
+		MaintenanceWindows: &tenantMaintenanceWindowStore{
+			MaintenanceWindowStore: t.MaintenanceWindows,
+			tenantId:               tenantId,
+		},
+		AlertHistory: &tenantAlertHistoryStore{
+			AlertHistoryStore: t.AlertHistory,
+			clusters:          clusters,
+			tenantId:          tenantId,
+		},
+		ClusterCredentials: &tenantClusterCredentialStore{
+			ClusterCredentialStore: t.ClusterCredentials,
+			clusters:               clusters,
+		},
+		Schema: t.Schema,
+		Scope: func(string) (*data.Models, func(), error) {
+			return nil, nil, data.ErrScoped
+		},
+	}
+
+	// The in-memory stores run no query to trace.
+	models.Trace = func(context.Context) *data.Models {
+		return models
+	}
+
+	return models, func() {}, nil
+}
+
+// errTenantCheck stands in for the row level security check violations of
+// the database.
+var errTenantCheck = errors.New("new row violates row-level security policy")
+
+type tenantUserStore struct {
+	*UserStore
+	tenantId string
+}
+
+func (s *tenantUserStore) Insert(user *data.User) error {
+	user.TenantId = s.tenantId
+	return s.UserStore.Insert(user)
+}
+
+func (s *tenantUserStore) GetById(id string) (*data.User, error) {
+	user, err := s.UserStore.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TenantId != s.tenantId {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return user, nil
+}
+
+func (s *tenantUserStore) GetByEmail(email string) (*data.User, error) {
+	user, err := s.UserStore.GetByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TenantId != s.tenantId {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return user, nil
+}
+
+func (s *tenantUserStore) Update(user *data.User) error {
+	if _, err := s.GetById(user.Id); err != nil {
+		return err
+	}
+	return s.UserStore.Update(user)
+}
+
+func (s *tenantUserStore) List(q *event.ListQuery) ([]*data.User, string, error) {
+	users, next, err := s.UserStore.List(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	users = slices.DeleteFunc(users, func(u *data.User) bool {
+		return u.TenantId != s.tenantId
+	})
+
+	return users, next, nil
+}
+
+type tenantTeamStore struct {
+	*TeamStore
+	tenantId string
+}
+
+func (s *tenantTeamStore) Insert(team *data.Team) error {
+	team.TenantId = s.tenantId
+	return s.TeamStore.Insert(team)
+}
+
+func (s *tenantTeamStore) GetById(id string) (*data.Team, error) {
+	team, err := s.TeamStore.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if team.TenantId != s.tenantId {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return team, nil
+}
+
+func (s *tenantTeamStore) List(q *event.ListQuery) ([]*data.Team, string, error) {
+	teams, next, err := s.TeamStore.List(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	teams = slices.DeleteFunc(teams, func(t *data.Team) bool {
+		return t.TenantId != s.tenantId
+	})
+
+	return teams, next, nil
+}
+
+func (s *tenantTeamStore) Delete(id string) error {
+	if _, err := s.GetById(id); err != nil {
+		return err
+	}
+	return s.TeamStore.Delete(id)
+}
+
+type tenantClusterStore struct {
+	*ClusterStore
+	tenantId string
+}
+
+func (s *tenantClusterStore) Insert(cluster *data.Cluster) error {
+	cluster.TenantId = s.tenantId
+	return s.ClusterStore.Insert(cluster)
+}
+
+func (s *tenantClusterStore) GetById(id string) (*data.Cluster, error) {
+	cluster, err := s.ClusterStore.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if cluster.TenantId != s.tenantId {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return cluster, nil
+}
+
+func (s *tenantClusterStore) List(q *event.ListQuery) ([]*data.Cluster, string, error) {
+	clusters, next, err := s.ClusterStore.List(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	clusters = slices.DeleteFunc(clusters, func(c *data.Cluster) bool {
+		return c.TenantId != s.tenantId
+	})
+
+	return clusters, next, nil
+}
+
+func (s *tenantClusterStore) Update(cluster *data.Cluster) error {
+	if _, err := s.GetById(cluster.Id); err != nil {
+		return err
+	}
+	return s.ClusterStore.Update(cluster)
+}
+
+func (s *tenantClusterStore) Delete(id string) error {
+	if _, err := s.GetById(id); err != nil {
+		return err
+	}
+	return s.ClusterStore.Delete(id)
+}
+
+// tenantRoleBindingStore only matches the bindings of the users of the
+// tenant.
+type tenantRoleBindingStore struct {
+	*RoleBindingStore
+	users *tenantUserStore
+}
+
+func (s *tenantRoleBindingStore) member(userId string) bool {
+	_, err := s.users.GetById(userId)
+	return err == nil
+}
+
+func (s *tenantRoleBindingStore) Insert(binding *data.RoleBinding) error {
+	if !s.member(binding.UserId) {
+		return errTenantCheck
+	}
+	return s.RoleBindingStore.Insert(binding)
+}
+
+func (s *tenantRoleBindingStore) GetById(id string) (*data.RoleBinding, error) {
+	binding, err := s.RoleBindingStore.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.member(binding.UserId) {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return binding, nil
+}
+
+func (s *tenantRoleBindingStore) ListForUser(userId string) ([]*data.RoleBinding, error) {
+	if !s.member(userId) {
+		return []*data.RoleBinding{}, nil
+	}
+	return s.RoleBindingStore.ListForUser(userId)
+}
+
+func (s *tenantRoleBindingStore) List(q *event.ListQuery) ([]*data.RoleBinding, string, error) {
+	bindings, next, err := s.RoleBindingStore.List(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	bindings = slices.DeleteFunc(bindings, func(b *data.RoleBinding) bool {
+		return !s.member(b.UserId)
+	})
+
+	return bindings, next, nil
+}
+
+func (s *tenantRoleBindingStore) Delete(id string) error {
+	if _, err := s.GetById(id); err != nil {
+		return err
+	}
+	return s.RoleBindingStore.Delete(id)
+}
+
+// tenantAuditStore only lists and verifies the events of the tenant.
+type tenantAuditStore struct {
+	*AuditStore
+	tenantId string
+}
+
+func (s *tenantAuditStore) List(q *event.ListQuery) ([]*data.AuditEvent, string, error) {
+	events, next, err := s.AuditStore.List(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	events = slices.DeleteFunc(events, func(e *data.AuditEvent) bool {
+		return e.TenantId != s.tenantId
+	})
+
+	return events, next, nil
+}
+
+func (s *tenantAuditStore) Verify() (*data.AuditVerification, error) {
+	return s.verify(func(e *data.AuditEvent) bool {
+		return e.TenantId == s.tenantId
+	})
+}
+
+type tenantTenantStore struct {
+	*TenantStore
+	tenantId string
+}
+
+func (s *tenantTenantStore) Insert(*data.Tenant) error {
+	return errTenantCheck
+}
+
+func (s *tenantTenantStore) GetById(id string) (*data.Tenant, error) {
+	if id != s.tenantId {
+		return nil, data.ErrRecordNotFound
+	}
+	return s.TenantStore.GetById(id)
+}
+
+func (s *tenantTenantStore) List(q *event.ListQuery) ([]*data.Tenant, string, error) {
+	tenant, err := s.TenantStore.GetById(s.tenantId)
+	if err != nil {
+		return nil, "", err
+	}
+	return []*data.Tenant{tenant}, "", nil
+}
+
+func (s *tenantTenantStore) AddUser(string, string, string) error {
+	return errTenantCheck
+}
@@ -0,0 +1,210 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxErrorBodyBytes bounds the part of the error responses of Alertmanager
+// that is read into error messages.
+const maxErrorBodyBytes = 1024
+
+// Client calls the API of an Alertmanager.
+type Client struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewClient returns a client of the Alertmanager listening on baseUrl, e.g.
+// `http://alertmanager:9093`.
+func NewClient(baseUrl string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		url:        strings.TrimSuffix(baseUrl, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// ApiError is an error response of the Alertmanager API.
+type ApiError struct {
+	Method string
+	Path   string
+	Status int
+	Body   string
+}
+
+func (e *ApiError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status %d: %s", e.Method, e.Path, e.Status, e.Body)
+}
+
+// Status is the status of an Alertmanager.
+type Status struct {
+	Config struct {
+		// Original is the configuration file the Alertmanager loaded.
+		Original string `json:"original"`
+	} `json:"config"`
+	VersionInfo map[string]string `json:"versionInfo"`
+}
+
+// Reload has the Alertmanager reload its configuration file.
+func (c *Client) Reload(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/-/reload", nil, nil)
+}
+
+// Status returns the status of the Alertmanager.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.do(ctx, http.MethodGet, "/api/v2/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// SilenceMatcher is a matcher of a silence, as in the Alertmanager API.
+type SilenceMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+// Silence mutes the alerts matching all its matchers between its start and
+// end.
+type Silence struct {
+	Id        string           `json:"id,omitempty"`
+	Matchers  []SilenceMatcher `json:"matchers"`
+	StartsAt  time.Time        `json:"startsAt"`
+	EndsAt    time.Time        `json:"endsAt"`
+	CreatedBy string           `json:"createdBy"`
+	Comment   string           `json:"comment"`
+}
+
+// SilenceMatchers converts the matchers to the ones of a silence.
+func SilenceMatchers(matchers []*Matcher) []SilenceMatcher {
+	sm := make([]SilenceMatcher, 0, len(matchers))
+
+	for _, m := range matchers {
+		sm = append(sm, SilenceMatcher{
+			Name:    m.Name,
+			Value:   m.Value,
+			IsRegex: m.Type == MatchRegexp || m.Type == MatchNotRegexp,
+			IsEqual: m.Type == MatchEqual || m.Type == MatchRegexp,
+		})
+	}
+
+	return sm
+}
+
+// CreateSilence creates the silence, and returns its id.
+func (c *Client) CreateSilence(ctx context.Context, silence *Silence) (string, error) {
+	var resp struct {
+		SilenceId string `json:"silenceID"`
+	}
+
+	if err := c.do(ctx, http.MethodPost, "/api/v2/silences", silence, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.SilenceId, nil
+}
+
+// ExpireSilence expires the silence with the given id. Silences that no
+// longer exist are considered expired.
+func (c *Client) ExpireSilence(ctx context.Context, id string) error {
+	err := c.do(ctx, http.MethodDelete, "/api/v2/silence/"+url.PathEscape(id), nil, nil)
+
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// Alert is an alert of an Alertmanager.
+type Alert struct {
+	Fingerprint  string            `json:"fingerprint"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorUrl string            `json:"generatorURL"`
+	Status       AlertStatus       `json:"status"`
+}
+
+// AlertStatus tells whether an alert is `active`, `suppressed` by silences
+// or inhibitions, or still `unprocessed`.
+type AlertStatus struct {
+	State       string   `json:"state"`
+	SilencedBy  []string `json:"silencedBy"`
+	InhibitedBy []string `json:"inhibitedBy"`
+}
+
+// Alerts returns the alerts of the Alertmanager, the suppressed ones
+// included.
+func (c *Client) Alerts(ctx context.Context) ([]*Alert, error) {
+	var alerts []*Alert
+	if err := c.do(ctx, http.MethodGet, "/api/v2/alerts", nil, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// do sends the request and decodes the json response into out, when not
+// nil. Responses with a status other than 2xx are returned as errors.
+func (c *Client) do(ctx context.Context, method string, path string, body any, out any) error {
+	var reader io.Reader
+
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = strings.NewReader(string(b))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, reader)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodyBytes))
+		return &ApiError{
+			Method: method,
+			Path:   path,
+			Status: res.StatusCode,
+			Body:   strings.TrimSpace(string(msg)),
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: invalid response; %v", method, path, err)
+	}
+
+	return nil
+}
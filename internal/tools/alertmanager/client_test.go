@@ -0,0 +1,127 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientSilences(t *testing.T) {
+	t.Parallel()
+
+	var created Silence
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v2/silences", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&created)
+		_, _ = w.Write([]byte(`{"silenceID": "abc"}`))
+	})
+	mux.HandleFunc("DELETE /api/v2/silence/{id}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("id") {
+		case "abc":
+		case "gone":
+			http.Error(w, "silence not found", http.StatusNotFound)
+		default:
+			http.Error(w, "silence already expired", http.StatusInternalServerError)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", nil)
+
+	matchers, err := ParseMatchers([]string{`alertname="X"`, `env!="dev"`, `team=~"a|b"`, `job!~"node.*"`})
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	now := time.Now().UTC()
+
+	id, err := client.CreateSilence(context.Background(), &Silence{
+		Matchers:  SilenceMatchers(matchers),
+		StartsAt:  now,
+		EndsAt:    now.Add(time.Hour),
+		CreatedBy: "test",
+		Comment:   "test",
+	})
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	if id != "abc" {
+		t.Fatalf("expected silence id to be abc, got %q", id)
+	}
+
+	expected := []SilenceMatcher{
+		{Name: "alertname", Value: "X", IsEqual: true},
+		{Name: "env", Value: "dev"},
+		{Name: "team", Value: "a|b", IsRegex: true, IsEqual: true},
+		{Name: "job", Value: "node.*", IsRegex: true},
+	}
+
+	for i, m := range expected {
+		if created.Matchers[i] != m {
+			t.Fatalf("expected matcher %+v, got %+v", m, created.Matchers[i])
+		}
+	}
+
+	if err := client.ExpireSilence(context.Background(), "abc"); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	// Silences that no longer exist are expired.
+	if err := client.ExpireSilence(context.Background(), "gone"); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	err = client.ExpireSilence(context.Background(), "other")
+
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusInternalServerError {
+		t.Fatalf("expected api error with status 500, got %v", err)
+	}
+}
+
+func TestClientAlerts(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v2/alerts", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{
+			"fingerprint": "f1",
+			"labels": {"alertname": "HighLatency", "severity": "critical"},
+			"annotations": {"summary": "Latency is high"},
+			"startsAt": "2030-01-01T00:00:00Z",
+			"endsAt": "2030-01-01T01:00:00Z",
+			"generatorURL": "http://prometheus/graph",
+			"status": {"state": "suppressed", "silencedBy": ["s1"], "inhibitedBy": []}
+		}]`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	alerts, err := NewClient(server.URL, nil).Alerts(context.Background())
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(alerts))
+	}
+
+	alert := alerts[0]
+
+	if alert.Fingerprint != "f1" || alert.Labels["severity"] != "critical" || alert.Annotations["summary"] != "Latency is high" {
+		t.Fatalf("unexpected alert %+v", alert)
+	}
+
+	if alert.Status.State != "suppressed" || len(alert.Status.SilencedBy) != 1 || alert.GeneratorUrl != "http://prometheus/graph" {
+		t.Fatalf("unexpected alert status %+v", alert)
+	}
+}
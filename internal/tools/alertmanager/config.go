@@ -0,0 +1,364 @@
+package alertmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"text/template"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// TemplatesDir is the directory of the templates, relative to the rendered
+// configuration file.
+const TemplatesDir = "templates"
+
+// Integrations lists the receiver integrations supported by Alertmanager.
+var Integrations = []string{
+	"discord_configs",
+	"email_configs",
+	"jira_configs",
+	"msteams_configs",
+	"msteamsv2_configs",
+	"opsgenie_configs",
+	"pagerduty_configs",
+	"pushover_configs",
+	"rocketchat_configs",
+	"slack_configs",
+	"sns_configs",
+	"telegram_configs",
+	"victorops_configs",
+	"webex_configs",
+	"webhook_configs",
+	"wechat_configs",
+}
+
+// templateNameRegex matches valid template file names.
+var templateNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+\.tmpl$`)
+
+// Config is the routing configuration of an Alertmanager: its routing tree,
+// receivers, inhibition rules and notification templates.
+type Config struct {
+	Route        *Route         `json:"route" yaml:"route"`
+	Receivers    []*Receiver    `json:"receivers" yaml:"receivers"`
+	InhibitRules []*InhibitRule `json:"inhibit_rules,omitempty" yaml:"inhibit_rules,omitempty"`
+
+	// Templates holds the notification templates by file name, e.g.
+	// `slack.tmpl`.
+	Templates map[string]string `json:"templates,omitempty" yaml:"-"`
+}
+
+// Route is a node of the routing tree. Alerts are routed to the receiver of
+// the deepest route matching them.
+type Route struct {
+	Receiver       string   `json:"receiver,omitempty" yaml:"receiver,omitempty"`
+	GroupBy        []string `json:"group_by,omitempty" yaml:"group_by,omitempty"`
+	Matchers       []string `json:"matchers,omitempty" yaml:"matchers,omitempty"`
+	Continue       bool     `json:"continue,omitempty" yaml:"continue,omitempty"`
+	GroupWait      string   `json:"group_wait,omitempty" yaml:"group_wait,omitempty"`
+	GroupInterval  string   `json:"group_interval,omitempty" yaml:"group_interval,omitempty"`
+	RepeatInterval string   `json:"repeat_interval,omitempty" yaml:"repeat_interval,omitempty"`
+	Routes         []*Route `json:"routes,omitempty" yaml:"routes,omitempty"`
+}
+
+// Receiver is a named set of notification integrations. Receivers without
+// integrations discard the alerts routed to them.
+type Receiver struct {
+	Name string `yaml:"name"`
+
+	// Integrations holds the configs of the receiver integrations by kind,
+	// e.g. `slack_configs`. They are passed as is to Alertmanager.
+	Integrations map[string][]map[string]any `yaml:",inline"`
+}
+
+// MarshalJSON writes the receiver integrations along with its name, as in
+// the Alertmanager configuration.
+func (r *Receiver) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(r.Integrations)+1)
+	for kind, configs := range r.Integrations {
+		m[kind] = configs
+	}
+	m["name"] = r.Name
+
+	return json.Marshal(m)
+}
+
+func (r *Receiver) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	*r = Receiver{Integrations: map[string][]map[string]any{}}
+
+	for key, value := range m {
+		if key == "name" {
+			if err := json.Unmarshal(value, &r.Name); err != nil {
+				return receiverTypeError(key, r.Name)
+			}
+			continue
+		}
+
+		var configs []map[string]any
+		if err := json.Unmarshal(value, &configs); err != nil {
+			return receiverTypeError(key, configs)
+		}
+
+		r.Integrations[key] = configs
+	}
+
+	return nil
+}
+
+// receiverTypeError reports a receiver field of the wrong type, the same way
+// the json decoder reports the fields of structs.
+func receiverTypeError(field string, expected any) error {
+	return &json.UnmarshalTypeError{
+		Value: "value",
+		Type:  reflect.TypeOf(expected),
+		Field: "receivers." + field,
+	}
+}
+
+// IsNull reports whether the receiver has no integration, so that alerts
+// routed to it are never notified.
+func (r *Receiver) IsNull() bool {
+	for _, configs := range r.Integrations {
+		if len(configs) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// InhibitRule mutes the alerts matching the target matchers while an alert
+// matching the source matchers fires, both having equal values for the
+// labels of Equal.
+type InhibitRule struct {
+	SourceMatchers []string `json:"source_matchers,omitempty" yaml:"source_matchers,omitempty"`
+	TargetMatchers []string `json:"target_matchers,omitempty" yaml:"target_matchers,omitempty"`
+	Equal          []string `json:"equal,omitempty" yaml:"equal,omitempty"`
+}
+
+// Receiver returns the receiver with the given name, or nil when there is
+// none.
+func (c *Config) Receiver(name string) *Receiver {
+	for _, r := range c.Receivers {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Validate checks the configuration as Alertmanager would load it, and
+// returns the first problem found.
+func (c *Config) Validate() error {
+	names := map[string]bool{}
+
+	for i, r := range c.Receivers {
+		if r == nil || r.Name == "" {
+			return fmt.Errorf("receiver %d has no name", i)
+		}
+
+		if names[r.Name] {
+			return fmt.Errorf("receiver %q is defined more than once", r.Name)
+		}
+		names[r.Name] = true
+
+		for _, kind := range slices.Sorted(maps.Keys(r.Integrations)) {
+			configs := r.Integrations[kind]
+
+			if !slices.Contains(Integrations, kind) {
+				return fmt.Errorf("receiver %q has an unknown integration %q", r.Name, kind)
+			}
+
+			for _, config := range configs {
+				if config == nil {
+					return fmt.Errorf("receiver %q has an empty %s entry", r.Name, kind)
+				}
+			}
+		}
+	}
+
+	if c.Route == nil {
+		return errors.New("route is required")
+	}
+
+	if c.Route.Receiver == "" {
+		return errors.New("root route must have a receiver")
+	}
+
+	if len(c.Route.Matchers) > 0 {
+		return errors.New("root route must not have matchers")
+	}
+
+	if c.Route.Continue {
+		return errors.New("root route must not have continue")
+	}
+
+	if err := c.validateRoute(c.Route, "route", names); err != nil {
+		return err
+	}
+
+	for i, rule := range c.InhibitRules {
+		if rule == nil {
+			return fmt.Errorf("inhibit rule %d is empty", i)
+		}
+
+		if _, err := ParseMatchers(rule.SourceMatchers); err != nil {
+			return fmt.Errorf("inhibit rule %d: %v", i, err)
+		}
+
+		if _, err := ParseMatchers(rule.TargetMatchers); err != nil {
+			return fmt.Errorf("inhibit rule %d: %v", i, err)
+		}
+
+		for _, name := range rule.Equal {
+			if !labelNameRegex.MatchString(name) {
+				return fmt.Errorf("inhibit rule %d has an invalid equal label %q", i, name)
+			}
+		}
+	}
+
+	for _, name := range c.TemplateNames() {
+		text := c.Templates[name]
+
+		if !templateNameRegex.MatchString(name) {
+			return fmt.Errorf("template name %q must only contain letters, digits, - and _ and end with .tmpl", name)
+		}
+
+		if _, err := template.New(name).Funcs(templateFuncs).Parse(text); err != nil {
+			return fmt.Errorf("template %q: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validateRoute(route *Route, path string, receivers map[string]bool) error {
+	if route.Receiver != "" && !receivers[route.Receiver] {
+		return fmt.Errorf("%s: receiver %q is not defined", path, route.Receiver)
+	}
+
+	if _, err := ParseMatchers(route.Matchers); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	for _, name := range route.GroupBy {
+		if name == "..." {
+			if len(route.GroupBy) > 1 {
+				return fmt.Errorf("%s: group_by ... must be used alone", path)
+			}
+			continue
+		}
+
+		if !labelNameRegex.MatchString(name) {
+			return fmt.Errorf("%s: invalid group_by label %q", path, name)
+		}
+	}
+
+	intervals := map[string]string{
+		"group_wait":      route.GroupWait,
+		"group_interval":  route.GroupInterval,
+		"repeat_interval": route.RepeatInterval,
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(intervals)) {
+		value := intervals[key]
+		if value == "" {
+			continue
+		}
+
+		d, err := ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", path, key, err)
+		}
+
+		if d == 0 && key != "group_wait" {
+			return fmt.Errorf("%s: %s must be greater than zero", path, key)
+		}
+	}
+
+	for i, child := range route.Routes {
+		if child == nil {
+			return fmt.Errorf("%s.routes[%d] is empty", path, i)
+		}
+
+		if err := c.validateRoute(child, fmt.Sprintf("%s.routes[%d]", path, i), receivers); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// templateFuncs stands in for the functions Alertmanager provides to the
+// templates, so that they can be parsed.
+var templateFuncs = template.FuncMap{
+	"toUpper":          templateFunc,
+	"toLower":          templateFunc,
+	"title":            templateFunc,
+	"trimSpace":        templateFunc,
+	"join":             templateFunc,
+	"match":            templateFunc,
+	"safeHtml":         templateFunc,
+	"safeUrl":          templateFunc,
+	"urlUnescape":      templateFunc,
+	"reReplaceAll":     templateFunc,
+	"stringSlice":      templateFunc,
+	"date":             templateFunc,
+	"tz":               templateFunc,
+	"since":            templateFunc,
+	"humanizeDuration": templateFunc,
+	"toJson":           templateFunc,
+}
+
+func templateFunc(...any) any {
+	return nil
+}
+
+// Render returns the configuration file of the Alertmanager. Templates are
+// loaded from TemplatesDir, next to the configuration file.
+func (c *Config) Render() ([]byte, error) {
+	file := struct {
+		Route        *Route         `yaml:"route"`
+		Receivers    []*Receiver    `yaml:"receivers"`
+		InhibitRules []*InhibitRule `yaml:"inhibit_rules,omitempty"`
+		Templates    []string       `yaml:"templates,omitempty"`
+	}{
+		Route:        c.Route,
+		Receivers:    c.Receivers,
+		InhibitRules: c.InhibitRules,
+	}
+
+	if len(c.Templates) > 0 {
+		file.Templates = []string{TemplatesDir + "/*.tmpl"}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Managed by ScopeHouse, changes made to this file are overwritten.\n")
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(file); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// TemplateNames returns the names of the templates, sorted.
+func (c *Config) TemplateNames() []string {
+	return slices.Sorted(maps.Keys(c.Templates))
+}
@@ -0,0 +1,226 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `{
+	"route": {
+		"receiver": "default",
+		"group_by": ["alertname"],
+		"group_wait": "30s",
+		"routes": [
+			{"receiver": "pager", "matchers": ["severity=\"critical\""], "repeat_interval": "1h"}
+		]
+	},
+	"receivers": [
+		{"name": "default"},
+		{"name": "pager", "pagerduty_configs": [{"routing_key": "key", "send_resolved": true}]}
+	],
+	"inhibit_rules": [
+		{"source_matchers": ["severity=critical"], "target_matchers": ["severity=warning"], "equal": ["alertname"]}
+	],
+	"templates": {"pager.tmpl": "{{ define \"pager.title\" }}{{ .Status | toUpper }}{{ end }}"}
+}`
+
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+
+	var config Config
+	if err := json.Unmarshal([]byte(testConfig), &config); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	return &config
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		change func(c *Config)
+		errMsg string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"missing route", func(c *Config) { c.Route = nil }, "route is required"},
+		{"root without receiver", func(c *Config) { c.Route.Receiver = "" }, "root route must have a receiver"},
+		{"root matchers", func(c *Config) { c.Route.Matchers = []string{"a=b"} }, "root route must not have matchers"},
+		{"undefined receiver", func(c *Config) { c.Route.Routes[0].Receiver = "other" }, `route.routes[0]: receiver "other" is not defined`},
+		{"duplicate receiver", func(c *Config) { c.Receivers[1].Name = "default" }, `receiver "default" is defined more than once`},
+		{"unknown integration", func(c *Config) {
+			c.Receivers[0].Integrations["fax_configs"] = []map[string]any{{}}
+		}, `unknown integration "fax_configs"`},
+		{"invalid matcher", func(c *Config) { c.Route.Routes[0].Matchers = []string{"severity"} }, "has no operator"},
+		{"invalid regex", func(c *Config) { c.Route.Routes[0].Matchers = []string{`env=~"("`} }, "invalid regular expression"},
+		{"invalid group by", func(c *Config) { c.Route.GroupBy = []string{"...", "env"} }, "must be used alone"},
+		{"invalid duration", func(c *Config) { c.Route.GroupWait = "30 seconds" }, "group_wait"},
+		{"zero interval", func(c *Config) { c.Route.RepeatInterval = "0s" }, "repeat_interval must be greater than zero"},
+		{"invalid inhibit matcher", func(c *Config) { c.InhibitRules[0].TargetMatchers = []string{"=x"} }, "inhibit rule 0"},
+		{"invalid template name", func(c *Config) { c.Templates["../x.tmpl"] = "" }, "template name"},
+		{"invalid template", func(c *Config) { c.Templates["pager.tmpl"] = "{{ .Status " }, `template "pager.tmpl"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := newTestConfig(t)
+			tc.change(config)
+
+			err := config.Validate()
+
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Fatalf("expected error to be nil, got %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+				t.Fatalf("expected error to contain %q, got %v", tc.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestConfigRender(t *testing.T) {
+	t.Parallel()
+
+	config := newTestConfig(t)
+
+	b, err := config.Render()
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	rendered := string(b)
+
+	for _, s := range []string{
+		"route:\n  receiver: default\n",
+		"    - receiver: pager\n      matchers:\n        - severity=\"critical\"\n",
+		"  - name: pager\n    pagerduty_configs:\n      - routing_key: key\n        send_resolved: true\n",
+		"inhibit_rules:\n",
+		"templates:\n  - templates/*.tmpl\n",
+	} {
+		if !strings.Contains(rendered, s) {
+			t.Fatalf("expected rendered config to contain %q, got\n%s", s, rendered)
+		}
+	}
+}
+
+func TestConfigJson(t *testing.T) {
+	t.Parallel()
+
+	config := newTestConfig(t)
+
+	b, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	var decoded Config
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	pager := decoded.Receiver("pager")
+	if pager == nil || pager.IsNull() {
+		t.Fatalf("expected pager receiver to have integrations, got %+v", pager)
+	}
+
+	if !decoded.Receiver("default").IsNull() {
+		t.Fatal("expected default receiver to be a null receiver")
+	}
+
+	if err := json.Unmarshal([]byte(`{"name": "x", "slack_configs": "y"}`), &Receiver{}); err == nil {
+		t.Fatal("expected error not to be nil")
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	t.Parallel()
+
+	labels := map[string]string{"env": "production", "severity": "critical"}
+
+	testCases := []struct {
+		matcher string
+		matches bool
+		isErr   bool
+	}{
+		{`env="production"`, true, false},
+		{`env = production`, true, false},
+		{`env!="production"`, false, false},
+		{`env=~"prod.*"`, true, false},
+		{`env=~"prod"`, false, false},
+		{`env!~"staging|qa"`, true, false},
+		{`team=""`, true, false},
+		{`team!=""`, false, false},
+		{`env`, false, true},
+		{`1env="x"`, false, true},
+		{`env="x`, false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.matcher, func(t *testing.T) {
+			m, err := ParseMatcher(tc.matcher)
+
+			if tc.isErr {
+				if err == nil {
+					t.Fatal("expected error not to be nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected error to be nil, got %v", err)
+			}
+
+			if m.Matches(labels) != tc.matches {
+				t.Fatalf("expected %s to match %v", m, tc.matches)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		value    string
+		expected time.Duration
+		isErr    bool
+	}{
+		{"30s", time.Second * 30, false},
+		{"1h30m", time.Minute * 90, false},
+		{"1d", time.Hour * 24, false},
+		{"1w", time.Hour * 24 * 7, false},
+		{"500ms", time.Millisecond * 500, false},
+		{"0s", 0, false},
+		{"", 0, true},
+		{"1.5h", 0, true},
+		{"30m1h", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			d, err := ParseDuration(tc.value)
+
+			if tc.isErr {
+				if err == nil {
+					t.Fatal("expected error not to be nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected error to be nil, got %v", err)
+			}
+
+			if d != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, d)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package alertmanager
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type MatchType string
+
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// labelNameRegex matches valid label names.
+var labelNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Matcher matches the value of a label, e.g. `severity="critical"`. Alerts
+// missing the label match as if its value was empty, as in Alertmanager.
+type Matcher struct {
+	Name  string
+	Type  MatchType
+	Value string
+
+	re *regexp.Regexp
+}
+
+// ParseMatcher parses a matcher in the Alertmanager syntax, where the value
+// may be double quoted, e.g. `env=~"prod|staging"`.
+func ParseMatcher(s string) (*Matcher, error) {
+	s = strings.TrimSpace(s)
+
+	i := strings.IndexAny(s, "=!")
+	if i < 0 {
+		return nil, fmt.Errorf("matcher %q has no operator", s)
+	}
+
+	m := &Matcher{Name: strings.TrimSpace(s[:i])}
+	rest := s[i:]
+
+	switch {
+	case strings.HasPrefix(rest, string(MatchRegexp)):
+		m.Type = MatchRegexp
+	case strings.HasPrefix(rest, string(MatchNotRegexp)):
+		m.Type = MatchNotRegexp
+	case strings.HasPrefix(rest, string(MatchNotEqual)):
+		m.Type = MatchNotEqual
+	case strings.HasPrefix(rest, string(MatchEqual)):
+		m.Type = MatchEqual
+	default:
+		return nil, fmt.Errorf("matcher %q has an invalid operator", s)
+	}
+
+	if !labelNameRegex.MatchString(m.Name) {
+		return nil, fmt.Errorf("matcher %q has an invalid label name", s)
+	}
+
+	value := strings.TrimSpace(rest[len(m.Type):])
+
+	if strings.HasPrefix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("matcher %q has an invalid quoted value", s)
+		}
+		value = unquoted
+	}
+
+	m.Value = value
+
+	if m.Type == MatchRegexp || m.Type == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("matcher %q has an invalid regular expression; %v", s, err)
+		}
+		m.re = re
+	}
+
+	return m, nil
+}
+
+// ParseMatchers parses a list of matchers.
+func ParseMatchers(values []string) ([]*Matcher, error) {
+	matchers := make([]*Matcher, 0, len(values))
+
+	for _, value := range values {
+		m, err := ParseMatcher(value)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+
+	return matchers, nil
+}
+
+// Matches reports whether the labels match.
+func (m *Matcher) Matches(labels map[string]string) bool {
+	value := labels[m.Name]
+
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	default:
+		return false
+	}
+}
+
+// String returns the matcher in the Alertmanager syntax.
+func (m *Matcher) String() string {
+	return m.Name + string(m.Type) + strconv.Quote(m.Value)
+}
+
+// MatchesAll reports whether the labels match all the matchers.
+func MatchesAll(matchers []*Matcher, labels map[string]string) bool {
+	for _, m := range matchers {
+		if !m.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+var durationRegex = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits are the units of the durations, in the order of the regex
+// groups.
+var durationUnits = []time.Duration{
+	time.Hour * 24 * 365,
+	time.Hour * 24 * 7,
+	time.Hour * 24,
+	time.Hour,
+	time.Minute,
+	time.Second,
+	time.Millisecond,
+}
+
+// ParseDuration parses an Alertmanager duration, e.g. `1h30m`.
+func ParseDuration(value string) (time.Duration, error) {
+	groups := durationRegex.FindStringSubmatch(value)
+	if value == "" || groups == nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	var d time.Duration
+
+	for i, unit := range durationUnits {
+		if n := groups[2+i*2]; n != "" {
+			v, err := strconv.ParseInt(n, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			d += time.Duration(v) * unit
+		}
+	}
+
+	return d, nil
+}
@@ -0,0 +1,90 @@
+package alertmanager
+
+import "fmt"
+
+// RouteMatch is a route of the routing tree an alert is routed to.
+type RouteMatch struct {
+	// Path locates the route in the tree, e.g. `route.routes[0]`.
+	Path     string   `json:"path"`
+	Receiver string   `json:"receiver"`
+	Matchers []string `json:"matchers,omitempty"`
+
+	// Default reports whether the alert fell through to the root route, no
+	// other route matching it.
+	Default bool `json:"default"`
+
+	// Null reports whether the receiver has no integration, alerts routed
+	// to it are never notified.
+	Null bool `json:"null_receiver"`
+}
+
+// Match evaluates the routing tree for an alert with the given labels, and
+// returns the routes it is routed to, the way Alertmanager dispatches it.
+func (c *Config) Match(labels map[string]string) []*RouteMatch {
+	if c.Route == nil {
+		return []*RouteMatch{}
+	}
+
+	matches := c.match(c.Route, "route", c.Route.Receiver, labels)
+
+	for _, m := range matches {
+		m.Default = m.Path == "route"
+
+		if r := c.Receiver(m.Receiver); r == nil || r.IsNull() {
+			m.Null = true
+		}
+	}
+
+	return matches
+}
+
+// match returns the routes of the subtree matching the labels. Routes stop
+// at the first matching child, unless it has continue set, and routes with
+// no matching child match themselves. Routes without a receiver inherit the
+// one of their parent.
+func (c *Config) match(route *Route, path string, receiver string, labels map[string]string) []*RouteMatch {
+	matchers, err := ParseMatchers(route.Matchers)
+	if err != nil || !MatchesAll(matchers, labels) {
+		return nil
+	}
+
+	if route.Receiver != "" {
+		receiver = route.Receiver
+	}
+
+	var all []*RouteMatch
+
+	for i, child := range route.Routes {
+		if child == nil {
+			continue
+		}
+
+		matches := c.match(child, fmt.Sprintf("%s.routes[%d]", path, i), receiver, labels)
+		all = append(all, matches...)
+
+		if matches != nil && !child.Continue {
+			break
+		}
+	}
+
+	if len(all) == 0 {
+		all = append(all, &RouteMatch{
+			Path:     path,
+			Receiver: receiver,
+			Matchers: route.Matchers,
+		})
+	}
+
+	return all
+}
+
+// FallsThrough reports whether the alert is only routed to the default
+// route or to null receivers, which usually means no route was meant for it.
+func FallsThrough(matches []*RouteMatch) bool {
+	for _, m := range matches {
+		if !m.Default && !m.Null {
+			return false
+		}
+	}
+	return true
+}
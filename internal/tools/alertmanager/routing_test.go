@@ -0,0 +1,87 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testRoutingConfig = `{
+	"route": {
+		"receiver": "default",
+		"routes": [
+			{"matchers": ["team=\"payments\""], "routes": [
+				{"receiver": "payments-pager", "matchers": ["severity=\"critical\""]},
+				{"receiver": "payments-slack", "matchers": ["severity=~\"warning|info\""]}
+			]},
+			{"receiver": "audit", "matchers": ["env=\"production\""], "continue": true},
+			{"receiver": "blackhole", "matchers": ["severity=\"critical\""]},
+			{"receiver": "ops", "matchers": ["env=\"production\""]}
+		]
+	},
+	"receivers": [
+		{"name": "default", "slack_configs": [{"channel": "#alerts"}]},
+		{"name": "payments-pager", "pagerduty_configs": [{"routing_key": "key"}]},
+		{"name": "payments-slack", "slack_configs": [{"channel": "#payments"}]},
+		{"name": "audit", "webhook_configs": [{"url": "http://audit"}]},
+		{"name": "blackhole"},
+		{"name": "ops", "slack_configs": [{"channel": "#ops"}]}
+	]
+}`
+
+func TestConfigMatch(t *testing.T) {
+	t.Parallel()
+
+	var config Config
+	if err := json.Unmarshal([]byte(testRoutingConfig), &config); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		labels       map[string]string
+		receivers    []string
+		fallsThrough bool
+	}{
+		{"nested route", map[string]string{"team": "payments", "severity": "critical"}, []string{"payments-pager"}, false},
+		{"regex route", map[string]string{"team": "payments", "severity": "info"}, []string{"payments-slack"}, false},
+		{"inherited receiver", map[string]string{"team": "payments", "severity": "page"}, []string{"default"}, false},
+		{"continue", map[string]string{"env": "production"}, []string{"audit", "ops"}, false},
+		{"continue into null receiver", map[string]string{"env": "production", "severity": "critical"}, []string{"audit", "blackhole"}, false},
+		{"null receiver", map[string]string{"severity": "critical"}, []string{"blackhole"}, true},
+		{"default route", map[string]string{"severity": "warning"}, []string{"default"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			matches := config.Match(tc.labels)
+
+			receivers := []string{}
+			for _, m := range matches {
+				receivers = append(receivers, m.Receiver)
+			}
+
+			if len(receivers) != len(tc.receivers) {
+				t.Fatalf("expected receivers %v, got %v", tc.receivers, receivers)
+			}
+
+			for i := range receivers {
+				if receivers[i] != tc.receivers[i] {
+					t.Fatalf("expected receivers %v, got %v", tc.receivers, receivers)
+				}
+			}
+
+			if FallsThrough(matches) != tc.fallsThrough {
+				t.Fatalf("expected falls through to be %v", tc.fallsThrough)
+			}
+		})
+	}
+
+	matches := config.Match(map[string]string{"team": "payments", "severity": "page"})
+	if matches[0].Path != "route.routes[0]" || matches[0].Default {
+		t.Fatalf("expected inherited receiver of route.routes[0], got %+v", matches[0])
+	}
+}
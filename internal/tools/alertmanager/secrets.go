@@ -0,0 +1,176 @@
+package alertmanager
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Secret stands in for the secrets of the receivers in the redacted
+// configurations.
+const Secret = "<secret>"
+
+// secretFields lists the fields of the integration configs holding secrets,
+// at any depth, e.g. the password of the basic_auth of an http_config.
+var secretFields = map[string]bool{
+	"api_key":       true,
+	"api_secret":    true,
+	"api_url":       true,
+	"auth_password": true,
+	"auth_secret":   true,
+	"bearer_token":  true,
+	"bot_token":     true,
+	"client_secret": true,
+	"credentials":   true,
+	"password":      true,
+	"routing_key":   true,
+	"secret_key":    true,
+	"service_key":   true,
+	"token":         true,
+	"token_id":      true,
+	"user_key":      true,
+	"webhook_url":   true,
+}
+
+// isSecretField reports whether the field of a config of the integration
+// holds a secret. The url of the webhooks is one, it often embeds a token.
+func isSecretField(kind string, field string) bool {
+	return secretFields[field] || (kind == "webhook_configs" && field == "url")
+}
+
+// Redact returns a copy of the configuration with the secrets of its
+// receivers replaced by Secret, so that it can be shown and recorded.
+func (c *Config) Redact() *Config {
+	redacted := *c
+	redacted.Receivers = make([]*Receiver, 0, len(c.Receivers))
+
+	for _, r := range c.Receivers {
+		if r == nil {
+			redacted.Receivers = append(redacted.Receivers, nil)
+			continue
+		}
+
+		receiver := &Receiver{Name: r.Name, Integrations: map[string][]map[string]any{}}
+
+		for kind, configs := range r.Integrations {
+			redactedConfigs := make([]map[string]any, 0, len(configs))
+			for _, config := range configs {
+				redactedConfig, _ := redactValue(kind, config).(map[string]any)
+				redactedConfigs = append(redactedConfigs, redactedConfig)
+			}
+			receiver.Integrations[kind] = redactedConfigs
+		}
+
+		redacted.Receivers = append(redacted.Receivers, receiver)
+	}
+
+	return &redacted
+}
+
+func redactValue(kind string, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		if v == nil {
+			return v
+		}
+
+		redacted := make(map[string]any, len(v))
+		for field, fieldValue := range v {
+			if s, ok := fieldValue.(string); ok && s != "" && isSecretField(kind, field) {
+				redacted[field] = Secret
+				continue
+			}
+			redacted[field] = redactValue(kind, fieldValue)
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, 0, len(v))
+		for _, item := range v {
+			redacted = append(redacted, redactValue(kind, item))
+		}
+		return redacted
+	default:
+		return value
+	}
+}
+
+// Unredact replaces the Secret placeholders of the receivers with the
+// secrets of the previous configuration, nil when there is none, found at
+// the same place of the receiver with the same name. A redacted
+// configuration can then be edited and saved back. It fails when a
+// placeholder has no previous secret.
+func (c *Config) Unredact(prev *Config) error {
+	for _, r := range c.Receivers {
+		if r == nil {
+			continue
+		}
+
+		var prevReceiver *Receiver
+		if prev != nil {
+			prevReceiver = prev.Receiver(r.Name)
+		}
+
+		for _, kind := range slices.Sorted(maps.Keys(r.Integrations)) {
+			configs := r.Integrations[kind]
+
+			for i, config := range configs {
+				var prevConfig map[string]any
+				if prevReceiver != nil && i < len(prevReceiver.Integrations[kind]) {
+					prevConfig = prevReceiver.Integrations[kind][i]
+				}
+
+				value, ok := unredactValue(config, prevConfig)
+				if !ok {
+					return fmt.Errorf("receiver %q has a %s secret without a previous value", r.Name, kind)
+				}
+				configs[i], _ = value.(map[string]any)
+			}
+		}
+	}
+
+	return nil
+}
+
+// unredactValue returns the value with its placeholders replaced, and
+// reports whether they all had a previous secret.
+func unredactValue(value any, prev any) (any, bool) {
+	switch v := value.(type) {
+	case string:
+		if v != Secret {
+			return v, true
+		}
+		s, ok := prev.(string)
+		return s, ok && s != "" && s != Secret
+	case map[string]any:
+		if v == nil {
+			return v, true
+		}
+
+		prevMap, _ := prev.(map[string]any)
+		for field, fieldValue := range v {
+			unredacted, ok := unredactValue(fieldValue, prevMap[field])
+			if !ok {
+				return nil, false
+			}
+			v[field] = unredacted
+		}
+		return v, true
+	case []any:
+		prevSlice, _ := prev.([]any)
+		for i, item := range v {
+			var prevItem any
+			if i < len(prevSlice) {
+				prevItem = prevSlice[i]
+			}
+
+			unredacted, ok := unredactValue(item, prevItem)
+			if !ok {
+				return nil, false
+			}
+			v[i] = unredacted
+		}
+		return v, true
+	default:
+		return value, true
+	}
+}
@@ -0,0 +1,121 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testSecretsConfig = `{
+	"route": {"receiver": "team"},
+	"receivers": [
+		{
+			"name": "team",
+			"slack_configs": [{"api_url": "https://hooks.slack.com/services/T0/B0/secret", "channel": "#alerts"}],
+			"webhook_configs": [
+				{
+					"url": "https://hooks.example.com/secret",
+					"http_config": {"basic_auth": {"username": "scopehouse", "password": "pa55word"}}
+				}
+			]
+		}
+	]
+}`
+
+func newTestSecretsConfig(t *testing.T) *Config {
+	t.Helper()
+
+	var config Config
+	if err := json.Unmarshal([]byte(testSecretsConfig), &config); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	return &config
+}
+
+func TestConfigRedact(t *testing.T) {
+	t.Parallel()
+
+	config := newTestSecretsConfig(t)
+
+	redacted := config.Redact()
+
+	if url := redacted.Receiver("team").Integrations["slack_configs"][0]["api_url"]; url != Secret {
+		t.Fatalf("expected slack api url to be %q, got %v", Secret, url)
+	}
+
+	b, err := json.Marshal(redacted)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	for _, secret := range []string{"T0/B0/secret", "hooks.example.com", "pa55word"} {
+		if strings.Contains(string(b), secret) {
+			t.Fatalf("expected %q to be redacted, got %s", secret, b)
+		}
+	}
+
+	for _, s := range []string{`"channel":"#alerts"`, `"username":"scopehouse"`} {
+		if !strings.Contains(string(b), s) {
+			t.Fatalf("expected redacted config to contain %s, got %s", s, b)
+		}
+	}
+
+	// The configuration itself is left as is.
+	if config.Receiver("team").Integrations["slack_configs"][0]["api_url"] == Secret {
+		t.Fatal("expected config not to be redacted")
+	}
+}
+
+func TestConfigUnredact(t *testing.T) {
+	t.Parallel()
+
+	prev := newTestSecretsConfig(t)
+
+	var config Config
+	if err := json.Unmarshal([]byte(`{
+		"route": {"receiver": "team"},
+		"receivers": [
+			{
+				"name": "team",
+				"slack_configs": [{"api_url": "<secret>", "channel": "#oncall"}],
+				"webhook_configs": [
+					{
+						"url": "https://hooks.example.com/rotated",
+						"http_config": {"basic_auth": {"username": "scopehouse", "password": "<secret>"}}
+					}
+				]
+			}
+		]
+	}`), &config); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	if err := config.Unredact(prev); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	team := config.Receiver("team")
+
+	if url := team.Integrations["slack_configs"][0]["api_url"]; url != "https://hooks.slack.com/services/T0/B0/secret" {
+		t.Fatalf("expected slack api url to be restored, got %v", url)
+	}
+
+	webhook := team.Integrations["webhook_configs"][0]
+	if url := webhook["url"]; url != "https://hooks.example.com/rotated" {
+		t.Fatalf("expected webhook url to be the new one, got %v", url)
+	}
+
+	basicAuth := webhook["http_config"].(map[string]any)["basic_auth"].(map[string]any)
+	if password := basicAuth["password"]; password != "pa55word" {
+		t.Fatalf("expected webhook password to be restored, got %v", password)
+	}
+
+	// Secrets cannot be restored from a receiver that did not exist.
+	config.Receivers[0].Name = "other"
+	config.Receivers[0].Integrations["slack_configs"][0]["api_url"] = Secret
+
+	if err := config.Unredact(prev); err == nil {
+		t.Fatal("expected error not to be nil")
+	}
+}
@@ -0,0 +1,161 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ConfigFile is the name of the configuration file written for each target.
+const ConfigFile = "alertmanager.yml"
+
+// Target is an Alertmanager instance the configuration is synced to.
+type Target struct {
+	// Id identifies the target, e.g. the id of its cluster.
+	Id  string
+	Url string
+
+	// HttpClient calls the target, e.g. with its credentials, the client of
+	// the syncer is used when nil.
+	HttpClient *http.Client
+}
+
+type Syncer interface {
+	// Sync pushes the configuration to the target Alertmanager, and has it
+	// reload the configuration.
+	Sync(ctx context.Context, target Target, config *Config) error
+}
+
+// targetIdRegex matches the target ids safe to be used as directory names.
+var targetIdRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Ensures that the FileSyncer implements the Syncer interface.
+var _ Syncer = (*FileSyncer)(nil)
+
+// FileSyncer writes the configuration of each target in its own directory,
+// `<dir>/<target id>/alertmanager.yml` along with its templates, which is
+// expected to be mounted in the target Alertmanager. The target is then
+// reloaded, and its status checked to make sure it loaded the configuration.
+type FileSyncer struct {
+	dir        string
+	httpClient *http.Client
+}
+
+func NewFileSyncer(dir string, httpClient *http.Client) *FileSyncer {
+	return &FileSyncer{dir: dir, httpClient: httpClient}
+}
+
+// Sync writes the configuration of the target and reloads it.
+func (s *FileSyncer) Sync(ctx context.Context, target Target, config *Config) error {
+	if !targetIdRegex.MatchString(target.Id) {
+		return fmt.Errorf("invalid target id %q", target.Id)
+	}
+
+	if target.Url == "" {
+		return errors.New("target has no url")
+	}
+
+	rendered, err := config.Render()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(s.dir, target.Id)
+
+	if err := s.writeTemplates(filepath.Join(dir, TemplatesDir), config); err != nil {
+		return fmt.Errorf("failed to write templates; %v", err)
+	}
+
+	if err := writeFile(filepath.Join(dir, ConfigFile), rendered); err != nil {
+		return fmt.Errorf("failed to write configuration; %v", err)
+	}
+
+	httpClient := target.HttpClient
+	if httpClient == nil {
+		httpClient = s.httpClient
+	}
+
+	client := NewClient(target.Url, httpClient)
+
+	if err := client.Reload(ctx); err != nil {
+		return fmt.Errorf("reload failed; %v", err)
+	}
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("status check failed; %v", err)
+	}
+
+	if strings.TrimSpace(status.Config.Original) != strings.TrimSpace(string(rendered)) {
+		return errors.New("alertmanager did not load the configuration, make sure it reads it from the synced directory")
+	}
+
+	return nil
+}
+
+// writeTemplates writes the templates of the configuration, and removes the
+// ones it no longer has.
+func (s *FileSyncer) writeTemplates(dir string, config *Config) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, name := range config.TemplateNames() {
+		if err := writeFile(filepath.Join(dir, name), []byte(config.Templates[name])); err != nil {
+			return err
+		}
+	}
+
+	stale, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range stale {
+		if _, ok := config.Templates[filepath.Base(path)]; ok {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFile writes the file atomically, so that a reload never reads a
+// partially written file.
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Name()
+	defer func() { _ = os.Remove(tmp) }()
+
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
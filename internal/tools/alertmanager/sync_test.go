@@ -0,0 +1,148 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testAlertmanager stands in for an Alertmanager loading its configuration
+// from a file on reload.
+type testAlertmanager struct {
+	mu         sync.Mutex
+	configFile string
+	original   string
+	reloads    int
+	reloadErr  string
+}
+
+func newTestAlertmanager(t *testing.T, configFile string) (*testAlertmanager, *httptest.Server) {
+	t.Helper()
+
+	am := &testAlertmanager{configFile: configFile}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /-/reload", am.reload)
+	mux.HandleFunc("GET /api/v2/status", am.status)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return am, server
+}
+
+func (am *testAlertmanager) reload(w http.ResponseWriter, _ *http.Request) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	am.reloads++
+
+	if am.reloadErr != "" {
+		http.Error(w, am.reloadErr, http.StatusInternalServerError)
+		return
+	}
+
+	b, err := os.ReadFile(am.configFile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	am.original = string(b)
+}
+
+func (am *testAlertmanager) status(w http.ResponseWriter, _ *http.Request) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	resp := map[string]any{
+		"config": map[string]string{"original": am.original},
+	}
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func TestFileSyncer(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	am, server := newTestAlertmanager(t, filepath.Join(dir, "c1", ConfigFile))
+
+	syncer := NewFileSyncer(dir, server.Client())
+	target := Target{Id: "c1", Url: server.URL}
+
+	config := newTestConfig(t)
+	config.Templates["old.tmpl"] = `{{ define "old" }}{{ end }}`
+
+	if err := syncer.Sync(context.Background(), target, config); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	rendered, _ := config.Render()
+	if am.original != string(rendered) {
+		t.Fatalf("expected alertmanager to load the rendered config, got\n%s", am.original)
+	}
+
+	for _, name := range []string{"pager.tmpl", "old.tmpl"} {
+		if _, err := os.Stat(filepath.Join(dir, "c1", TemplatesDir, name)); err != nil {
+			t.Fatalf("expected template %s to be written, got %v", name, err)
+		}
+	}
+
+	// Templates removed from the config are removed from the directory.
+	delete(config.Templates, "old.tmpl")
+
+	if err := syncer.Sync(context.Background(), target, config); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "c1", TemplatesDir, "old.tmpl")); !os.IsNotExist(err) {
+		t.Fatalf("expected old template to be removed, got %v", err)
+	}
+
+	if am.reloads != 2 {
+		t.Fatalf("expected 2 reloads, got %d", am.reloads)
+	}
+}
+
+func TestFileSyncerErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reload failure", func(t *testing.T) {
+		dir := t.TempDir()
+		am, server := newTestAlertmanager(t, filepath.Join(dir, "c1", ConfigFile))
+		am.reloadErr = "failed to load configuration"
+
+		err := NewFileSyncer(dir, server.Client()).Sync(context.Background(), Target{Id: "c1", Url: server.URL}, newTestConfig(t))
+		if err == nil || !strings.Contains(err.Error(), "failed to load configuration") {
+			t.Fatalf("expected reload error, got %v", err)
+		}
+	})
+
+	t.Run("config not loaded", func(t *testing.T) {
+		// The Alertmanager reads another file than the synced one.
+		dir := t.TempDir()
+		other := filepath.Join(t.TempDir(), ConfigFile)
+		_ = os.WriteFile(other, []byte("route: {}\n"), 0o644)
+
+		_, server := newTestAlertmanager(t, other)
+
+		err := NewFileSyncer(dir, server.Client()).Sync(context.Background(), Target{Id: "c1", Url: server.URL}, newTestConfig(t))
+		if err == nil || !strings.Contains(err.Error(), "did not load the configuration") {
+			t.Fatalf("expected load check error, got %v", err)
+		}
+	})
+
+	t.Run("invalid target", func(t *testing.T) {
+		err := NewFileSyncer(t.TempDir(), nil).Sync(context.Background(), Target{Id: "../c1", Url: "http://x"}, newTestConfig(t))
+		if err == nil {
+			t.Fatal("expected error not to be nil")
+		}
+	})
+}
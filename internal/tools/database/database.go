@@ -22,6 +22,10 @@ type Config struct {
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxIdleTime time.Duration
+
+	// RuntimeParams are the run-time parameters every connection starts
+	// with, e.g. custom settings read by the database functions.
+	RuntimeParams map[string]string
 }
 
 func New(config Config) (*sql.DB, error) {
@@ -51,12 +55,21 @@ func New(config Config) (*sql.DB, error) {
 }
 
 func (c *Config) parse() (*Config, error) {
-	_, err := url.ParseRequestURI(c.Url)
+	u, err := url.ParseRequestURI(c.Url)
 	if err != nil {
 		// Intentionally suppressing the error to avoid exposing credentials.
 		return nil, errors.New("invalid database url")
 	}
 
+	if len(c.RuntimeParams) > 0 {
+		query := u.Query()
+		for name, value := range c.RuntimeParams {
+			query.Set(name, value)
+		}
+		u.RawQuery = query.Encode()
+		c.Url = u.String()
+	}
+
 	if c.MaxIdleConns < 1 {
 		c.MaxIdleConns = defaultMaxIdleConns
 	}
@@ -0,0 +1,117 @@
+package event
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag returns a strong entity tag computed from the given representation.
+func ETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// NotModified sets the `ETag` and `Last-Modified` validators on the response
+// and reports whether the request preconditions indicate that the client
+// already holds the current representation. In that case a 304 response is
+// written and the caller must not write a body.
+//
+// Either validator may be left empty (or zero) to skip it.
+func (e *Event) NotModified(etag string, lastModified time.Time) bool {
+	h := e.Response.Header()
+
+	if etag != "" {
+		h.Set("ETag", etag)
+	}
+
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if e.Request.Method != http.MethodGet && e.Request.Method != http.MethodHead {
+		return false
+	}
+
+	if !e.isNotModified(etag, lastModified) {
+		return false
+	}
+
+	// A 304 response must not carry representation metadata for a body
+	// that is never sent.
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+
+	e.Response.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+// JsonConditional works like Json, but responds with 304 Not Modified when
+// the request preconditions match the encoded data or the given last
+// modification time.
+func (e *Event) JsonConditional(data any, status int, lastModified time.Time) error {
+	res, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	res = append(res, '\n')
+
+	if e.NotModified(ETag(res), lastModified) {
+		return nil
+	}
+
+	e.Response.Header().Set("Content-Type", "application/json")
+	e.Response.WriteHeader(status)
+
+	if _, err := e.Response.Write(res); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *Event) isNotModified(etag string, lastModified time.Time) bool {
+	// When `If-None-Match` is present `If-Modified-Since` must be ignored,
+	// as entity tags are the more accurate validator.
+	if inm := e.Request.Header.Get("If-None-Match"); inm != "" {
+		return etag != "" && etagMatches(inm, etag)
+	}
+
+	ims := e.Request.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified.IsZero() {
+		return false
+	}
+
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	// The header has a one second resolution.
+	return !lastModified.Truncate(time.Second).After(t)
+}
+
+// etagMatches reports whether the given `If-None-Match` header value matches
+// the entity tag, using the weak comparison function.
+func etagMatches(header string, etag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
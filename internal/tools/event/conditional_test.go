@@ -0,0 +1,213 @@
+package event
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestETag(t *testing.T) {
+	t.Parallel()
+
+	a := ETag([]byte(`{"foo":"bar"}`))
+	b := ETag([]byte(`{"foo":"bar"}`))
+	c := ETag([]byte(`{"foo":"baz"}`))
+
+	if a != b {
+		t.Fatalf("expected etag %s to be equal %s", a, b)
+	}
+
+	if a == c {
+		t.Fatalf("expected etag %s not to be equal %s", a, c)
+	}
+
+	if a[0] != '"' || a[len(a)-1] != '"' {
+		t.Fatalf("expected etag to be a quoted string, got %s", a)
+	}
+}
+
+func TestEventNotModified(t *testing.T) {
+	t.Parallel()
+
+	etag := `"abc"`
+	modified := time.Date(2025, time.January, 2, 3, 4, 5, 600, time.UTC)
+
+	testCases := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		etag         string
+		modified     time.Time
+		notModified  bool
+		lastModified string
+	}{
+		{
+			name:         "no preconditions",
+			method:       http.MethodGet,
+			etag:         etag,
+			modified:     modified,
+			notModified:  false,
+			lastModified: "Thu, 02 Jan 2025 03:04:05 GMT",
+		},
+		{
+			name:        "if-none-match matches",
+			method:      http.MethodGet,
+			headers:     map[string]string{"If-None-Match": `"xyz", "abc"`},
+			etag:        etag,
+			notModified: true,
+		},
+		{
+			name:        "if-none-match weak match",
+			method:      http.MethodGet,
+			headers:     map[string]string{"If-None-Match": `W/"abc"`},
+			etag:        etag,
+			notModified: true,
+		},
+		{
+			name:        "if-none-match wildcard",
+			method:      http.MethodGet,
+			headers:     map[string]string{"If-None-Match": `*`},
+			etag:        etag,
+			notModified: true,
+		},
+		{
+			name:        "if-none-match mismatch",
+			method:      http.MethodGet,
+			headers:     map[string]string{"If-None-Match": `"xyz"`},
+			etag:        etag,
+			notModified: false,
+		},
+		{
+			name:   "if-none-match takes precedence",
+			method: http.MethodGet,
+			headers: map[string]string{
+				"If-None-Match":     `"xyz"`,
+				"If-Modified-Since": "Thu, 02 Jan 2025 03:04:05 GMT",
+			},
+			etag:        etag,
+			modified:    modified,
+			notModified: false,
+		},
+		{
+			name:        "if-modified-since not modified",
+			method:      http.MethodGet,
+			headers:     map[string]string{"If-Modified-Since": "Thu, 02 Jan 2025 03:04:05 GMT"},
+			modified:    modified,
+			notModified: true,
+		},
+		{
+			name:        "if-modified-since modified",
+			method:      http.MethodGet,
+			headers:     map[string]string{"If-Modified-Since": "Thu, 02 Jan 2025 03:04:04 GMT"},
+			modified:    modified,
+			notModified: false,
+		},
+		{
+			name:        "if-modified-since invalid date",
+			method:      http.MethodGet,
+			headers:     map[string]string{"If-Modified-Since": "yesterday"},
+			modified:    modified,
+			notModified: false,
+		},
+		{
+			name:        "unsafe method",
+			method:      http.MethodPost,
+			headers:     map[string]string{"If-None-Match": `"abc"`},
+			etag:        etag,
+			notModified: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			rec.Header().Set("Content-Type", "application/json")
+
+			e := Event{
+				Request:  req,
+				Response: rec,
+			}
+
+			notModified := e.NotModified(tc.etag, tc.modified)
+			if notModified != tc.notModified {
+				t.Fatalf("expected not modified to be %v, got %v", tc.notModified, notModified)
+			}
+
+			res := rec.Result()
+
+			if tc.notModified {
+				if res.StatusCode != http.StatusNotModified {
+					t.Fatalf("expected status code 304, got %d", res.StatusCode)
+				}
+				if value := res.Header.Get("Content-Type"); value != "" {
+					t.Fatalf("expected no content-type header, got %q", value)
+				}
+			}
+
+			if value := res.Header.Get("ETag"); value != tc.etag {
+				t.Fatalf("expected etag header to be %q, got %q", tc.etag, value)
+			}
+
+			if tc.lastModified != "" {
+				if value := res.Header.Get("Last-Modified"); value != tc.lastModified {
+					t.Fatalf(
+						"expected last-modified header to be %q, got %q",
+						tc.lastModified, value,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestEventJsonConditional(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]any{"foo": "bar"}
+
+	rec := httptest.NewRecorder()
+	e := Event{
+		Request:  httptest.NewRequest(http.MethodGet, "/", nil),
+		Response: rec,
+	}
+
+	if err := e.JsonConditional(data, http.StatusOK, time.Time{}); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code 200, got %d", rec.Code)
+	}
+
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected etag header not to be empty")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+
+	rec = httptest.NewRecorder()
+	e = Event{
+		Request:  req,
+		Response: rec,
+	}
+
+	if err := e.JsonConditional(data, http.StatusOK, time.Time{}); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected status code 304, got %d", rec.Code)
+	}
+
+	if rec.Body.Len() != 0 {
+		t.Fatalf("expected empty content, got \n%v", rec.Body.String())
+	}
+}
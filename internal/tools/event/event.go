@@ -49,6 +49,11 @@ func (e *Event) Status(status int) error {
 	return e.Text(status, "")
 }
 
+// NoContent writes a 204 response, which must not carry a body.
+func (e *Event) NoContent() {
+	e.Response.WriteHeader(http.StatusNoContent)
+}
+
 func (e *Event) InternalServerError(message string) *ApiError {
 	return NewInternalServerError(message)
 }
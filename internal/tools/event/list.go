@@ -0,0 +1,106 @@
+package event
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// ListOperators are the filter operators accepted in list queries.
+var ListOperators = []string{"eq", "ne", "gt", "gte", "lt", "lte", "like"}
+
+// ListQuery holds the pagination, sorting and filtering parameters of a
+// list request, i.e. `?limit=20&cursor=abc&sort=-created_at&filter=email:like:foo`.
+type ListQuery struct {
+	Limit   int
+	Cursor  string
+	Sort    string
+	Desc    bool
+	Filters []ListFilter
+}
+
+// ListFilter is a single `field:operator:value` filter expression.
+// The operator may be omitted (i.e. `field:value`) and defaults to `eq`,
+// so is an unknown one, taken as part of a value with colons.
+type ListFilter struct {
+	Field    string
+	Operator string
+	Value    string
+}
+
+// ListQuery parses the list parameters from the request query string.
+// It only validates their syntax, field names are validated by the store
+// running the query.
+func (e *Event) ListQuery() (*ListQuery, *ApiError) {
+	values := e.Request.URL.Query()
+
+	q := &ListQuery{
+		Limit:   DefaultListLimit,
+		Cursor:  strings.TrimSpace(values.Get("cursor")),
+		Filters: []ListFilter{},
+	}
+
+	if limit := strings.TrimSpace(values.Get("limit")); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxListLimit {
+			msg := fmt.Sprintf("Limit must be a number between 1 and %d.", MaxListLimit)
+			return nil, NewApiError(http.StatusBadRequest, msg)
+		}
+		q.Limit = n
+	}
+
+	sort := strings.TrimSpace(values.Get("sort"))
+	if field, ok := strings.CutPrefix(sort, "-"); ok {
+		q.Desc = true
+		sort = field
+	}
+	q.Sort = sort
+
+	for _, raw := range values["filter"] {
+		filter, err := parseListFilter(raw)
+		if err != nil {
+			return nil, err
+		}
+		q.Filters = append(q.Filters, filter)
+	}
+
+	return q, nil
+}
+
+func parseListFilter(raw string) (ListFilter, *ApiError) {
+	parts := strings.SplitN(raw, ":", 3)
+
+	if len(parts) < 2 {
+		msg := fmt.Sprintf("Invalid filter %q, expected format is field:operator:value.", raw)
+		return ListFilter{}, NewApiError(http.StatusBadRequest, msg)
+	}
+
+	filter := ListFilter{
+		Field:    strings.TrimSpace(parts[0]),
+		Operator: "eq",
+		Value:    strings.Join(parts[1:], ":"),
+	}
+
+	// The middle part is only an operator when it is a known one, otherwise
+	// it is part of a value with colons, e.g. `name:payments:api`.
+	if len(parts) == 3 {
+		if op := strings.ToLower(strings.TrimSpace(parts[1])); slices.Contains(ListOperators, op) {
+			filter.Operator = op
+			filter.Value = parts[2]
+		}
+	}
+
+	if filter.Field == "" {
+		msg := fmt.Sprintf("Invalid filter %q, field must not be empty.", raw)
+		return filter, NewApiError(http.StatusBadRequest, msg)
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,115 @@
+package event
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestEventListQuery(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		url      string
+		expected *ListQuery
+		message  string
+	}{
+		{
+			name: "defaults",
+			url:  "/",
+			expected: &ListQuery{
+				Limit:   DefaultListLimit,
+				Filters: []ListFilter{},
+			},
+		},
+		{
+			name: "all params",
+			url:  "/?limit=10&cursor=abc&sort=-created_at&filter=email:like:foo&filter=is_activated:true",
+			expected: &ListQuery{
+				Limit:  10,
+				Cursor: "abc",
+				Sort:   "created_at",
+				Desc:   true,
+				Filters: []ListFilter{
+					{Field: "email", Operator: "like", Value: "foo"},
+					{Field: "is_activated", Operator: "eq", Value: "true"},
+				},
+			},
+		},
+		{
+			name: "filter value with colons",
+			url:  "/?filter=created_at:gte:2025-01-01T00:00:00Z",
+			expected: &ListQuery{
+				Limit: DefaultListLimit,
+				Filters: []ListFilter{
+					{Field: "created_at", Operator: "gte", Value: "2025-01-01T00:00:00Z"},
+				},
+			},
+		},
+		{
+			name:    "invalid limit",
+			url:     "/?limit=abc",
+			message: "Limit must be a number between 1 and 500.",
+		},
+		{
+			name:    "limit too large",
+			url:     "/?limit=501",
+			message: "Limit must be a number between 1 and 500.",
+		},
+		{
+			name:    "invalid filter format",
+			url:     "/?filter=email",
+			message: `Invalid filter "email", expected format is field:operator:value.`,
+		},
+		{
+			name:    "empty filter field",
+			url:     "/?filter=:eq:foo",
+			message: `Invalid filter ":eq:foo", field must not be empty.`,
+		},
+		{
+			name: "filter value with colons and no operator",
+			url:  "/?filter=name:payments:api&filter=email:in:foo",
+			expected: &ListQuery{
+				Limit: DefaultListLimit,
+				Filters: []ListFilter{
+					{Field: "name", Operator: "eq", Value: "payments:api"},
+					{Field: "email", Operator: "eq", Value: "in:foo"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := Event{
+				Request:  httptest.NewRequest(http.MethodGet, tc.url, nil),
+				Response: httptest.NewRecorder(),
+			}
+
+			q, err := e.ListQuery()
+
+			if tc.message != "" {
+				if err == nil {
+					t.Fatal("expected error not to be nil")
+				}
+				if err.Status != http.StatusBadRequest {
+					t.Fatalf("expected error status to be 400, got %d", err.Status)
+				}
+				if err.Message != tc.message {
+					t.Fatalf("expected error message to be %q, got %q", tc.message, err.Message)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected error to be nil, got %v", err)
+			}
+
+			if !reflect.DeepEqual(q, tc.expected) {
+				t.Fatalf("expected list query to be %+v, got %+v", tc.expected, q)
+			}
+		})
+	}
+}
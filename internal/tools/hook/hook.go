@@ -0,0 +1,56 @@
+package hook
+
+import (
+	"slices"
+	"sync"
+)
+
+// Hook is a list of handlers of the events of type T, e.g. the changes of
+// the models of the app. Handlers run in the order they were bound.
+type Hook[T any] struct {
+	mu       sync.RWMutex
+	handlers []*handler[T]
+	lastId   int
+}
+
+type handler[T any] struct {
+	id int
+	fn func(e T) error
+}
+
+// Bind adds the handler to the hook, and returns its id to unbind it.
+func (h *Hook[T]) Bind(fn func(e T) error) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastId++
+	h.handlers = append(h.handlers, &handler[T]{id: h.lastId, fn: fn})
+
+	return h.lastId
+}
+
+// Unbind removes the handler with the id from the hook.
+func (h *Hook[T]) Unbind(id int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.handlers = slices.DeleteFunc(h.handlers, func(handler *handler[T]) bool {
+		return handler.id == id
+	})
+}
+
+// Trigger runs the handlers with the event, and stops at the first one
+// failing, whose error it returns.
+func (h *Hook[T]) Trigger(e T) error {
+	h.mu.RLock()
+	handlers := slices.Clone(h.handlers)
+	h.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler.fn(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
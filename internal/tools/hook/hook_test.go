@@ -0,0 +1,54 @@
+package hook
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestHook(t *testing.T) {
+	t.Parallel()
+
+	var h Hook[*[]string]
+
+	record := func(name string, err error) func(*[]string) error {
+		return func(calls *[]string) error {
+			*calls = append(*calls, name)
+			return err
+		}
+	}
+
+	h.Bind(record("a", nil))
+	b := h.Bind(record("b", nil))
+	h.Bind(record("c", errors.New("c failed")))
+	h.Bind(record("d", nil))
+
+	var calls []string
+
+	if err := h.Trigger(&calls); err == nil || err.Error() != "c failed" {
+		t.Fatalf("expected the error of the failing handler, got %v", err)
+	}
+
+	if !slices.Equal(calls, []string{"a", "b", "c"}) {
+		t.Fatalf("expected handlers to run in order until one fails, got %v", calls)
+	}
+
+	h.Unbind(b)
+
+	calls = nil
+	_ = h.Trigger(&calls)
+
+	if !slices.Equal(calls, []string{"a", "c"}) {
+		t.Fatalf("expected unbound handler not to run, got %v", calls)
+	}
+}
+
+func TestHookEmpty(t *testing.T) {
+	t.Parallel()
+
+	var h Hook[string]
+
+	if err := h.Trigger("event"); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // contextKey is the logger string type used to avoid context collisions.
@@ -70,10 +72,118 @@ func NewLoggerWithConfig(config Config) *slog.Logger {
 	}
 
 	if config.Format == formatJson {
-		return slog.New(slog.NewJSONHandler(os.Stderr, options))
+		return slog.New(NewTraceHandler(slog.NewJSONHandler(os.Stderr, options)))
+	}
+
+	return slog.New(NewTraceHandler(slog.NewTextHandler(os.Stderr, options)))
+}
+
+// traceHandler adds the trace and span ids of the span of the context of
+// the records, if any, so that the logs of a request can be found from its
+// trace and the other way around.
+type traceHandler struct {
+	slog.Handler
+}
+
+// NewTraceHandler wraps the handler to add the `trace_id` and `span_id` of
+// the span of the context of the records, e.g. the one of a request logged
+// with `logger.InfoContext(req.Context(), ...)`.
+func NewTraceHandler(handler slog.Handler) slog.Handler {
+	return &traceHandler{Handler: handler}
+}
+
+func (h *traceHandler) Handle(ctx context.Context, record slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &traceHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+	return &traceHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// Redacted replaces the secrets in the logs.
+const Redacted = "[REDACTED]"
+
+// redactHandler replaces the secrets in the messages and the attributes of
+// the records, e.g. in the error of a call that includes its credentials.
+type redactHandler struct {
+	slog.Handler
+	replacer *strings.Replacer
+}
+
+// NewRedactHandler wraps the handler to replace the secrets in the records
+// with Redacted.
+func NewRedactHandler(handler slog.Handler, secrets []string) slog.Handler {
+	var pairs []string
+
+	for _, secret := range secrets {
+		if secret != "" {
+			pairs = append(pairs, secret, Redacted)
+		}
+	}
+
+	if len(pairs) == 0 {
+		return handler
+	}
+
+	return &redactHandler{Handler: handler, replacer: strings.NewReplacer(pairs...)}
+}
+
+func (h *redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.replacer.Replace(record.Message), record.PC)
+
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redact(attr))
+		return true
+	})
+
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redact(attr)
+	}
+	return &redactHandler{Handler: h.Handler.WithAttrs(redacted), replacer: h.replacer}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{Handler: h.Handler.WithGroup(name), replacer: h.replacer}
+}
+
+func (h *redactHandler) redact(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		attr.Value = slog.StringValue(h.replacer.Replace(attr.Value.String()))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, a := range group {
+			redacted[i] = h.redact(a)
+		}
+		attr.Value = slog.GroupValue(redacted...)
+	case slog.KindAny:
+		// Values, e.g. errors, are only replaced by their redacted text
+		// when they include a secret.
+		s := fmt.Sprint(attr.Value.Any())
+		if r := h.replacer.Replace(s); r != s {
+			attr.Value = slog.StringValue(r)
+		}
 	}
 
-	return slog.New(slog.NewTextHandler(os.Stderr, options))
+	return attr
 }
 
 func DefaultLogger() *slog.Logger {
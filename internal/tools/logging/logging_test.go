@@ -1,10 +1,15 @@
 package logging
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"log/slog"
+	"strings"
 	"testing"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestNewLogger(t *testing.T) {
@@ -224,3 +229,71 @@ func TestGetLogLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestTraceHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := slog.New(NewTraceHandler(slog.NewJSONHandler(&buf, nil))).With(slog.String("app", "test"))
+
+	logger.InfoContext(t.Context(), "untraced")
+
+	if strings.Contains(buf.String(), "trace_id") {
+		t.Fatalf("expected no trace id without span, got %s", buf.String())
+	}
+
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	ctx := trace.ContextWithSpanContext(t.Context(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceId,
+		SpanID:  spanId,
+	}))
+
+	buf.Reset()
+	logger.InfoContext(ctx, "traced")
+
+	for _, expected := range []string{
+		`"app":"test"`,
+		`"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`,
+		`"span_id":"00f067aa0ba902b7"`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Fatalf("expected %s in log record, got %s", expected, buf.String())
+		}
+	}
+}
+
+func TestRedactHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	handler := NewRedactHandler(slog.NewJSONHandler(&buf, nil), []string{"s3cret", ""})
+	logger := slog.New(handler).With(slog.String("dsn", "postgres://app:s3cret@db"))
+
+	logger.Error("login with s3cret failed",
+		slog.Group("request", slog.String("password", "s3cret")),
+		slog.Any("error", errors.New("dial postgres://app:s3cret@db failed")),
+		slog.Int("attempt", 1),
+	)
+
+	if strings.Contains(buf.String(), "s3cret") {
+		t.Fatalf("expected secret to be redacted, got %s", buf.String())
+	}
+
+	for _, expected := range []string{
+		`"msg":"login with [REDACTED] failed"`,
+		`"dsn":"postgres://app:[REDACTED]@db"`,
+		`"request":{"password":"[REDACTED]"}`,
+		`"error":"dial postgres://app:[REDACTED]@db failed"`,
+		`"attempt":1`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Fatalf("expected %s in log record, got %s", expected, buf.String())
+		}
+	}
+
+	// Handlers without secrets are not wrapped.
+	json := slog.NewJSONHandler(&buf, nil)
+	if NewRedactHandler(json, nil) != slog.Handler(json) {
+		t.Fatalf("expected handler not to be wrapped without secrets")
+	}
+}
@@ -0,0 +1,99 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultPort = 587
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+type Mailer interface {
+	// Send delivers the message to its recipient.
+	Send(message *Message) error
+}
+
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Sender   string
+}
+
+// Ensures that the SmtpClient implements the Mailer interface.
+var _ Mailer = (*SmtpClient)(nil)
+
+type SmtpClient struct {
+	config Config
+}
+
+func NewSmtpClient(config Config) *SmtpClient {
+	if config.Port < 1 {
+		config.Port = defaultPort
+	}
+
+	return &SmtpClient{config: config}
+}
+
+// Send delivers a plain text message through the SMTP server. The
+// connection is upgraded with STARTTLS when the server supports it.
+func (c *SmtpClient) Send(message *Message) error {
+	if c.config.Host == "" {
+		return errors.New("smtp host not configured")
+	}
+
+	sender, err := mail.ParseAddress(c.config.Sender)
+	if err != nil {
+		return fmt.Errorf("invalid sender address; %v", err)
+	}
+
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address; %v", err)
+	}
+
+	var auth smtp.Auth
+	if c.config.Username != "" {
+		auth = smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host)
+	}
+
+	addr := net.JoinHostPort(c.config.Host, strconv.Itoa(c.config.Port))
+
+	return smtp.SendMail(addr, auth, sender.Address, []string{to.Address}, build(sender, to, message))
+}
+
+func build(sender *mail.Address, to *mail.Address, message *Message) []byte {
+	var buf bytes.Buffer
+
+	headers := [][2]string{
+		{"From", sender.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Subject)},
+		{"Date", time.Now().UTC().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+	}
+
+	for _, h := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", h[0], h[1])
+	}
+
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(message.Text, "\n", "\r\n"))
+
+	return buf.Bytes()
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+
+	sender := &mail.Address{Name: "ScopeHouse", Address: "noreply@example.com"}
+	to := &mail.Address{Address: "user@example.com"}
+
+	msg := string(build(sender, to, &Message{
+		Subject: "Activate your account",
+		Text:    "line 1\nline 2",
+	}))
+
+	content := []string{
+		"From: \"ScopeHouse\" <noreply@example.com>\r\n",
+		"To: <user@example.com>\r\n",
+		"Subject: Activate your account\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nline 1\r\nline 2",
+	}
+
+	for _, item := range content {
+		if !strings.Contains(msg, item) {
+			t.Fatalf("expected content %q in message \n%v", item, msg)
+		}
+	}
+}
+
+func TestSendNotConfigured(t *testing.T) {
+	t.Parallel()
+
+	client := NewSmtpClient(Config{})
+
+	err := client.Send(&Message{To: "user@example.com"})
+	if err == nil || err.Error() != "smtp host not configured" {
+		t.Fatalf("expected smtp host not configured error, got %v", err)
+	}
+}
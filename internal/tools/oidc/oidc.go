@@ -0,0 +1,199 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const defaultGroupsClaim = "groups"
+
+var ErrEmailNotVerified = errors.New("email not verified")
+
+type Config struct {
+	IssuerUrl    string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+
+	// GroupsClaim is the id token claim listing the user groups.
+	GroupsClaim string
+
+	// GroupRoles maps identity provider groups to ScopeHouse roles.
+	GroupRoles map[string]string
+}
+
+// Identity is the verified identity of a user signed in with the provider.
+type Identity struct {
+	Subject string
+	Email   string
+	Groups  []string
+}
+
+type Provider struct {
+	config   Config
+	oauth2   oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+// NewProvider discovers the issuer endpoints and keys from its
+// `/.well-known/openid-configuration` document.
+func NewProvider(ctx context.Context, config Config) (*Provider, error) {
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = defaultGroupsClaim
+	}
+
+	provider, err := gooidc.NewProvider(ctx, config.IssuerUrl)
+	if err != nil {
+		return nil, fmt.Errorf("oidc provider discovery failed; %v", err)
+	}
+
+	p := &Provider{
+		config: config,
+		oauth2: oauth2.Config{
+			ClientID:     config.ClientId,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectUrl,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{gooidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: provider.Verifier(&gooidc.Config{ClientID: config.ClientId}),
+	}
+
+	return p, nil
+}
+
+// AuthCodeUrl returns the provider URL the user is redirected to in order to
+// sign in, using PKCE with the S256 challenge of the given verifier.
+func (p *Provider) AuthCodeUrl(state string, nonce string, verifier string) string {
+	return p.oauth2.AuthCodeURL(
+		state,
+		gooidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(verifier),
+	)
+}
+
+// Exchange trades the authorization code for the user id token, and returns
+// the identity found in it once its signature, audience, expiry and nonce
+// have been verified.
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string, nonce string) (*Identity, error) {
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc code exchange failed; %v", err)
+	}
+
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("oidc token response is missing the id token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIdToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidc id token verification failed; %v", err)
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, errors.New("oidc id token nonce mismatch")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("oidc id token claims decoding failed; %v", err)
+	}
+
+	email, _ := claims["email"].(string)
+	if email == "" {
+		return nil, errors.New("oidc id token is missing the email claim")
+	}
+
+	// Emails are trusted to identify the users, so they must be verified,
+	// the providers that do not send the claim included.
+	if !emailVerified(claims["email_verified"]) {
+		return nil, ErrEmailNotVerified
+	}
+
+	identity := &Identity{
+		Subject: idToken.Subject,
+		Email:   strings.ToLower(email),
+		Groups:  stringSlice(claims[p.config.GroupsClaim]),
+	}
+
+	return identity, nil
+}
+
+// emailVerified reports whether the email_verified claim is true, some
+// providers sending it as a string.
+func emailVerified(claim any) bool {
+	switch v := claim.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
+
+// Roles returns the sorted roles mapped to the identity groups.
+func (p *Provider) Roles(identity *Identity) []string {
+	roles := []string{}
+
+	for _, group := range identity.Groups {
+		role, ok := p.config.GroupRoles[group]
+		if ok && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	slices.Sort(roles)
+
+	return roles
+}
+
+// stringSlice converts a claim holding either a list of strings or a single
+// string to a slice.
+func stringSlice(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return []string{}
+	}
+}
+
+// ParseGroupRoles parses a group to role mapping in the form of
+// `group1=role1,group2=role2`.
+func ParseGroupRoles(s string) (map[string]string, error) {
+	groupRoles := map[string]string{}
+
+	for pair := range strings.SplitSeq(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		group, role, ok := strings.Cut(pair, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+
+		if !ok || group == "" || role == "" {
+			return nil, fmt.Errorf("invalid group role mapping %q", pair)
+		}
+
+		groupRoles[group] = role
+	}
+
+	return groupRoles, nil
+}
@@ -0,0 +1,113 @@
+package oidc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGroupRoles(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		value    string
+		expected map[string]string
+		isErr    bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"single", "sre=admin", map[string]string{"sre": "admin"}, false},
+		{"multiple", " sre = admin, devs=editor,", map[string]string{"sre": "admin", "devs": "editor"}, false},
+		{"missing role", "sre=", nil, true},
+		{"missing separator", "sre", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			groupRoles, err := ParseGroupRoles(tc.value)
+
+			if tc.isErr {
+				if err == nil {
+					t.Fatal("expected error not to be nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected error to be nil, got %v", err)
+			}
+
+			if !reflect.DeepEqual(groupRoles, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, groupRoles)
+			}
+		})
+	}
+}
+
+func TestProviderRoles(t *testing.T) {
+	t.Parallel()
+
+	p := &Provider{
+		config: Config{
+			GroupRoles: map[string]string{
+				"sre":     "admin",
+				"devs":    "editor",
+				"leads":   "editor",
+				"unknown": "viewer",
+			},
+		},
+	}
+
+	identity := &Identity{Groups: []string{"leads", "sre", "devs", "other"}}
+
+	roles := p.Roles(identity)
+	expected := []string{"admin", "editor"}
+
+	if !reflect.DeepEqual(roles, expected) {
+		t.Fatalf("expected %v, got %v", expected, roles)
+	}
+}
+
+func TestStringSlice(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		claim    any
+		expected []string
+	}{
+		{"list", []any{"a", 1, "b"}, []string{"a", "b"}},
+		{"string", "a", []string{"a"}},
+		{"missing", nil, []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if values := stringSlice(tc.claim); !reflect.DeepEqual(values, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, values)
+			}
+		})
+	}
+}
+
+func TestEmailVerified(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		claim    any
+		expected bool
+	}{
+		{"true", true, true},
+		{"false", false, false},
+		{"string", "true", true},
+		{"missing", nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if verified := emailVerified(tc.claim); verified != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, verified)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Package prometheus calls the HTTP API of Prometheus servers.
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxErrorBodyBytes bounds the part of the error responses of Prometheus
+// that is read into error messages.
+const maxErrorBodyBytes = 1024
+
+// Client calls the API of a Prometheus server.
+type Client struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewClient returns a client of the Prometheus server listening on baseUrl,
+// e.g. `http://prometheus:9090`.
+func NewClient(baseUrl string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		url:        strings.TrimSuffix(baseUrl, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// ApiError is an error response of the Prometheus API.
+type ApiError struct {
+	Path   string
+	Status int
+	Body   string
+}
+
+func (e *ApiError) Error() string {
+	return fmt.Sprintf("GET %s: unexpected status %d: %s", e.Path, e.Status, e.Body)
+}
+
+// Alert is an alert of the alerting rules of a Prometheus server, `pending`
+// until it has been active for the duration of its rule, then `firing`.
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	State       string            `json:"state"`
+	ActiveAt    time.Time         `json:"activeAt"`
+	Value       string            `json:"value"`
+}
+
+// Alerts returns the pending and firing alerts of the server.
+func (c *Client) Alerts(ctx context.Context) ([]*Alert, error) {
+	var resp struct {
+		Data struct {
+			Alerts []*Alert `json:"alerts"`
+		} `json:"data"`
+	}
+
+	if err := c.get(ctx, "/api/v1/alerts", &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Data.Alerts, nil
+}
+
+// get sends the request and decodes the json response into out. Responses
+// with a status other than 2xx are returned as errors.
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+path, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodyBytes))
+		return &ApiError{
+			Path:   path,
+			Status: res.StatusCode,
+			Body:   strings.TrimSpace(string(msg)),
+		}
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("GET %s: invalid response; %v", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientAlerts(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/alerts", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"status": "success",
+			"data": {
+				"alerts": [{
+					"labels": {"alertname": "HighLatency", "severity": "warning"},
+					"annotations": {"summary": "Latency is high"},
+					"state": "pending",
+					"activeAt": "2030-01-01T00:00:00Z",
+					"value": "1.5e+00"
+				}]
+			}
+		}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	alerts, err := NewClient(server.URL+"/", nil).Alerts(context.Background())
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(alerts))
+	}
+
+	alert := alerts[0]
+
+	if alert.Labels["alertname"] != "HighLatency" || alert.State != "pending" || alert.ActiveAt.IsZero() {
+		t.Fatalf("unexpected alert %+v", alert)
+	}
+}
+
+func TestClientError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL, nil).Alerts(context.Background())
+
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusServiceUnavailable || apiErr.Body != "unavailable" {
+		t.Fatalf("expected api error, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+// Package schedule computes the occurrences of recurring events described
+// by cron expressions or iCalendar recurrence rules.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/teambition/rrule-go"
+)
+
+const (
+	// KindCron schedules are standard 5 field cron expressions, e.g.
+	// `0 2 * * SUN`, or descriptors such as `@weekly`.
+	KindCron = "cron"
+
+	// KindRRule schedules are RFC 5545 recurrence rules, e.g.
+	// `FREQ=WEEKLY;BYDAY=SU;BYHOUR=2`.
+	KindRRule = "rrule"
+)
+
+// Schedule returns the occurrences of a recurring event.
+type Schedule interface {
+	// Next returns the first occurrence strictly after t, or the zero time
+	// when there is none.
+	Next(t time.Time) time.Time
+}
+
+// Parse parses the expression of a schedule of the given kind, whose times
+// are in the location, e.g. `0 2 * * SUN` in Europe/Berlin occurs at 2 AM
+// local time whether daylight saving time is in effect or not. Recurrence
+// rules without a DTSTART start at midnight of the day of start in the
+// location.
+func Parse(kind string, expr string, loc *time.Location, start time.Time) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, errors.New("schedule is empty")
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	switch kind {
+	case KindCron:
+		return parseCron(expr, loc)
+	case KindRRule:
+		return parseRRule(expr, loc, start)
+	default:
+		return nil, fmt.Errorf("unknown schedule kind %q", kind)
+	}
+}
+
+// LoadLocation returns the location with the IANA name, e.g.
+// `Europe/Berlin`, UTC when empty.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "UTC" {
+		return time.UTC, nil
+	}
+
+	// The local time zone of the server is not a valid time zone of a
+	// schedule, it differs between replicas.
+	if name == "Local" {
+		return nil, errors.New("unknown time zone Local")
+	}
+
+	return time.LoadLocation(name)
+}
+
+type cronSchedule struct {
+	schedule cron.Schedule
+	loc      *time.Location
+}
+
+func parseCron(expr string, loc *time.Location) (Schedule, error) {
+	// The location is given apart from the expression.
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		return nil, errors.New("time zone must not be set in the cron expression")
+	}
+
+	s, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cronSchedule{schedule: s, loc: loc}, nil
+}
+
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	next := s.schedule.Next(t.In(s.loc))
+	if next.IsZero() {
+		return next
+	}
+	return next.In(t.Location())
+}
+
+type rruleSchedule struct {
+	rule *rrule.RRule
+}
+
+func parseRRule(expr string, loc *time.Location, start time.Time) (Schedule, error) {
+	opt, err := rrule.StrToROptionInLocation(expr, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.Dtstart.IsZero() {
+		y, m, d := start.In(loc).Date()
+		opt.Dtstart = time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+
+	rule, err := rrule.NewRRule(*opt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rruleSchedule{rule: rule}, nil
+}
+
+func (s *rruleSchedule) Next(t time.Time) time.Time {
+	next := s.rule.After(t, false)
+	if next.IsZero() {
+		return next
+	}
+	return next.In(t.Location())
+}
@@ -0,0 +1,141 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	// Sunday, a week before daylight saving time starts in Berlin.
+	start := time.Date(2026, time.March, 22, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name string
+		kind string
+		expr string
+		loc  *time.Location
+		next []time.Time
+	}{
+		{
+			"cron utc",
+			KindCron,
+			"0 2 * * SUN",
+			time.UTC,
+			[]time.Time{
+				time.Date(2026, time.March, 29, 2, 0, 0, 0, time.UTC),
+				time.Date(2026, time.April, 5, 2, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			"cron across daylight saving time",
+			KindCron,
+			"30 3 * * SUN",
+			berlin,
+			[]time.Time{
+				time.Date(2026, time.March, 29, 1, 30, 0, 0, time.UTC),
+				time.Date(2026, time.April, 5, 1, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			"cron descriptor",
+			KindCron,
+			"@daily",
+			berlin,
+			[]time.Time{
+				time.Date(2026, time.March, 22, 23, 0, 0, 0, time.UTC),
+				time.Date(2026, time.March, 23, 23, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			"rrule across daylight saving time",
+			KindRRule,
+			"FREQ=WEEKLY;BYDAY=SU;BYHOUR=3;BYMINUTE=30",
+			berlin,
+			[]time.Time{
+				time.Date(2026, time.March, 29, 1, 30, 0, 0, time.UTC),
+				time.Date(2026, time.April, 5, 1, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			"rrule first sunday of the month",
+			KindRRule,
+			"RRULE:FREQ=MONTHLY;BYDAY=1SU;BYHOUR=2",
+			time.UTC,
+			[]time.Time{
+				time.Date(2026, time.April, 5, 2, 0, 0, 0, time.UTC),
+				time.Date(2026, time.May, 3, 2, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			"rrule with dtstart and count",
+			KindRRule,
+			"DTSTART;TZID=Europe/Berlin:20260401T220000\nRRULE:FREQ=DAILY;COUNT=2",
+			time.UTC,
+			[]time.Time{
+				time.Date(2026, time.April, 1, 20, 0, 0, 0, time.UTC),
+				time.Date(2026, time.April, 2, 20, 0, 0, 0, time.UTC),
+				{},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := Parse(tc.kind, tc.expr, tc.loc, start)
+			if err != nil {
+				t.Fatalf("expected error to be nil, got %v", err)
+			}
+
+			after := start
+
+			for _, expected := range tc.next {
+				next := s.Next(after)
+				if !next.Equal(expected) {
+					t.Fatalf("expected next occurrence after %v to be %v, got %v", after, expected, next)
+				}
+				after = next
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		kind string
+		expr string
+	}{
+		{"empty", KindCron, " "},
+		{"unknown kind", "interval", "1h"},
+		{"invalid cron", KindCron, "0 25 * * *"},
+		{"cron with seconds", KindCron, "0 0 2 * * SUN"},
+		{"cron with time zone", KindCron, "CRON_TZ=Europe/Berlin 0 2 * * *"},
+		{"rrule without freq", KindRRule, "BYDAY=SU"},
+		{"invalid rrule", KindRRule, "FREQ=SOMETIMES"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse(tc.kind, tc.expr, time.UTC, time.Now()); err == nil {
+				t.Fatal("expected error not to be nil")
+			}
+		})
+	}
+
+	if _, err := LoadLocation("Local"); err == nil {
+		t.Fatal("expected local time zone to be invalid")
+	}
+
+	if _, err := LoadLocation("Mars/Olympus"); err == nil {
+		t.Fatal("expected unknown time zone to be invalid")
+	}
+}
@@ -0,0 +1,187 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownKey = errors.New("secret master key unknown")
+
+// Envelope is a secret encrypted with envelope encryption: the secret is
+// encrypted with a data key of its own, stored along with it wrapped, i.e.
+// encrypted, with the master key of KeyId. The master keys are never
+// stored with the secrets.
+//
+// Envelopes without a KeyId were encrypted with the master key directly,
+// before the data keys were introduced.
+type Envelope struct {
+	KeyId      string
+	WrappedKey []byte
+	Ciphertext []byte
+}
+
+// Keyring seals the secrets into envelopes with its current master key, and
+// opens the ones of its previous master keys, so that the master key can
+// be rotated without losing the secrets sealed with the previous one.
+type Keyring struct {
+	current string
+	ciphers map[string]*Cipher
+
+	// order lists the ids of the master keys, the current one first.
+	order []string
+}
+
+// NewKeyring creates a keyring sealing the secrets with the current master
+// key, and opening them with any of the master keys.
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{ciphers: map[string]*Cipher{}}
+
+	for _, key := range append([][]byte{current}, previous...) {
+		c, err := NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		id := KeyId(key)
+		if _, ok := k.ciphers[id]; ok {
+			continue
+		}
+
+		k.ciphers[id] = c
+		k.order = append(k.order, id)
+	}
+
+	k.current = k.order[0]
+
+	return k, nil
+}
+
+// KeyId returns the id of the master key, derived from the key so that it
+// needs no configuration, without revealing it.
+func KeyId(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// KeyId returns the id of the current master key.
+func (k *Keyring) KeyId() string {
+	return k.current
+}
+
+// Seal encrypts the plaintext with a new data key, wrapped with the current
+// master key. Both are bound to the additional data, e.g. the id of the
+// record holding them.
+func (k *Keyring) Seal(plaintext []byte, additionalData []byte) (*Envelope, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	c, err := NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := c.Encrypt(plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := k.ciphers[k.current].Encrypt(dataKey, additionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		KeyId:      k.current,
+		WrappedKey: wrappedKey,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// Open decrypts the secret of the envelope, bound to the same additional
+// data. It fails with ErrUnknownKey when the envelope was sealed with a
+// master key not in the keyring.
+func (k *Keyring) Open(e *Envelope, additionalData []byte) ([]byte, error) {
+	if e.KeyId == "" {
+		return k.openUnwrapped(e, additionalData)
+	}
+
+	dataKey, err := k.unwrap(e, additionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Decrypt(e.Ciphertext, additionalData)
+}
+
+// Rewrap wraps the data key of the envelope with the current master key,
+// leaving its ciphertext as is. The envelopes without a data key are
+// sealed again. It reports whether the envelope changed, i.e. whether it
+// was not wrapped with the current master key already.
+func (k *Keyring) Rewrap(e *Envelope, additionalData []byte) (bool, error) {
+	if e.KeyId == k.current {
+		return false, nil
+	}
+
+	if e.KeyId == "" {
+		plaintext, err := k.openUnwrapped(e, additionalData)
+		if err != nil {
+			return false, err
+		}
+
+		sealed, err := k.Seal(plaintext, additionalData)
+		if err != nil {
+			return false, err
+		}
+
+		*e = *sealed
+
+		return true, nil
+	}
+
+	dataKey, err := k.unwrap(e, additionalData)
+	if err != nil {
+		return false, err
+	}
+
+	wrappedKey, err := k.ciphers[k.current].Encrypt(dataKey, additionalData)
+	if err != nil {
+		return false, err
+	}
+
+	e.KeyId = k.current
+	e.WrappedKey = wrappedKey
+
+	return true, nil
+}
+
+// unwrap decrypts the data key of the envelope with its master key.
+func (k *Keyring) unwrap(e *Envelope, additionalData []byte) ([]byte, error) {
+	c, ok := k.ciphers[e.KeyId]
+	if !ok {
+		return nil, fmt.Errorf("%w; %s", ErrUnknownKey, e.KeyId)
+	}
+
+	return c.Decrypt(e.WrappedKey, additionalData)
+}
+
+// openUnwrapped decrypts the envelopes encrypted with a master key directly,
+// trying each of them since their key is not recorded.
+func (k *Keyring) openUnwrapped(e *Envelope, additionalData []byte) ([]byte, error) {
+	for _, id := range k.order {
+		if plaintext, err := k.ciphers[id].Decrypt(e.Ciphertext, additionalData); err == nil {
+			return plaintext, nil
+		}
+	}
+
+	return nil, ErrDecrypt
+}
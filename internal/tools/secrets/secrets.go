@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// filePrefix prefixes the references to the files holding a secret,
+	// e.g. `file:///run/secrets/db`.
+	filePrefix = "file://"
+
+	// envPrefix prefixes the references to the environment variables
+	// holding a secret, e.g. `env:DATABASE_PASSWORD`.
+	envPrefix = "env:"
+)
+
+// IsReference reports whether the value references a secret held by a file
+// or an environment variable.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, filePrefix) || strings.HasPrefix(value, envPrefix)
+}
+
+// Resolve returns the secret referenced by the value, or the value itself
+// when it is not a reference. The trailing newline of the files is
+// trimmed.
+func Resolve(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, filePrefix):
+		path := strings.TrimPrefix(value, filePrefix)
+		if path == "" {
+			return "", errors.New("secret file path is empty")
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("secret file read failed; %v", err)
+		}
+
+		return strings.TrimRight(string(b), "\r\n"), nil
+
+	case strings.HasPrefix(value, envPrefix):
+		name := strings.TrimPrefix(value, envPrefix)
+		if name == "" {
+			return "", errors.New("secret variable name is empty")
+		}
+
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret variable %s is not set", name)
+		}
+
+		return secret, nil
+
+	default:
+		return value, nil
+	}
+}
+
+// ReferencedEnv returns the environment variable referenced by the value,
+// or an empty string when it does not reference one.
+func ReferencedEnv(value string) string {
+	if name, ok := strings.CutPrefix(value, envPrefix); ok {
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "db")
+	if err := os.WriteFile(file, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("failed to write secret file; %v", err)
+	}
+
+	t.Setenv("SECRETS_TEST_PASSWORD", "hunter2")
+
+	scenarios := []struct {
+		value    string
+		expected string
+		error    string
+	}{
+		{value: "plain", expected: "plain"},
+		{value: "file://" + file, expected: "s3cret"},
+		{value: "env:SECRETS_TEST_PASSWORD", expected: "hunter2"},
+		{value: "file://" + file + ".missing", error: "secret file read failed"},
+		{value: "env:SECRETS_TEST_MISSING", error: "secret variable SECRETS_TEST_MISSING is not set"},
+		{value: "env:", error: "secret variable name is empty"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.value, func(t *testing.T) {
+			secret, err := Resolve(s.value)
+
+			if s.error != "" {
+				if err == nil || !strings.Contains(err.Error(), s.error) {
+					t.Fatalf("expected error %q, got %v", s.error, err)
+				}
+				return
+			}
+
+			if err != nil || secret != s.expected {
+				t.Fatalf("expected %q, got %q (%v)", s.expected, secret, err)
+			}
+		})
+	}
+}
+
+func TestCipher(t *testing.T) {
+	t.Parallel()
+
+	key, err := ParseKey(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, KeySize)))
+	if err != nil {
+		t.Fatalf("failed to parse key; %v", err)
+	}
+
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatalf("failed to create cipher; %v", err)
+	}
+
+	ciphertext, err := c.Encrypt([]byte("token"), []byte("cluster-1"))
+	if err != nil {
+		t.Fatalf("failed to encrypt; %v", err)
+	}
+
+	if bytes.Contains(ciphertext, []byte("token")) {
+		t.Fatalf("expected ciphertext not to contain the plaintext")
+	}
+
+	plaintext, err := c.Decrypt(ciphertext, []byte("cluster-1"))
+	if err != nil || string(plaintext) != "token" {
+		t.Fatalf("expected token, got %q (%v)", plaintext, err)
+	}
+
+	// The ciphertext is bound to its additional data.
+	if _, err := c.Decrypt(ciphertext, []byte("cluster-2")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected decrypt error, got %v", err)
+	}
+
+	if _, err := ParseKey(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Fatalf("expected key size error")
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	t.Parallel()
+
+	oldKey := bytes.Repeat([]byte{1}, KeySize)
+	newKey := bytes.Repeat([]byte{2}, KeySize)
+
+	old, err := NewKeyring(oldKey)
+	if err != nil {
+		t.Fatalf("failed to create keyring; %v", err)
+	}
+
+	envelope, err := old.Seal([]byte("token"), []byte("cluster-1"))
+	if err != nil {
+		t.Fatalf("failed to seal; %v", err)
+	}
+
+	if envelope.KeyId != KeyId(oldKey) || bytes.Contains(envelope.Ciphertext, []byte("token")) {
+		t.Fatalf("expected envelope sealed with the old key, got %+v", envelope)
+	}
+
+	// Each envelope has a data key of its own.
+	other, _ := old.Seal([]byte("token"), []byte("cluster-1"))
+	if bytes.Equal(other.WrappedKey, envelope.WrappedKey) {
+		t.Fatal("expected envelopes to have their own data key")
+	}
+
+	rotated, err := NewKeyring(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("failed to create keyring; %v", err)
+	}
+
+	// The keyring opens the envelopes of its previous keys, and rewraps
+	// them with its current one without encrypting them again.
+	plaintext, err := rotated.Open(envelope, []byte("cluster-1"))
+	if err != nil || string(plaintext) != "token" {
+		t.Fatalf("expected token, got %q (%v)", plaintext, err)
+	}
+
+	ciphertext := envelope.Ciphertext
+
+	if changed, err := rotated.Rewrap(envelope, []byte("cluster-1")); !changed || err != nil {
+		t.Fatalf("expected envelope to be rewrapped, got %v (%v)", changed, err)
+	}
+
+	if envelope.KeyId != KeyId(newKey) || !bytes.Equal(envelope.Ciphertext, ciphertext) {
+		t.Fatalf("expected data key wrapped with the new key, got %+v", envelope)
+	}
+
+	if changed, _ := rotated.Rewrap(envelope, []byte("cluster-1")); changed {
+		t.Fatal("expected envelope of the current key not to change")
+	}
+
+	current, _ := NewKeyring(newKey)
+
+	if plaintext, err := current.Open(envelope, []byte("cluster-1")); err != nil || string(plaintext) != "token" {
+		t.Fatalf("expected token, got %q (%v)", plaintext, err)
+	}
+
+	if _, err := old.Open(envelope, []byte("cluster-1")); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+
+	if _, err := current.Open(envelope, []byte("cluster-2")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected decrypt error, got %v", err)
+	}
+
+	// The secrets encrypted with a master key directly are sealed again.
+	c, _ := NewCipher(oldKey)
+	direct, _ := c.Encrypt([]byte("password"), []byte("cluster-2"))
+
+	legacy := &Envelope{Ciphertext: direct}
+
+	if changed, err := rotated.Rewrap(legacy, []byte("cluster-2")); !changed || err != nil {
+		t.Fatalf("expected envelope to be sealed again, got %v (%v)", changed, err)
+	}
+
+	if plaintext, err := current.Open(legacy, []byte("cluster-2")); err != nil || string(plaintext) != "password" {
+		t.Fatalf("expected password, got %q (%v)", plaintext, err)
+	}
+}
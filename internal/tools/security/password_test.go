@@ -0,0 +1,67 @@
+package security
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	t.Parallel()
+
+	hash, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Fatalf("expected argon2id encoded hash, got %s", hash)
+	}
+
+	other, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	if hash == other {
+		t.Fatal("expected hashes of the same password to use different salts")
+	}
+}
+
+func TestComparePassword(t *testing.T) {
+	t.Parallel()
+
+	hash, err := HashPassword("secret-password")
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		password string
+		hash     string
+		match    bool
+		err      error
+	}{
+		{"match", "secret-password", hash, true, nil},
+		{"mismatch", "wrong-password", hash, false, nil},
+		{"empty hash", "secret-password", "", false, ErrInvalidHash},
+		{"bcrypt hash", "secret-password", "$2a$12$abcdefghijklmnopqrstuv", false, ErrInvalidHash},
+		{"invalid params", "secret-password", "$argon2id$v=19$m=x$c2FsdA$a2V5", false, ErrInvalidHash},
+		{"invalid salt", "secret-password", "$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5", false, ErrInvalidHash},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			match, err := ComparePassword(tc.password, tc.hash)
+
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected error to be %v, got %v", tc.err, err)
+			}
+
+			if match != tc.match {
+				t.Fatalf("expected match to be %v, got %v", tc.match, match)
+			}
+		})
+	}
+}
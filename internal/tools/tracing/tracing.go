@@ -0,0 +1,129 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// InstrumentationName names the tracer of the spans of the app.
+const InstrumentationName = "github.com/dlbarduzzi/scopehouse"
+
+const (
+	defaultServiceName string  = "scopehouse"
+	defaultSampleRatio float64 = 1
+)
+
+// propagator carries the trace context in the W3C traceparent and
+// tracestate headers of the requests.
+var propagator = propagation.TraceContext{}
+
+type Config struct {
+	// Endpoint is the url of the OTLP HTTP collector the spans are exported
+	// to, e.g. `http://localhost:4318`. Tracing is disabled when empty.
+	Endpoint string
+
+	// ServiceName is the name of the service the spans are exported for.
+	ServiceName string
+
+	// SampleRatio is the ratio of the traces started by the app that are
+	// sampled, between 0 and 1. Traces started by the callers of the app
+	// follow the decision of the caller.
+	SampleRatio float64
+}
+
+// NewProvider creates the provider exporting the spans to the collector of
+// the config, or a provider discarding them when no endpoint is configured.
+// The returned function flushes the pending spans and stops the exporter.
+func NewProvider(ctx context.Context, config Config) (trace.TracerProvider, func(context.Context) error, error) {
+	if config.Endpoint == "" {
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+
+	if config.ServiceName == "" {
+		config.ServiceName = defaultServiceName
+	}
+
+	if config.SampleRatio <= 0 || config.SampleRatio > 1 {
+		config.SampleRatio = defaultSampleRatio
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(config.Endpoint))
+	if err != nil {
+		return nil, nil, fmt.Errorf("trace exporter init failed; %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", config.ServiceName),
+		)),
+	)
+
+	return provider, provider.Shutdown, nil
+}
+
+// Extract returns a copy of ctx carrying the trace context of the headers
+// of an incoming request, if any.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// NewTransport returns a transport tracing the requests sent with next as
+// client spans, and propagating the trace context to the called services.
+// The default transport is used when next is nil.
+func NewTransport(next http.RoundTripper, tracer trace.Tracer) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next, tracer: tracer}
+}
+
+type transport struct {
+	next   http.RoundTripper
+	tracer trace.Tracer
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Credentials are never recorded.
+	u := *req.URL
+	u.User = nil
+
+	ctx, span := t.tracer.Start(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+			attribute.String("url.full", u.String()),
+		),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+
+	if res.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
+
+	return res, nil
+}
@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	var traceparent string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer(InstrumentationName)
+
+	client := &http.Client{Transport: NewTransport(nil, tracer)}
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v2/status", nil)
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request; %v", err)
+	}
+	_ = res.Body.Close()
+
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	span := spans[0]
+
+	if span.Name != http.MethodGet || span.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("expected a client span child of the parent, got %+v", span)
+	}
+
+	if span.Status.Code != codes.Error {
+		t.Fatalf("expected span status to be error, got %v", span.Status.Code)
+	}
+
+	expected := "00-" + span.SpanContext.TraceID().String() + "-" + span.SpanContext.SpanID().String() + "-01"
+	if traceparent != expected {
+		t.Fatalf("expected traceparent header to be %q, got %q", expected, traceparent)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	t.Parallel()
+
+	provider, shutdown, err := NewProvider(context.Background(), Config{})
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	_, span := provider.Tracer(InstrumentationName).Start(context.Background(), "test")
+	if span.SpanContext().IsValid() {
+		t.Fatal("expected spans to be discarded without endpoint")
+	}
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package scopehouse
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+// RotateKeys wraps the data keys of the secrets stored in the database with
+// the master key of the secrets.key setting, so that the master keys of
+// secrets.previous_keys can then be dropped. It returns the number of
+// secrets rotated, and can be run again after a failure.
+func RotateKeys(settings *Settings) (int, error) {
+	keyring, err := newKeyring(settings)
+	if err != nil {
+		return 0, err
+	}
+
+	if keyring == nil {
+		return 0, errors.New("secrets.key setting is required to rotate the keys")
+	}
+
+	db, err := newDatabase(settings.DB)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = db.Close() }()
+
+	rotated, err := core.RotateClusterCredentials(keyring, data.NewModels(db, nil))
+	if err != nil {
+		return rotated, err
+	}
+
+	logger := newLogger(settings.Logger, settings.secrets)
+	logger.Info("keys rotated", slog.String("key_id", keyring.KeyId()), slog.Int("secrets", rotated))
+
+	return rotated, nil
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS activation_tokens;
+DROP TABLE IF EXISTS sessions;
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS teams (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name text NOT NULL UNIQUE,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS clusters (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name text NOT NULL UNIQUE,
+    team_id uuid NOT NULL REFERENCES teams ON DELETE RESTRICT,
+    labels jsonb NOT NULL DEFAULT '{}',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS clusters_team_id_idx ON clusters (team_id);
+
+-- Bindings with a token id only apply to requests made with that token,
+-- narrowing down the bindings of the token owner.
+CREATE TABLE IF NOT EXISTS role_bindings (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users ON DELETE CASCADE,
+    token_id uuid REFERENCES tokens ON DELETE CASCADE,
+    role text NOT NULL,
+    scope_type text NOT NULL CHECK (scope_type IN ('global', 'team', 'cluster')),
+    scope_id uuid,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    CHECK ((scope_type = 'global') = (scope_id IS NULL)),
+    UNIQUE NULLS NOT DISTINCT (user_id, token_id, role, scope_type, scope_id)
+);
+
+CREATE INDEX IF NOT EXISTS role_bindings_scope_idx ON role_bindings (scope_type, scope_id);
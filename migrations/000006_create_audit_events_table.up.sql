@@ -0,0 +1,39 @@
+-- The before/after states are stored as json, not jsonb, so that they are
+-- kept byte for byte and the event hashes can be recomputed.
+CREATE TABLE IF NOT EXISTS audit_events (
+    seq bigint PRIMARY KEY,
+    occurred_at timestamptz NOT NULL,
+    actor_user_id uuid,
+    actor_token_id uuid,
+    actor_email text NOT NULL DEFAULT '',
+    method text NOT NULL,
+    route text NOT NULL,
+    path text NOT NULL,
+    status integer NOT NULL,
+    remote_addr text NOT NULL,
+    user_agent text NOT NULL,
+    resource_type text NOT NULL DEFAULT '',
+    resource_id text NOT NULL DEFAULT '',
+    before json,
+    after json,
+    prev_hash text NOT NULL,
+    hash text NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_occurred_at_idx ON audit_events (occurred_at);
+CREATE INDEX IF NOT EXISTS audit_events_actor_user_id_idx ON audit_events (actor_user_id);
+CREATE INDEX IF NOT EXISTS audit_events_resource_idx ON audit_events (resource_type, resource_id);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update_delete
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
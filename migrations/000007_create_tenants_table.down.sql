@@ -0,0 +1,54 @@
+DROP POLICY IF EXISTS tenant_isolation ON role_bindings;
+ALTER TABLE role_bindings NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE role_bindings DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON activation_tokens;
+ALTER TABLE activation_tokens NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE activation_tokens DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON sessions;
+ALTER TABLE sessions NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE sessions DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON tokens;
+ALTER TABLE tokens NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE tokens DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON audit_events;
+ALTER TABLE audit_events NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE audit_events DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON clusters;
+ALTER TABLE clusters NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE clusters DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON teams;
+ALTER TABLE teams NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE teams DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON users;
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON tenants;
+
+-- The audit events are append-only, the tenant column is kept.
+DROP INDEX IF EXISTS audit_events_tenant_id_idx;
+
+ALTER TABLE clusters DROP CONSTRAINT IF EXISTS clusters_team_id_fkey;
+ALTER TABLE clusters DROP CONSTRAINT IF EXISTS clusters_tenant_id_name_key;
+ALTER TABLE clusters DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE clusters ADD CONSTRAINT clusters_name_key UNIQUE (name);
+ALTER TABLE clusters ADD CONSTRAINT clusters_team_id_fkey
+    FOREIGN KEY (team_id) REFERENCES teams ON DELETE RESTRICT;
+
+ALTER TABLE teams DROP CONSTRAINT IF EXISTS teams_tenant_id_id_key;
+ALTER TABLE teams DROP CONSTRAINT IF EXISTS teams_tenant_id_name_key;
+ALTER TABLE teams DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE teams ADD CONSTRAINT teams_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS users_tenant_id_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+
+DROP FUNCTION IF EXISTS scopehouse_tenant_id();
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,105 @@
+CREATE TABLE IF NOT EXISTS tenants (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name text NOT NULL UNIQUE,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Self-registered and single sign-on users join the default tenant, which
+-- also owns all the data created before tenants existed.
+INSERT INTO tenants (id, name)
+VALUES ('00000000-0000-0000-0000-000000000001', 'default')
+ON CONFLICT DO NOTHING;
+
+-- The tenant of the connection is set by the service for the duration of an
+-- authenticated request. Connections without a tenant see every row.
+CREATE OR REPLACE FUNCTION scopehouse_tenant_id() RETURNS uuid AS $$
+    SELECT NULLIF(current_setting('scopehouse.tenant_id', true), '')::uuid
+$$ LANGUAGE sql STABLE;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS tenant_id uuid NOT NULL
+    DEFAULT COALESCE(scopehouse_tenant_id(), '00000000-0000-0000-0000-000000000001')
+    REFERENCES tenants ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS users_tenant_id_idx ON users (tenant_id);
+
+-- Existing teams and clusters are moved to the default tenant, new ones are
+-- created in the tenant of the connection.
+ALTER TABLE teams
+    ADD COLUMN IF NOT EXISTS tenant_id uuid NOT NULL
+    DEFAULT '00000000-0000-0000-0000-000000000001'
+    REFERENCES tenants ON DELETE RESTRICT;
+
+ALTER TABLE teams ALTER COLUMN tenant_id SET DEFAULT scopehouse_tenant_id();
+ALTER TABLE teams DROP CONSTRAINT IF EXISTS teams_name_key;
+ALTER TABLE teams ADD CONSTRAINT teams_tenant_id_name_key UNIQUE (tenant_id, name);
+ALTER TABLE teams ADD CONSTRAINT teams_tenant_id_id_key UNIQUE (tenant_id, id);
+
+ALTER TABLE clusters
+    ADD COLUMN IF NOT EXISTS tenant_id uuid NOT NULL
+    DEFAULT '00000000-0000-0000-0000-000000000001'
+    REFERENCES tenants ON DELETE RESTRICT;
+
+ALTER TABLE clusters ALTER COLUMN tenant_id SET DEFAULT scopehouse_tenant_id();
+ALTER TABLE clusters DROP CONSTRAINT IF EXISTS clusters_name_key;
+ALTER TABLE clusters ADD CONSTRAINT clusters_tenant_id_name_key UNIQUE (tenant_id, name);
+
+-- Clusters can only be owned by a team of their own tenant.
+ALTER TABLE clusters DROP CONSTRAINT IF EXISTS clusters_team_id_fkey;
+ALTER TABLE clusters ADD CONSTRAINT clusters_team_id_fkey
+    FOREIGN KEY (tenant_id, team_id) REFERENCES teams (tenant_id, id) ON DELETE RESTRICT;
+
+-- Existing audit events are not bound to a tenant, and neither are the
+-- events of anonymous requests.
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS tenant_id uuid;
+
+CREATE INDEX IF NOT EXISTS audit_events_tenant_id_idx ON audit_events (tenant_id);
+
+-- Row level security is forced so that it also applies to the owner of the
+-- tables, it never applies to superusers.
+ALTER TABLE tenants ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tenants FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON tenants
+    USING (scopehouse_tenant_id() IS NULL OR id = scopehouse_tenant_id());
+
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON users
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+ALTER TABLE teams ENABLE ROW LEVEL SECURITY;
+ALTER TABLE teams FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON teams
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+ALTER TABLE clusters ENABLE ROW LEVEL SECURITY;
+ALTER TABLE clusters FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON clusters
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+ALTER TABLE audit_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE audit_events FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON audit_events
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+-- The rows owned by users belong to the tenant of their user, the row level
+-- security of the users table applies to the subquery.
+ALTER TABLE tokens ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tokens FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON tokens
+    USING (scopehouse_tenant_id() IS NULL OR user_id IN (SELECT id FROM users));
+
+ALTER TABLE sessions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE sessions FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON sessions
+    USING (scopehouse_tenant_id() IS NULL OR user_id IN (SELECT id FROM users));
+
+ALTER TABLE activation_tokens ENABLE ROW LEVEL SECURITY;
+ALTER TABLE activation_tokens FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON activation_tokens
+    USING (scopehouse_tenant_id() IS NULL OR user_id IN (SELECT id FROM users));
+
+ALTER TABLE role_bindings ENABLE ROW LEVEL SECURITY;
+ALTER TABLE role_bindings FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON role_bindings
+    USING (scopehouse_tenant_id() IS NULL OR user_id IN (SELECT id FROM users));
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS change_request_comments;
+DROP TABLE IF EXISTS change_requests;
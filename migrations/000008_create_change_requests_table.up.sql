@@ -0,0 +1,43 @@
+-- Changes to production clusters are requested, and only applied once
+-- approved by another user. Pending requests past their expiry are expired,
+-- whether or not their status was updated yet.
+CREATE TABLE IF NOT EXISTS change_requests (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL DEFAULT scopehouse_tenant_id() REFERENCES tenants ON DELETE RESTRICT,
+    cluster_id uuid REFERENCES clusters ON DELETE SET NULL,
+    resource_type text NOT NULL,
+    resource_id text NOT NULL,
+    action text NOT NULL CHECK (action IN ('update', 'delete')),
+    before jsonb,
+    after jsonb,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    requested_by uuid NOT NULL REFERENCES users ON DELETE CASCADE,
+    reviewed_by uuid REFERENCES users ON DELETE SET NULL,
+    reviewed_at timestamptz,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS change_requests_cluster_id_idx ON change_requests (cluster_id);
+CREATE INDEX IF NOT EXISTS change_requests_status_idx ON change_requests (status, expires_at);
+
+CREATE TABLE IF NOT EXISTS change_request_comments (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    change_request_id uuid NOT NULL REFERENCES change_requests ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES users ON DELETE CASCADE,
+    body text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS change_request_comments_change_request_id_idx
+    ON change_request_comments (change_request_id);
+
+ALTER TABLE change_requests ENABLE ROW LEVEL SECURITY;
+ALTER TABLE change_requests FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON change_requests
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+ALTER TABLE change_request_comments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE change_request_comments FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON change_request_comments
+    USING (scopehouse_tenant_id() IS NULL OR change_request_id IN (SELECT id FROM change_requests));
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS alertmanager_configs;
+ALTER TABLE clusters DROP COLUMN IF EXISTS alertmanager_url;
@@ -0,0 +1,20 @@
+-- The Alertmanager of a cluster is reached at its url to sync its routing
+-- configuration.
+ALTER TABLE clusters ADD COLUMN IF NOT EXISTS alertmanager_url text NOT NULL DEFAULT '';
+
+-- The routing configuration of the Alertmanager of each cluster. Synced is
+-- the time of the last successful sync of the configuration, it is cleared
+-- when the configuration changes.
+CREATE TABLE IF NOT EXISTS alertmanager_configs (
+    cluster_id uuid PRIMARY KEY REFERENCES clusters ON DELETE CASCADE,
+    tenant_id uuid NOT NULL DEFAULT scopehouse_tenant_id() REFERENCES tenants ON DELETE RESTRICT,
+    config jsonb NOT NULL,
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    synced_at timestamptz,
+    sync_error text NOT NULL DEFAULT ''
+);
+
+ALTER TABLE alertmanager_configs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE alertmanager_configs FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON alertmanager_configs
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS silence_targets;
+DROP TABLE IF EXISTS silences;
@@ -0,0 +1,41 @@
+-- Silences are created on the Alertmanager of every cluster matching their
+-- selector at the time they are created. The clusters they target are
+-- tracked along with the id of the silence in their Alertmanager and the
+-- outcome of the last call made to it.
+CREATE TABLE IF NOT EXISTS silences (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL DEFAULT scopehouse_tenant_id() REFERENCES tenants ON DELETE RESTRICT,
+    matchers jsonb NOT NULL,
+    cluster_selector jsonb NOT NULL DEFAULT '{}',
+    starts_at timestamptz NOT NULL,
+    ends_at timestamptz NOT NULL,
+    comment text NOT NULL,
+    created_by uuid REFERENCES users ON DELETE SET NULL,
+    expired_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS silences_ends_at_idx ON silences (ends_at);
+
+CREATE TABLE IF NOT EXISTS silence_targets (
+    silence_id uuid NOT NULL REFERENCES silences ON DELETE CASCADE,
+    cluster_id uuid NOT NULL REFERENCES clusters ON DELETE CASCADE,
+    alertmanager_silence_id text NOT NULL DEFAULT '',
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'active', 'failed', 'expired')),
+    error text NOT NULL DEFAULT '',
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (silence_id, cluster_id)
+);
+
+CREATE INDEX IF NOT EXISTS silence_targets_cluster_id_idx ON silence_targets (cluster_id);
+
+ALTER TABLE silences ENABLE ROW LEVEL SECURITY;
+ALTER TABLE silences FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON silences
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+ALTER TABLE silence_targets ENABLE ROW LEVEL SECURITY;
+ALTER TABLE silence_targets FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON silence_targets
+    USING (scopehouse_tenant_id() IS NULL OR silence_id IN (SELECT id FROM silences));
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS leases;
+DROP INDEX IF EXISTS silences_maintenance_window_id_starts_at_key;
+ALTER TABLE silences DROP COLUMN IF EXISTS maintenance_window_id;
+DROP TABLE IF EXISTS maintenance_windows;
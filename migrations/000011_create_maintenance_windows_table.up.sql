@@ -0,0 +1,45 @@
+-- Maintenance windows recur on a cron or RRULE schedule in their time zone.
+-- Next run is the start of their next occurrence, the scheduler silences the
+-- clusters matching their selector shortly before, and moves it forward.
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL DEFAULT scopehouse_tenant_id() REFERENCES tenants ON DELETE RESTRICT,
+    name text NOT NULL,
+    schedule_kind text NOT NULL CHECK (schedule_kind IN ('cron', 'rrule')),
+    schedule text NOT NULL,
+    timezone text NOT NULL DEFAULT 'UTC',
+    duration text NOT NULL,
+    matchers jsonb NOT NULL,
+    cluster_selector jsonb NOT NULL DEFAULT '{}',
+    comment text NOT NULL,
+    enabled boolean NOT NULL DEFAULT true,
+    next_run_at timestamptz,
+    created_by uuid REFERENCES users ON DELETE SET NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (tenant_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS maintenance_windows_next_run_at_idx
+    ON maintenance_windows (next_run_at) WHERE enabled;
+
+ALTER TABLE maintenance_windows ENABLE ROW LEVEL SECURITY;
+ALTER TABLE maintenance_windows FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON maintenance_windows
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+-- A window silences each of its occurrences once, whichever replica fires
+-- it.
+ALTER TABLE silences
+    ADD COLUMN IF NOT EXISTS maintenance_window_id uuid REFERENCES maintenance_windows ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS silences_maintenance_window_id_starts_at_key
+    ON silences (maintenance_window_id, starts_at);
+
+-- Leases elect the replica running a background job, e.g. the scheduler.
+-- A lease is held until it expires, unless its holder renews it.
+CREATE TABLE IF NOT EXISTS leases (
+    name text PRIMARY KEY,
+    holder text NOT NULL,
+    expires_at timestamptz NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE clusters DROP COLUMN IF EXISTS prometheus_url;
@@ -0,0 +1 @@
+ALTER TABLE clusters ADD COLUMN IF NOT EXISTS prometheus_url text NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS alert_states;
+DROP TABLE IF EXISTS alert_transitions;
@@ -0,0 +1,45 @@
+-- The state transitions of the alerts observed on the clusters. Alerts are
+-- identified in a cluster by their fingerprint, and grouped into rules by
+-- their name.
+CREATE TABLE IF NOT EXISTS alert_transitions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id uuid NOT NULL REFERENCES tenants ON DELETE RESTRICT,
+    cluster_id uuid NOT NULL REFERENCES clusters ON DELETE CASCADE,
+    fingerprint text NOT NULL,
+    rule text NOT NULL,
+    severity text NOT NULL DEFAULT '',
+    labels jsonb NOT NULL DEFAULT '{}',
+    state text NOT NULL CHECK (state IN ('pending', 'firing', 'resolved')),
+    previous_state text NOT NULL DEFAULT '' CHECK (previous_state IN ('', 'pending', 'firing')),
+    observed_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS alert_transitions_rule_observed_at_idx
+    ON alert_transitions (tenant_id, rule, observed_at);
+
+CREATE INDEX IF NOT EXISTS alert_transitions_cluster_id_fingerprint_idx
+    ON alert_transitions (cluster_id, fingerprint, observed_at);
+
+-- The last observed state of the alerts that are not resolved, to tell the
+-- transitions of the next observation.
+CREATE TABLE IF NOT EXISTS alert_states (
+    tenant_id uuid NOT NULL REFERENCES tenants ON DELETE RESTRICT,
+    cluster_id uuid NOT NULL REFERENCES clusters ON DELETE CASCADE,
+    fingerprint text NOT NULL,
+    rule text NOT NULL,
+    severity text NOT NULL DEFAULT '',
+    labels jsonb NOT NULL DEFAULT '{}',
+    state text NOT NULL CHECK (state IN ('pending', 'firing')),
+    since timestamptz NOT NULL,
+    PRIMARY KEY (cluster_id, fingerprint)
+);
+
+ALTER TABLE alert_transitions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE alert_transitions FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON alert_transitions
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+ALTER TABLE alert_states ENABLE ROW LEVEL SECURITY;
+ALTER TABLE alert_states FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON alert_states
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
@@ -0,0 +1,15 @@
+-- The credentials the services of a cluster are called with, e.g. a bearer
+-- token. They are encrypted by the service before they are stored, the
+-- database only holds their ciphertext.
+CREATE TABLE IF NOT EXISTS cluster_credentials (
+    cluster_id uuid PRIMARY KEY REFERENCES clusters ON DELETE CASCADE,
+    tenant_id uuid NOT NULL DEFAULT scopehouse_tenant_id() REFERENCES tenants ON DELETE RESTRICT,
+    ciphertext bytea NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+ALTER TABLE cluster_credentials ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cluster_credentials FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON cluster_credentials
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
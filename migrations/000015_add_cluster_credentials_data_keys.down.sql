@@ -0,0 +1,2 @@
+ALTER TABLE cluster_credentials DROP COLUMN IF EXISTS wrapped_key;
+ALTER TABLE cluster_credentials DROP COLUMN IF EXISTS key_id;
@@ -0,0 +1,5 @@
+-- The credentials are encrypted with a data key of their own, stored
+-- wrapped with the master key of key_id. The credentials stored before
+-- have no key id, until the master key is rotated.
+ALTER TABLE cluster_credentials ADD COLUMN IF NOT EXISTS key_id text NOT NULL DEFAULT '';
+ALTER TABLE cluster_credentials ADD COLUMN IF NOT EXISTS wrapped_key bytea;
@@ -0,0 +1,3 @@
+ALTER TABLE silence_targets DROP CONSTRAINT IF EXISTS silence_targets_status_check;
+ALTER TABLE silence_targets ADD CONSTRAINT silence_targets_status_check
+    CHECK (status IN ('pending', 'active', 'failed', 'expired'));
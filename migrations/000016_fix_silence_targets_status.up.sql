@@ -0,0 +1,5 @@
+-- The targets the silence was created on are `created`, not `active`, the
+-- status of the silences themselves.
+ALTER TABLE silence_targets DROP CONSTRAINT IF EXISTS silence_targets_status_check;
+ALTER TABLE silence_targets ADD CONSTRAINT silence_targets_status_check
+    CHECK (status IN ('pending', 'created', 'failed', 'expired'));
@@ -0,0 +1,77 @@
+DROP POLICY IF EXISTS tenant_isolation ON tenants;
+CREATE POLICY tenant_isolation ON tenants
+    USING (scopehouse_tenant_id() IS NULL OR id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON users;
+CREATE POLICY tenant_isolation ON users
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON teams;
+CREATE POLICY tenant_isolation ON teams
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON clusters;
+CREATE POLICY tenant_isolation ON clusters
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON audit_events;
+CREATE POLICY tenant_isolation ON audit_events
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON tokens;
+CREATE POLICY tenant_isolation ON tokens
+    USING (scopehouse_tenant_id() IS NULL OR user_id IN (SELECT id FROM users));
+
+DROP POLICY IF EXISTS tenant_isolation ON sessions;
+CREATE POLICY tenant_isolation ON sessions
+    USING (scopehouse_tenant_id() IS NULL OR user_id IN (SELECT id FROM users));
+
+DROP POLICY IF EXISTS tenant_isolation ON activation_tokens;
+CREATE POLICY tenant_isolation ON activation_tokens
+    USING (scopehouse_tenant_id() IS NULL OR user_id IN (SELECT id FROM users));
+
+DROP POLICY IF EXISTS tenant_isolation ON role_bindings;
+CREATE POLICY tenant_isolation ON role_bindings
+    USING (scopehouse_tenant_id() IS NULL OR user_id IN (SELECT id FROM users));
+
+DROP POLICY IF EXISTS tenant_isolation ON change_requests;
+CREATE POLICY tenant_isolation ON change_requests
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON change_request_comments;
+CREATE POLICY tenant_isolation ON change_request_comments
+    USING (scopehouse_tenant_id() IS NULL OR change_request_id IN (SELECT id FROM change_requests));
+
+DROP POLICY IF EXISTS tenant_isolation ON alertmanager_configs;
+CREATE POLICY tenant_isolation ON alertmanager_configs
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON silences;
+CREATE POLICY tenant_isolation ON silences
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON silence_targets;
+CREATE POLICY tenant_isolation ON silence_targets
+    USING (scopehouse_tenant_id() IS NULL OR silence_id IN (SELECT id FROM silences));
+
+DROP POLICY IF EXISTS tenant_isolation ON maintenance_windows;
+CREATE POLICY tenant_isolation ON maintenance_windows
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON alert_transitions;
+CREATE POLICY tenant_isolation ON alert_transitions
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON alert_states;
+CREATE POLICY tenant_isolation ON alert_states
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON cluster_credentials;
+CREATE POLICY tenant_isolation ON cluster_credentials
+    USING (scopehouse_tenant_id() IS NULL OR tenant_id = scopehouse_tenant_id());
+
+DROP SEQUENCE IF EXISTS audit_events_seq;
+DROP INDEX IF EXISTS audit_events_chain_seq_idx;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS chain_seq;
+
+DROP FUNCTION IF EXISTS scopehouse_system();
@@ -0,0 +1,98 @@
+-- The connections without a tenant used to see every row, so a connection
+-- that was not scoped by mistake leaked the data of all the tenants. The
+-- policies now fail closed: the connections see no rows unless they are
+-- scoped to a tenant, or explicitly run as the system role, the one of the
+-- background workers and of the requests not bound to a tenant yet.
+CREATE OR REPLACE FUNCTION scopehouse_system() RETURNS boolean AS $$
+    SELECT COALESCE(current_setting('scopehouse.role', true), '') = 'system'
+$$ LANGUAGE sql STABLE;
+
+-- The migration itself needs to see every audit event.
+SELECT set_config('scopehouse.role', 'system', true);
+
+DROP POLICY IF EXISTS tenant_isolation ON tenants;
+CREATE POLICY tenant_isolation ON tenants
+    USING (scopehouse_system() OR id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON users;
+CREATE POLICY tenant_isolation ON users
+    USING (scopehouse_system() OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON teams;
+CREATE POLICY tenant_isolation ON teams
+    USING (scopehouse_system() OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON clusters;
+CREATE POLICY tenant_isolation ON clusters
+    USING (scopehouse_system() OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON audit_events;
+CREATE POLICY tenant_isolation ON audit_events
+    USING (scopehouse_system() OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON tokens;
+CREATE POLICY tenant_isolation ON tokens
+    USING (scopehouse_system() OR user_id IN (SELECT id FROM users));
+
+DROP POLICY IF EXISTS tenant_isolation ON sessions;
+CREATE POLICY tenant_isolation ON sessions
+    USING (scopehouse_system() OR user_id IN (SELECT id FROM users));
+
+DROP POLICY IF EXISTS tenant_isolation ON activation_tokens;
+CREATE POLICY tenant_isolation ON activation_tokens
+    USING (scopehouse_system() OR user_id IN (SELECT id FROM users));
+
+DROP POLICY IF EXISTS tenant_isolation ON role_bindings;
+CREATE POLICY tenant_isolation ON role_bindings
+    USING (scopehouse_system() OR user_id IN (SELECT id FROM users));
+
+DROP POLICY IF EXISTS tenant_isolation ON change_requests;
+CREATE POLICY tenant_isolation ON change_requests
+    USING (scopehouse_system() OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON change_request_comments;
+CREATE POLICY tenant_isolation ON change_request_comments
+    USING (scopehouse_system() OR change_request_id IN (SELECT id FROM change_requests));
+
+DROP POLICY IF EXISTS tenant_isolation ON alertmanager_configs;
+CREATE POLICY tenant_isolation ON alertmanager_configs
+    USING (scopehouse_system() OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON silences;
+CREATE POLICY tenant_isolation ON silences
+    USING (scopehouse_system() OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON silence_targets;
+CREATE POLICY tenant_isolation ON silence_targets
+    USING (scopehouse_system() OR silence_id IN (SELECT id FROM silences));
+
+DROP POLICY IF EXISTS tenant_isolation ON maintenance_windows;
+CREATE POLICY tenant_isolation ON maintenance_windows
+    USING (scopehouse_system() OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON alert_transitions;
+CREATE POLICY tenant_isolation ON alert_transitions
+    USING (scopehouse_system() OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON alert_states;
+CREATE POLICY tenant_isolation ON alert_states
+    USING (scopehouse_system() OR tenant_id = scopehouse_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON cluster_credentials;
+CREATE POLICY tenant_isolation ON cluster_credentials
+    USING (scopehouse_system() OR tenant_id = scopehouse_tenant_id());
+
+-- The audit events are chained per tenant, so that verifying the chain of a
+-- tenant does not need to read the events of the others. The events chained
+-- before have no chain sequence number, they remain a single chain spanning
+-- all the tenants. The sequence numbers of the events are drawn from a
+-- sequence since the connections of a tenant cannot see the last event of
+-- the others.
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS chain_seq bigint;
+
+CREATE UNIQUE INDEX IF NOT EXISTS audit_events_chain_seq_idx
+    ON audit_events (COALESCE(tenant_id, '00000000-0000-0000-0000-000000000000'), chain_seq)
+    WHERE chain_seq IS NOT NULL;
+
+CREATE SEQUENCE IF NOT EXISTS audit_events_seq OWNED BY audit_events.seq;
+SELECT setval('audit_events_seq', COALESCE((SELECT max(seq) FROM audit_events), 0) + 1, false);
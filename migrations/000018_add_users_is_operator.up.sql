@@ -0,0 +1,19 @@
+-- The operators of the service manage the tenants. They used to be the global
+-- admins of the default tenant, which self-registered and single sign-on
+-- users join, so operators are now explicitly flagged. The existing global
+-- admins of the default tenant keep operating the service.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_operator boolean NOT NULL DEFAULT false;
+
+SELECT set_config('scopehouse.role', 'system', true);
+
+UPDATE users
+SET is_operator = true
+WHERE tenant_id = '00000000-0000-0000-0000-000000000001'
+    AND (
+        'admin' = ANY (roles)
+        OR id IN (
+            SELECT user_id
+            FROM role_bindings
+            WHERE token_id IS NULL AND role = 'admin' AND scope_type = 'global'
+        )
+    );
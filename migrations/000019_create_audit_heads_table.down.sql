@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS audit_heads;
+DROP FUNCTION IF EXISTS audit_heads_forward_only();
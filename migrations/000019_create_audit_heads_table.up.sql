@@ -0,0 +1,50 @@
+-- The head of an audit chain is its last event, recorded along with the
+-- event. Deleting the last events of a chain leaves the rest of it valid,
+-- the head tells they are missing. The chain is the one of a tenant,
+-- 'tenant:<id>', the events of anonymous requests being in 'tenant:', or
+-- the chain recorded before the chains were split per tenant, ''.
+CREATE TABLE IF NOT EXISTS audit_heads (
+    chain text PRIMARY KEY,
+    tenant_id uuid,
+    position bigint NOT NULL,
+    hash text NOT NULL
+);
+
+-- Heads only move forward, they are never deleted.
+CREATE OR REPLACE FUNCTION audit_heads_forward_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'UPDATE' OR NEW.chain <> OLD.chain OR NEW.position <= OLD.position THEN
+        RAISE EXCEPTION 'audit_heads only move forward';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_heads_forward_only
+    BEFORE UPDATE OR DELETE ON audit_heads
+    FOR EACH ROW EXECUTE FUNCTION audit_heads_forward_only();
+
+CREATE TRIGGER audit_heads_no_truncate
+    BEFORE TRUNCATE ON audit_heads
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_heads_forward_only();
+
+ALTER TABLE audit_heads ENABLE ROW LEVEL SECURITY;
+ALTER TABLE audit_heads FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON audit_heads
+    USING (scopehouse_system() OR tenant_id = scopehouse_tenant_id());
+
+-- The heads of the existing chains are their last events.
+SELECT set_config('scopehouse.role', 'system', true);
+
+INSERT INTO audit_heads (chain, tenant_id, position, hash)
+SELECT '', NULL, seq, hash
+FROM audit_events
+WHERE chain_seq IS NULL
+ORDER BY seq DESC
+LIMIT 1;
+
+INSERT INTO audit_heads (chain, tenant_id, position, hash)
+SELECT DISTINCT ON (tenant_id) 'tenant:' || COALESCE(tenant_id::text, ''), tenant_id, chain_seq, hash
+FROM audit_events
+WHERE chain_seq IS NOT NULL
+ORDER BY tenant_id, chain_seq DESC;
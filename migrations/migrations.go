@@ -0,0 +1,38 @@
+// Package migrations embeds the migrations of the database schema, applied
+// with golang-migrate.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Latest returns the version of the last migration, the version the schema
+// of the database is expected to be at.
+func Latest() (uint, error) {
+	names, err := fs.Glob(files, "*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest uint
+
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(name, "_")
+
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration %q; %v", name, err)
+		}
+
+		latest = max(latest, uint(version))
+	}
+
+	return latest, nil
+}
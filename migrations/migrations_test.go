@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+func TestLatest(t *testing.T) {
+	t.Parallel()
+
+	latest, err := Latest()
+	if err != nil {
+		t.Fatalf("expected error to be nil, got %v", err)
+	}
+
+	for _, direction := range []string{"up", "down"} {
+		matches, _ := fs.Glob(files, fmt.Sprintf("%06d_*.%s.sql", latest, direction))
+		if len(matches) != 1 {
+			t.Fatalf("expected a single %s migration for version %d, got %v", direction, latest, matches)
+		}
+	}
+
+	matches, _ := fs.Glob(files, fmt.Sprintf("%06d_*.up.sql", latest+1))
+	if len(matches) != 0 {
+		t.Fatalf("expected %d to be the latest version, got %v", latest, matches)
+	}
+}
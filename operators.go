@@ -0,0 +1,41 @@
+package scopehouse
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+)
+
+// SetOperator grants, or revokes, the operator flag of the user with the
+// email. Operators manage the tenants of the service, the first one can
+// only be granted from the command line.
+func SetOperator(settings *Settings, email string, isOperator bool) error {
+	db, err := newDatabase(settings.DB)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	models := data.NewModels(db, nil)
+
+	user, err := models.Users.GetByEmail(email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return fmt.Errorf("user %s not found", email)
+		default:
+			return err
+		}
+	}
+
+	if err := models.Users.SetOperator(user.Id, isOperator); err != nil {
+		return err
+	}
+
+	logger := newLogger(settings.Logger, settings.secrets)
+	logger.Info("operator updated", slog.String("user_id", user.Id), slog.Bool("is_operator", isOperator))
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package scopehouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// liveSettings are the keys of the settings applied while the service
+// runs, the changes of the others require a restart.
+var liveSettings = map[string]bool{
+	"log.level":                   true,
+	"log.format":                  true,
+	"log.use_nano":                true,
+	"log.use_source":              true,
+	"database.max_idle_conns":     true,
+	"database.max_open_conns":     true,
+	"database.conn_max_idle_time": true,
+	"alert.poll_interval":         true,
+	"scheduler.interval":          true,
+}
+
+// settingsReloadDelay groups the events of the config file written at once,
+// e.g. truncated then written.
+const settingsReloadDelay = time.Millisecond * 100
+
+// Reload loads the settings again, and applies the changed ones that can
+// change while the service runs, e.g. the log level. The changes of the
+// other settings are logged as requiring a restart. The current settings
+// are kept when the new ones are invalid.
+func (sh *ScopeHouse) Reload() error {
+	if sh.load == nil {
+		return errors.New("settings not loaded from the process")
+	}
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	s, err := sh.load()
+	if err != nil {
+		return err
+	}
+
+	var live, restart []string
+
+	for _, k := range settingsKeys {
+		if s.values[k.key] == sh.settings.values[k.key] {
+			continue
+		}
+
+		if liveSettings[k.key] {
+			live = append(live, k.key)
+		} else {
+			restart = append(restart, k.key)
+		}
+	}
+
+	// The settings requiring a restart keep their current value, so that
+	// they are reported by each reload until then.
+	current := *sh.settings
+	current.values = maps.Clone(sh.settings.values)
+
+	for _, key := range live {
+		current.values[key] = s.values[key]
+	}
+
+	current.Logger = s.Logger
+	current.DB.MaxIdleConns = s.DB.MaxIdleConns
+	current.DB.MaxOpenConns = s.DB.MaxOpenConns
+	current.DB.ConnMaxIdleTime = s.DB.ConnMaxIdleTime
+	current.AlertPollInterval = s.AlertPollInterval
+	current.SchedulerInterval = s.SchedulerInterval
+
+	if current.Logger != sh.settings.Logger {
+		sh.base.SetLogger(newLogger(current.Logger, current.secrets))
+	}
+
+	sh.db.SetMaxIdleConns(current.DB.MaxIdleConns)
+	sh.db.SetMaxOpenConns(current.DB.MaxOpenConns)
+	sh.db.SetConnMaxIdleTime(current.DB.ConnMaxIdleTime)
+
+	sh.Scheduler().SetInterval(current.SchedulerInterval)
+	sh.AlertPoller().SetInterval(current.AlertPollInterval)
+
+	sh.settings = &current
+
+	if len(live) > 0 {
+		sh.Logger().Info("settings reloaded", slog.Any("settings", live))
+	}
+
+	if len(restart) > 0 {
+		sh.Logger().Warn("settings changed, restart required", slog.Any("settings", restart))
+	}
+
+	return nil
+}
+
+// watchSettings reloads the settings when the process receives SIGHUP or
+// its config file changes. The returned function stops watching.
+func (sh *ScopeHouse) watchSettings() (func(context.Context) error, error) {
+	var (
+		watcher *fsnotify.Watcher
+		events  <-chan fsnotify.Event
+		errs    <-chan error
+	)
+
+	file := sh.Settings().ConfigFile
+
+	if file != "" {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, fmt.Errorf("config file path failed; %v", err)
+		}
+		file = abs
+
+		watcher, err = fsnotify.NewWatcher()
+		if err != nil {
+			return nil, fmt.Errorf("config file watcher init failed; %v", err)
+		}
+
+		// Watch the directory rather than the file, which editors and
+		// Kubernetes config maps replace rather than write.
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			_ = watcher.Close()
+			return nil, fmt.Errorf("config file watch failed; %v", err)
+		}
+
+		events = watcher.Events
+		errs = watcher.Errors
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	reload := func(reason string) {
+		if err := sh.Reload(); err != nil {
+			sh.Logger().Error("settings reload failed",
+				slog.String("reason", reason),
+				slog.Any("error", err),
+			)
+		}
+	}
+
+	go func() {
+		defer close(done)
+
+		var delay <-chan time.Time
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-hup:
+				reload("signal")
+			case e := <-events:
+				// Kubernetes swaps the `..data` link of the config map.
+				if filepath.Clean(e.Name) == file || filepath.Base(e.Name) == "..data" {
+					delay = time.After(settingsReloadDelay)
+				}
+			case err := <-errs:
+				sh.Logger().Error("config file watch failed", slog.Any("error", err))
+			case <-delay:
+				delay = nil
+				reload("config file")
+			}
+		}
+	}()
+
+	return func(context.Context) error {
+		signal.Stop(hup)
+
+		close(stop)
+		<-done
+
+		if watcher != nil {
+			return watcher.Close()
+		}
+
+		return nil
+	}, nil
+}
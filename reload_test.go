@@ -0,0 +1,180 @@
+package scopehouse
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/tests"
+)
+
+const reloadTestSettings = `
+database:
+  url: postgres://localhost:5432/scopehouse
+  max_open_conns: 10
+server:
+  port: 8090
+alert:
+  poll_interval: 30s
+`
+
+// newReloadTestScopeHouse creates a ScopeHouse of a test app, loading its
+// settings from the config file.
+func newReloadTestScopeHouse(t *testing.T, file string) (*ScopeHouse, *tests.TestApp) {
+	t.Helper()
+
+	app, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("failed to initialize test app instance; %v", err)
+	}
+
+	load := func() (*Settings, error) {
+		return LoadSettings([]string{"--config", file})
+	}
+
+	settings, err := load()
+	if err != nil {
+		t.Fatalf("failed to load settings; %v", err)
+	}
+
+	// The connections are opened on first use only.
+	db, err := sql.Open("postgres", settings.DB.Url)
+	if err != nil {
+		t.Fatalf("failed to open database; %v", err)
+	}
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	sh := &ScopeHouse{
+		App:      app,
+		base:     app.BaseApp,
+		db:       db,
+		load:     load,
+		settings: settings,
+	}
+
+	return sh, app
+}
+
+func TestReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scopehouse.yaml")
+	writeSettingsFile(t, file, reloadTestSettings)
+
+	sh, app := newReloadTestScopeHouse(t, file)
+
+	writeSettingsFile(t, file, `
+database:
+  url: postgres://localhost:5432/scopehouse
+  max_open_conns: 20
+server:
+  port: 9090
+alert:
+  poll_interval: 10s
+`)
+
+	if err := sh.Reload(); err != nil {
+		t.Fatalf("failed to reload settings; %v", err)
+	}
+
+	s := sh.Settings()
+
+	if s.AlertPollInterval != time.Second*10 || s.DB.MaxOpenConns != 20 {
+		t.Fatalf("expected live settings to be applied, got %+v", s)
+	}
+
+	if sh.db.Stats().MaxOpenConnections != 20 {
+		t.Fatalf("expected 20 max open connections, got %d", sh.db.Stats().MaxOpenConnections)
+	}
+
+	if s.Server.Port != 8090 {
+		t.Fatalf("expected port to require a restart, got %d", s.Server.Port)
+	}
+
+	if !app.Logs.Contains("settings reloaded", "alert.poll_interval", "database.max_open_conns") {
+		t.Fatalf("expected reloaded settings to be logged")
+	}
+
+	if !app.Logs.Contains("restart required", "server.port") {
+		t.Fatalf("expected settings requiring a restart to be logged")
+	}
+
+	// Invalid settings are not applied.
+	writeSettingsFile(t, file, `
+database:
+  url: postgres://localhost:5432/scopehouse
+alert:
+  poll_interval: 1s
+`)
+
+	if err := sh.Reload(); err == nil {
+		t.Fatalf("expected invalid settings to fail the reload")
+	}
+
+	if sh.Settings().AlertPollInterval != time.Second*10 {
+		t.Fatalf("expected settings to be kept, got %v", sh.Settings().AlertPollInterval)
+	}
+
+	// The logger is replaced when the log settings change.
+	logger := sh.Logger()
+
+	writeSettingsFile(t, file, reloadTestSettings+"log:\n  level: debug\n")
+
+	if err := sh.Reload(); err != nil {
+		t.Fatalf("failed to reload settings; %v", err)
+	}
+
+	if sh.Logger() == logger || sh.Settings().Logger.Level != "debug" {
+		t.Fatalf("expected debug logger, got %+v", sh.Settings().Logger)
+	}
+}
+
+func TestWatchSettings(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scopehouse.yaml")
+	writeSettingsFile(t, file, reloadTestSettings)
+
+	sh, _ := newReloadTestScopeHouse(t, file)
+
+	stop, err := sh.watchSettings()
+	if err != nil {
+		t.Fatalf("failed to watch settings; %v", err)
+	}
+
+	defer func() {
+		if err := stop(context.Background()); err != nil {
+			t.Fatalf("failed to stop watching settings; %v", err)
+		}
+	}()
+
+	waitSettings := func(ok func(*Settings) bool) {
+		t.Helper()
+
+		deadline := time.Now().Add(time.Second * 5)
+
+		for !ok(sh.Settings()) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected settings to be reloaded, got %+v", sh.Settings())
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+	}
+
+	writeSettingsFile(t, file, reloadTestSettings+"scheduler:\n  interval: 1m\n")
+
+	waitSettings(func(s *Settings) bool {
+		return s.SchedulerInterval == time.Minute
+	})
+
+	// The environment is read again on SIGHUP.
+	t.Setenv("SH_ALERT_POLL_INTERVAL", "45s")
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("failed to send SIGHUP; %v", err)
+	}
+
+	waitSettings(func(s *Settings) bool {
+		return s.AlertPollInterval == time.Second*45
+	})
+}
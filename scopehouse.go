@@ -0,0 +1,261 @@
+// Package scopehouse builds the ScopeHouse service, so that it can be
+// extended with routes, middlewares, hooks and Alertmanager sync backends
+// from another binary:
+//
+//	app, err := scopehouse.New()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	app.OnServe().Bind(func(e *scopehouse.ServeEvent) error {
+//		e.Router.Route("GET /api/v1/acme/ping", scopehouse.RoleViewer, ping)
+//		return nil
+//	})
+//
+//	if err := app.Start(); err != nil {
+//		log.Fatal(err)
+//	}
+package scopehouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dlbarduzzi/scopehouse/internal/apis"
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/database"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/logging"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/mailer"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/oidc"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/secrets"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/tracing"
+)
+
+// ScopeHouse is the app of the service, along with the resources it is
+// built from, e.g. its database connection pool.
+type ScopeHouse struct {
+	core.App
+
+	base *core.BaseApp
+	db   *sql.DB
+
+	// load loads the settings again on reload, nil when they were given
+	// in the config.
+	load func() (*Settings, error)
+
+	mu       sync.Mutex
+	settings *Settings
+}
+
+// Config configures a ScopeHouse.
+type Config struct {
+	// Settings are the settings of the service, loaded with LoadSettings
+	// from the arguments of the process when nil.
+	Settings *Settings
+
+	// AlertmanagerSyncer syncs the Alertmanager configurations of the
+	// clusters, instead of the files of the AlertmanagerConfigDir setting.
+	AlertmanagerSyncer AlertmanagerSyncer
+}
+
+// New creates a ScopeHouse with the settings of the flags, the environment
+// and the config file of the process.
+func New() (*ScopeHouse, error) {
+	return NewWithConfig(Config{})
+}
+
+// NewWithConfig creates a ScopeHouse, connecting to its database and
+// single sign-on provider. Its hooks are bound before it is started.
+func NewWithConfig(config Config) (*ScopeHouse, error) {
+	var load func() (*Settings, error)
+
+	settings := config.Settings
+	if settings == nil {
+		args := os.Args[1:]
+
+		load = func() (*Settings, error) {
+			return LoadSettings(args)
+		}
+
+		s, err := load()
+		if err != nil {
+			return nil, err
+		}
+		settings = s
+	}
+
+	logger := newLogger(settings.Logger, settings.secrets)
+
+	keyring, err := newKeyring(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcProvider, err := newOIDCProvider(settings.OIDC)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := newDatabase(settings.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	tracerProvider, shutdownTracing, err := tracing.NewProvider(context.Background(), settings.Tracing)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	// clusterClient calls the services of the clusters, e.g. their
+	// Alertmanager.
+	clusterClient := &http.Client{
+		Timeout:   defaultAlertmanagerTimeout,
+		Transport: tracing.NewTransport(nil, tracerProvider.Tracer(tracing.InstrumentationName)),
+	}
+
+	syncer := config.AlertmanagerSyncer
+	if syncer == nil {
+		syncer = newAlertmanagerSyncer(settings.AlertmanagerConfigDir, clusterClient)
+	}
+
+	app := core.NewBaseApp(core.BaseAppConfig{
+		DB:         db,
+		Logger:     logger,
+		Mailer:     mailer.NewSmtpClient(settings.Smtp),
+		OIDC:       oidcProvider,
+		HttpClient: clusterClient,
+		Secrets:    keyring,
+
+		TracerProvider: tracerProvider,
+
+		InsecureCookies: !settings.SecureCookies,
+
+		AlertmanagerSyncer: syncer,
+		AlertPollInterval:  settings.AlertPollInterval,
+		SchedulerInterval:  settings.SchedulerInterval,
+	})
+
+	// Flush the spans once the other hooks, which may produce some, are
+	// done.
+	app.ShutdownHooks().Register(&core.ShutdownHook{
+		Name:     "tracing",
+		Priority: -1,
+		Timeout:  time.Second * 5,
+		Run:      shutdownTracing,
+	})
+
+	return &ScopeHouse{
+		App:      app,
+		base:     app,
+		db:       db,
+		load:     load,
+		settings: settings,
+	}, nil
+}
+
+// Settings returns the settings of the service, including the ones
+// reloaded since it started.
+func (sh *ScopeHouse) Settings() *Settings {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	return sh.settings
+}
+
+// Start bootstraps the app and serves the API until the process is
+// signaled to stop, then closes the database connection pool.
+func (sh *ScopeHouse) Start() (err error) {
+	// Serving runs the shutdown hooks once it returns. When the start fails
+	// before, e.g. on bootstrap, they run here instead, flushing the spans
+	// among others. The database is closed once they are done.
+	serving := false
+
+	defer func() {
+		if !serving {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+			defer cancel()
+
+			err = errors.Join(err, sh.OnShutdown(ctx))
+		}
+
+		if err := sh.db.Close(); err != nil {
+			sh.Logger().Error("db close failed", slog.Any("error", err))
+		}
+	}()
+
+	if err := sh.Bootstrap(); err != nil {
+		return err
+	}
+
+	if sh.load != nil {
+		stop, err := sh.watchSettings()
+		if err != nil {
+			return err
+		}
+
+		sh.ShutdownHooks().Register(&core.ShutdownHook{
+			Name: "settings_watcher",
+			Run:  stop,
+		})
+	}
+
+	serving = true
+
+	return apis.Serve(sh.App, sh.Settings().Server)
+}
+
+// newLogger creates the logger of the service, redacting the secrets of
+// its settings.
+func newLogger(config logging.Config, secrets []string) *slog.Logger {
+	handler := logging.NewRedactHandler(logging.NewLoggerWithConfig(config).Handler(), secrets)
+	return slog.New(handler).With(slog.String("app", "scopehouse"))
+}
+
+// newDatabase opens the database pool, its connections run as the system
+// role until they are scoped to a tenant.
+func newDatabase(config database.Config) (*sql.DB, error) {
+	config.RuntimeParams = data.SystemParams()
+	return database.New(config)
+}
+
+// newKeyring returns the keyring of the master keys of the settings, or nil
+// when none is configured.
+func newKeyring(settings *Settings) (*secrets.Keyring, error) {
+	if len(settings.SecretsKey) == 0 {
+		return nil, nil
+	}
+	return secrets.NewKeyring(settings.SecretsKey, settings.SecretsPreviousKeys...)
+}
+
+// newOIDCProvider returns the single sign-on provider, or nil when no issuer
+// is configured.
+func newOIDCProvider(config oidc.Config) (*oidc.Provider, error) {
+	if config.IssuerUrl == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	return oidc.NewProvider(ctx, config)
+}
+
+// newAlertmanagerSyncer returns the backend syncing the Alertmanager
+// configurations of the clusters to the directory, or nil when no directory
+// is configured.
+func newAlertmanagerSyncer(dir string, httpClient *http.Client) alertmanager.Syncer {
+	if dir == "" {
+		return nil
+	}
+
+	return alertmanager.NewFileSyncer(dir, httpClient)
+}
@@ -0,0 +1,56 @@
+package scopehouse
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+)
+
+func TestNewWithConfigInvalidDatabase(t *testing.T) {
+	t.Parallel()
+
+	settings := &Settings{}
+	settings.DB.Url = "not a url"
+
+	if _, err := NewWithConfig(Config{Settings: settings}); err == nil || err.Error() != "invalid database url" {
+		t.Fatalf("expected invalid database url error, got %v", err)
+	}
+}
+
+func TestStartBootstrapError(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "scopehouse.yaml")
+	writeSettingsFile(t, file, reloadTestSettings)
+
+	sh, app := newReloadTestScopeHouse(t, file)
+
+	app.OnBeforeBootstrap().Bind(func(e *core.BootstrapEvent) error {
+		return errors.New("license expired")
+	})
+
+	var shutdown bool
+
+	app.ShutdownHooks().Register(&core.ShutdownHook{
+		Name: "tracing",
+		Run: func(context.Context) error {
+			shutdown = true
+			return nil
+		},
+	})
+
+	if err := sh.Start(); err == nil || err.Error() != "license expired" {
+		t.Fatalf("expected bootstrap error, got %v", err)
+	}
+
+	if !shutdown {
+		t.Fatal("expected shutdown hooks to run")
+	}
+
+	if err := sh.db.Ping(); err == nil || err.Error() != "sql: database is closed" {
+		t.Fatalf("expected database to be closed, got %v", err)
+	}
+}
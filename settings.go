@@ -0,0 +1,504 @@
+package scopehouse
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/dlbarduzzi/scopehouse/internal/apis"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/database"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/logging"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/mailer"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/oidc"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/secrets"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/tracing"
+)
+
+// Settings are the settings of the service.
+type Settings struct {
+	DB      database.Config
+	Logger  logging.Config
+	Server  apis.ServerConfig
+	Smtp    mailer.Config
+	OIDC    oidc.Config
+	Tracing tracing.Config
+
+	// AlertmanagerConfigDir is the directory the Alertmanager
+	// configurations of the clusters are synced to.
+	AlertmanagerConfigDir string
+
+	// AlertPollInterval is the interval between two polls of the alerts of
+	// the clusters.
+	AlertPollInterval time.Duration
+
+	// SchedulerInterval is the interval between two runs of the maintenance
+	// window scheduler.
+	SchedulerInterval time.Duration
+
+	// SecureCookies only sends the cookies of the API over https, it is
+	// applied by the app, see core.BaseAppConfig.InsecureCookies.
+	SecureCookies bool
+
+	// SecretsKey is the master key encrypting the secrets stored in the
+	// database, e.g. the credentials of the clusters, which cannot be
+	// stored when it is empty.
+	SecretsKey []byte
+
+	// SecretsPreviousKeys are the master keys replaced by SecretsKey, still
+	// decrypting the secrets until they are rotated.
+	SecretsPreviousKeys [][]byte
+
+	// ConfigFile is the config file the settings were read from, empty
+	// when there is none.
+	ConfigFile string
+
+	// values are the values of the settings by key, to find the settings
+	// changed by a reload.
+	values map[string]string
+
+	// secrets are the values of the secret settings and of the secret
+	// references, redacted from the logs.
+	secrets []string
+}
+
+// ErrHelp is returned by LoadSettings when the help of the flags is
+// requested, with `--help`.
+var ErrHelp = pflag.ErrHelp
+
+// SettingsError lists all the invalid settings, so that they can be fixed
+// at once.
+type SettingsError struct {
+	Invalid []string
+}
+
+func (e *SettingsError) Error() string {
+	return fmt.Sprintf("invalid settings; %s", strings.Join(e.Invalid, "; "))
+}
+
+// defaultAlertmanagerTimeout bounds the calls to the services of the
+// clusters, e.g. their Alertmanager.
+var defaultAlertmanagerTimeout = time.Second * 10
+
+// settingsEnvPrefix prefixes the environment variables of the settings.
+const settingsEnvPrefix = "SH"
+
+// settingsKeys are the keys of the settings in the config file, e.g.
+// `server.port`, along with their default. Each setting is also read from
+// its environment variable, e.g. `SH_SERVER_PORT`, and its flag, e.g.
+// `--server-port`. The string settings can reference their value rather
+// than contain it, e.g. `file:///run/secrets/db` or `env:DATABASE_URL`.
+var settingsKeys = []struct {
+	key   string
+	value any
+	usage string
+}{
+	{"database.url", "", "url of the Postgres database"},
+	{"database.max_idle_conns", 10, "maximum number of idle connections"},
+	{"database.max_open_conns", 10, "maximum number of open connections"},
+	{"database.conn_max_idle_time", time.Minute * 3, "maximum idle time of a connection, at least 1m"},
+
+	{"log.level", "info", "log level, debug, info, warn or error"},
+	{"log.format", "json", "log format, json or text"},
+	{"log.use_nano", false, "log times with nanoseconds"},
+	{"log.use_source", false, "log the source of the records"},
+
+	{"server.port", 8090, "port of the API"},
+	{"server.idle_timeout", time.Second * 10, "idle timeout of the connections, at least 10s"},
+	{"server.read_timeout", time.Second * 5, "read timeout of the requests, at least 1s"},
+	{"server.write_timeout", time.Second * 5, "write timeout of the responses, at least 1s"},
+	{"server.secure_cookies", true, "only send the cookies over https, disable to sign in over http in development"},
+
+	{"smtp.host", "", "host of the SMTP server"},
+	{"smtp.port", 587, "port of the SMTP server"},
+	{"smtp.username", "", "username of the SMTP server"},
+	{"smtp.password", "", "password of the SMTP server"},
+	{"smtp.sender", "ScopeHouse <no-reply@scopehouse.local>", "sender of the emails"},
+
+	{"oidc.issuer_url", "", "issuer url of the single sign-on provider"},
+	{"oidc.client_id", "", "client id of the single sign-on provider"},
+	{"oidc.client_secret", "", "client secret of the single sign-on provider"},
+	{"oidc.redirect_url", "", "callback url of the single sign-on"},
+	{"oidc.groups_claim", "", "claim of the groups of the users"},
+	{"oidc.group_roles", "", "roles of the groups, e.g. sre=admin,developers=editor"},
+
+	{"tracing.endpoint", "", "endpoint of the OTLP HTTP collector"},
+	{"tracing.service_name", "scopehouse", "service name of the spans"},
+	{"tracing.sample_ratio", 1.0, "ratio of the traces sampled, between 0 and 1"},
+
+	{"alertmanager.config_dir", "", "directory the Alertmanager configurations are synced to"},
+
+	{"alert.poll_interval", time.Second * 30, "interval between two polls of the alerts, at least 5s"},
+
+	{"scheduler.interval", time.Second * 30, "interval between two runs of the maintenance window scheduler, at least 5s"},
+
+	{"secrets.key", "", "base64 master key of 32 bytes encrypting the stored secrets, e.g. file:///run/secrets/key"},
+	{"secrets.previous_keys", "", "comma separated base64 master keys replaced by secrets.key, until rotated"},
+}
+
+// LoadSettings reads the settings from the flags of args, the environment,
+// and the config file of the `--config` flag or the `SH_CONFIG` variable,
+// in that order of precedence. It fails with a SettingsError listing all
+// the invalid settings, including the unknown ones, e.g. mistyped.
+func LoadSettings(args []string) (*Settings, error) {
+	flags := pflag.NewFlagSet("scopehouse", pflag.ContinueOnError)
+	flags.String("config", "", "config file, yaml or toml")
+
+	for _, s := range settingsKeys {
+		switch s.value {
+		case false:
+			flags.Bool(settingsFlag(s.key), false, s.usage)
+		case "":
+			flags.String(settingsFlag(s.key), "", s.usage)
+		default:
+			flags.String(settingsFlag(s.key), "", fmt.Sprintf("%s (default %v)", s.usage, s.value))
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	v.SetEnvPrefix(settingsEnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	for _, s := range settingsKeys {
+		v.SetDefault(s.key, s.value)
+
+		if err := v.BindPFlag(s.key, flags.Lookup(settingsFlag(s.key))); err != nil {
+			return nil, err
+		}
+	}
+
+	file, _ := flags.GetString("config")
+	if file == "" {
+		file = os.Getenv(settingsEnvPrefix + "_CONFIG")
+	}
+
+	if file != "" {
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml", ".toml":
+		default:
+			return nil, fmt.Errorf("config file %s must be yaml or toml", file)
+		}
+
+		v.SetConfigFile(file)
+
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("config file read failed; %v", err)
+		}
+	}
+
+	s, err := readSettings(v)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ConfigFile = file
+
+	return s, nil
+}
+
+// readSettings reads the settings of v, checking each of them.
+func readSettings(v *viper.Viper) (*Settings, error) {
+	r := &settingsReader{v: v, resolved: map[string]string{}, envs: map[string]bool{}}
+
+	s := &Settings{
+		DB: database.Config{
+			Url:             r.string("database.url"),
+			MaxIdleConns:    r.int("database.max_idle_conns", 1, math.MaxInt),
+			MaxOpenConns:    r.int("database.max_open_conns", 1, math.MaxInt),
+			ConnMaxIdleTime: r.duration("database.conn_max_idle_time", time.Minute),
+		},
+		Logger: logging.Config{
+			Level:     r.oneOf("log.level", "debug", "info", "warn", "error"),
+			Format:    r.oneOf("log.format", "json", "text"),
+			UseNano:   r.bool("log.use_nano"),
+			UseSource: r.bool("log.use_source"),
+		},
+		Server: apis.ServerConfig{
+			Port:         r.int("server.port", 1, math.MaxUint16),
+			IdleTimeout:  r.duration("server.idle_timeout", time.Second*10),
+			ReadTimeout:  r.duration("server.read_timeout", time.Second),
+			WriteTimeout: r.duration("server.write_timeout", time.Second),
+		},
+		Smtp: mailer.Config{
+			Host:     r.string("smtp.host"),
+			Port:     r.int("smtp.port", 1, math.MaxUint16),
+			Username: r.string("smtp.username"),
+			Password: r.string("smtp.password"),
+			Sender:   r.string("smtp.sender"),
+		},
+		OIDC: oidc.Config{
+			IssuerUrl:    r.string("oidc.issuer_url"),
+			ClientId:     r.string("oidc.client_id"),
+			ClientSecret: r.string("oidc.client_secret"),
+			RedirectUrl:  r.string("oidc.redirect_url"),
+			GroupsClaim:  r.string("oidc.groups_claim"),
+		},
+		Tracing: tracing.Config{
+			Endpoint:    r.string("tracing.endpoint"),
+			ServiceName: r.string("tracing.service_name"),
+			SampleRatio: r.ratio("tracing.sample_ratio"),
+		},
+		AlertmanagerConfigDir: r.string("alertmanager.config_dir"),
+		AlertPollInterval:     r.duration("alert.poll_interval", time.Second*5),
+		SchedulerInterval:     r.duration("scheduler.interval", time.Second*5),
+		SecureCookies:         r.bool("server.secure_cookies"),
+		values:                map[string]string{},
+	}
+
+	// The values of the references are compared on reload, so that the
+	// changes of the referenced files are found.
+	for _, k := range settingsKeys {
+		if value, ok := r.resolved[k.key]; ok {
+			s.values[k.key] = value
+		} else {
+			s.values[k.key] = fmt.Sprint(v.Get(k.key))
+		}
+	}
+
+	key := r.string("secrets.key")
+	if key != "" {
+		b, err := secrets.ParseKey(key)
+		if err != nil {
+			r.invalid("secrets.key", "is invalid; %v", err)
+		}
+		s.SecretsKey = b
+	}
+
+	previousKeys := r.string("secrets.previous_keys")
+
+	for _, previous := range strings.FieldsFunc(previousKeys, isKeySeparator) {
+		b, err := secrets.ParseKey(previous)
+		if err != nil {
+			r.invalid("secrets.previous_keys", "is invalid; %v", err)
+			continue
+		}
+		s.SecretsPreviousKeys = append(s.SecretsPreviousKeys, b)
+	}
+
+	if key == "" && previousKeys != "" {
+		r.invalid("secrets.key", "is required with secrets.previous_keys")
+	}
+
+	// The secret settings are redacted from the logs, along with the
+	// values of the references.
+	for _, value := range []string{s.DB.Url, s.Smtp.Password, s.OIDC.ClientSecret, key} {
+		if value != "" {
+			r.secrets = append(r.secrets, value)
+		}
+	}
+
+	if u, err := url.Parse(s.DB.Url); err == nil && u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			r.secrets = append(r.secrets, password)
+		}
+	}
+
+	for _, previous := range strings.FieldsFunc(previousKeys, isKeySeparator) {
+		r.secrets = append(r.secrets, previous)
+	}
+
+	s.secrets = r.secrets
+
+	if s.DB.Url == "" {
+		r.invalid("database.url", "is required")
+	}
+
+	groupRoles, err := oidc.ParseGroupRoles(r.string("oidc.group_roles"))
+	if err != nil {
+		r.invalid("oidc.group_roles", "%v", err)
+	}
+
+	for group, role := range groupRoles {
+		if !data.IsValidRole(role) {
+			r.invalid("oidc.group_roles", "has unknown role %q for group %q", role, group)
+		}
+	}
+
+	s.OIDC.GroupRoles = groupRoles
+
+	if s.OIDC.IssuerUrl != "" {
+		if s.OIDC.ClientId == "" {
+			r.invalid("oidc.client_id", "is required with oidc.issuer_url")
+		}
+
+		if s.OIDC.RedirectUrl == "" {
+			r.invalid("oidc.redirect_url", "is required with oidc.issuer_url")
+		}
+	}
+
+	r.unknown()
+
+	if len(r.errors) > 0 {
+		slices.Sort(r.errors)
+		return nil, &SettingsError{Invalid: r.errors}
+	}
+
+	return s, nil
+}
+
+// isKeySeparator reports whether r separates the keys of a list, e.g. the
+// lines of a file referenced by secrets.previous_keys.
+func isKeySeparator(r rune) bool {
+	return r == ',' || unicode.IsSpace(r)
+}
+
+// settingsFlag returns the flag of the setting key, e.g. `server-port`.
+func settingsFlag(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// settingsEnv returns the environment variable of the setting key, e.g.
+// `SH_SERVER_PORT`.
+func settingsEnv(key string) string {
+	return settingsEnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// settingsReader reads the settings, collecting the errors of all the
+// invalid ones rather than failing at the first.
+type settingsReader struct {
+	v      *viper.Viper
+	errors []string
+
+	// resolved are the values of the settings referencing them, by key.
+	resolved map[string]string
+
+	// envs are the environment variables referenced by the settings.
+	envs map[string]bool
+
+	// secrets are the values of the references.
+	secrets []string
+}
+
+func (r *settingsReader) invalid(key string, format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf("%s %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (r *settingsReader) string(key string) string {
+	s, err := cast.ToStringE(r.v.Get(key))
+	if err != nil {
+		r.invalid(key, "must be a string")
+		return ""
+	}
+
+	if !secrets.IsReference(s) {
+		return s
+	}
+
+	if value, ok := r.resolved[key]; ok {
+		return value
+	}
+
+	if env := secrets.ReferencedEnv(s); env != "" {
+		r.envs[env] = true
+	}
+
+	value, err := secrets.Resolve(s)
+	r.resolved[key] = value
+
+	if err != nil {
+		r.invalid(key, "%v", err)
+		return ""
+	}
+
+	if value != "" {
+		r.secrets = append(r.secrets, value)
+	}
+
+	return value
+}
+
+func (r *settingsReader) oneOf(key string, values ...string) string {
+	s := r.string(key)
+	if !slices.Contains(values, s) {
+		r.invalid(key, "must be one of %s, got %q", strings.Join(values, ", "), s)
+	}
+	return s
+}
+
+func (r *settingsReader) bool(key string) bool {
+	b, err := cast.ToBoolE(r.v.Get(key))
+	if err != nil {
+		r.invalid(key, "must be true or false, got %v", r.v.Get(key))
+	}
+	return b
+}
+
+func (r *settingsReader) int(key string, least int, most int) int {
+	n, err := cast.ToIntE(r.v.Get(key))
+	if err != nil {
+		r.invalid(key, "must be a number, got %v", r.v.Get(key))
+		return 0
+	}
+
+	if n < least || n > most {
+		if most == math.MaxInt {
+			r.invalid(key, "must be at least %d, got %d", least, n)
+		} else {
+			r.invalid(key, "must be between %d and %d, got %d", least, most, n)
+		}
+	}
+
+	return n
+}
+
+func (r *settingsReader) duration(key string, least time.Duration) time.Duration {
+	d, err := cast.ToDurationE(r.v.Get(key))
+	if err != nil {
+		r.invalid(key, "must be a duration, e.g. 30s, got %v", r.v.Get(key))
+		return 0
+	}
+
+	if d < least {
+		r.invalid(key, "must be at least %v, got %v", least, d)
+	}
+
+	return d
+}
+
+func (r *settingsReader) ratio(key string) float64 {
+	f, err := cast.ToFloat64E(r.v.Get(key))
+	if err != nil || f <= 0 || f > 1 {
+		r.invalid(key, "must be a number greater than 0 and at most 1, got %v", r.v.Get(key))
+	}
+	return f
+}
+
+// unknown reports the settings of the config file and the environment
+// variables with the prefix of the settings that are not settings.
+func (r *settingsReader) unknown() {
+	keys := map[string]bool{}
+	envs := map[string]bool{settingsEnvPrefix + "_CONFIG": true}
+
+	for _, s := range settingsKeys {
+		keys[s.key] = true
+		envs[settingsEnv(s.key)] = true
+	}
+
+	for _, key := range r.v.AllKeys() {
+		if !keys[key] {
+			r.errors = append(r.errors, fmt.Sprintf("%s is not a setting", key))
+		}
+	}
+
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if strings.HasPrefix(name, settingsEnvPrefix+"_") && !envs[name] && !r.envs[name] {
+			r.errors = append(r.errors, fmt.Sprintf("%s is not a setting", name))
+		}
+	}
+}
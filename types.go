@@ -0,0 +1,40 @@
+package scopehouse
+
+import (
+	"github.com/dlbarduzzi/scopehouse/internal/core"
+	"github.com/dlbarduzzi/scopehouse/internal/data"
+	"github.com/dlbarduzzi/scopehouse/internal/tools/alertmanager"
+)
+
+// The types of the app, its hooks and its extension points, so that they
+// can be named outside of this module.
+type (
+	App            = core.App
+	EventRequest   = core.EventRequest
+	BootstrapEvent = core.BootstrapEvent
+	ServeEvent     = core.ServeEvent
+	ServeRouter    = core.ServeRouter
+	ModelEvent     = core.ModelEvent
+	ShutdownHook   = core.ShutdownHook
+
+	// AlertmanagerSyncer pushes the Alertmanager configuration of a
+	// cluster, its target, to the Alertmanager of the cluster.
+	AlertmanagerSyncer = alertmanager.Syncer
+	AlertmanagerTarget = alertmanager.Target
+	AlertmanagerConfig = alertmanager.Config
+)
+
+// The actions of the model changes.
+const (
+	ModelCreate = core.ModelCreate
+	ModelUpdate = core.ModelUpdate
+	ModelDelete = core.ModelDelete
+)
+
+// The roles granted to the users, required by the routes.
+const (
+	RoleViewer   = data.RoleViewer
+	RoleEditor   = data.RoleEditor
+	RoleApprover = data.RoleApprover
+	RoleAdmin    = data.RoleAdmin
+)